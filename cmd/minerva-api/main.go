@@ -4,11 +4,15 @@ import (
 	"log"
 	"minerva/internal/api/handlers"
 	"minerva/internal/config"
-	"minerva/internal/db"
+	"minerva/internal/store"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
+
+	_ "minerva/internal/db"     // PostgreSQL storage
+	_ "minerva/internal/sqlite" // SQLite storage
 )
 
 func main() {
@@ -26,18 +30,28 @@ func main() {
 		conf.Database.Name = dbName
 	}
 
-	// Connect to the database
-	dbPort := os.Getenv("MINERVA_DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432" // Default PostgreSQL port
+	// Override the PostgreSQL port if the environment variable is set; the
+	// default is 5432.
+	if dbPort := os.Getenv("MINERVA_DB_PORT"); dbPort != "" {
+		port, err := strconv.Atoi(dbPort)
+		if err != nil {
+			log.Fatalf("Invalid MINERVA_DB_PORT %q", dbPort)
+		}
+		conf.Database.Port = port
 	}
-	database, err := db.Connect(conf.Database.Host, dbPort, conf.Database.User, conf.Database.Password, conf.Database.Name)
+
+	// Open the storage backend selected in the config.
+	s, err := store.Open(conf)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CheckSchema(); err != nil {
+		log.Fatalf("%v; run 'minerva migrate up'", err)
 	}
-	defer database.Close()
 
-	log.Println("minerva-api is running and connected to the database.")
+	log.Printf("minerva-api is running with %s storage.", conf.Storage.Driver)
 
 	// Set up HTTP server (placeholder)
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -46,9 +60,13 @@ func main() {
 	})
 
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/logs", handlers.GetLogs(database)).Methods("GET")
-	router.HandleFunc("/api/v1/stats", handlers.GetStats(database)).Methods("GET")
-	router.HandleFunc("/api/v1/geo/{ip}", handlers.GetGeo(database)).Methods("GET")
+	router.HandleFunc("/api/v1/logs", handlers.GetLogs(s)).Methods("GET")
+	router.HandleFunc("/api/v1/stats", handlers.GetStats(s)).Methods("GET")
+	router.HandleFunc("/api/v1/geo/{ip}", handlers.GetGeo(s)).Methods("GET")
+	router.HandleFunc("/api/v1/ips/{ip}", handlers.GetIPProfile(s)).Methods("GET")
+	router.HandleFunc("/api/v1/asns/top", handlers.GetTopASNs(s)).Methods("GET")
+	router.HandleFunc("/api/v1/incidents", handlers.GetIncidents(s)).Methods("GET")
+	router.HandleFunc("/api/v1/sensors", handlers.GetSensors(s)).Methods("GET")
 
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"minerva/internal/blocklist"
+	"minerva/internal/config"
+	"minerva/internal/store"
+	"time"
+)
+
+const blocklistRefreshInterval = time.Hour
+
+// runBlocklistRefresher fetches the feeds older than conf.RefreshHours right
+// away and then every blocklistRefreshInterval, for long-running modes, and
+// loads the lists into matcher whenever one was fetched.
+func runBlocklistRefresher(s store.Store, conf config.BlocklistConfig, matcher *blocklist.Matcher) {
+	if conf.RefreshHours <= 0 || len(conf.Feeds) == 0 {
+		return
+	}
+	ticker := time.NewTicker(blocklistRefreshInterval)
+	defer ticker.Stop()
+	for {
+		refreshed, err := blocklist.Refresh(s, conf.Feeds, conf.RefreshInterval())
+		if err != nil {
+			log.Printf("Blocklist refresh failed: %v", err)
+		}
+		if refreshed > 0 {
+			n, err := matcher.Load(s, conf.Feeds)
+			if err != nil {
+				log.Printf("Failed to load blocklists: %v", err)
+			} else {
+				log.Printf("Refreshed %d blocklist(s), matching against %d network(s)", refreshed, n)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// runBlocklist implements `minerva blocklist refresh`: it fetches every feed
+// and replaces the stored lists.
+func runBlocklist(s store.Store, conf config.BlocklistConfig, args []string) {
+	if len(args) != 1 || args[0] != "refresh" {
+		log.Fatalf("Usage: minerva blocklist refresh")
+	}
+	if len(conf.Feeds) == 0 {
+		fmt.Println("No blocklists are configured; add [[blocklists.feeds]] to fetch them")
+	}
+
+	refreshed, err := blocklist.Refresh(s, conf.Feeds, 0)
+	if err != nil {
+		log.Fatalf("Blocklist refresh failed after %d list(s): %v", refreshed, err)
+	}
+	fmt.Printf("Refreshed %d blocklist(s)\n", refreshed)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"minerva/internal/config"
+	"minerva/internal/follow"
+	"minerva/internal/pipeline"
+	"minerva/internal/syslog"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runDaemon feeds messages from the syslog receiver into the pipeline until
+// SIGINT or SIGTERM, then drains every accepted message before returning.
+// Header timestamps without a UTC offset are taken to be in loc.
+func runDaemon(conf config.SyslogConfig, loc *time.Location, p *pipeline.Pipeline) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := syslog.NewServer(func(msg syslog.Message) {
+		p.Feed(msg.LineWithPriority())
+	}, loc)
+
+	if conf.UDPAddress == "" && conf.TCPAddress == "" {
+		log.Fatalf("Syslog receiver has no UDP or TCP address configured")
+	}
+	if conf.UDPAddress != "" {
+		addr, err := server.ListenUDP(conf.UDPAddress)
+		if err != nil {
+			log.Fatalf("Failed to start syslog receiver: %v", err)
+		}
+		log.Printf("Listening for syslog on udp %s", addr)
+	}
+	if conf.TCPAddress != "" {
+		addr, err := server.ListenTCP(conf.TCPAddress)
+		if err != nil {
+			log.Fatalf("Failed to start syslog receiver: %v", err)
+		}
+		log.Printf("Listening for syslog on tcp %s", addr)
+	}
+
+	<-ctx.Done()
+	log.Println("Shutting down syslog receiver; draining queued work...")
+
+	// Stop accepting messages before closing the pipeline so nothing is fed after Close.
+	server.Shutdown()
+	p.Close()
+}
+
+// runFollow tails path until SIGINT or SIGTERM, saving its position in statePath
+// so the next run resumes after the last line that was fully processed.
+func runFollow(path, statePath string, p *pipeline.Pipeline) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	state, err := follow.LoadState(statePath)
+	if err != nil {
+		log.Fatalf("Failed to load follow state: %v", err)
+	}
+
+	log.Printf("Following %s", path)
+	follower := follow.New(path, state, p.Feed, p.Flush)
+	if err := follower.Run(ctx); err != nil {
+		log.Printf("Error following %s: %v", path, err)
+	}
+	p.Close()
+}
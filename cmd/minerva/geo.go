@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"minerva/internal/config"
+	"minerva/internal/geo"
+	"minerva/internal/store"
+	"strconv"
+	"time"
+)
+
+const geoRefreshInterval = time.Hour
+
+// runGeoRefresher refreshes up to conf.RefreshLimit stale geolocation entries
+// every geoRefreshInterval, for long-running modes. It shares provider with the
+// pipeline's lookups.
+func runGeoRefresher(s store.Store, conf config.GeoConfig, provider geo.Provider) {
+	if conf.TTLDays <= 0 || conf.RefreshLimit <= 0 {
+		return
+	}
+	ticker := time.NewTicker(geoRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		refreshed, err := geo.Refresh(s, provider, conf.TTL(), conf.RefreshLimit)
+		if err != nil {
+			log.Printf("Geolocation refresh failed: %v", err)
+		}
+		if refreshed > 0 {
+			log.Printf("Refreshed geolocation data for %d IP(s)", refreshed)
+		}
+	}
+}
+
+// runGeo implements `minerva geo refresh [limit]`: it looks up again every
+// address, or the first limit, whose geolocation data is older than the TTL.
+func runGeo(s store.Store, conf config.GeoConfig, provider geo.Provider, args []string) {
+	if len(args) == 0 || args[0] != "refresh" || len(args) > 2 {
+		log.Fatalf("Usage: minerva geo refresh [limit]")
+	}
+	limit := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid number of addresses to refresh: %q", args[1])
+		}
+		limit = n
+	}
+	if conf.TTLDays <= 0 {
+		fmt.Println("Geolocation refresh is disabled; set ttl_days in [geo] to refresh old entries")
+		return
+	}
+
+	refreshed, err := geo.Refresh(s, provider, conf.TTL(), limit)
+	if err != nil {
+		log.Fatalf("Geolocation refresh failed after %d IP(s): %v", refreshed, err)
+	}
+	fmt.Printf("Refreshed geolocation data for %d IP(s) older than %d days\n", refreshed, conf.TTLDays)
+}
@@ -4,22 +4,32 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"minerva/internal/blocklist"
 	"minerva/internal/config"
-	"minerva/internal/db"
 	"minerva/internal/geo"
 	"minerva/internal/input"
+	"minerva/internal/netclass"
 	"minerva/internal/parser"
+	"minerva/internal/pipeline"
 	"minerva/internal/progress"
+	"minerva/internal/rules"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"minerva/internal/threatintel"
 	"os"
-	"strconv"
-	"sync"
 	"time"
-)
 
-const maxGeoQueriesPerMinute = 40
+	_ "minerva/internal/db"     // PostgreSQL storage
+	_ "minerva/internal/sqlite" // SQLite storage
+)
 
 func main() {
 	reverseFlag := flag.Bool("r", false, "Process logs in oldest-first order")
+	daemonFlag := flag.Bool("daemon", false, "Receive logs from the built-in syslog receiver instead of stdin")
+	listenFlag := flag.String("listen", "", "Address for the syslog receiver on both UDP and TCP (overrides config)")
+	followFlag := flag.String("follow", "", "Tail the given log file instead of reading stdin")
+	stateFlag := flag.String("state", "minerva_follow_state.json", "File that records the -follow position between runs")
+	sensorFlag := flag.String("sensor", "", "Sensor ID to store the logs under (overrides config)")
 	flag.Parse()
 
 	log.SetOutput(os.Stderr)
@@ -36,152 +46,141 @@ func main() {
 		conf.Database.Name = dbName
 	}
 
-	// Connect to the database using config values.
-	dbPort := strconv.Itoa(conf.Database.Port)
-	database, err := db.Connect(conf.Database.Host, dbPort, conf.Database.User, conf.Database.Password, conf.Database.Name)
+	// Open the storage backend selected in the config.
+	s, err := store.Open(conf)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to open storage: %v", err)
 	}
-	defer database.Close()
+	defer s.Close()
 
-	dbHandler := &db.Handler{DB: database}
+	if flag.Arg(0) == "migrate" {
+		runMigrate(s, flag.Args()[1:])
+		return
+	}
+	if err := s.CheckSchema(); err != nil {
+		log.Fatalf("%v; run 'minerva migrate up'", err)
+	}
 
-	// Read input logs from stdin.
-	lines, err := input.ReadLines(os.Stdin)
+	// Lookups for new IPs and refreshes of old ones share the providers and
+	// so their rate limits.
+	provider, err := geo.NewProvider(conf.Geo)
 	if err != nil {
-		log.Fatalf("Error reading input: %v", err)
+		log.Fatalf("Invalid geo configuration: %v", err)
 	}
-	totalLines := len(lines)
+	defer provider.Close()
 
-	// Optionally reverse lines.
-	if !*reverseFlag {
-		lines = input.ReverseLines(lines)
+	if flag.Arg(0) == "geo" {
+		runGeo(s, conf.Geo, provider, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "retention" {
+		runRetention(s, conf.Retention.Days, flag.Args()[1:])
+		return
 	}
+	if err := blocklist.Validate(conf.Blocklists.Feeds); err != nil {
+		log.Fatalf("Invalid blocklists configuration: %v", err)
+	}
+	if flag.Arg(0) == "blocklist" {
+		runBlocklist(s, conf.Blocklists, flag.Args()[1:])
+		return
+	}
+	if err := maintainPartitions(s, conf.Retention.Days); err != nil {
+		log.Fatalf("Partition maintenance failed: %v", err)
+	}
+	go runMaintenance(s, conf.Retention.Days)
 
-	// Set up statistics and progress tracker.
+	// Set up statistics and progress tracker. The total is unknown while streaming.
 	stats := &progress.Stats{}
-	prog := progress.NewProgress(int64(totalLines), stats)
-
-	// Channels to move data through pipeline.
-	logChan := make(chan string, 10000)
-	geoChan := make(chan string, 10000)
-	doneChan := make(chan struct{})
-
-	// We’ll keep track of IPs we’ve already queued for geo so we don’t re-queue them.
-	var seenIPs sync.Map
-
-	//
-	// 1) Pre-filter logs
-	//    - If line is invalid → stats.IncrementMalformed()
-	//    - Else if flagged → send to logChan
-	//    - Else increment benign
-	//
-	go func() {
-		for _, line := range lines {
-			stats.IncrementLinesRead()
-
-			if !parser.IsValidLine(line) {
-				stats.IncrementMalformed()
-				continue
-			}
-			if parser.IsFlaggedLog(line) {
-				stats.IncrementFlagged()
-				logChan <- line
-			} else {
-				stats.IncrementBenign()
-			}
-		}
-		close(logChan)
-	}()
-
-	//
-	// 2) Worker pool to insert logs in DB and dispatch new IP lookups.
-	//
-	workerCount := 20
-	var wg sync.WaitGroup
-	wg.Add(workerCount)
-
-	for i := 0; i < workerCount; i++ {
-		go func() {
-			defer wg.Done()
-			for line := range logChan {
-				timestamp, srcIP, dstIP, spt, dpt, proto, action, reason, packetLength, ttl := parser.ExtractFields(line)
-
-				if dstIP == "" {
-					// Additional malformed check
-					prog.BufferMessage(fmt.Sprintf("Skipping malformed log line: %s", line))
-					stats.IncrementMalformed()
-					continue
-				}
-
-				// Insert into database
-				if rowInserted, err := db.InsertLogEntry(database, timestamp, srcIP, dstIP, proto, action, reason, spt, dpt, packetLength, ttl); err != nil {
-					stats.IncrementErrors()
-					prog.BufferMessage(fmt.Sprintf("Insert error for DST=%q: %v", dstIP, err))
-				} else if rowInserted > 0 {
-					stats.IncrementInserted()
-				}
-
-				// Check for IP lookups
-				if srcIP != "" {
-					if _, loaded := seenIPs.LoadOrStore(srcIP, struct{}{}); !loaded {
-						exists, err := dbHandler.IsIPInGeoTable(srcIP)
-						if err != nil {
-							stats.IncrementErrors()
-							prog.BufferMessage(fmt.Sprintf("DB error checking IP: %v", err))
-						} else if !exists {
-							stats.IncrementGeoQueued()
-							geoChan <- srcIP
-						} // IP not seen yet, queue it
-					}
-				}
-
-				prog.IncrementProcessed()
-				prog.DisplayIfNeeded(2 * time.Second) // Show updates periodically
-			}
-		}()
+	prog := progress.NewProgress(0, stats)
+
+	// Pick the log format parser for this input.
+	sources := []string{"stdin"}
+	switch {
+	case *daemonFlag:
+		sources = []string{"syslog"}
+	case *followFlag != "":
+		sources = []string{*followFlag, "follow"}
 	}
+	lp, err := parser.ForFormat(conf.Parser.FormatFor(sources...))
+	if err != nil {
+		log.Fatalf("Invalid parser configuration: %v", err)
+	}
+	loc, err := conf.Syslog.Location()
+	if err != nil {
+		log.Fatalf("Invalid syslog configuration: %v", err)
+	}
+	lp = parser.WithEnvelope(lp, loc)
 
-	//
-	// 3) Single goroutine to handle geo lookups with throttling.
-	//
-	var geoWG sync.WaitGroup
-	geoWG.Add(1)
-
-	go func() {
-		defer geoWG.Done()
-		ticker := time.NewTicker(time.Minute / time.Duration(maxGeoQueriesPerMinute))
-		defer ticker.Stop()
+	engine, err := rules.New(conf.Rules)
+	if err != nil {
+		log.Fatalf("Invalid rules configuration: %v", err)
+	}
+	class, err := netclass.New(conf.Network.OwnCIDRs)
+	if err != nil {
+		log.Fatalf("Invalid network configuration: %v", err)
+	}
 
-		for ip := range geoChan {
-			<-ticker.C
+	intel, err := threatintel.NewProviders(conf.ThreatIntel)
+	if err != nil {
+		log.Fatalf("Invalid threat_intel configuration: %v", err)
+	}
+	defer intel.Close()
 
-			err := geo.ProcessIP(dbHandler, ip)
+	// Match against the stored lists; long-running modes refresh them.
+	matcher := blocklist.NewMatcher()
+	if _, err := matcher.Load(s, conf.Blocklists.Feeds); err != nil {
+		log.Fatalf("Failed to load blocklists: %v", err)
+	}
 
-			// Decrement from the “in queue” count
-			stats.DecrementGeoQueued()
+	if *sensorFlag != "" {
+		conf.Sensor.ID = *sensorFlag
+	}
 
-			if err != nil {
-				stats.IncrementGeoErrors()
-				prog.BufferMessage(fmt.Sprintf("Geo lookup failed for IP=%s: %v", ip, err))
-				continue
-			}
-			stats.IncrementGeoCompleted()
+	p := pipeline.New(pipeline.Options{
+		Store:      s,
+		Parser:     lp,
+		Rules:      engine,
+		Sensor:     conf.Sensor.ID,
+		Classifier: class,
+		Geo:        provider,
+		GeoTTL:     conf.Geo.TTL(),
+		Intel:      intel,
+		IntelTTL:   conf.ThreatIntel.TTL(),
+		Blocklists: matcher,
+		Scans:      scan.NewDetector(conf.ScanDetection),
+		Stats:      stats,
+		Progress:   prog,
+	})
+
+	switch {
+	case *daemonFlag:
+		if *listenFlag != "" {
+			conf.Syslog.UDPAddress = *listenFlag
+			conf.Syslog.TCPAddress = *listenFlag
+		}
+		go runDaemon(conf.Syslog, loc, p)
+		go runGeoRefresher(s, conf.Geo, provider)
+		go runBlocklistRefresher(s, conf.Blocklists, matcher)
+	case *followFlag != "":
+		go runFollow(*followFlag, *stateFlag, p)
+		go runGeoRefresher(s, conf.Geo, provider)
+		go runBlocklistRefresher(s, conf.Blocklists, matcher)
+	default:
+		// Stream input logs from stdin, newest first unless -r is given.
+		streamLines := input.StreamLinesReverse
+		if *reverseFlag {
+			streamLines = input.StreamLines
 		}
-	}()
-
-	// Close geoChan when DB workers finish
-	go func() {
-		wg.Wait()
-		close(geoChan)
-	}()
 
-	// Signal main doneChan when geo lookups finish
-	go func() {
-		geoWG.Wait()
-		close(doneChan)
-	}()
+		go func() {
+			if err := streamLines(os.Stdin, p.Feed); err != nil {
+				stats.IncrementErrors()
+				prog.BufferMessage(fmt.Sprintf("Error reading input: %v", err))
+			}
+			p.Close()
+		}()
+	}
 
 	// Start periodic progress display until everything is done
-	prog.StartPeriodicDisplay(5*time.Second, doneChan)
+	prog.StartPeriodicDisplay(5*time.Second, p.Done())
 }
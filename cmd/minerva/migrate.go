@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"minerva/internal/store"
+	"strconv"
+)
+
+// runMigrate implements `minerva migrate up|down [steps]|status`.
+func runMigrate(s store.Store, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: minerva migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := s.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations to revert: %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := s.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations to revert")
+		}
+	case "status":
+		statuses, err := s.MigrationStatuses()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-24s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q; expected up, down or status", args[0])
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"minerva/internal/store"
+	"time"
+)
+
+const maintenanceInterval = time.Hour
+
+// maintainPartitions prepares s for upcoming entries, for example by creating
+// log_data partitions, and, if days is positive, drops the partitions holding
+// only entries older than days.
+func maintainPartitions(s store.Store, days int) error {
+	now := time.Now()
+	if err := s.Maintain(now); err != nil {
+		return err
+	}
+	if days <= 0 {
+		return nil
+	}
+
+	expired, err := store.ExpiredPartitions(s, retentionCutoff(now, days))
+	if err != nil {
+		return err
+	}
+	for _, p := range expired {
+		if err := s.DropPartition(p); err != nil {
+			return err
+		}
+		log.Printf("Dropped partition %s (%s to %s) past retention", p.Name, p.From.Format(time.DateOnly), p.To.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// runMaintenance calls maintainPartitions every maintenanceInterval, for
+// long-running modes.
+func runMaintenance(s store.Store, days int) {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := maintainPartitions(s, days); err != nil {
+			log.Printf("Partition maintenance failed: %v", err)
+		}
+	}
+}
+
+// retentionCutoff returns the time before which entries are past retention.
+func retentionCutoff(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
+
+// runRetention implements `minerva retention [enforce]`: it lists the log_data
+// partitions and which of them are past retention, and drops those with enforce.
+func runRetention(s store.Store, days int, args []string) {
+	enforce := false
+	if len(args) > 0 {
+		if args[0] != "enforce" || len(args) > 1 {
+			log.Fatalf("Usage: minerva retention [enforce]")
+		}
+		enforce = true
+	}
+
+	list, err := s.Partitions()
+	if err != nil {
+		log.Fatalf("Failed to list partitions: %v", err)
+	}
+	expired := map[string]bool{}
+	if days > 0 {
+		old, err := store.ExpiredPartitions(s, retentionCutoff(time.Now(), days))
+		if err != nil {
+			log.Fatalf("Failed to list partitions: %v", err)
+		}
+		for _, p := range old {
+			expired[p.Name] = true
+		}
+	} else {
+		fmt.Println("Retention is disabled; set days in [retention] to drop old partitions")
+	}
+
+	for _, p := range list {
+		status := "keep"
+		if expired[p.Name] {
+			status = "drop"
+		}
+		size := "     ? MB"
+		if p.Size >= 0 {
+			size = fmt.Sprintf("%6.1f MB", float64(p.Size)/(1<<20))
+		}
+		fmt.Printf("%-24s %s to %s  ~%d rows  %s  %s\n", p.Name,
+			p.From.Format(time.DateOnly), p.To.Format(time.DateOnly), max(p.Rows, 0), size, status)
+	}
+	if days <= 0 {
+		return
+	}
+	if !enforce {
+		fmt.Printf("%d partition(s) older than %d days would be dropped; run 'minerva retention enforce' to drop them\n", len(expired), days)
+		return
+	}
+	if err := maintainPartitions(s, days); err != nil {
+		log.Fatalf("Failed to enforce retention: %v", err)
+	}
+	fmt.Printf("Dropped %d partition(s) older than %d days\n", len(expired), days)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"minerva/internal/api"
+	"minerva/internal/store"
+)
+
+// GetTopASNs returns the autonomous systems that sent the most flagged
+// entries, with how many of their addresses did so and how many of those are
+// hosting or data center addresses. It can be restricted to one sensor and to
+// entries since a time, given as RFC 3339 or as a duration before now such
+// as 24h.
+func GetTopASNs(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 10
+		}
+
+		query := store.ASNQuery{Sensor: r.URL.Query().Get("sensor"), Limit: limit}
+		if since := r.URL.Query().Get("since"); since != "" {
+			if query.Since, err = parseSince(since, time.Now()); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid since")
+				return
+			}
+		}
+
+		asns, err := s.TopASNs(query)
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": asns})
+	}
+}
+
+// parseSince parses an RFC 3339 time, or a duration such as 24h that is
+// subtracted from now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid time or duration %q", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input     string
+		expected  time.Time
+		expectErr bool
+	}{
+		{"24h", now.Add(-24 * time.Hour), false},
+		{"90m", now.Add(-90 * time.Minute), false},
+		{"2025-01-01T00:00:00Z", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"-1h", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseSince(tc.input, now)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("parseSince(%q): expected error %v, got %v", tc.input, tc.expectErr, err)
+			continue
+		}
+		if !got.Equal(tc.expected) {
+			t.Errorf("parseSince(%q): expected %v, got %v", tc.input, tc.expected, got)
+		}
+	}
+}
@@ -1,39 +1,69 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 
 	"minerva/internal/api"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"minerva/internal/threatintel"
 
 	"github.com/gorilla/mux"
 )
 
-// GetGeo returns geolocation data for an IP address.
-func GetGeo(db *sql.DB) http.HandlerFunc {
+// GetGeo returns geolocation data for an IP address, with the threat
+// intelligence reports on it and the highest of their scores, null if there
+// are none. With a sensor filter, only addresses that appear in that sensor's
+// logs are found.
+func GetGeo(s store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
-		ip := vars["ip"]
-		query := `SELECT country, region, city, isp, latitude, longitude FROM ip_geo WHERE ip_address = $1`
-		var country, region, city, isp string
-		var latitude, longitude sql.NullFloat64
-
-		err := db.QueryRow(query, ip).Scan(&country, &region, &city, &isp, &latitude, &longitude)
+		addr, err := parser.ParseAddr(vars["ip"])
 		if err != nil {
+			api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid IP address")
+			return
+		}
+		ip := addr.String()
+
+		data, err := s.Geo(ip, r.URL.Query().Get("sensor"))
+		if errors.Is(err, store.ErrNotFound) {
 			api.JsonErrorResponse(w, http.StatusNotFound, "IP not found")
 			return
 		}
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
 
-		geoData := map[string]interface{}{
-			"ip":        ip,
-			"country":   country,
-			"region":    region,
-			"city":      city,
-			"isp":       isp,
-			"latitude":  latitude.Float64,
-			"longitude": longitude.Float64,
+		reports, err := s.Reputation(ip, "")
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
 		}
 
+		geoData := geoFields(data)
+		geoData["ip"] = ip
+		geoData["threat_score"] = threatintel.MaxScore(reports)
+		geoData["reputation"] = reports
+
 		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": geoData})
 	}
 }
+
+// geoFields returns the JSON fields of geolocation data.
+func geoFields(data *geo.Data) map[string]interface{} {
+	return map[string]interface{}{
+		"country":   data.Country,
+		"region":    data.Region,
+		"city":      data.City,
+		"isp":       data.ISP,
+		"latitude":  data.Latitude,
+		"longitude": data.Longitude,
+		"asn":       data.ASN,
+		"as_org":    data.ASOrg,
+		"as_prefix": data.ASPrefix,
+		"hosting":   data.Hosting,
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"minerva/internal/api"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+)
+
+// GetIncidents returns a paginated list of detected scans, latest start first,
+// optionally restricted to one sensor, to source addresses in src_cidr, to one
+// scan type (vertical, horizontal or slow), and to scans that ended since a
+// time, given as RFC 3339 or as a duration before now such as 24h.
+func GetIncidents(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 50
+		}
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+
+		query := store.IncidentQuery{Sensor: r.URL.Query().Get("sensor"), Limit: limit, Offset: offset}
+		if cidr := r.URL.Query().Get("src_cidr"); cidr != "" {
+			if query.SourceCIDR, err = parseCIDR(cidr); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid src_cidr")
+				return
+			}
+		}
+		if t := r.URL.Query().Get("type"); t != "" {
+			if query.Type, err = scan.ParseType(t); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid type")
+				return
+			}
+		}
+		if since := r.URL.Query().Get("since"); since != "" {
+			if query.Since, err = parseSince(since, time.Now()); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid since")
+				return
+			}
+		}
+
+		incidents, err := s.Incidents(query)
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": incidents})
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"minerva/internal/api"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// GetIPProfile returns what is known about a source address: its class, when
+// it was first and last seen, how many flagged entries it sent, the ports it targeted, the
+// reasons it was logged for, and its geolocation. With a sensor filter, only
+// addresses that appear in that sensor's logs are found.
+func GetIPProfile(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, err := parser.ParseAddr(mux.Vars(r)["ip"])
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid IP address")
+			return
+		}
+
+		profile, err := s.IPProfile(addr.String(), r.URL.Query().Get("sensor"))
+		if errors.Is(err, store.ErrNotFound) {
+			api.JsonErrorResponse(w, http.StatusNotFound, "IP not found")
+			return
+		}
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		data := map[string]interface{}{
+			"ip":                profile.IP,
+			"class":             profile.Class,
+			"first_seen":        profile.FirstSeen,
+			"last_seen":         profile.LastSeen,
+			"total_hits":        profile.TotalHits,
+			"destination_ports": profile.DestinationPorts,
+			"reasons":           profile.Reasons,
+			"geo":               nil,
+		}
+		if profile.Geo != nil {
+			data["geo"] = geoFields(profile.Geo)
+		}
+
+		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": data})
+	}
+}
@@ -1,15 +1,21 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
+	"net/netip"
 	"strconv"
 
 	"minerva/internal/api"
+	"minerva/internal/netclass"
+	"minerva/internal/parser"
+	"minerva/internal/store"
 )
 
-// GetLogs returns a paginated list of logs from the log_data table.
-func GetLogs(db *sql.DB) http.HandlerFunc {
+// GetLogs returns a paginated list of logs, newest first, optionally restricted
+// to one sensor, to source addresses in src_cidr, such as 203.0.113.0/24, and
+// to those of class src_class, such as public. A bare address in src_cidr
+// matches only itself.
+func GetLogs(s store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
 		if err != nil || limit <= 0 {
@@ -20,30 +26,35 @@ func GetLogs(db *sql.DB) http.HandlerFunc {
 			offset = 0
 		}
 
-		query := `SELECT timestamp, source_ip, destination_ip, protocol, action FROM log_data ORDER BY timestamp DESC LIMIT $1 OFFSET $2`
-		rows, err := db.Query(query, limit, offset)
-		if err != nil {
-			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
-			return
+		query := store.LogQuery{Sensor: r.URL.Query().Get("sensor"), Limit: limit, Offset: offset}
+		if cidr := r.URL.Query().Get("src_cidr"); cidr != "" {
+			if query.SourceCIDR, err = parseCIDR(cidr); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid src_cidr")
+				return
+			}
 		}
-		defer rows.Close()
-
-		logs := []map[string]interface{}{}
-		for rows.Next() {
-			var ts, srcIP, dstIP, proto, action string
-			if err := rows.Scan(&ts, &srcIP, &dstIP, &proto, &action); err != nil {
-				api.JsonErrorResponse(w, http.StatusInternalServerError, "Scan error")
+		if class := r.URL.Query().Get("src_class"); class != "" {
+			if _, err := netclass.Parse(class); err != nil {
+				api.JsonErrorResponse(w, http.StatusBadRequest, "Invalid src_class")
 				return
 			}
-			logs = append(logs, map[string]interface{}{
-				"timestamp":      ts,
-				"source_ip":      srcIP,
-				"destination_ip": dstIP,
-				"protocol":       proto,
-				"action":         action,
-			})
+			query.SourceClass = class
+		}
+
+		logs, err := s.Logs(query)
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
 		}
 
 		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": logs})
 	}
 }
+
+// parseCIDR parses a prefix such as 203.0.113.0/24 or a single address.
+func parseCIDR(s string) (netip.Prefix, error) {
+	if addr, err := parser.ParseAddr(s); err == nil {
+		return addr.Prefix(addr.BitLen())
+	}
+	return netip.ParsePrefix(s)
+}
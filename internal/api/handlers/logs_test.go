@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  netip.Prefix
+		expectErr bool
+	}{
+		{"203.0.113.0/24", netip.MustParsePrefix("203.0.113.0/24"), false},
+		{"203.0.113.7/24", netip.MustParsePrefix("203.0.113.7/24"), false},
+		{"203.0.113.7", netip.MustParsePrefix("203.0.113.7/32"), false},
+		{"2001:db8::/32", netip.MustParsePrefix("2001:db8::/32"), false},
+		{"2001:DB8:0:0:0:0:0:7", netip.MustParsePrefix("2001:db8::7/128"), false},
+		{"::ffff:203.0.113.7", netip.MustParsePrefix("203.0.113.7/32"), false},
+		{"fe80::1%eth0", netip.Prefix{}, true},
+		{"203.0.113.0/33", netip.Prefix{}, true},
+		{"not-an-ip", netip.Prefix{}, true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseCIDR(tc.input)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("parseCIDR(%q): expected error %v, got %v", tc.input, tc.expectErr, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("parseCIDR(%q): expected %v, got %v", tc.input, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"minerva/internal/api"
+	"minerva/internal/store"
+)
+
+// GetSensors returns the sensors whose logs are stored, with their log count
+// and the time range of their logs.
+func GetSensors(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensors, err := s.Sensors(r.URL.Query().Get("sensor"))
+		if err != nil {
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": sensors})
+	}
+}
@@ -1,56 +1,77 @@
 package handlers
 
 import (
-	"database/sql"
+	"fmt"
 	"net/http"
+	"time"
 
 	"minerva/internal/api"
+	"minerva/internal/store"
 )
 
-// GetStats returns database size and row counts.
-func GetStats(db *sql.DB) http.HandlerFunc {
+// GetStats returns database size, row counts and the log_data partitions. With
+// a sensor filter, it also returns that sensor's log count and time range.
+func GetStats(s store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var dbSize string
-		if err := db.QueryRow("SELECT pg_size_pretty(pg_database_size(current_database()))").Scan(&dbSize); err != nil {
-			api.JsonErrorResponse(w, http.StatusInternalServerError, "Failed to get database size")
-			return
-		}
-
-		rows, err := db.Query(`
-			SELECT c.relname AS table_name, 
-				COALESCE(s.n_live_tup, 0) AS row_count, 
-				pg_size_pretty(pg_total_relation_size(c.oid)) AS size 
-			FROM pg_class c
-			JOIN pg_stat_user_tables s ON c.relname = s.relname
-			ORDER BY pg_total_relation_size(c.oid) DESC;
-		`)
+		stats, err := s.Stats()
 		if err != nil {
-			api.JsonErrorResponse(w, http.StatusInternalServerError, "Failed to get table statistics")
+			api.JsonErrorResponse(w, http.StatusInternalServerError, "Failed to get database statistics")
 			return
 		}
-		defer rows.Close()
 
 		tables := []map[string]interface{}{}
-		for rows.Next() {
-			var tableName string
-			var rowCount int64
-			var size string
-			if err := rows.Scan(&tableName, &rowCount, &size); err != nil {
-				api.JsonErrorResponse(w, http.StatusInternalServerError, "Failed to scan table statistics")
-				return
-			}
+		for _, t := range stats.Tables {
 			tables = append(tables, map[string]interface{}{
-				"name":      tableName,
-				"row_count": rowCount,
-				"size":      size,
+				"name":      t.Name,
+				"row_count": t.Rows,
+				"size":      prettySize(t.Size),
 			})
 		}
 
-		api.JsonResponse(w, http.StatusOK, map[string]interface{}{
-			"data": map[string]interface{}{
-				"database_size": dbSize,
-				"tables":        tables,
-			},
-		})
+		partitions := []map[string]interface{}{}
+		for _, p := range stats.Partitions {
+			partitions = append(partitions, map[string]interface{}{
+				"name":         p.Name,
+				"from":         p.From.Format(time.DateTime),
+				"to":           p.To.Format(time.DateTime),
+				"row_estimate": max(p.Rows, 0),
+				"size_bytes":   p.Size,
+			})
+		}
+
+		data := map[string]interface{}{
+			"database_size": prettySize(stats.DatabaseSize),
+			"tables":        tables,
+			"partitions":    partitions,
+		}
+		if sensor := r.URL.Query().Get("sensor"); sensor != "" {
+			sensors, err := s.Sensors(sensor)
+			if err != nil {
+				api.JsonErrorResponse(w, http.StatusInternalServerError, "Failed to get sensor statistics")
+				return
+			}
+			if len(sensors) == 0 {
+				api.JsonErrorResponse(w, http.StatusNotFound, "Sensor not found")
+				return
+			}
+			data["sensor"] = sensors[0]
+		}
+
+		api.JsonResponse(w, http.StatusOK, map[string]interface{}{"data": data})
+	}
+}
+
+// prettySize formats a size in bytes like PostgreSQL's pg_size_pretty: in the
+// largest unit that keeps the value at least 10, rounded half up. A negative
+// size is unknown.
+func prettySize(n int64) string {
+	if n < 0 {
+		return "unknown"
+	}
+	units := []string{"bytes", "kB", "MB", "GB", "TB", "PB"}
+	i := 0
+	for ; i < len(units)-1 && n >= 10*1024; i++ {
+		n = (n + 512) / 1024
 	}
+	return fmt.Sprintf("%d %s", n, units[i])
 }
@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestPrettySize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 bytes"},
+		{10239, "10239 bytes"},
+		{10240, "10 kB"},
+		{8 << 20, "8192 kB"},
+		{10 << 20, "10 MB"},
+		{(10 << 20) + (1 << 19), "11 MB"},
+		{-1, "unknown"},
+	}
+
+	for _, tc := range tests {
+		if got := prettySize(tc.size); got != tc.expected {
+			t.Errorf("prettySize(%d): expected %q, got %q", tc.size, tc.expected, got)
+		}
+	}
+}
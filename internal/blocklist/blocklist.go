@@ -0,0 +1,241 @@
+// Package blocklist matches source addresses against blocklist feeds, such as
+// FireHOL, Spamhaus DROP and Emerging Threats, read from URLs or local files.
+//
+// Feeds are fetched on a schedule and stored, so that every run matches
+// against the same networks without fetching them itself. While logs are
+// ingested, the stored networks are held in memory in a prefix trie, which a
+// Matcher shares between the insert workers without locking.
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"minerva/internal/config"
+	"minerva/internal/parser"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// client is a reusable HTTP client with a timeout. Feeds can be a few
+// megabytes, so the timeout is longer than for lookups.
+var client = &http.Client{Timeout: time.Minute}
+
+// SetHTTPClient allows overriding the default HTTP client.
+func SetHTTPClient(c *http.Client) {
+	client = c
+}
+
+// List is a stored blocklist.
+type List struct {
+	Name      string
+	Networks  []netip.Prefix
+	UpdatedAt time.Time // When the feed was last fetched
+}
+
+// DataHandler defines methods for storing blocklists.
+type DataHandler interface {
+	// BlocklistsUpdatedAt returns when each stored list was last fetched, by
+	// name.
+	BlocklistsUpdatedAt() (map[string]time.Time, error)
+	// Blocklists returns the stored lists with their networks.
+	Blocklists() ([]List, error)
+	// ReplaceBlocklist replaces the stored networks of a list, which are
+	// distinct, as Parse returns them. A list without networks is deleted.
+	ReplaceBlocklist(l List) error
+}
+
+// Validate checks that every feed has a unique name that can be used as a tag,
+// and a URL or a path.
+func Validate(feeds []config.BlocklistFeedConfig) error {
+	seen := map[string]bool{}
+	for _, f := range feeds {
+		if f.Name == "" || strings.IndexFunc(f.Name, invalidNameRune) >= 0 {
+			return fmt.Errorf("invalid blocklist name %q: use letters, digits, '.', '_' and '-'", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate blocklist name %q", f.Name)
+		}
+		seen[f.Name] = true
+		if f.URL == "" && f.Path == "" {
+			return fmt.Errorf("blocklist %q needs a url or a path", f.Name)
+		}
+	}
+	return nil
+}
+
+func invalidNameRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-')
+}
+
+// ParseNetwork parses an address or a CIDR network. A single address is a
+// network of one address, and IPv4-mapped networks are unmapped.
+func ParseNetwork(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := parser.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return unmapPrefix(p).Masked(), nil
+}
+
+// Parse reads a feed with one address or CIDR network per line, as FireHOL
+// .netset and .ipset files, Spamhaus DROP and Emerging Threats lists have.
+// Anything after '#' or ';' is a comment, and only the first field of a line
+// is read. Networks are returned once each, in the order they first appear.
+// Invalid lines are skipped, but a feed with nothing else is an error, since
+// it is more likely an error page than an empty list.
+func Parse(r io.Reader) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	seen := map[netip.Prefix]bool{}
+	invalid := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		network, err := ParseNetwork(fields[0])
+		if err != nil {
+			invalid++
+			continue
+		}
+		if !seen[network] {
+			seen[network] = true
+			networks = append(networks, network)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	if len(networks) == 0 && invalid > 0 {
+		return nil, fmt.Errorf("no valid networks in %d line(s)", invalid)
+	}
+	return networks, nil
+}
+
+// Fetch reads and parses a feed from its URL, or from its path if it has no
+// URL.
+func Fetch(feed config.BlocklistFeedConfig) ([]netip.Prefix, error) {
+	if feed.URL == "" {
+		f, err := os.Open(feed.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open blocklist: %w", err)
+		}
+		defer f.Close()
+		return Parse(f)
+	}
+
+	resp, err := client.Get(feed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch blocklist: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch blocklist: status code %d", resp.StatusCode)
+	}
+	return Parse(resp.Body)
+}
+
+// Refresh fetches the feeds whose stored list is older than maxAge, or every
+// feed if maxAge is 0, and replaces their stored networks. Stored lists that
+// are no longer configured are deleted. A feed that cannot be fetched keeps
+// its stored networks; the errors of all such feeds are logged and returned
+// together once the others are stored. It returns the number of feeds
+// fetched.
+func Refresh(handler DataHandler, feeds []config.BlocklistFeedConfig, maxAge time.Duration) (int, error) {
+	updated, err := handler.BlocklistsUpdatedAt()
+	if err != nil {
+		return 0, err
+	}
+
+	configured := map[string]bool{}
+	var errs []error
+	refreshed := 0
+	now := time.Now()
+	for _, feed := range feeds {
+		configured[feed.Name] = true
+		if at, ok := updated[feed.Name]; ok && maxAge > 0 && now.Sub(at) < maxAge {
+			continue
+		}
+		networks, err := Fetch(feed)
+		if err != nil {
+			log.Printf("Error fetching blocklist %s: %v", feed.Name, err)
+			errs = append(errs, fmt.Errorf("blocklist %s: %w", feed.Name, err))
+			continue
+		}
+		if err := handler.ReplaceBlocklist(List{Name: feed.Name, Networks: networks, UpdatedAt: now}); err != nil {
+			return refreshed, err
+		}
+		refreshed++
+	}
+
+	for name := range updated {
+		if !configured[name] {
+			if err := handler.ReplaceBlocklist(List{Name: name}); err != nil {
+				return refreshed, err
+			}
+		}
+	}
+	return refreshed, errors.Join(errs...)
+}
+
+// Matcher matches addresses against the stored lists. Load replaces its trie
+// while Match is in use, so the lists can be refreshed during ingestion.
+type Matcher struct {
+	trie atomic.Pointer[Trie]
+}
+
+// NewMatcher returns a Matcher without any lists.
+func NewMatcher() *Matcher {
+	m := &Matcher{}
+	m.trie.Store(NewTrie())
+	return m
+}
+
+// Load replaces the matched networks with the stored lists of the feeds, and
+// returns how many it loaded. Stored lists of other names are ignored.
+func (m *Matcher) Load(handler DataHandler, feeds []config.BlocklistFeedConfig) (int, error) {
+	lists, err := handler.Blocklists()
+	if err != nil {
+		return 0, err
+	}
+	configured := map[string]bool{}
+	for _, feed := range feeds {
+		configured[feed.Name] = true
+	}
+
+	trie := NewTrie()
+	for _, l := range lists {
+		if !configured[l.Name] {
+			continue
+		}
+		for _, network := range l.Networks {
+			trie.Insert(network, l.Name)
+		}
+	}
+	m.trie.Store(trie)
+	return trie.Len(), nil
+}
+
+// Match returns the names of the lists with a network containing addr,
+// sorted, or nil if there are none.
+func (m *Matcher) Match(addr netip.Addr) []string {
+	return m.trie.Load().Match(addr)
+}
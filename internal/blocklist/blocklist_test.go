@@ -0,0 +1,156 @@
+package blocklist
+
+import (
+	"fmt"
+	"minerva/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		feed      string
+		expected  string
+		expectErr bool
+	}{
+		{"FireHOL", "#\n# firehol_level1\n#\n1.0.0.0/24\n203.0.113.0/24\n203.0.113.5/24\n", "[1.0.0.0/24 203.0.113.0/24]", false},
+		{"Spamhaus DROP", "; Spamhaus DROP List 2024/03/01\n1.10.16.0/20 ; SBL256894\n2.56.192.0/22 ; SBL459831\n", "[1.10.16.0/20 2.56.192.0/22]", false},
+		{"Emerging Threats", "# compromised-ips\n198.51.100.7\n2001:db8::1\n\n", "[198.51.100.7/32 2001:db8::1/128]", false},
+		{"Tab-separated", "192.0.2.1\t2024-03-01\tscanner\n", "[192.0.2.1/32]", false},
+		{"Mapped", "::ffff:192.0.2.1\n::ffff:192.0.2.0/120\n", "[192.0.2.1/32 192.0.2.0/24]", false},
+		{"Invalid lines skipped", "192.0.2.1\nnot-an-ip\n192.0.2.0/33\nfe80::1%eth0\n", "[192.0.2.1/32]", false},
+		{"Empty", "# nothing listed today\n", "[]", false},
+		{"Error page", "<html>\n<body>Too many requests</body>\n</html>\n", "", true},
+	}
+	for _, tc := range tests {
+		networks, err := Parse(strings.NewReader(tc.feed))
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+			continue
+		}
+		if got := fmt.Sprint(networks); err == nil && got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		feeds     []config.BlocklistFeedConfig
+		expectErr bool
+	}{
+		{"Valid", []config.BlocklistFeedConfig{{Name: "firehol_level1", URL: "https://example.com"}, {Name: "local-2", Path: "list.txt"}}, false},
+		{"No name", []config.BlocklistFeedConfig{{URL: "https://example.com"}}, true},
+		{"Comma", []config.BlocklistFeedConfig{{Name: "a,b", URL: "https://example.com"}}, true},
+		{"Duplicate", []config.BlocklistFeedConfig{{Name: "a", URL: "https://example.com"}, {Name: "a", Path: "list.txt"}}, true},
+		{"No source", []config.BlocklistFeedConfig{{Name: "a"}}, true},
+	}
+	for _, tc := range tests {
+		if err := Validate(tc.feeds); (err != nil) != tc.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+		}
+	}
+}
+
+// mockHandler stores lists in memory.
+type mockHandler struct {
+	lists map[string]List
+}
+
+func (h *mockHandler) BlocklistsUpdatedAt() (map[string]time.Time, error) {
+	updated := map[string]time.Time{}
+	for name, l := range h.lists {
+		updated[name] = l.UpdatedAt
+	}
+	return updated, nil
+}
+
+func (h *mockHandler) Blocklists() ([]List, error) {
+	var lists []List
+	for _, l := range h.lists {
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+func (h *mockHandler) ReplaceBlocklist(l List) error {
+	if len(l.Networks) == 0 {
+		delete(h.lists, l.Name)
+		return nil
+	}
+	h.lists[l.Name] = l
+	return nil
+}
+
+func TestRefresh(t *testing.T) {
+	var requests int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/drop.txt":
+			w.Write([]byte("203.0.113.0/24 ; SBL1\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	path := filepath.Join(t.TempDir(), "local.txt")
+	if err := os.WriteFile(path, []byte("198.51.100.7\n"), 0600); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+	feeds := []config.BlocklistFeedConfig{
+		{Name: "drop", URL: mockServer.URL + "/drop.txt"},
+		{Name: "local", Path: path},
+		{Name: "gone", URL: mockServer.URL + "/gone.txt"},
+	}
+	stale := List{Name: "gone", Networks: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, UpdatedAt: time.Now().AddDate(0, 0, -2)}
+	removed := List{Name: "removed", Networks: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, UpdatedAt: time.Now()}
+	handler := &mockHandler{lists: map[string]List{"gone": stale, "removed": removed}}
+
+	// A feed that fails keeps its stored networks, and lists that are no
+	// longer configured are deleted.
+	refreshed, err := Refresh(handler, feeds, 24*time.Hour)
+	if refreshed != 2 || err == nil || !strings.Contains(err.Error(), "gone") {
+		t.Errorf("Expected two feeds and an error for the third, got %d, %v", refreshed, err)
+	}
+	if _, ok := handler.lists["removed"]; ok {
+		t.Error("Expected the list that is no longer configured to be deleted")
+	}
+	if l := handler.lists["gone"]; len(l.Networks) != 1 {
+		t.Errorf("Expected the failed feed to keep its networks, got %+v", l)
+	}
+
+	// Fresh lists are not fetched again, unless every feed is.
+	requests = 0
+	feeds = feeds[:2]
+	if refreshed, err := Refresh(handler, feeds, 24*time.Hour); refreshed != 0 || err != nil || requests != 0 {
+		t.Errorf("Expected nothing to be fetched, got %d, %v after %d requests", refreshed, err, requests)
+	}
+	if refreshed, err := Refresh(handler, feeds, 0); refreshed != 2 || err != nil {
+		t.Errorf("Expected every feed to be fetched, got %d, %v", refreshed, err)
+	}
+
+	m := NewMatcher()
+	if got := m.Match(netip.MustParseAddr("203.0.113.7")); got != nil {
+		t.Errorf("Expected an empty matcher to match nothing, got %v", got)
+	}
+	n, err := m.Load(handler, feeds)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected two networks to be loaded, got %d, %v", n, err)
+	}
+	if got := fmt.Sprint(m.Match(netip.MustParseAddr("203.0.113.7"))); got != "[drop]" {
+		t.Errorf("Expected a match in drop, got %s", got)
+	}
+	if got := m.Match(netip.MustParseAddr("192.0.2.1")); got != nil {
+		t.Errorf("Expected the unconfigured list not to be loaded, got %v", got)
+	}
+}
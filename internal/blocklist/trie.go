@@ -0,0 +1,123 @@
+package blocklist
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// node is a node of a binary trie over address bits. lists holds the indexes
+// into Trie.names of the lists with a network ending at this node.
+type node struct {
+	children [2]*node
+	lists    []uint16
+}
+
+// Trie matches addresses against the networks of many lists. IPv4 and IPv6
+// networks are kept in separate tries, one bit per level, so a lookup takes at
+// most 32 or 128 steps however many networks are loaded. A Trie is not safe
+// for concurrent Insert, but any number of goroutines may Match once it is
+// built.
+type Trie struct {
+	v4, v6 node
+	names  []string
+	index  map[string]uint16
+	size   int
+}
+
+// NewTrie returns an empty Trie.
+func NewTrie() *Trie {
+	return &Trie{index: map[string]uint16{}}
+}
+
+// Insert adds network to the list called name. IPv4-mapped IPv6 networks are
+// stored as the IPv4 networks they map, since Match unmaps addresses.
+func (t *Trie) Insert(network netip.Prefix, name string) {
+	if !network.IsValid() {
+		return
+	}
+	network = unmapPrefix(network)
+	id, ok := t.index[name]
+	if !ok {
+		id = uint16(len(t.names))
+		t.names = append(t.names, name)
+		t.index[name] = id
+	}
+
+	network = network.Masked()
+	n := t.root(network.Addr())
+	bytes := network.Addr().AsSlice()
+	for i := 0; i < network.Bits(); i++ {
+		b := bit(bytes, i)
+		if n.children[b] == nil {
+			n.children[b] = &node{}
+		}
+		n = n.children[b]
+	}
+	for _, l := range n.lists {
+		if l == id {
+			return
+		}
+	}
+	n.lists = append(n.lists, id)
+	t.size++
+}
+
+// Match returns the names of the lists with a network containing addr,
+// sorted, or nil if there are none.
+func (t *Trie) Match(addr netip.Addr) []string {
+	if !addr.IsValid() {
+		return nil
+	}
+	addr = addr.Unmap()
+	var ids []uint16
+	n := t.root(addr)
+	bytes := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		ids = append(ids, n.lists...)
+		if i == len(bytes)*8 {
+			break
+		}
+		n = n.children[bit(bytes, i)]
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	seen := make(map[uint16]bool, len(ids))
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			names = append(names, t.names[id])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of networks inserted, counting a network once per
+// list it is in.
+func (t *Trie) Len() int {
+	return t.size
+}
+
+func (t *Trie) root(addr netip.Addr) *node {
+	if addr.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// unmapPrefix returns the IPv4 network mapped by an IPv4-mapped IPv6 network,
+// and any other network unchanged.
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p
+}
+
+// bit returns bit i of b, counting from the most significant bit.
+func bit(b []byte, i int) int {
+	return int(b[i/8]>>(7-i%8)) & 1
+}
@@ -0,0 +1,70 @@
+package blocklist
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestTrie(t *testing.T) {
+	trie := NewTrie()
+	for _, e := range []struct{ network, list string }{
+		{"203.0.113.0/24", "drop"},
+		{"203.0.113.128/25", "firehol"},
+		{"203.0.113.7/32", "et"},
+		{"203.0.113.7/32", "et"}, // Counted once
+		{"198.51.100.0/24", "firehol"},
+		{"2001:db8::/32", "drop"},
+		{"::ffff:192.0.2.0/120", "mapped"},
+	} {
+		trie.Insert(netip.MustParsePrefix(e.network), e.list)
+	}
+	if trie.Len() != 6 {
+		t.Errorf("Expected 6 networks, got %d", trie.Len())
+	}
+
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"203.0.113.7", "[drop et]"},
+		{"203.0.113.200", "[drop firehol]"},
+		{"203.0.113.1", "[drop]"},
+		{"198.51.100.255", "[firehol]"},
+		{"198.51.101.0", "[]"},
+		{"2001:db8::1", "[drop]"},
+		{"2001:db9::1", "[]"},
+		{"::ffff:203.0.113.7", "[drop et]"},
+		{"192.0.2.9", "[mapped]"},
+	}
+	for _, tc := range tests {
+		if got := fmt.Sprint(trie.Match(netip.MustParseAddr(tc.addr))); got != tc.expected {
+			t.Errorf("Match(%s): expected %s, got %s", tc.addr, tc.expected, got)
+		}
+	}
+
+	everything := NewTrie()
+	everything.Insert(netip.MustParsePrefix("0.0.0.0/0"), "all")
+	if got := everything.Match(netip.MustParseAddr("192.0.2.1")); len(got) != 1 {
+		t.Errorf("Expected /0 to match every IPv4 address, got %v", got)
+	}
+	if got := everything.Match(netip.MustParseAddr("2001:db8::1")); got != nil {
+		t.Errorf("Expected an IPv4 network not to match IPv6 addresses, got %v", got)
+	}
+	if got := everything.Match(netip.Addr{}); got != nil {
+		t.Errorf("Expected no match for the zero Addr, got %v", got)
+	}
+}
+
+func BenchmarkTrieMatch(b *testing.B) {
+	trie := NewTrie()
+	for i := 0; i < 20000; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(i >> 8), byte(i), 0, 0})
+		trie.Insert(netip.PrefixFrom(addr, 16+i%8), "list")
+	}
+	addr := netip.MustParseAddr("203.0.113.7")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Match(addr)
+	}
+}
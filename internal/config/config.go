@@ -3,13 +3,30 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 // Config represents the application configuration loaded from a TOML file.
 type Config struct {
-	Database DatabaseConfig `toml:"database"`
+	Database      DatabaseConfig    `toml:"database"`
+	Syslog        SyslogConfig      `toml:"syslog"`
+	Parser        ParserConfig      `toml:"parser"`
+	Sensor        SensorConfig      `toml:"sensor"`
+	Retention     RetentionConfig   `toml:"retention"`
+	Storage       StorageConfig     `toml:"storage"`
+	Geo           GeoConfig         `toml:"geo"`
+	ThreatIntel   ThreatIntelConfig `toml:"threat_intel"`
+	Blocklists    BlocklistConfig   `toml:"blocklists"`
+	ScanDetection ScanConfig        `toml:"scan_detection"`
+	Network       NetworkConfig     `toml:"network"`
+
+	// Rules decide which events are flagged. RulesFile names an optional TOML
+	// file, relative to the config file, whose [[rules]] are appended to these.
+	Rules     []RuleConfig `toml:"rules"`
+	RulesFile string       `toml:"rules_file"`
 }
 
 // DatabaseConfig holds the database connection parameters.
@@ -21,6 +38,298 @@ type DatabaseConfig struct {
 	Name     string `toml:"name"`
 }
 
+// StorageConfig selects where log entries and geolocation data are stored.
+type StorageConfig struct {
+	// Driver is "postgres", which uses the [database] connection, or "sqlite",
+	// which stores everything in a single file and needs no database server.
+	Driver string `toml:"driver"`
+	// Path is the SQLite database file, relative to the config file.
+	Path string `toml:"path"`
+}
+
+// SyslogConfig holds the listen addresses for the built-in syslog receiver.
+// An empty address disables that transport.
+type SyslogConfig struct {
+	UDPAddress string `toml:"udp_address"`
+	TCPAddress string `toml:"tcp_address"`
+	// Timezone is the IANA zone (for example "America/New_York") of syslog
+	// timestamps that carry no UTC offset. Empty or "Local" uses the system zone.
+	Timezone string `toml:"timezone"`
+}
+
+// Location returns the configured timezone.
+func (c SyslogConfig) Location() (*time.Location, error) {
+	if c.Timezone == "" || c.Timezone == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// ParserConfig selects the log format parser for each input.
+type ParserConfig struct {
+	// Format is the parser used when an input has no entry in Inputs.
+	// "auto" detects the format from the lines themselves.
+	Format string `toml:"format"`
+	// Inputs maps an input ("stdin", "syslog", "follow", or a followed file path) to a format.
+	Inputs map[string]string `toml:"inputs"`
+}
+
+// FormatFor returns the format configured for the first of the given inputs
+// that has an entry, or the default format.
+func (c ParserConfig) FormatFor(inputs ...string) string {
+	for _, input := range inputs {
+		if format, ok := c.Inputs[input]; ok {
+			return format
+		}
+	}
+	return c.Format
+}
+
+// SensorConfig identifies the site or device whose logs this instance collects,
+// so that several sensors can share one database.
+type SensorConfig struct {
+	// ID is stored with every log entry. When empty, the hostname from each
+	// line's syslog header is used instead.
+	ID string `toml:"id"`
+}
+
+// RetentionConfig controls how log_data is partitioned and how long entries
+// are kept.
+type RetentionConfig struct {
+	// PartitionInterval is the time range of each partition: day, week or month.
+	PartitionInterval string `toml:"partition_interval"`
+	// Days is how long entries are kept. Partitions holding only older entries
+	// are dropped; 0 keeps everything.
+	Days int `toml:"days"`
+}
+
+// GeoConfig selects where geolocation data comes from and how long it is
+// trusted before it is looked up again, since addresses get reassigned.
+type GeoConfig struct {
+	// TTLDays is the age after which geolocation data is refreshed; 0 never
+	// refreshes it.
+	TTLDays int `toml:"ttl_days"`
+	// RefreshLimit is the most addresses refreshed per hour in the background.
+	RefreshLimit int `toml:"refresh_limit"`
+
+	// Providers are tried in order until one has data for an address:
+	// "mmdb", "ip-api" or "http".
+	Providers []string      `toml:"providers"`
+	IPAPI     IPAPIConfig   `toml:"ip_api"`
+	MMDB      MMDBConfig    `toml:"mmdb"`
+	HTTP      GeoHTTPConfig `toml:"http"`
+}
+
+// IPAPIConfig configures the ip-api.com provider.
+type IPAPIConfig struct {
+	URL string `toml:"url"`
+	// PerMinute is the most lookups a minute; the free tier allows 45.
+	PerMinute int `toml:"per_minute"`
+}
+
+// MMDBConfig configures the offline provider, which reads MaxMind
+// GeoLite2/GeoIP2 or DB-IP .mmdb files. Paths are relative to the config file.
+type MMDBConfig struct {
+	CityPath string `toml:"city_path"`
+	// ASNPath is an optional ASN database, which fills in the ISP, AS number
+	// and announced prefix.
+	ASNPath string `toml:"asn_path"`
+}
+
+// GeoHTTPConfig configures the provider for other HTTP JSON APIs.
+type GeoHTTPConfig struct {
+	// URL is queried for each address, with "{ip}" replaced by it.
+	URL     string            `toml:"url"`
+	Headers map[string]string `toml:"headers"`
+	// PerMinute is the most lookups a minute; 0 does not limit them.
+	PerMinute int `toml:"per_minute"`
+	// Fields maps each geolocation field to a dotted path in the response,
+	// such as "location.city".
+	Fields GeoFieldsConfig `toml:"fields"`
+}
+
+// GeoFieldsConfig holds the response paths of the HTTP provider's fields.
+type GeoFieldsConfig struct {
+	Country   string `toml:"country"`
+	Region    string `toml:"region"`
+	City      string `toml:"city"`
+	ISP       string `toml:"isp"`
+	Latitude  string `toml:"latitude"`
+	Longitude string `toml:"longitude"`
+	ASN       string `toml:"asn"`
+	ASOrg     string `toml:"as_org"`
+	ASPrefix  string `toml:"as_prefix"`
+	Hosting   string `toml:"hosting"`
+}
+
+// TTL returns TTLDays as a duration.
+func (c GeoConfig) TTL() time.Duration {
+	return time.Duration(c.TTLDays) * 24 * time.Hour
+}
+
+// ThreatIntelConfig selects the threat intelligence services that public
+// source addresses are checked against, and how long their answers are
+// trusted before an address is checked again.
+type ThreatIntelConfig struct {
+	// TTLDays is the age after which an address is checked again; 0 checks
+	// each address only once.
+	TTLDays int `toml:"ttl_days"`
+
+	// Providers are each asked about every address: "abuseipdb" or
+	// "virustotal". None are used by default, since both need an API key.
+	Providers  []string         `toml:"providers"`
+	AbuseIPDB  AbuseIPDBConfig  `toml:"abuseipdb"`
+	VirusTotal VirusTotalConfig `toml:"virustotal"`
+}
+
+// AbuseIPDBConfig configures the AbuseIPDB provider.
+type AbuseIPDBConfig struct {
+	APIKey string `toml:"api_key"`
+	URL    string `toml:"url"`
+	// PerDay is the most checks a day; the free tier allows 1000.
+	PerDay int `toml:"per_day"`
+	// MaxAgeDays is how far back reports are considered, up to 365.
+	MaxAgeDays int `toml:"max_age_days"`
+}
+
+// VirusTotalConfig configures the VirusTotal provider.
+type VirusTotalConfig struct {
+	APIKey string `toml:"api_key"`
+	// URL is queried for each address, with "{ip}" replaced by it.
+	URL string `toml:"url"`
+	// PerDay is the most checks a day; the free tier allows 500, and at most
+	// 4 a minute.
+	PerDay int `toml:"per_day"`
+}
+
+// TTL returns TTLDays as a duration.
+func (c ThreatIntelConfig) TTL() time.Duration {
+	return time.Duration(c.TTLDays) * 24 * time.Hour
+}
+
+// BlocklistConfig lists the blocklist feeds that flagged source addresses are
+// matched against.
+type BlocklistConfig struct {
+	// RefreshHours is the age after which long-running modes fetch a feed
+	// again; 0 fetches feeds only with `minerva blocklist refresh`.
+	RefreshHours int                   `toml:"refresh_hours"`
+	Feeds        []BlocklistFeedConfig `toml:"feeds"`
+}
+
+// BlocklistFeedConfig is one blocklist, read from a URL or a local file, with
+// one address or CIDR per line.
+type BlocklistFeedConfig struct {
+	// Name identifies the list in tags and in blocklist_entries.
+	Name string `toml:"name"`
+	URL  string `toml:"url"`
+	// Path is a local file, relative to the config file, read when URL is
+	// empty.
+	Path string `toml:"path"`
+}
+
+// RefreshInterval returns RefreshHours as a duration.
+func (c BlocklistConfig) RefreshInterval() time.Duration {
+	return time.Duration(c.RefreshHours) * time.Hour
+}
+
+// ScanConfig sets when a source address is considered to be scanning, from
+// the flagged events it sends. A threshold or window of 0 disables that kind of
+// scan.
+type ScanConfig struct {
+	// WindowSeconds is the sliding window of vertical scans and horizontal
+	// sweeps.
+	WindowSeconds int `toml:"window_seconds"`
+	// VerticalPorts is how many distinct destination ports a source must
+	// reach within a window to be running a vertical scan.
+	VerticalPorts int `toml:"vertical_ports"`
+	// HorizontalHosts is how many distinct destination addresses a source must
+	// reach on one port within a window to be running a horizontal sweep.
+	HorizontalHosts int `toml:"horizontal_hosts"`
+	// SlowWindowHours is the sliding window of slow scans.
+	SlowWindowHours int `toml:"slow_window_hours"`
+	// SlowTargets is how many distinct destination address and port pairs a
+	// source must reach within a slow window, outside of faster scans, to be
+	// running a slow scan.
+	SlowTargets int `toml:"slow_targets"`
+}
+
+// Window returns WindowSeconds as a duration.
+func (c ScanConfig) Window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// SlowWindow returns SlowWindowHours as a duration.
+func (c ScanConfig) SlowWindow() time.Duration {
+	return time.Duration(c.SlowWindowHours) * time.Hour
+}
+
+// NetworkConfig describes our own networks, whose addresses are classified
+// as "own" and never looked up.
+type NetworkConfig struct {
+	// OwnCIDRs are our networks, such as "203.0.113.0/24", or single addresses.
+	OwnCIDRs []string `toml:"own_cidrs"`
+}
+
+// RuleConfig defines a flagging rule. When is a boolean condition over the
+// parsed event's fields; see package rules for the syntax.
+type RuleConfig struct {
+	Name     string `toml:"name"`
+	Severity string `toml:"severity"`
+	When     string `toml:"when"`
+}
+
+// defaultConfig returns the values used for settings missing from the config file.
+func defaultConfig() Config {
+	return Config{
+		Syslog: SyslogConfig{
+			UDPAddress: ":514",
+			TCPAddress: ":514",
+		},
+		Parser: ParserConfig{
+			Format: "auto",
+		},
+		Storage: StorageConfig{
+			Driver: "postgres",
+			Path:   "minerva.db",
+		},
+		Geo: GeoConfig{
+			TTLDays:      30,
+			RefreshLimit: 200,
+			Providers:    []string{"ip-api"},
+			IPAPI: IPAPIConfig{
+				URL:       "http://ip-api.com/json",
+				PerMinute: 40,
+			},
+		},
+		ThreatIntel: ThreatIntelConfig{
+			TTLDays: 7,
+			AbuseIPDB: AbuseIPDBConfig{
+				URL:        "https://api.abuseipdb.com/api/v2/check",
+				PerDay:     1000,
+				MaxAgeDays: 90,
+			},
+			VirusTotal: VirusTotalConfig{
+				URL:    "https://www.virustotal.com/api/v3/ip_addresses/{ip}",
+				PerDay: 500,
+			},
+		},
+		Blocklists: BlocklistConfig{
+			RefreshHours: 24,
+		},
+		ScanDetection: ScanConfig{
+			WindowSeconds:   60,
+			VerticalPorts:   20,
+			HorizontalHosts: 10,
+			SlowWindowHours: 6,
+			SlowTargets:     30,
+		},
+	}
+}
+
 // LoadConfig loads and parses the configuration from the specified file path.
 func LoadConfig(path string) (*Config, error) {
 	// Check if the config file exists and return a wrapped error if not.
@@ -31,10 +340,34 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("error checking config file: %w", err)
 	}
 
-	var conf Config
+	conf := defaultConfig()
 	if _, err := toml.DecodeFile(path, &conf); err != nil {
 		return nil, fmt.Errorf("unable to decode config file: %w", err)
 	}
 
+	paths := []*string{&conf.Storage.Path, &conf.Geo.MMDB.CityPath, &conf.Geo.MMDB.ASNPath}
+	for i := range conf.Blocklists.Feeds {
+		paths = append(paths, &conf.Blocklists.Feeds[i].Path)
+	}
+	for _, p := range paths {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+
+	if conf.RulesFile != "" {
+		rulesPath := conf.RulesFile
+		if !filepath.IsAbs(rulesPath) {
+			rulesPath = filepath.Join(filepath.Dir(path), rulesPath)
+		}
+		var file struct {
+			Rules []RuleConfig `toml:"rules"`
+		}
+		if _, err := toml.DecodeFile(rulesPath, &file); err != nil {
+			return nil, fmt.Errorf("unable to decode rules file: %w", err)
+		}
+		conf.Rules = append(conf.Rules, file.Rules...)
+	}
+
 	return &conf, nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // createTempConfigFile creates a temporary directory and writes the given
@@ -76,3 +77,294 @@ port = 5432
 		t.Fatal("Expected an error for invalid TOML, but got nil")
 	}
 }
+
+func TestLoadConfig_SyslogDefaults(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[database]
+host = "localhost"
+
+[syslog]
+tcp_address = ""
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+
+	if conf.Syslog.UDPAddress != ":514" {
+		t.Errorf("Expected default UDP address ':514', got %q", conf.Syslog.UDPAddress)
+	}
+	if conf.Syslog.TCPAddress != "" {
+		t.Errorf("Expected TCP receiver to be disabled, got %q", conf.Syslog.TCPAddress)
+	}
+	if loc, err := conf.Syslog.Location(); err != nil || loc != time.Local {
+		t.Errorf("Expected the local time zone by default, got %v, %v", loc, err)
+	}
+}
+
+func TestLoadConfig_Sensor(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[sensor]
+id = "site2"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if conf.Sensor.ID != "site2" {
+		t.Errorf("Expected sensor ID 'site2', got %q", conf.Sensor.ID)
+	}
+}
+
+func TestLoadConfig_Retention(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[retention]
+partition_interval = "week"
+days = 90
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if conf.Retention.PartitionInterval != "week" || conf.Retention.Days != 90 {
+		t.Errorf("Expected weekly partitions kept for 90 days, got %+v", conf.Retention)
+	}
+}
+
+func TestLoadConfig_Storage(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[database]
+host = "localhost"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if conf.Storage.Driver != "postgres" {
+		t.Errorf("Expected the postgres driver by default, got %q", conf.Storage.Driver)
+	}
+
+	tempDir, configPath = createTempConfigFile(t, `
+[storage]
+driver = "sqlite"
+path = "data/minerva.db"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if expected := filepath.Join(tempDir, "data", "minerva.db"); conf.Storage.Driver != "sqlite" || conf.Storage.Path != expected {
+		t.Errorf("Expected sqlite at %s, got %+v", expected, conf.Storage)
+	}
+}
+
+func TestLoadConfig_Geo(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[geo]
+ttl_days = 7
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if conf.Geo.TTL() != 7*24*time.Hour || conf.Geo.RefreshLimit != 200 {
+		t.Errorf("Expected a 7 day TTL and the default refresh limit, got %+v", conf.Geo)
+	}
+	if len(conf.Geo.Providers) != 1 || conf.Geo.Providers[0] != "ip-api" || conf.Geo.IPAPI.PerMinute != 40 {
+		t.Errorf("Expected the rate-limited ip-api provider by default, got %+v", conf.Geo)
+	}
+
+	tempDir, configPath = createTempConfigFile(t, `
+[geo]
+providers = ["mmdb", "ip-api"]
+
+[geo.mmdb]
+city_path = "GeoLite2-City.mmdb"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if expected := filepath.Join(tempDir, "GeoLite2-City.mmdb"); conf.Geo.MMDB.CityPath != expected || conf.Geo.MMDB.ASNPath != "" {
+		t.Errorf("Expected the City database at %s and no ASN database, got %+v", expected, conf.Geo.MMDB)
+	}
+}
+
+func TestLoadConfig_Network(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[network]
+own_cidrs = ["203.0.113.0/24", "2001:db8::1"]
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if len(conf.Network.OwnCIDRs) != 2 || conf.Network.OwnCIDRs[1] != "2001:db8::1" {
+		t.Errorf("Expected two own networks, got %+v", conf.Network)
+	}
+}
+
+func TestLoadConfig_ThreatIntel(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[threat_intel]
+providers = ["abuseipdb"]
+
+[threat_intel.abuseipdb]
+api_key = "secret"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	intel := conf.ThreatIntel
+	if len(intel.Providers) != 1 || intel.AbuseIPDB.APIKey != "secret" {
+		t.Errorf("Expected the abuseipdb provider with its key, got %+v", intel)
+	}
+	if intel.TTL() != 7*24*time.Hour || intel.AbuseIPDB.PerDay != 1000 || intel.AbuseIPDB.MaxAgeDays != 90 || intel.VirusTotal.URL == "" {
+		t.Errorf("Expected the defaults to be kept, got %+v", intel)
+	}
+}
+
+func TestLoadConfig_Blocklists(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[[blocklists.feeds]]
+name = "spamhaus_drop"
+url = "https://www.spamhaus.org/drop/drop.txt"
+
+[[blocklists.feeds]]
+name = "local"
+path = "blocklist.txt"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	lists := conf.Blocklists
+	if lists.RefreshInterval() != 24*time.Hour || len(lists.Feeds) != 2 {
+		t.Fatalf("Expected two feeds refreshed daily, got %+v", lists)
+	}
+	if lists.Feeds[0].Path != "" || lists.Feeds[1].Path != filepath.Join(tempDir, "blocklist.txt") {
+		t.Errorf("Expected the file path to be relative to the config file, got %+v", lists.Feeds)
+	}
+}
+
+func TestLoadConfig_ScanDetection(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[scan_detection]
+vertical_ports = 50
+slow_targets = 0
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	scan := conf.ScanDetection
+	if scan.VerticalPorts != 50 || scan.SlowTargets != 0 {
+		t.Errorf("Expected the configured thresholds, got %+v", scan)
+	}
+	if scan.Window() != time.Minute || scan.HorizontalHosts != 10 || scan.SlowWindow() != 6*time.Hour {
+		t.Errorf("Expected defaults for the other settings, got %+v", scan)
+	}
+}
+
+func TestSyslogConfig_Location(t *testing.T) {
+	loc, err := SyslogConfig{Timezone: "America/Chicago"}.Location()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loc.String() != "America/Chicago" {
+		t.Errorf("Expected America/Chicago, got %s", loc)
+	}
+
+	if _, err := (SyslogConfig{Timezone: "Mars/Olympus_Mons"}).Location(); err == nil {
+		t.Error("Expected an error for an unknown time zone")
+	}
+}
+
+func TestParserConfig_FormatFor(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+[parser.inputs]
+syslog = "ufw"
+"/var/log/syslog" = "netfilter"
+`)
+	defer os.RemoveAll(tempDir)
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{[]string{"stdin"}, "auto"},
+		{[]string{"syslog"}, "ufw"},
+		{[]string{"/var/log/syslog", "follow"}, "netfilter"},
+		{[]string{"/var/log/other", "follow"}, "auto"},
+	}
+	for _, tc := range tests {
+		if format := conf.Parser.FormatFor(tc.inputs...); format != tc.expected {
+			t.Errorf("FormatFor(%v): expected %q, got %q", tc.inputs, tc.expected, format)
+		}
+	}
+}
+
+func TestLoadConfig_Rules(t *testing.T) {
+	tempDir, configPath := createTempConfigFile(t, `
+rules_file = "rules.toml"
+
+[[rules]]
+name = "ssh"
+severity = "medium"
+when = 'dst_port == 22'
+`)
+	defer os.RemoveAll(tempDir)
+
+	rulesTOML := `
+[[rules]]
+name = "portscan"
+severity = "high"
+when = 'reason in ["PORTSCAN"]'
+`
+	if err := os.WriteFile(filepath.Join(tempDir, "rules.toml"), []byte(rulesTOML), 0600); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+
+	if len(conf.Rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(conf.Rules))
+	}
+	if conf.Rules[0].Name != "ssh" || conf.Rules[1].Name != "portscan" {
+		t.Errorf("Expected rules ssh and portscan, got %+v", conf.Rules)
+	}
+	if conf.Rules[1].When != `reason in ["PORTSCAN"]` {
+		t.Errorf("Unexpected condition %q", conf.Rules[1].When)
+	}
+}
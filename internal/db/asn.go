@@ -0,0 +1,59 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"minerva/internal/store"
+)
+
+// TopASNs returns the autonomous systems with the most flagged entries. Without
+// a sensor or start time the ip_intel counters are summed; otherwise the
+// matching log_data entries are counted.
+func (s *Store) TopASNs(q store.ASNQuery) ([]store.ASNStats, error) {
+	var query string
+	var f *filter
+	if q.Sensor == "" && q.Since.IsZero() {
+		f = &filter{}
+		f.conds = append(f.conds, "g.asn IS NOT NULL", "i.total_hits > 0")
+		query = `
+			SELECT g.asn, MAX(g.as_org), COUNT(*), COUNT(*) FILTER (WHERE g.hosting), SUM(i.total_hits)
+			FROM ip_intel i
+			JOIN ip_geo g ON g.ip_address = i.ip_address` + f.where()
+	} else {
+		f = sensorFilter(q.Sensor, "l.sensor_id")
+		f.conds = append(f.conds, "g.asn IS NOT NULL")
+		if !q.Since.IsZero() {
+			f.add("l.timestamp >= ?", q.Since)
+		}
+		query = `
+			SELECT g.asn, MAX(g.as_org), COUNT(DISTINCT l.source_ip),
+				COUNT(DISTINCT l.source_ip) FILTER (WHERE g.hosting), COUNT(*)
+			FROM log_data l
+			JOIN ip_geo g ON g.ip_address = l.source_ip` + f.where()
+	}
+	query += `
+		GROUP BY g.asn
+		ORDER BY 5 DESC, g.asn
+		LIMIT ` + f.arg(q.Limit)
+
+	rows, err := s.DB.Query(query, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ASNs: %w", err)
+	}
+	defer rows.Close()
+
+	asns := []store.ASNStats{}
+	for rows.Next() {
+		var a store.ASNStats
+		var org sql.NullString
+		if err := rows.Scan(&a.ASN, &org, &a.Sources, &a.HostingSources, &a.Hits); err != nil {
+			return nil, fmt.Errorf("failed to read ASN: %w", err)
+		}
+		a.Org = org.String
+		asns = append(asns, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ASNs: %w", err)
+	}
+	return asns, nil
+}
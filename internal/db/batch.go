@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// InsertLogEntries inserts a batch of events in one transaction. The rows are
+// copied into a temporary staging table with COPY and moved into log_data with
+// a single INSERT ... SELECT, so duplicates are skipped by the unique_log_entry
+// constraint, including duplicates within the batch, and the ip_intel counters
+// are updated with the rows inserted. It returns the number of rows inserted;
+// the rest of the batch were duplicates. Any invalid event fails the whole
+// batch.
+func InsertLogEntries(db *sql.DB, events []parser.LogEvent) (rowsInserted int64, err error) {
+	rows := make([][]interface{}, len(events))
+	for i, ev := range events {
+		if rows[i], err = store.LogEntryValues(ev); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin batch insert: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	columns := strings.Join(store.LogEntryColumns, ", ")
+	_, err = tx.Exec(`CREATE TEMP TABLE log_data_batch ON COMMIT DROP AS SELECT ` + columns + ` FROM log_data WITH NO DATA`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("log_data_batch", store.LogEntryColumns...))
+	if err != nil {
+		return 0, fmt.Errorf("failed to start copy: %w", err)
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("failed to copy log entry: %w", err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, fmt.Errorf("failed to copy log entries: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish copy: %w", err)
+	}
+
+	err = tx.QueryRow(trackIntelSQL(`INSERT INTO log_data (` + columns + `)
+		SELECT ` + columns + ` FROM log_data_batch
+		ON CONFLICT ` + store.LogEntryConflict + ` DO NOTHING`)).Scan(&rowsInserted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert log entries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit batch insert: %w", err)
+	}
+	return rowsInserted, nil
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	"minerva/internal/blocklist"
+	"net/netip"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BlocklistsUpdatedAt returns when each stored list was last fetched, by name.
+func (s *Store) BlocklistsUpdatedAt() (map[string]time.Time, error) {
+	rows, err := s.DB.Query(`SELECT source, MAX(updated_at) FROM blocklist_entries GROUP BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	defer rows.Close()
+
+	updated := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("failed to read blocklists: %w", err)
+		}
+		updated[name] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	return updated, nil
+}
+
+// Blocklists returns the stored lists with their networks, ordered by name.
+func (s *Store) Blocklists() ([]blocklist.List, error) {
+	rows, err := s.DB.Query(`SELECT source, text(network), updated_at FROM blocklist_entries ORDER BY source, network`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []blocklist.List
+	for rows.Next() {
+		var name, network string
+		var at time.Time
+		if err := rows.Scan(&name, &network, &at); err != nil {
+			return nil, fmt.Errorf("failed to read blocklists: %w", err)
+		}
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blocklist %s: %w", name, err)
+		}
+		if len(lists) == 0 || lists[len(lists)-1].Name != name {
+			lists = append(lists, blocklist.List{Name: name})
+		}
+		l := &lists[len(lists)-1]
+		l.Networks = append(l.Networks, prefix)
+		if at.After(l.UpdatedAt) {
+			l.UpdatedAt = at
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	return lists, nil
+}
+
+// ReplaceBlocklist replaces the stored networks of a list in one transaction,
+// copying the new ones in with COPY. A list without networks is deleted.
+func (s *Store) ReplaceBlocklist(l blocklist.List) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to replace blocklist %s: %w", l.Name, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`DELETE FROM blocklist_entries WHERE source = $1`, l.Name); err != nil {
+		return fmt.Errorf("failed to delete blocklist %s: %w", l.Name, err)
+	}
+	if len(l.Networks) > 0 {
+		stmt, err := tx.Prepare(pq.CopyIn("blocklist_entries", "source", "network", "updated_at"))
+		if err != nil {
+			return fmt.Errorf("failed to start copy: %w", err)
+		}
+		for _, network := range l.Networks {
+			if _, err := stmt.Exec(l.Name, network.String(), l.UpdatedAt); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to copy blocklist %s: %w", l.Name, err)
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy blocklist %s: %w", l.Name, err)
+		}
+		if err := stmt.Close(); err != nil {
+			return fmt.Errorf("failed to finish copy: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace blocklist %s: %w", l.Name, err)
+	}
+	return nil
+}
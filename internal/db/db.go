@@ -2,8 +2,12 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"strings"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
@@ -26,47 +30,38 @@ func Connect(host, port, user, password, dbname string) (*sql.DB, error) {
 	return db, nil
 }
 
-// InsertLogEntry inserts a new log entry into the log_data table, including new fields.
-func InsertLogEntry(db *sql.DB, timestamp, sourceIP, destinationIP, protocol, action, reason string,
-	sourcePort, destinationPort, packetLength, ttl int) (rowsInserted int64, err error) {
-
-	// Basic validation to enforce mandatory fields.
-	if timestamp == "" || timestamp == "unknown" {
-		return 0, fmt.Errorf("invalid timestamp")
-	}
-	if destinationIP == "" || destinationIP == "unknown" {
-		return 0, fmt.Errorf("invalid destination IP")
+// InsertSensor registers a sensor in the sensors table if it is not there yet.
+// Log entries reference their sensor, so it must exist before they are inserted.
+func InsertSensor(db *sql.DB, id string) error {
+	_, err := db.Exec(`INSERT INTO sensors (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, id)
+	if err != nil {
+		return fmt.Errorf("failed to insert sensor %s: %w", id, err)
 	}
+	return nil
+}
 
-	insertSQL := `
-        INSERT INTO log_data (
-            timestamp, source_ip, destination_ip, protocol,
-            source_port, destination_port, action, reason,
-            packet_length, ttl
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-        ON CONFLICT (timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
-        DO NOTHING;
-    `
-	result, errExec := db.Exec(insertSQL,
-		timestamp,
-		sourceIP,
-		destinationIP,
-		protocol,
-		sourcePort,
-		destinationPort,
-		action,
-		reason,
-		packetLength,
-		ttl,
-	)
-	if errExec != nil {
-		return 0, fmt.Errorf("failed to insert log entry: %w", errExec)
+// InsertLogEntry inserts a parsed log event into the log_data table, along with
+// the names of the rules that flagged it and the reporting device from its
+// syslog header, and adds it to the ip_intel counters of its source address.
+// Optional header fields are stored as JSON in the header column. Events
+// without a sensor are stored under store.DefaultSensor.
+func InsertLogEntry(db *sql.DB, ev parser.LogEvent) (rowsInserted int64, err error) {
+	values, err := store.LogEntryValues(ev)
+	if err != nil {
+		return 0, err
 	}
 
-	// Get the number of affected rows
-	rowsInserted, errRowsAffected := result.RowsAffected()
-	if errRowsAffected != nil {
-		return 0, fmt.Errorf("failed to retrieve affected row count: %w", errRowsAffected)
+	placeholders := make([]string, len(values))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insertSQL := trackIntelSQL(`
+        INSERT INTO log_data (` + strings.Join(store.LogEntryColumns, ", ") + `)
+        VALUES (` + strings.Join(placeholders, ", ") + `)
+        ON CONFLICT ` + store.LogEntryConflict + `
+        DO NOTHING`)
+	if err := db.QueryRow(insertSQL, values...).Scan(&rowsInserted); err != nil {
+		return 0, fmt.Errorf("failed to insert log entry: %w", err)
 	}
 	return rowsInserted, nil
 }
@@ -87,12 +82,25 @@ func (h *Handler) IsIPInGeoTable(ip string) (bool, error) {
 	return exists, nil
 }
 
-// InsertOrUpdateGeoData inserts or updates geolocation data for an IP address.
+// InsertOrUpdateGeoData inserts or updates geolocation data for an IP address,
+// and clears its failed lookup. The address is added to ip_intel first if its
+// entries have not been inserted yet.
 func (h *Handler) InsertOrUpdateGeoData(ip string, geoData *geo.Data) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO ip_intel (ip_address) VALUES ($1) ON CONFLICT DO NOTHING`, ip); err != nil {
+		return fmt.Errorf("failed to insert intel for IP %s: %w", ip, err)
+	}
+
 	insertSQL := `
     INSERT INTO ip_geo (
-        ip_address, country, region, city, isp, latitude, longitude, last_updated
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+        ip_address, country, region, city, isp, latitude, longitude,
+        asn, as_org, as_prefix, hosting, last_updated
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
     ON CONFLICT (ip_address) DO UPDATE SET 
         country = EXCLUDED.country,
         region = EXCLUDED.region,
@@ -100,11 +108,56 @@ func (h *Handler) InsertOrUpdateGeoData(ip string, geoData *geo.Data) error {
         isp = EXCLUDED.isp,
         latitude = EXCLUDED.latitude,
         longitude = EXCLUDED.longitude,
+        asn = EXCLUDED.asn,
+        as_org = EXCLUDED.as_org,
+        as_prefix = EXCLUDED.as_prefix,
+        hosting = EXCLUDED.hosting,
         last_updated = NOW();`
 
-	_, err := h.DB.Exec(insertSQL, ip, geoData.Country, geoData.Region, geoData.City, geoData.ISP, geoData.Latitude, geoData.Longitude)
+	_, err = tx.Exec(insertSQL, append([]interface{}{ip}, store.GeoValues(geoData)...)...)
 	if err != nil {
 		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
 	}
+	if _, err := tx.Exec(`DELETE FROM geo_failures WHERE ip_address = $1`, ip); err != nil {
+		return fmt.Errorf("failed to clear failed lookup of IP %s: %w", ip, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
+	}
+	return nil
+}
+
+// GeoFailure returns the last failed lookup of ip, or nil if there is none.
+func (h *Handler) GeoFailure(ip string) (*geo.Failure, error) {
+	var f geo.Failure
+	var retryAfter sql.NullTime
+	err := h.DB.QueryRow(`
+		SELECT reason, permanent, attempts, last_attempt, retry_after
+		FROM geo_failures WHERE ip_address = $1`, ip).Scan(&f.Reason, &f.Permanent, &f.Attempts, &f.LastAttempt, &retryAfter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed lookup of IP %s: %w", ip, err)
+	}
+	f.RetryAfter = retryAfter.Time
+	return &f, nil
+}
+
+// RecordGeoFailure stores a failed lookup of ip, replacing the previous one.
+func (h *Handler) RecordGeoFailure(ip string, f geo.Failure) error {
+	_, err := h.DB.Exec(`
+    INSERT INTO geo_failures (ip_address, reason, permanent, attempts, last_attempt, retry_after)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (ip_address) DO UPDATE SET
+        reason = excluded.reason,
+        permanent = excluded.permanent,
+        attempts = excluded.attempts,
+        last_attempt = excluded.last_attempt,
+        retry_after = excluded.retry_after`,
+		ip, f.Reason, f.Permanent, f.Attempts, f.LastAttempt, sql.NullTime{Time: f.RetryAfter, Valid: !f.RetryAfter.IsZero()})
+	if err != nil {
+		return fmt.Errorf("failed to record failed lookup of IP %s: %w", ip, err)
+	}
 	return nil
 }
@@ -2,8 +2,16 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"minerva/internal/blocklist"
 	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"minerva/internal/threatintel"
+	"net/netip"
+	"sort"
 	"testing"
 	"time"
 )
@@ -90,48 +98,103 @@ func TestInsertLogEntry(t *testing.T) {
 
 	// Truncate the log_data table before testing.
 	truncateTable(t, db, "log_data")
+	if err := NewPartitioner(db, store.Monthly).Ensure(time.Now()); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+
+	if err := InsertSensor(db, "test-site"); err != nil {
+		t.Fatalf("Failed to register sensor: %v", err)
+	}
 
 	testCases := []struct {
 		name      string
-		timestamp string
-		sourceIP  string
-		destIP    string
-		protocol  string
-		action    string
-		reason    string
-		packetLen int
-		ttl       int
+		event     parser.LogEvent
 		expectErr bool
 	}{
 		{
-			name:      "Valid entry",
-			timestamp: time.Now().Format(time.RFC3339),
-			sourceIP:  "192.0.2.1",
-			destIP:    "203.0.113.5",
-			protocol:  "TCP",
-			action:    "ALLOW",
-			reason:    "Routine test",
-			packetLen: 128,
-			ttl:       64,
+			name: "Valid entry",
+			event: parser.LogEvent{
+				Timestamp:       time.Now(),
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "ALLOW",
+				Reason:          "Routine test",
+				PacketLength:    128,
+				TTL:             64,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Registered sensor",
+			event: parser.LogEvent{
+				Sensor:          "test-site",
+				Timestamp:       time.Now(),
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "DROP",
+			},
 			expectErr: false,
 		},
 		{
-			name:      "Missing destination IP",
-			timestamp: time.Now().Format(time.RFC3339),
-			sourceIP:  "192.0.2.1",
-			destIP:    "",
-			protocol:  "TCP",
-			action:    "DROP",
-			reason:    "No destination",
-			packetLen: 64,
-			ttl:       128,
+			name: "Unregistered sensor",
+			event: parser.LogEvent{
+				Sensor:        "no-such-site",
+				Timestamp:     time.Now(),
+				SourceIP:      netip.MustParseAddr("192.0.2.1"),
+				DestinationIP: netip.MustParseAddr("203.0.113.5"),
+				Protocol:      "TCP",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Missing source IP",
+			event: parser.LogEvent{
+				Timestamp:       time.Now(),
+				DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "DROP",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Missing destination IP",
+			event: parser.LogEvent{
+				Timestamp:       time.Now(),
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "DROP",
+				Reason:          "No destination",
+				PacketLength:    64,
+				TTL:             128,
+			},
+			expectErr: true,
+		},
+		{
+			name: "Missing timestamp",
+			event: parser.LogEvent{
+				SourceIP:      netip.MustParseAddr("192.0.2.1"),
+				DestinationIP: netip.MustParseAddr("203.0.113.5"),
+				Protocol:      "TCP",
+				Action:        "DROP",
+				Reason:        "No timestamp",
+			},
 			expectErr: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := InsertLogEntry(db, tc.timestamp, tc.sourceIP, tc.destIP, tc.protocol, tc.action, tc.reason, 12345, 80, tc.packetLen, tc.ttl)
+			_, err := InsertLogEntry(db, tc.event)
 			if (err != nil) != tc.expectErr {
 				t.Errorf("Test %q: expected error: %v, got: %v", tc.name, tc.expectErr, err)
 			}
@@ -139,6 +202,53 @@ func TestInsertLogEntry(t *testing.T) {
 	}
 }
 
+func TestInsertLogEntries(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "log_data")
+	if err := NewPartitioner(db, store.Monthly).Ensure(time.Now()); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+
+	ev := parser.LogEvent{
+		Timestamp:       time.Now(),
+		SourceIP:        netip.MustParseAddr("192.0.2.1"),
+		DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+		SourcePort:      12345,
+		DestinationPort: 80,
+		Protocol:        "TCP",
+		Action:          "DROP",
+	}
+	other := ev
+	other.DestinationPort = 443
+
+	// The duplicate within the batch is skipped.
+	inserted, err := InsertLogEntries(db, []parser.LogEvent{ev, ev, other})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("Expected 2 rows inserted, got %d", inserted)
+	}
+
+	// So are events already stored.
+	inserted, err = InsertLogEntries(db, []parser.LogEvent{ev, other})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inserted != 0 {
+		t.Errorf("Expected no rows inserted, got %d", inserted)
+	}
+
+	if _, err := InsertLogEntries(db, []parser.LogEvent{ev, {}}); err == nil {
+		t.Error("Expected an error for a batch with an invalid event")
+	}
+}
+
 func TestGeoDataInsertion(t *testing.T) {
 	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
 	if err != nil {
@@ -173,3 +283,371 @@ func TestGeoDataInsertion(t *testing.T) {
 		}
 	})
 }
+
+func TestIPProfile(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "log_data")
+	truncateTable(t, db, "ip_intel")
+	s := NewStore(db, store.Monthly)
+
+	ts := time.Now().Truncate(time.Microsecond)
+	ev := parser.LogEvent{
+		Timestamp:       ts,
+		SourceIP:        netip.MustParseAddr("192.0.2.1"),
+		DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+		DestinationPort: 443,
+		Protocol:        "TCP",
+		Action:          "DROP",
+		Reason:          "PORTSCAN",
+		SourceClass:     "bogon",
+	}
+	later := ev
+	later.Timestamp = ts.Add(time.Minute)
+	later.DestinationPort = 22
+	later.Reason = "DROP-IN"
+
+	// The duplicate is not counted.
+	if _, err := s.InsertLogEntries([]parser.LogEvent{ev, ev}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.InsertLogEntry(later); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	profile, err := s.IPProfile("192.0.2.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.TotalHits != 2 || profile.Class != "bogon" || !profile.FirstSeen.Equal(ts) || !profile.LastSeen.Equal(later.Timestamp) {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+	if fmt.Sprint(profile.DestinationPorts) != "[22 443]" || fmt.Sprint(profile.Reasons) != "[DROP-IN PORTSCAN]" {
+		t.Errorf("Unexpected ports %v and reasons %v", profile.DestinationPorts, profile.Reasons)
+	}
+
+	// The class is that of the latest entry, not of the last one inserted.
+	newer, older := ev, ev
+	newer.SourceIP, newer.SourceClass = netip.MustParseAddr("198.51.100.9"), "bogon"
+	older.SourceIP, older.SourceClass, older.Timestamp = newer.SourceIP, "public", ts.Add(-time.Hour)
+	oldest := older
+	oldest.SourceClass, oldest.Timestamp = "own", ts.Add(-2*time.Hour)
+	if _, err := s.InsertLogEntries([]parser.LogEvent{newer, older}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.InsertLogEntry(oldest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile, err := s.IPProfile("198.51.100.9", ""); err != nil || profile.Class != "bogon" {
+		t.Errorf("Expected the class of the latest entry, got %+v, %v", profile, err)
+	}
+
+	// Ports are recorded up to store.MaxIntelPorts.
+	var events []parser.LogEvent
+	for port := store.MaxIntelPorts + 10; port > 0; port-- {
+		e := ev
+		e.SourceIP, e.DestinationPort = netip.MustParseAddr("198.51.100.10"), uint16(port)
+		events = append(events, e)
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	profile, err = s.IPProfile("198.51.100.10", "")
+	if err != nil || len(profile.DestinationPorts) != store.MaxIntelPorts || !sort.IntsAreSorted(profile.DestinationPorts) {
+		t.Errorf("Expected %d sorted ports, got %v, %v", store.MaxIntelPorts, profile, err)
+	}
+
+	if _, err := s.IPProfile("198.51.100.7", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTopASNs(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "log_data")
+	truncateTable(t, db, "ip_intel")
+	s := NewStore(db, store.Monthly)
+
+	ts := time.Now().Truncate(time.Microsecond)
+	var events []parser.LogEvent
+	for i, ip := range []string{"192.0.2.1", "192.0.2.1", "192.0.2.2", "198.51.100.1"} {
+		events = append(events, parser.LogEvent{
+			Timestamp:       ts.Add(time.Duration(i) * time.Minute),
+			SourceIP:        netip.MustParseAddr(ip),
+			DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+			DestinationPort: 443,
+			Protocol:        "TCP",
+			Action:          "DROP",
+		})
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for ip, data := range map[string]*geo.Data{
+		"192.0.2.1":    {ASN: 64500, ASOrg: "Example Hosting", ASPrefix: "192.0.2.0/24", Hosting: true},
+		"192.0.2.2":    {ASN: 64500, ASOrg: "Example Hosting"},
+		"198.51.100.1": {ASN: 64501, ASOrg: "Other"},
+	} {
+		if err := s.InsertOrUpdateGeoData(ip, data); err != nil {
+			t.Fatalf("Failed to insert geolocation data: %v", err)
+		}
+	}
+
+	expected := "[{64500 Example Hosting 2 1 3} {64501 Other 1 0 1}]"
+	for _, q := range []store.ASNQuery{{Limit: 10}, {Sensor: store.DefaultSensor, Limit: 10}} {
+		asns, err := s.TopASNs(q)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if fmt.Sprint(asns) != expected {
+			t.Errorf("TopASNs(%+v): expected %s, got %v", q, expected, asns)
+		}
+	}
+
+	if data, err := s.Geo("192.0.2.1", ""); err != nil || data.ASPrefix != "192.0.2.0/24" || !data.Hosting {
+		t.Errorf("Expected the AS prefix and hosting flag, got %+v, %v", data, err)
+	}
+}
+
+func TestGeoFailures(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "geo_failures")
+	handler := &Handler{DB: db}
+
+	now := time.Now().Truncate(time.Microsecond)
+	failure := geo.Failure{Reason: "private range", Permanent: true, Attempts: 1, LastAttempt: now, RetryAfter: now.Add(time.Hour)}
+	if err := handler.RecordGeoFailure("10.0.0.1", failure); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f, err := handler.GeoFailure("10.0.0.1")
+	if err != nil || f == nil || f.Reason != failure.Reason || f.Attempts != 1 || !f.RetryAfter.Equal(failure.RetryAfter) {
+		t.Fatalf("Expected %+v, got %+v, %v", failure, f, err)
+	}
+
+	if err := handler.InsertOrUpdateGeoData("10.0.0.1", &geo.Data{Country: "Germany"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	if f, err := handler.GeoFailure("10.0.0.1"); err != nil || f != nil {
+		t.Errorf("Expected the failure to be cleared, got %+v, %v", f, err)
+	}
+}
+
+func TestReputation(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "log_data")
+	truncateTable(t, db, "ip_intel")
+	s := NewStore(db, store.Monthly)
+
+	ts := time.Now().Truncate(time.Microsecond)
+	ev := parser.LogEvent{
+		Timestamp:       ts,
+		SourceIP:        netip.MustParseAddr("192.0.2.1"),
+		DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+		DestinationPort: 22,
+		Protocol:        "TCP",
+		Action:          "DROP",
+	}
+	if _, err := s.InsertLogEntry(ev); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reported := ts.Add(-time.Hour)
+	for _, r := range []*threatintel.Report{
+		{Provider: "virustotal", Score: 10, Categories: []string{"malware"}, FetchedAt: ts},
+		{Provider: "abuseipdb", Score: 40, Categories: []string{"port-scan"}, FetchedAt: ts},
+		{Provider: "abuseipdb", Score: 87, Categories: []string{"port-scan", "ssh"}, LastReported: &reported, FetchedAt: ts.Add(time.Minute)},
+	} {
+		if err := s.InsertOrUpdateReputation("192.0.2.1", r); err != nil {
+			t.Fatalf("Failed to insert reputation: %v", err)
+		}
+	}
+
+	if fetched, err := s.ReputationFetchedAt("192.0.2.1", "abuseipdb"); err != nil || !fetched.Equal(ts.Add(time.Minute)) {
+		t.Errorf("Expected the latest fetch time, got %v, %v", fetched, err)
+	}
+	reports, err := s.Reputation("192.0.2.1", "")
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("Expected a report per provider, got %+v, %v", reports, err)
+	}
+	if reports[0].Provider != "abuseipdb" || reports[0].Score != 87 || fmt.Sprint(reports[0].Categories) != "[port-scan ssh]" ||
+		reports[0].LastReported == nil || !reports[0].LastReported.Equal(reported) {
+		t.Errorf("Expected the replaced AbuseIPDB report, got %+v", reports[0])
+	}
+
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 1 || logs[0].ThreatScore == nil || *logs[0].ThreatScore != 87 {
+		t.Errorf("Expected the highest score on the entry, got %+v, %v", logs, err)
+	}
+
+	// Reports can arrive before the entries are inserted.
+	if err := s.InsertOrUpdateReputation("198.51.100.7", &threatintel.Report{Provider: "abuseipdb", Categories: []string{}, FetchedAt: ts}); err != nil {
+		t.Fatalf("Failed to insert reputation: %v", err)
+	}
+	if reports, err := s.Reputation("198.51.100.7", store.DefaultSensor); err != nil || len(reports) != 0 {
+		t.Errorf("Expected no reports for an IP not in the sensor's logs, got %+v, %v", reports, err)
+	}
+}
+
+func TestBlocklists(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "blocklist_entries")
+	truncateTable(t, db, "log_data")
+	s := NewStore(db, store.Monthly)
+
+	updated := time.Now().Truncate(time.Microsecond)
+	drop := blocklist.List{
+		Name:      "spamhaus_drop",
+		Networks:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8::/32")},
+		UpdatedAt: updated,
+	}
+	if err := s.ReplaceBlocklist(drop); err != nil {
+		t.Fatalf("Failed to store blocklist: %v", err)
+	}
+	drop.Networks = drop.Networks[:1]
+	if err := s.ReplaceBlocklist(drop); err != nil {
+		t.Fatalf("Failed to store blocklist: %v", err)
+	}
+
+	lists, err := s.Blocklists()
+	if err != nil || len(lists) != 1 || fmt.Sprint(lists[0].Networks) != "[203.0.113.0/24]" || !lists[0].UpdatedAt.Equal(updated) {
+		t.Errorf("Expected the replaced list, got %+v, %v", lists, err)
+	}
+	if updatedAt, err := s.BlocklistsUpdatedAt(); err != nil || !updatedAt["spamhaus_drop"].Equal(updated) {
+		t.Errorf("Expected when the list was fetched, got %v, %v", updatedAt, err)
+	}
+
+	ev := parser.LogEvent{
+		Timestamp:       updated,
+		SourceIP:        netip.MustParseAddr("203.0.113.7"),
+		DestinationIP:   netip.MustParseAddr("198.51.100.5"),
+		DestinationPort: 22,
+		Protocol:        "TCP",
+		Action:          "DROP",
+		Blocklists:      []string{"spamhaus_drop"},
+	}
+	if _, err := s.InsertLogEntries([]parser.LogEvent{ev}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 1 || fmt.Sprint(logs[0].Blocklists) != "[spamhaus_drop]" {
+		t.Errorf("Expected the entry's blocklists, got %+v, %v", logs, err)
+	}
+}
+
+func TestIncidents(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "incidents")
+	s := NewStore(db, store.Monthly)
+
+	start := time.Now().Truncate(time.Microsecond)
+	incidents := []scan.Incident{
+		{Sensor: store.DefaultSensor, SourceIP: netip.MustParseAddr("203.0.113.7"), Type: scan.Vertical,
+			Start: start, End: start.Add(30 * time.Second), Ports: []int{22, 80, 443}, Hosts: 1, Hits: 25},
+		{Sensor: store.DefaultSensor, SourceIP: netip.MustParseAddr("2001:db8::7"), Type: scan.Slow,
+			Start: start.Add(time.Hour), End: start.Add(7 * time.Hour), Ports: []int{3389}, Hosts: 40, Hits: 40},
+	}
+	for _, inc := range incidents {
+		if err := s.InsertIncident(inc); err != nil {
+			t.Fatalf("Failed to store incident: %v", err)
+		}
+	}
+	// Reading the same logs again finds the same scan, which is merged.
+	again := incidents[0]
+	again.End, again.Ports, again.Hits = start.Add(40*time.Second), []int{22, 8080}, 30
+	if err := s.InsertIncident(again); err != nil {
+		t.Fatalf("Failed to store incident: %v", err)
+	}
+
+	got, err := s.Incidents(store.IncidentQuery{Limit: 10})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected two incidents, got %+v, %v", got, err)
+	}
+	slow := got[0]
+	if slow.Type != scan.Slow || slow.SourceIP != incidents[1].SourceIP || !slow.Start.Equal(incidents[1].Start) ||
+		fmt.Sprint(slow.Ports) != "[3389]" || slow.Hosts != 40 || slow.Hits != 40 {
+		t.Errorf("Expected the slow scan first, got %+v", slow)
+	}
+	got, err = s.Incidents(store.IncidentQuery{SourceCIDR: netip.MustParsePrefix("203.0.113.0/24"), Type: scan.Vertical, Limit: 10})
+	if err != nil || len(got) != 1 || fmt.Sprint(got[0].Ports) != "[22 80 443 8080]" || got[0].Hits != 30 || !got[0].End.Equal(again.End) {
+		t.Errorf("Expected the vertical scan, got %+v, %v", got, err)
+	}
+}
+
+func TestDropPartitionIntel(t *testing.T) {
+	db, err := Connect(testHost, testPort, testUser, testPassword, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	defer db.Close()
+
+	truncateTable(t, db, "log_data")
+	truncateTable(t, db, "ip_intel")
+	s := NewStore(db, store.Monthly)
+
+	jan := time.Date(2020, 1, 15, 10, 30, 0, 0, time.UTC)
+	mar := time.Date(2020, 3, 2, 8, 0, 0, 0, time.UTC)
+	var events []parser.LogEvent
+	for i, ts := range []time.Time{jan, mar} {
+		events = append(events, parser.LogEvent{
+			Timestamp:       ts,
+			SourceIP:        netip.MustParseAddr("192.0.2.1"),
+			DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+			DestinationPort: uint16(22 + i),
+			Protocol:        "TCP",
+			Action:          "DROP",
+		})
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	partitions, err := s.Partitions()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, p := range partitions {
+		if p.From.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			if err := s.DropPartition(p); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+
+	// The profile of the source address only covers the remaining entry.
+	profile, err := s.IPProfile("192.0.2.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.TotalHits != 1 || !profile.FirstSeen.Equal(mar) || fmt.Sprint(profile.DestinationPorts) != "[23]" {
+		t.Errorf("Expected the profile of the March entry, got %+v", profile)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// filter builds the WHERE clause of a query from optional conditions, keeping
+// the placeholders numbered in the order their arguments were added.
+type filter struct {
+	conds []string
+	args  []interface{}
+}
+
+// add appends a condition whose single "?" is replaced by the placeholder for arg.
+func (f *filter) add(cond string, arg interface{}) {
+	f.conds = append(f.conds, strings.Replace(cond, "?", f.arg(arg), 1))
+}
+
+// arg adds an argument that is not part of the WHERE clause, such as a limit,
+// and returns its placeholder.
+func (f *filter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return "$" + strconv.Itoa(len(f.args))
+}
+
+// where returns the WHERE clause, or "" when there are no conditions.
+func (f *filter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// sensorFilter restricts a query to sensor, if it is not empty, using column
+// as the sensor ID.
+func sensorFilter(sensor, column string) *filter {
+	f := &filter{}
+	if sensor != "" {
+		f.add(column+" = ?", sensor)
+	}
+	return f
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSensorFilter(t *testing.T) {
+	tests := []struct {
+		sensor   string
+		where    string
+		limit    string
+		expected []interface{}
+	}{
+		{"", "", "$1", []interface{}{50}},
+		{"home", " WHERE sensor_id = $1", "$2", []interface{}{"home", 50}},
+	}
+
+	for _, tc := range tests {
+		f := sensorFilter(tc.sensor, "sensor_id")
+		if where := f.where(); where != tc.where {
+			t.Errorf("%q: expected WHERE clause %q, got %q", tc.sensor, tc.where, where)
+		}
+		if limit := f.arg(50); limit != tc.limit {
+			t.Errorf("%q: expected placeholder %s, got %s", tc.sensor, tc.limit, limit)
+		}
+		if !reflect.DeepEqual(f.args, tc.expected) {
+			t.Errorf("%q: expected arguments %v, got %v", tc.sensor, tc.expected, f.args)
+		}
+	}
+}
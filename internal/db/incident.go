@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"minerva/internal/parser"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// InsertIncident stores a completed incident. An incident already stored with
+// the same sensor, source, type and start, found again when logs are read
+// again, is merged with it: their ports are combined and the later end is
+// kept, but the events behind the counts are not, so hosts and hits are the
+// larger of the two. They are lower bounds for incidents that only overlap.
+func (h *Handler) InsertIncident(inc scan.Incident) error {
+	ports := make([]int64, len(inc.Ports))
+	for i, port := range inc.Ports {
+		ports[i] = int64(port)
+	}
+	_, err := h.DB.Exec(`
+    INSERT INTO incidents (sensor_id, source_ip, scan_type, start_time, end_time, ports, hosts, hits)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    ON CONFLICT (sensor_id, source_ip, scan_type, start_time) DO UPDATE SET
+        end_time = GREATEST(incidents.end_time, EXCLUDED.end_time),
+        ports = ARRAY(SELECT DISTINCT unnest(incidents.ports || EXCLUDED.ports) ORDER BY 1),
+        hosts = GREATEST(incidents.hosts, EXCLUDED.hosts),
+        hits = GREATEST(incidents.hits, EXCLUDED.hits)`,
+		inc.Sensor, inc.SourceIP.String(), string(inc.Type), inc.Start, inc.End, pq.Array(ports), inc.Hosts, inc.Hits)
+	if err != nil {
+		return fmt.Errorf("failed to insert incident for IP %s: %w", inc.SourceIP, err)
+	}
+	return nil
+}
+
+// Incidents returns detected scans, latest start first.
+func (s *Store) Incidents(q store.IncidentQuery) ([]scan.Incident, error) {
+	f := sensorFilter(q.Sensor, "sensor_id")
+	if q.SourceCIDR.IsValid() {
+		f.add("source_ip <<= ?::inet", q.SourceCIDR.Masked().String())
+	}
+	if q.Type != "" {
+		f.add("scan_type = ?", string(q.Type))
+	}
+	if !q.Since.IsZero() {
+		f.add("end_time >= ?", q.Since)
+	}
+	query := `
+		SELECT id, sensor_id, host(source_ip), scan_type, start_time, end_time, ports, hosts, hits
+		FROM incidents` + f.where() +
+		` ORDER BY start_time DESC, id DESC LIMIT ` + f.arg(q.Limit) + ` OFFSET ` + f.arg(q.Offset)
+	rows, err := s.DB.Query(query, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []scan.Incident{}
+	for rows.Next() {
+		var inc scan.Incident
+		var ip, scanType string
+		var ports []int64
+		if err := rows.Scan(&inc.ID, &inc.Sensor, &ip, &scanType, &inc.Start, &inc.End, pq.Array(&ports), &inc.Hosts, &inc.Hits); err != nil {
+			return nil, fmt.Errorf("failed to read incident: %w", err)
+		}
+		inc.Type = scan.Type(scanType)
+		if inc.SourceIP, err = parser.ParseAddr(ip); err != nil {
+			return nil, fmt.Errorf("failed to read incident %d: %w", inc.ID, err)
+		}
+		inc.Ports = make([]int, len(ports))
+		for i, port := range ports {
+			inc.Ports[i] = int(port)
+		}
+		incidents = append(incidents, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	return incidents, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minerva/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// trackIntelSQL turns insert, an INSERT into log_data, into a statement that
+// also adds the rows it inserts to the ip_intel counters of their source
+// addresses, and returns the number of rows inserted. Skipped duplicates are
+// not counted, nor is port 0, as logged for ICMP. The class of an address is
+// that of its entry with the latest timestamp, whatever order they arrive in.
+// New destination ports are appended until there are store.MaxIntelPorts, and
+// the stored ones are left as they are.
+func trackIntelSQL(insert string) string {
+	maxPorts := strconv.Itoa(store.MaxIntelPorts)
+	return `
+        WITH inserted AS (` + insert + `
+            RETURNING source_ip, timestamp, destination_port, reason, source_class
+        ), intel AS (
+            INSERT INTO ip_intel AS i (ip_address, class, first_seen, last_seen, total_hits, destination_ports, reasons)
+            SELECT source_ip,
+                (array_agg(source_class ORDER BY timestamp DESC) FILTER (WHERE source_class IS NOT NULL))[1],
+                MIN(timestamp), MAX(timestamp), COUNT(*),
+                COALESCE((array_agg(DISTINCT destination_port) FILTER (WHERE destination_port > 0))[1:` + maxPorts + `], '{}'),
+                COALESCE(array_agg(DISTINCT reason) FILTER (WHERE reason IS NOT NULL), '{}')
+            FROM inserted
+            GROUP BY source_ip
+            ON CONFLICT (ip_address) DO UPDATE SET
+                class = CASE WHEN i.last_seen IS NULL OR EXCLUDED.last_seen >= i.last_seen
+                    THEN COALESCE(EXCLUDED.class, i.class) ELSE COALESCE(i.class, EXCLUDED.class) END,
+                first_seen = LEAST(i.first_seen, EXCLUDED.first_seen),
+                last_seen = GREATEST(i.last_seen, EXCLUDED.last_seen),
+                total_hits = i.total_hits + EXCLUDED.total_hits,
+                destination_ports = CASE
+                    WHEN EXCLUDED.destination_ports <@ i.destination_ports OR cardinality(i.destination_ports) >= ` + maxPorts + `
+                    THEN i.destination_ports
+                    ELSE i.destination_ports || ARRAY(
+                        SELECT p FROM unnest(EXCLUDED.destination_ports) p WHERE p <> ALL (i.destination_ports)
+                        ORDER BY p LIMIT ` + maxPorts + ` - cardinality(i.destination_ports))
+                END,
+                reasons = ARRAY(SELECT DISTINCT r FROM unnest(i.reasons || EXCLUDED.reasons) r ORDER BY r)
+        )
+        SELECT COUNT(*) FROM inserted`
+}
+
+// IPProfile returns the activity and geolocation data recorded for ip. With a
+// sensor, only addresses that appear in that sensor's logs are found; the
+// counters still cover every sensor.
+func (s *Store) IPProfile(ip, sensor string) (*store.IPProfile, error) {
+	query := `
+		SELECT i.class, i.first_seen, i.last_seen, i.total_hits, i.destination_ports, i.reasons,
+			g.ip_address IS NOT NULL, ` + strings.Join(store.GeoColumns, ", ") + `
+		FROM ip_intel i
+		LEFT JOIN ip_geo g ON g.ip_address = i.ip_address
+		WHERE i.ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+
+	profile := store.IPProfile{IP: ip}
+	var class sql.NullString
+	var first, last sql.NullTime
+	var ports []int64
+	var hasGeo bool
+	var row store.GeoRow
+	dest := append([]interface{}{&class, &first, &last, &profile.TotalHits, pq.Array(&ports), pq.Array(&profile.Reasons), &hasGeo}, row.Dest()...)
+	err := s.DB.QueryRow(query, args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intel for IP %s: %w", ip, err)
+	}
+
+	profile.Class = class.String
+	if first.Valid {
+		profile.FirstSeen, profile.LastSeen = &first.Time, &last.Time
+	}
+	profile.DestinationPorts = make([]int, len(ports))
+	for i, port := range ports {
+		profile.DestinationPorts[i] = int(port)
+	}
+	sort.Ints(profile.DestinationPorts) // Kept in the order they were first seen
+	if profile.Reasons == nil {
+		profile.Reasons = []string{}
+	}
+	if hasGeo {
+		profile.Geo = row.Data()
+	}
+	return &profile, nil
+}
+
+// StaleGeoIPs returns up to limit addresses, or all if limit is 0, whose
+// geolocation data was last updated before cutoff, most recently seen first.
+// Addresses that are not public, and those whose last failed lookup is not due
+// for a retry, are left out.
+func (s *Store) StaleGeoIPs(cutoff time.Time, limit int) ([]string, error) {
+	rows, err := s.DB.Query(`
+		SELECT host(g.ip_address)
+		FROM ip_geo g
+		JOIN ip_intel i ON i.ip_address = g.ip_address
+		LEFT JOIN geo_failures f ON f.ip_address = g.ip_address
+		WHERE (g.last_updated IS NULL OR g.last_updated < $1)
+			AND COALESCE(i.class, 'public') = 'public'
+			AND (f.ip_address IS NULL OR f.retry_after <= NOW())
+		ORDER BY i.last_seen DESC NULLS LAST, g.last_updated NULLS FIRST
+		LIMIT $2`, cutoff, sql.NullInt64{Int64: int64(limit), Valid: limit > 0})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale geolocation data: %w", err)
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, fmt.Errorf("failed to read stale geolocation data: %w", err)
+		}
+		ips = append(ips, ip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stale geolocation data: %w", err)
+	}
+	return ips, nil
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"time"
+
+	"minerva/internal/store"
+)
+
+// migrationFiles holds the schema migrations, named NNNN_description.up.sql and
+// NNNN_description.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]store.Migration, error) {
+	return store.LoadMigrations(migrationFiles, "migrations")
+}
+
+// createMigrationsTable creates the table recording applied migrations.
+const createMigrationsTable = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+    )`
+
+// SchemaVersion returns the version of the last migration applied to db, or 0
+// for a database that has never been migrated.
+func SchemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check for schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchema returns an error wrapping ErrSchemaOutdated unless every embedded
+// migration has been applied to db. A schema newer than this binary is also
+// rejected, since the binary may not know how to use it.
+func CheckSchema(db *sql.DB) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	return store.CheckVersion(version, migrations)
+}
+
+// MigrateUp applies every pending migration, each in its own transaction, and
+// returns those it applied.
+func MigrateUp(db *sql.DB) ([]store.Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []store.Migration
+	for _, m := range migrations {
+		ok, err := migrate(db, m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps migrations, newest first, and returns
+// those it reverted.
+func MigrateDown(db *sql.DB, steps int) ([]store.Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this binary", version)
+	}
+
+	var reverted []store.Migration
+	for v := version; v > 0 && len(reverted) < steps; v-- {
+		m := migrations[v-1]
+		ok, err := migrate(db, m, false)
+		if err != nil {
+			return reverted, err
+		}
+		if ok {
+			reverted = append(reverted, m)
+		}
+	}
+	return reverted, nil
+}
+
+// migrate applies (up) or reverts m in a transaction. Other migrators are locked
+// out for the duration, and it reports false if one already did the work.
+func migrate(db *sql.DB, m store.Migration, up bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return false, fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+	var applied bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	script, record := m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	if !up {
+		script, record = m.Down, `DELETE FROM schema_migrations WHERE version = $1 AND name = $2`
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return false, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(record, m.Version, m.Name); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// MigrationStatuses returns every embedded migration with the time it was
+// applied to db, if it was.
+func MigrationStatuses(db *sql.DB) ([]store.MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[int]time.Time{}
+	if version, err := SchemaVersion(db); err != nil {
+		return nil, err
+	} else if version > 0 {
+		rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+			}
+			appliedAt[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+
+	statuses := make([]store.MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if at, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
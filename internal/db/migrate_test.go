@@ -0,0 +1,13 @@
+package db
+
+import "testing"
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Unexpected error loading the embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "initial" {
+		t.Errorf("Expected the first migration to be \"initial\", got %+v", migrations)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ip_geo;
+DROP TABLE IF EXISTS log_data;
@@ -0,0 +1,3 @@
+ALTER TABLE log_data DROP COLUMN IF EXISTS header;
+ALTER TABLE log_data DROP COLUMN IF EXISTS severity;
+ALTER TABLE log_data DROP COLUMN IF EXISTS rules;
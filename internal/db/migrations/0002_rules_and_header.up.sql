@@ -0,0 +1,6 @@
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS rules TEXT;      -- Comma-separated names of the rules that flagged the entry
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS severity TEXT;   -- Highest severity among those rules (low, medium, high, critical)
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS header JSONB;    -- Optional header fields: in, out, mac, tos, prec, id, ip_flags, tcp_flags, window, res, urgp, icmp_type, icmp_code
+
+CREATE INDEX IF NOT EXISTS idx_log_severity ON log_data(severity);
+CREATE INDEX IF NOT EXISTS idx_log_tcp_flags ON log_data((header->>'tcp_flags'));
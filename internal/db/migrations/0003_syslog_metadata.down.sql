@@ -0,0 +1,5 @@
+ALTER TABLE log_data DROP COLUMN IF EXISTS syslog_severity;
+ALTER TABLE log_data DROP COLUMN IF EXISTS facility;
+ALTER TABLE log_data DROP COLUMN IF EXISTS pid;
+ALTER TABLE log_data DROP COLUMN IF EXISTS program;
+ALTER TABLE log_data DROP COLUMN IF EXISTS hostname;
@@ -0,0 +1,7 @@
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS hostname TEXT;           -- Device that reported the entry, from the syslog header
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS program TEXT;            -- Program or tag that logged it (e.g. "kernel", "L4 FIREWALL")
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS pid INTEGER;             -- Process ID from the syslog tag, if any
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS facility SMALLINT;       -- Syslog facility, when received with a <PRI>
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS syslog_severity SMALLINT; -- Syslog severity (0-7), when received with a <PRI>
+
+CREATE INDEX IF NOT EXISTS idx_log_hostname ON log_data(hostname);
@@ -0,0 +1,13 @@
+-- Entries that differ only by sensor must be removed before the old
+-- constraint can be restored.
+DELETE FROM log_data a USING log_data b
+    WHERE a.id > b.id
+      AND (a.timestamp, a.source_ip, a.destination_ip, a.protocol, a.source_port, a.destination_port)
+          = (b.timestamp, b.source_ip, b.destination_ip, b.protocol, b.source_port, b.destination_port);
+
+ALTER TABLE log_data DROP CONSTRAINT IF EXISTS unique_log_entry;
+ALTER TABLE log_data
+    ADD CONSTRAINT unique_log_entry UNIQUE (timestamp, source_ip, destination_ip, protocol, source_port, destination_port);
+
+ALTER TABLE log_data DROP COLUMN IF EXISTS sensor_id;
+DROP TABLE IF EXISTS sensors;
@@ -0,0 +1,16 @@
+-- Sites or devices whose logs are collected in this database
+CREATE TABLE IF NOT EXISTS sensors (
+    id TEXT PRIMARY KEY,              -- Sensor ID from the [sensor] config, -sensor flag or syslog hostname
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Entries without a sensor, including those stored before sensors existed,
+-- belong to the default one.
+INSERT INTO sensors (id) VALUES ('default') ON CONFLICT DO NOTHING;
+
+ALTER TABLE log_data ADD COLUMN IF NOT EXISTS sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id);
+CREATE INDEX IF NOT EXISTS idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
+
+ALTER TABLE log_data DROP CONSTRAINT IF EXISTS unique_log_entry;
+ALTER TABLE log_data
+    ADD CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port);
@@ -0,0 +1,56 @@
+-- Move the rows of every partition back into a single table.
+
+ALTER TABLE log_data RENAME TO log_data_partitioned;
+ALTER INDEX log_data_pkey RENAME TO log_data_partitioned_pkey;
+ALTER TABLE log_data_partitioned RENAME CONSTRAINT unique_log_entry TO unique_log_entry_partitioned;
+ALTER SEQUENCE log_data_id_seq OWNED BY NONE;
+
+CREATE TABLE log_data (
+    id INTEGER PRIMARY KEY DEFAULT nextval('log_data_id_seq'),
+    timestamp TIMESTAMP NOT NULL,
+    source_ip TEXT NOT NULL,
+    destination_ip TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    source_port INTEGER,
+    destination_port INTEGER,
+    action TEXT,
+    reason TEXT,
+    packet_length INTEGER,
+    ttl INTEGER,
+    rules TEXT,
+    severity TEXT,
+    header JSONB,
+    hostname TEXT,
+    program TEXT,
+    pid INTEGER,
+    facility SMALLINT,
+    syslog_severity SMALLINT,
+    sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id),
+    CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
+);
+
+ALTER SEQUENCE log_data_id_seq OWNED BY log_data.id;
+
+INSERT INTO log_data (
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+)
+SELECT
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+FROM log_data_partitioned;
+
+-- Drops the partitions as well.
+DROP TABLE log_data_partitioned;
+
+CREATE INDEX idx_log_timestamp ON log_data(timestamp);
+CREATE INDEX idx_log_source_ip ON log_data(source_ip);
+CREATE INDEX idx_log_destination_ip ON log_data(destination_ip);
+CREATE INDEX idx_log_action ON log_data(action);
+CREATE INDEX idx_log_reason ON log_data(reason);
+CREATE INDEX idx_log_severity ON log_data(severity);
+CREATE INDEX idx_log_tcp_flags ON log_data((header->>'tcp_flags'));
+CREATE INDEX idx_log_hostname ON log_data(hostname);
+CREATE INDEX idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
@@ -0,0 +1,70 @@
+-- Partition log_data by range of timestamp. Existing rows are moved into
+-- monthly partitions named log_data_pYYYYMMDD after their first day; minerva
+-- creates further partitions as rows arrive, of the interval set in [retention].
+
+ALTER TABLE log_data RENAME TO log_data_unpartitioned;
+ALTER INDEX IF EXISTS log_data_pkey RENAME TO log_data_unpartitioned_pkey;
+ALTER TABLE log_data_unpartitioned RENAME CONSTRAINT unique_log_entry TO unique_log_entry_unpartitioned;
+ALTER SEQUENCE log_data_id_seq OWNED BY NONE;
+
+-- Unique constraints on a partitioned table must include the partition key,
+-- so the primary key becomes (id, timestamp).
+CREATE TABLE log_data (
+    id INTEGER NOT NULL DEFAULT nextval('log_data_id_seq'), -- Unique identifier for each log entry
+    timestamp TIMESTAMP NOT NULL,     -- The exact time the log entry was recorded
+    source_ip TEXT NOT NULL,          -- The IP address from which the packet originated
+    destination_ip TEXT NOT NULL,     -- The IP address to which the packet was directed
+    protocol TEXT NOT NULL,           -- The protocol used (e.g., TCP, UDP, ICMP)
+    source_port INTEGER,              -- The source port of the packet
+    destination_port INTEGER,         -- The destination port of the packet
+    action TEXT,                      -- Tracks what was done to the packet
+    reason TEXT,                      -- Categorizes the attack or packet handling.
+    packet_length INTEGER,            -- The size of the packet. Useful for traffic pattern analysis
+    ttl INTEGER,                      -- Time-to-Live (TTL) value. Indicates distance or latency to the source
+    rules TEXT,                       -- Comma-separated names of the rules that flagged the entry
+    severity TEXT,                    -- Highest severity among those rules (low, medium, high, critical)
+    header JSONB,                     -- Optional header fields: in, out, mac, tos, prec, id, ip_flags, tcp_flags, window, res, urgp, icmp_type, icmp_code
+    hostname TEXT,                    -- Device that reported the entry, from the syslog header
+    program TEXT,                     -- Program or tag that logged it (e.g. "kernel", "L4 FIREWALL")
+    pid INTEGER,                      -- Process ID from the syslog tag, if any
+    facility SMALLINT,                -- Syslog facility, when received with a <PRI>
+    syslog_severity SMALLINT,         -- Syslog severity (0-7), when received with a <PRI>
+    sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id), -- Sensor that collected the entry
+    PRIMARY KEY (id, timestamp),
+    CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
+) PARTITION BY RANGE (timestamp);
+
+ALTER SEQUENCE log_data_id_seq OWNED BY log_data.id;
+
+DO $$
+DECLARE
+    first_day TIMESTAMP;
+BEGIN
+    FOR first_day IN SELECT DISTINCT date_trunc('month', timestamp) FROM log_data_unpartitioned LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF log_data FOR VALUES FROM (%L) TO (%L)',
+            'log_data_p' || to_char(first_day, 'YYYYMMDD'), first_day, first_day + INTERVAL '1 month');
+    END LOOP;
+END $$;
+
+INSERT INTO log_data (
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+)
+SELECT
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+FROM log_data_unpartitioned;
+
+DROP TABLE log_data_unpartitioned;
+
+CREATE INDEX idx_log_timestamp ON log_data(timestamp);
+CREATE INDEX idx_log_source_ip ON log_data(source_ip);
+CREATE INDEX idx_log_destination_ip ON log_data(destination_ip);
+CREATE INDEX idx_log_action ON log_data(action);
+CREATE INDEX idx_log_reason ON log_data(reason);
+CREATE INDEX idx_log_severity ON log_data(severity);
+CREATE INDEX idx_log_tcp_flags ON log_data((header->>'tcp_flags'));
+CREATE INDEX idx_log_hostname ON log_data(hostname);
+CREATE INDEX idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
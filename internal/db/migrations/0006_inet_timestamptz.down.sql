@@ -0,0 +1,77 @@
+-- Store addresses as text and times as wall-clock TIMESTAMP in the session's
+-- TimeZone again.
+
+ALTER TABLE sensors ALTER COLUMN created_at TYPE TIMESTAMP;
+
+DROP INDEX idx_ip_geo_address;
+ALTER TABLE ip_geo ALTER COLUMN last_updated TYPE TIMESTAMP;
+ALTER TABLE ip_geo ALTER COLUMN ip_address TYPE TEXT USING host(ip_address);
+CREATE INDEX IF NOT EXISTS idx_ip_address ON ip_geo(ip_address);
+
+ALTER TABLE log_data RENAME TO log_data_inet;
+ALTER INDEX log_data_pkey RENAME TO log_data_inet_pkey;
+ALTER TABLE log_data_inet RENAME CONSTRAINT unique_log_entry TO unique_log_entry_inet;
+ALTER SEQUENCE log_data_id_seq OWNED BY NONE;
+
+CREATE TABLE log_data (
+    id INTEGER NOT NULL DEFAULT nextval('log_data_id_seq'),
+    timestamp TIMESTAMP NOT NULL,
+    source_ip TEXT NOT NULL,
+    destination_ip TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    source_port INTEGER,
+    destination_port INTEGER,
+    action TEXT,
+    reason TEXT,
+    packet_length INTEGER,
+    ttl INTEGER,
+    rules TEXT,
+    severity TEXT,
+    header JSONB,
+    hostname TEXT,
+    program TEXT,
+    pid INTEGER,
+    facility SMALLINT,
+    syslog_severity SMALLINT,
+    sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id),
+    PRIMARY KEY (id, timestamp),
+    CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
+) PARTITION BY RANGE (timestamp);
+
+ALTER SEQUENCE log_data_id_seq OWNED BY log_data.id;
+
+DO $$
+DECLARE
+    first_day TIMESTAMP;
+BEGIN
+    FOR first_day IN SELECT DISTINCT date_trunc('month', timestamp::TIMESTAMP) FROM log_data_inet LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF log_data FOR VALUES FROM (%L) TO (%L)',
+            'log_data_p' || to_char(first_day, 'YYYYMMDD'), first_day, first_day + INTERVAL '1 month');
+    END LOOP;
+END $$;
+
+-- Entries that differed only by their UTC offset are now duplicates.
+INSERT INTO log_data (
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+)
+SELECT
+    id, timestamp::TIMESTAMP, host(source_ip), host(destination_ip), protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+FROM log_data_inet
+ON CONFLICT DO NOTHING;
+
+-- Drops the partitions as well.
+DROP TABLE log_data_inet;
+
+CREATE INDEX idx_log_timestamp ON log_data(timestamp);
+CREATE INDEX idx_log_source_ip ON log_data(source_ip);
+CREATE INDEX idx_log_destination_ip ON log_data(destination_ip);
+CREATE INDEX idx_log_action ON log_data(action);
+CREATE INDEX idx_log_reason ON log_data(reason);
+CREATE INDEX idx_log_severity ON log_data(severity);
+CREATE INDEX idx_log_tcp_flags ON log_data((header->>'tcp_flags'));
+CREATE INDEX idx_log_hostname ON log_data(hostname);
+CREATE INDEX idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
@@ -0,0 +1,96 @@
+-- Store addresses as INET and times as TIMESTAMPTZ. The partition key cannot
+-- change type in place, so log_data is rebuilt as in 0005, with monthly
+-- partitions aligned to UTC.
+--
+-- Existing timestamps were stored without their UTC offset; they are read in
+-- the session's TimeZone, which defaults to the server's. Set it for the
+-- migrating user first (ALTER ROLE ... SET timezone) if the logs were in
+-- another zone. Source addresses that are not valid, such as the "unknown"
+-- stored for lines without one, become 0.0.0.0.
+
+CREATE FUNCTION pg_temp.to_inet(address TEXT) RETURNS INET AS $$
+BEGIN
+    RETURN address::INET;
+EXCEPTION WHEN invalid_text_representation THEN
+    RETURN NULL;
+END $$ LANGUAGE plpgsql;
+
+ALTER TABLE log_data RENAME TO log_data_text;
+ALTER INDEX log_data_pkey RENAME TO log_data_text_pkey;
+ALTER TABLE log_data_text RENAME CONSTRAINT unique_log_entry TO unique_log_entry_text;
+ALTER SEQUENCE log_data_id_seq OWNED BY NONE;
+
+CREATE TABLE log_data (
+    id INTEGER NOT NULL DEFAULT nextval('log_data_id_seq'), -- Unique identifier for each log entry
+    timestamp TIMESTAMPTZ NOT NULL,   -- The exact time the log entry was recorded
+    source_ip INET NOT NULL,          -- The IP address from which the packet originated
+    destination_ip INET NOT NULL,     -- The IP address to which the packet was directed
+    protocol TEXT NOT NULL,           -- The protocol used (e.g., TCP, UDP, ICMP)
+    source_port INTEGER,              -- The source port of the packet
+    destination_port INTEGER,         -- The destination port of the packet
+    action TEXT,                      -- Tracks what was done to the packet
+    reason TEXT,                      -- Categorizes the attack or packet handling.
+    packet_length INTEGER,            -- The size of the packet. Useful for traffic pattern analysis
+    ttl INTEGER,                      -- Time-to-Live (TTL) value. Indicates distance or latency to the source
+    rules TEXT,                       -- Comma-separated names of the rules that flagged the entry
+    severity TEXT,                    -- Highest severity among those rules (low, medium, high, critical)
+    header JSONB,                     -- Optional header fields: in, out, mac, tos, prec, id, ip_flags, tcp_flags, window, res, urgp, icmp_type, icmp_code
+    hostname TEXT,                    -- Device that reported the entry, from the syslog header
+    program TEXT,                     -- Program or tag that logged it (e.g. "kernel", "L4 FIREWALL")
+    pid INTEGER,                      -- Process ID from the syslog tag, if any
+    facility SMALLINT,                -- Syslog facility, when received with a <PRI>
+    syslog_severity SMALLINT,         -- Syslog severity (0-7), when received with a <PRI>
+    sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id), -- Sensor that collected the entry
+    PRIMARY KEY (id, timestamp),
+    CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
+) PARTITION BY RANGE (timestamp);
+
+ALTER SEQUENCE log_data_id_seq OWNED BY log_data.id;
+
+DO $$
+DECLARE
+    first_day TIMESTAMP;
+BEGIN
+    FOR first_day IN
+        SELECT DISTINCT date_trunc('month', timestamp::TIMESTAMPTZ AT TIME ZONE 'UTC') FROM log_data_text
+    LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF log_data FOR VALUES FROM (%L) TO (%L)',
+            'log_data_p' || to_char(first_day, 'YYYYMMDD'),
+            first_day AT TIME ZONE 'UTC', (first_day + INTERVAL '1 month') AT TIME ZONE 'UTC');
+    END LOOP;
+END $$;
+
+-- Entries that only differed by an invalid source address are now duplicates.
+INSERT INTO log_data (
+    id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+)
+SELECT
+    id, timestamp::TIMESTAMPTZ, COALESCE(pg_temp.to_inet(source_ip), '0.0.0.0'), destination_ip::INET,
+    protocol, source_port, destination_port,
+    action, reason, packet_length, ttl, rules, severity, header,
+    hostname, program, pid, facility, syslog_severity, sensor_id
+FROM log_data_text
+ON CONFLICT DO NOTHING;
+
+DROP TABLE log_data_text;
+
+CREATE INDEX idx_log_timestamp ON log_data(timestamp);
+CREATE INDEX idx_log_source_ip ON log_data USING gist (source_ip inet_ops);
+CREATE INDEX idx_log_destination_ip ON log_data USING gist (destination_ip inet_ops);
+CREATE INDEX idx_log_action ON log_data(action);
+CREATE INDEX idx_log_reason ON log_data(reason);
+CREATE INDEX idx_log_severity ON log_data(severity);
+CREATE INDEX idx_log_tcp_flags ON log_data((header->>'tcp_flags'));
+CREATE INDEX idx_log_hostname ON log_data(hostname);
+CREATE INDEX idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
+
+-- Geolocation data is a cache, so entries for invalid addresses are dropped.
+DELETE FROM ip_geo WHERE pg_temp.to_inet(ip_address) IS NULL;
+ALTER TABLE ip_geo ALTER COLUMN ip_address TYPE INET USING ip_address::INET;
+ALTER TABLE ip_geo ALTER COLUMN last_updated TYPE TIMESTAMPTZ;
+DROP INDEX IF EXISTS idx_ip_address; -- Duplicates the unique constraint's index
+CREATE INDEX idx_ip_geo_address ON ip_geo USING gist (ip_address inet_ops);
+
+ALTER TABLE sensors ALTER COLUMN created_at TYPE TIMESTAMPTZ;
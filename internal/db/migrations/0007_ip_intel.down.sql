@@ -0,0 +1,2 @@
+ALTER TABLE ip_geo DROP CONSTRAINT IF EXISTS ip_geo_ip_address_fkey;
+DROP TABLE IF EXISTS ip_intel;
@@ -0,0 +1,27 @@
+-- Activity of each source address, kept up to date as entries are inserted.
+-- Geolocation data belongs to an address in ip_intel. Addresses that only have
+-- geolocation data so far have no hits and no first or last sighting.
+CREATE TABLE ip_intel (
+    ip_address INET PRIMARY KEY,
+    first_seen TIMESTAMPTZ,                         -- Earliest flagged entry from the address
+    last_seen TIMESTAMPTZ,                          -- Latest flagged entry from the address
+    total_hits BIGINT NOT NULL DEFAULT 0,           -- Number of flagged entries
+    destination_ports INTEGER[] NOT NULL DEFAULT '{}', -- Distinct ports targeted, sorted
+    reasons TEXT[] NOT NULL DEFAULT '{}'            -- Distinct reasons, sorted
+);
+
+CREATE INDEX idx_ip_intel_last_seen ON ip_intel(last_seen);
+
+INSERT INTO ip_intel (ip_address, first_seen, last_seen, total_hits, destination_ports, reasons)
+SELECT source_ip, MIN(timestamp), MAX(timestamp), COUNT(*),
+       COALESCE(array_agg(DISTINCT destination_port ORDER BY destination_port) FILTER (WHERE destination_port > 0), '{}'),
+       COALESCE(array_agg(DISTINCT reason ORDER BY reason) FILTER (WHERE reason IS NOT NULL), '{}')
+FROM log_data
+GROUP BY source_ip;
+
+INSERT INTO ip_intel (ip_address)
+SELECT ip_address FROM ip_geo
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE ip_geo
+    ADD CONSTRAINT ip_geo_ip_address_fkey FOREIGN KEY (ip_address) REFERENCES ip_intel(ip_address) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_ip_geo_asn;
+ALTER TABLE ip_geo
+    DROP COLUMN IF EXISTS asn,
+    DROP COLUMN IF EXISTS as_org,
+    DROP COLUMN IF EXISTS as_prefix,
+    DROP COLUMN IF EXISTS hosting;
@@ -0,0 +1,12 @@
+-- The autonomous system announcing each address, and whether it belongs to a
+-- hosting provider or data center.
+ALTER TABLE ip_geo
+    ADD COLUMN asn BIGINT,                          -- NULL if unknown
+    ADD COLUMN as_org TEXT,
+    ADD COLUMN as_prefix CIDR,                      -- Announced prefix containing the address, if known
+    ADD COLUMN hosting BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_ip_geo_asn ON ip_geo(asn);
+
+-- Existing data has no ASN yet; mark it stale so the refresher looks it up again.
+UPDATE ip_geo SET last_updated = NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_log_data_source_class;
+ALTER TABLE log_data DROP COLUMN IF EXISTS source_class;
+ALTER TABLE ip_intel DROP COLUMN IF EXISTS class;
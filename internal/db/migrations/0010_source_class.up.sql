@@ -0,0 +1,27 @@
+-- The class of each source address (public, private, loopback, multicast,
+-- bogon or own), so that only public addresses are looked up and entries can
+-- be filtered by it. Existing rows are classified as package netclass does,
+-- without own networks, which are only known from the configuration.
+CREATE FUNCTION pg_temp.inet_class(a INET) RETURNS TEXT IMMUTABLE LANGUAGE SQL AS $$
+    SELECT CASE
+        WHEN a <<= ANY ('{127.0.0.0/8,::1/128}'::inet[]) THEN 'loopback'
+        WHEN a <<= ANY ('{224.0.0.0/4,ff00::/8}'::inet[]) THEN 'multicast'
+        WHEN a <<= ANY ('{10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,100.64.0.0/10,169.254.0.0/16,fc00::/7,fe80::/10}'::inet[]) THEN 'private'
+        WHEN a <<= ANY ('{0.0.0.0/8,192.0.0.0/24,192.0.2.0/24,198.18.0.0/15,198.51.100.0/24,203.0.113.0/24,240.0.0.0/4,::/128,100::/64,2001:db8::/32}'::inet[])
+            OR (family(a) = 6 AND NOT a <<= '2000::/3') THEN 'bogon'
+        ELSE 'public'
+    END
+$$;
+
+ALTER TABLE log_data ADD COLUMN source_class TEXT;
+UPDATE log_data SET source_class = pg_temp.inet_class(source_ip);
+CREATE INDEX idx_log_data_source_class ON log_data(source_class);
+
+ALTER TABLE ip_intel ADD COLUMN class TEXT; -- Class of the latest entries; NULL before any
+UPDATE ip_intel SET class = pg_temp.inet_class(ip_address) WHERE total_hits > 0;
+
+-- Lookups of addresses that are not public only returned empty data.
+DELETE FROM ip_geo WHERE pg_temp.inet_class(ip_address) <> 'public';
+DELETE FROM geo_failures WHERE pg_temp.inet_class(ip_address) <> 'public';
+
+DROP FUNCTION pg_temp.inet_class(INET);
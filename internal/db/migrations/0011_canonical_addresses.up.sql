@@ -0,0 +1,75 @@
+-- Addresses are stored in the canonical form of parser.ParseAddr, so that an
+-- IPv4 packet logged with an IPv4-mapped IPv6 address (::ffff:192.0.2.1) has
+-- one row per address. The rows stored before are rewritten: the counters of
+-- a mapped address are merged into those of its IPv4 address, and its
+-- geolocation data is dropped to be looked up again under that address.
+-- Migration 0010 classified mapped addresses as bogons; they are classified
+-- again as their IPv4 address.
+CREATE FUNCTION pg_temp.inet_unmap(a INET) RETURNS INET IMMUTABLE LANGUAGE SQL AS $$
+    SELECT CASE WHEN a <<= '::ffff:0.0.0.0/96' THEN '0.0.0.0'::inet + (a - '::ffff:0.0.0.0'::inet) ELSE a END
+$$;
+
+CREATE FUNCTION pg_temp.inet4_class(a INET) RETURNS TEXT IMMUTABLE LANGUAGE SQL AS $$
+    SELECT CASE
+        WHEN a <<= '127.0.0.0/8' THEN 'loopback'
+        WHEN a <<= '224.0.0.0/4' THEN 'multicast'
+        WHEN a <<= ANY ('{10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,100.64.0.0/10,169.254.0.0/16}'::inet[]) THEN 'private'
+        WHEN a <<= ANY ('{0.0.0.0/8,192.0.0.0/24,192.0.2.0/24,198.18.0.0/15,198.51.100.0/24,203.0.113.0/24,240.0.0.0/4}'::inet[]) THEN 'bogon'
+        ELSE 'public'
+    END
+$$;
+
+INSERT INTO ip_intel (ip_address, class, first_seen, last_seen, total_hits, destination_ports, reasons)
+SELECT pg_temp.inet_unmap(ip_address),
+       CASE WHEN class IS NOT NULL THEN pg_temp.inet4_class(pg_temp.inet_unmap(ip_address)) END,
+       first_seen, last_seen, total_hits, destination_ports, reasons
+FROM ip_intel
+WHERE ip_address <<= '::ffff:0.0.0.0/96'
+ON CONFLICT (ip_address) DO UPDATE SET
+    class = COALESCE(ip_intel.class, excluded.class),
+    first_seen = LEAST(ip_intel.first_seen, excluded.first_seen),
+    last_seen = GREATEST(ip_intel.last_seen, excluded.last_seen),
+    total_hits = ip_intel.total_hits + excluded.total_hits,
+    destination_ports = ARRAY(SELECT DISTINCT unnest(ip_intel.destination_ports || excluded.destination_ports) ORDER BY 1),
+    reasons = ARRAY(SELECT DISTINCT unnest(ip_intel.reasons || excluded.reasons) ORDER BY 1);
+
+-- Deleting from ip_intel cascades to ip_geo.
+DELETE FROM ip_intel WHERE ip_address <<= '::ffff:0.0.0.0/96';
+DELETE FROM geo_failures WHERE ip_address <<= '::ffff:0.0.0.0/96';
+
+-- Rewriting an address can make an entry a duplicate of one already stored
+-- with the canonical address, so those entries are dropped first and taken
+-- out of the hits of their source.
+WITH dropped AS (
+    DELETE FROM log_data m USING log_data l
+    WHERE m.source_ip <<= '::ffff:0.0.0.0/96'
+      AND l.sensor_id = m.sensor_id AND l.timestamp = m.timestamp
+      AND l.source_ip = pg_temp.inet_unmap(m.source_ip) AND l.destination_ip = m.destination_ip
+      AND l.protocol = m.protocol AND l.source_port = m.source_port AND l.destination_port = m.destination_port
+    RETURNING l.source_ip
+)
+UPDATE ip_intel SET total_hits = total_hits - d.hits
+FROM (SELECT source_ip, COUNT(*) AS hits FROM dropped GROUP BY source_ip) d
+WHERE ip_intel.ip_address = d.source_ip;
+
+UPDATE log_data
+SET source_ip = pg_temp.inet_unmap(source_ip),
+    source_class = pg_temp.inet4_class(pg_temp.inet_unmap(source_ip))
+WHERE source_ip <<= '::ffff:0.0.0.0/96';
+
+WITH dropped AS (
+    DELETE FROM log_data m USING log_data l
+    WHERE m.destination_ip <<= '::ffff:0.0.0.0/96'
+      AND l.sensor_id = m.sensor_id AND l.timestamp = m.timestamp
+      AND l.source_ip = m.source_ip AND l.destination_ip = pg_temp.inet_unmap(m.destination_ip)
+      AND l.protocol = m.protocol AND l.source_port = m.source_port AND l.destination_port = m.destination_port
+    RETURNING m.source_ip
+)
+UPDATE ip_intel SET total_hits = total_hits - d.hits
+FROM (SELECT source_ip, COUNT(*) AS hits FROM dropped GROUP BY source_ip) d
+WHERE ip_intel.ip_address = d.source_ip;
+
+UPDATE log_data SET destination_ip = pg_temp.inet_unmap(destination_ip) WHERE destination_ip <<= '::ffff:0.0.0.0/96';
+
+DROP FUNCTION pg_temp.inet4_class(INET);
+DROP FUNCTION pg_temp.inet_unmap(INET);
@@ -0,0 +1,11 @@
+-- What threat intelligence services report about source addresses, one row
+-- per address and provider. A report belongs to an address in ip_intel.
+CREATE TABLE ip_reputation (
+    ip_address INET NOT NULL REFERENCES ip_intel(ip_address) ON DELETE CASCADE,
+    provider TEXT NOT NULL,                     -- Such as "abuseipdb"
+    score SMALLINT NOT NULL,                    -- 0 (no sign of abuse) to 100
+    categories TEXT[] NOT NULL DEFAULT '{}',    -- Kinds of abuse reported, sorted
+    last_reported TIMESTAMPTZ,                  -- Latest report of abuse
+    fetched_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (ip_address, provider)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE log_data DROP COLUMN IF EXISTS blocklists;
+DROP TABLE IF EXISTS blocklist_entries;
@@ -0,0 +1,12 @@
+-- Networks of the blocklist feeds in the [blocklists] config, replaced as a
+-- whole each time a feed is fetched.
+CREATE TABLE blocklist_entries (
+    source TEXT NOT NULL,                       -- Feed name, such as "spamhaus_drop"
+    network CIDR NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,            -- When the feed was last fetched
+    PRIMARY KEY (source, network)
+);
+
+-- Comma-separated names of the lists the source address was on when the
+-- entry was inserted
+ALTER TABLE log_data ADD COLUMN blocklists TEXT;
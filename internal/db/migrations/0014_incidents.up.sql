@@ -0,0 +1,17 @@
+-- Port scans and sweeps detected from flagged entries; see package scan.
+CREATE TABLE incidents (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id TEXT NOT NULL REFERENCES sensors(id),
+    source_ip INET NOT NULL,
+    scan_type TEXT NOT NULL,                    -- vertical, horizontal or slow
+    start_time TIMESTAMPTZ NOT NULL,            -- Timestamp of the first entry
+    end_time TIMESTAMPTZ NOT NULL,              -- Timestamp of the last entry
+    ports INTEGER[] NOT NULL,                   -- Distinct destination ports
+    hosts INTEGER NOT NULL,                     -- Distinct destination addresses
+    hits BIGINT NOT NULL,
+    -- A scan found again when logs are read again is merged into the same row
+    CONSTRAINT unique_incident UNIQUE (sensor_id, source_ip, scan_type, start_time)
+);
+
+CREATE INDEX idx_incidents_start_time ON incidents(start_time);
+CREATE INDEX idx_incidents_source_ip ON incidents(source_ip);
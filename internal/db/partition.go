@@ -0,0 +1,250 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"minerva/internal/store"
+)
+
+// partitionTimeLayout is the format of the partition bounds minerva creates,
+// which are in UTC.
+const partitionTimeLayout = "2006-01-02 15:04:05-07"
+
+// boundTimeLayouts are the formats of timestamptz partition bounds as shown by
+// pg_get_expr, in the session time zone. PostgreSQL writes the offset as hours
+// alone when it can.
+var boundTimeLayouts = []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05-07:00:00"}
+
+// ListPartitions returns the partitions of log_data in time order.
+func ListPartitions(db *sql.DB) ([]store.Partition, error) {
+	rows, err := db.Query(`
+        SELECT c.relname, pg_get_expr(c.relpartbound, c.oid), c.reltuples::BIGINT, pg_total_relation_size(c.oid)
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'log_data'::regclass`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []store.Partition
+	for rows.Next() {
+		var p store.Partition
+		var bound string
+		if err := rows.Scan(&p.Name, &bound, &p.Rows, &p.Size); err != nil {
+			return nil, fmt.Errorf("failed to list partitions: %w", err)
+		}
+		if p.From, p.To, err = parsePartitionBound(bound); err != nil {
+			return nil, fmt.Errorf("partition %s: %w", p.Name, err)
+		}
+		partitions = append(partitions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].From.Before(partitions[j].From) })
+	return partitions, nil
+}
+
+// parsePartitionBound parses a range partition bound as shown by pg_get_expr,
+// e.g. FOR VALUES FROM ('2025-01-01 00:00:00') TO ('2025-02-01 00:00:00').
+func parsePartitionBound(bound string) (from, to time.Time, err error) {
+	parts := strings.Split(bound, "'")
+	if len(parts) != 5 {
+		return from, to, fmt.Errorf("unsupported partition bound %q", bound)
+	}
+	if from, err = parseBoundTime(parts[1]); err != nil {
+		return from, to, fmt.Errorf("unsupported partition bound %q: %w", bound, err)
+	}
+	if to, err = parseBoundTime(parts[3]); err != nil {
+		return from, to, fmt.Errorf("unsupported partition bound %q: %w", bound, err)
+	}
+	return from, to, nil
+}
+
+// parseBoundTime parses one time of a partition bound, returning it in UTC.
+func parseBoundTime(s string) (t time.Time, err error) {
+	for _, layout := range boundTimeLayouts {
+		if t, err = time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return t, err
+}
+
+// untrackIntelSQL recomputes the ip_intel counters of the source addresses
+// with entries in the partition $1 from their entries in other partitions,
+// before it is dropped, keeping the lowest store.MaxIntelPorts ports. Addresses
+// left without entries keep their class and geolocation data, with no hits.
+var untrackIntelSQL = `
+    UPDATE ip_intel i SET
+        first_seen = r.first_seen,
+        last_seen = r.last_seen,
+        total_hits = r.total_hits,
+        destination_ports = r.destination_ports,
+        reasons = r.reasons
+    FROM (
+        SELECT d.source_ip, MIN(l.timestamp) AS first_seen, MAX(l.timestamp) AS last_seen, COUNT(l.timestamp) AS total_hits,
+            COALESCE((array_agg(DISTINCT l.destination_port ORDER BY l.destination_port) FILTER (WHERE l.destination_port > 0))[1:` + strconv.Itoa(store.MaxIntelPorts) + `], '{}') AS destination_ports,
+            COALESCE(array_agg(DISTINCT l.reason ORDER BY l.reason) FILTER (WHERE l.reason IS NOT NULL), '{}') AS reasons
+        FROM (SELECT DISTINCT source_ip FROM log_data WHERE tableoid = $1::regclass) d
+        LEFT JOIN log_data l ON l.source_ip = d.source_ip AND l.tableoid <> $1::regclass
+        GROUP BY d.source_ip
+    ) r
+    WHERE i.ip_address = r.source_ip`
+
+// DropPartition drops a partition of log_data along with its rows, and takes
+// them out of the ip_intel counters.
+func DropPartition(db *sql.DB, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	// Only drop tables that are partitions of log_data.
+	var ok bool
+	err = tx.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+            WHERE i.inhparent = 'log_data'::regclass AND c.relname = $1)`, name).Scan(&ok)
+	if err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", name, err)
+	}
+	if !ok {
+		return fmt.Errorf("%s is not a partition of log_data", name)
+	}
+	if _, err := tx.Exec(untrackIntelSQL, quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to update intel for partition %s: %w", name, err)
+	}
+	if _, err := tx.Exec(`DROP TABLE ` + quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a table name for use in SQL.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Partitioner creates the log_data partitions that rows are about to need.
+// It is safe for concurrent use.
+type Partitioner struct {
+	db       *sql.DB
+	interval store.PartitionInterval
+
+	mu     sync.Mutex
+	ranges []store.Partition // Known partitions, loaded on first use
+	loaded bool
+}
+
+// NewPartitioner returns a Partitioner that creates partitions of interval.
+func NewPartitioner(db *sql.DB, interval store.PartitionInterval) *Partitioner {
+	return &Partitioner{db: db, interval: interval}
+}
+
+// Ensure creates any missing partitions needed to store rows with the given
+// timestamps. Partitions created with a different interval are kept, and new
+// ones are shortened so as not to overlap them.
+func (p *Partitioner) Ensure(times ...time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, t := range times {
+		t = t.UTC()
+		if p.covered(t) {
+			continue
+		}
+		if err := p.create(t); err != nil {
+			// Another process may have created it first.
+			if errReload := p.reload(); errReload != nil {
+				return errReload
+			}
+			if !p.covered(t) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureUpcoming creates the partitions for the interval containing now and
+// the n intervals after it, so that they exist before rows arrive.
+func (p *Partitioner) EnsureUpcoming(now time.Time, n int) error {
+	start := p.interval.Start(now)
+	times := []time.Time{start}
+	for i := 0; i < n; i++ {
+		start = p.interval.Next(start)
+		times = append(times, start)
+	}
+	return p.Ensure(times...)
+}
+
+// Forget drops the cached partitions so that they are listed again on next use,
+// for example after partitions have been dropped.
+func (p *Partitioner) Forget() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ranges, p.loaded = nil, false
+}
+
+// covered reports whether a known partition holds t, loading them if needed.
+func (p *Partitioner) covered(t time.Time) bool {
+	if !p.loaded {
+		if err := p.reload(); err != nil {
+			return false // create reports the problem
+		}
+	}
+	for _, r := range p.ranges {
+		if !t.Before(r.From) && t.Before(r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Partitioner) reload() error {
+	ranges, err := ListPartitions(p.db)
+	if err != nil {
+		return err
+	}
+	p.ranges, p.loaded = ranges, true
+	return nil
+}
+
+// create adds the partition for the interval containing t, clipped to the
+// partitions on either side.
+func (p *Partitioner) create(t time.Time) error {
+	from := p.interval.Start(t)
+	to := p.interval.Next(from)
+	for _, r := range p.ranges {
+		if !r.To.After(t) && r.To.After(from) {
+			from = r.To
+		}
+		if r.From.After(t) && r.From.Before(to) {
+			to = r.From
+		}
+	}
+
+	name := "log_data_p" + from.Format("20060102")
+	if from.Hour() != 0 || from.Minute() != 0 || from.Second() != 0 {
+		name += from.Format("150405")
+	}
+	_, err := p.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF log_data FOR VALUES FROM ('%s') TO ('%s')`,
+		quoteIdentifier(name), from.Format(partitionTimeLayout), to.Format(partitionTimeLayout)))
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+	p.ranges = append(p.ranges, store.Partition{Name: name, From: from, To: to, Rows: -1})
+	return nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePartitionBound(t *testing.T) {
+	// The same bounds shown in UTC, New York and India sessions.
+	for _, bound := range []string{
+		"FOR VALUES FROM ('2025-01-01 00:00:00+00') TO ('2025-02-01 00:00:00+00')",
+		"FOR VALUES FROM ('2024-12-31 19:00:00-05') TO ('2025-01-31 19:00:00-05')",
+		"FOR VALUES FROM ('2025-01-01 05:30:00+05:30') TO ('2025-02-01 05:30:00+05:30')",
+	} {
+		from, to, err := parsePartitionBound(bound)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", bound, err)
+		}
+		if !from.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected bounds %v to %v for %q", from, to, bound)
+		}
+		if from.Location() != time.UTC {
+			t.Errorf("Expected bounds in UTC, got %v", from.Location())
+		}
+	}
+
+	for _, bound := range []string{"DEFAULT", "FOR VALUES FROM (MINVALUE) TO ('2025-01-01 00:00:00+00')", "FOR VALUES FROM ('2025-01-01') TO ('2025-02-01')"} {
+		if _, _, err := parsePartitionBound(bound); err == nil {
+			t.Errorf("Expected an error for %q", bound)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minerva/internal/threatintel"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReputationFetchedAt returns when provider's report on ip was fetched, or the
+// zero Time if there is none.
+func (h *Handler) ReputationFetchedAt(ip, provider string) (time.Time, error) {
+	var fetched time.Time
+	err := h.DB.QueryRow(`SELECT fetched_at FROM ip_reputation WHERE ip_address = $1 AND provider = $2`, ip, provider).Scan(&fetched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	return fetched, nil
+}
+
+// InsertOrUpdateReputation stores a report on ip, replacing the previous
+// report of the same provider. The address is added to ip_intel first if its
+// entries have not been inserted yet.
+func (h *Handler) InsertOrUpdateReputation(ip string, r *threatintel.Report) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO ip_intel (ip_address) VALUES ($1) ON CONFLICT DO NOTHING`, ip); err != nil {
+		return fmt.Errorf("failed to insert intel for IP %s: %w", ip, err)
+	}
+	categories := r.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	var lastReported sql.NullTime
+	if r.LastReported != nil {
+		lastReported = sql.NullTime{Time: *r.LastReported, Valid: true}
+	}
+	_, err = tx.Exec(`
+    INSERT INTO ip_reputation (ip_address, provider, score, categories, last_reported, fetched_at)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (ip_address, provider) DO UPDATE SET
+        score = excluded.score,
+        categories = excluded.categories,
+        last_reported = excluded.last_reported,
+        fetched_at = excluded.fetched_at`,
+		ip, r.Provider, r.Score, pq.Array(categories), lastReported, r.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	return nil
+}
+
+// Reputation returns the threat intelligence reports on ip, ordered by
+// provider.
+func (s *Store) Reputation(ip, sensor string) ([]threatintel.Report, error) {
+	query := `
+		SELECT provider, score, categories, last_reported, fetched_at
+		FROM ip_reputation WHERE ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+	rows, err := s.DB.Query(query+` ORDER BY provider`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	defer rows.Close()
+
+	reports := []threatintel.Report{}
+	for rows.Next() {
+		var r threatintel.Report
+		var lastReported sql.NullTime
+		if err := rows.Scan(&r.Provider, &r.Score, pq.Array(&r.Categories), &lastReported, &r.FetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+		}
+		if r.Categories == nil {
+			r.Categories = []string{}
+		}
+		if lastReported.Valid {
+			r.LastReported = &lastReported.Time
+		}
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	return reports, nil
+}
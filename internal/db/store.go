@@ -0,0 +1,239 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minerva/internal/config"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	store.Register("postgres", Open)
+}
+
+var _ store.Store = (*Store)(nil)
+
+// Store is the PostgreSQL implementation of store.Store. It creates log_data
+// partitions as entries need them.
+type Store struct {
+	Handler
+	partitions *Partitioner
+}
+
+// Open connects to the PostgreSQL database of the [database] config section.
+// A port of 0 means the default, 5432.
+func Open(conf *config.Config) (store.Store, error) {
+	interval, err := store.ParsePartitionInterval(conf.Retention.PartitionInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention configuration: %w", err)
+	}
+	port := conf.Database.Port
+	if port == 0 {
+		port = 5432
+	}
+	db, err := Connect(conf.Database.Host, strconv.Itoa(port), conf.Database.User, conf.Database.Password, conf.Database.Name)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(db, interval), nil
+}
+
+// NewStore returns a Store for db that creates partitions of interval.
+func NewStore(db *sql.DB, interval store.PartitionInterval) *Store {
+	return &Store{Handler: Handler{DB: db}, partitions: NewPartitioner(db, interval)}
+}
+
+// InsertSensor registers a sensor if it is not known yet.
+func (s *Store) InsertSensor(id string) error {
+	return InsertSensor(s.DB, id)
+}
+
+// InsertLogEntry inserts one event, creating its partition if needed.
+func (s *Store) InsertLogEntry(ev parser.LogEvent) (int64, error) {
+	if !ev.Timestamp.IsZero() {
+		if err := s.partitions.Ensure(ev.Timestamp); err != nil {
+			return 0, err
+		}
+	}
+	return InsertLogEntry(s.DB, ev)
+}
+
+// InsertLogEntries inserts a batch of events with InsertLogEntries, creating
+// the partitions they need first.
+func (s *Store) InsertLogEntries(events []parser.LogEvent) (int64, error) {
+	times := make([]time.Time, 0, len(events))
+	for _, ev := range events {
+		if !ev.Timestamp.IsZero() {
+			times = append(times, ev.Timestamp)
+		}
+	}
+	if err := s.partitions.Ensure(times...); err != nil {
+		return 0, err
+	}
+	return InsertLogEntries(s.DB, events)
+}
+
+// Logs returns stored events, newest first. A source prefix is matched with
+// the GiST index on source_ip.
+func (s *Store) Logs(q store.LogQuery) ([]parser.LogEvent, error) {
+	f := sensorFilter(q.Sensor, "sensor_id")
+	if q.SourceCIDR.IsValid() {
+		f.add("source_ip <<= ?::inet", q.SourceCIDR.Masked().String())
+	}
+	if q.SourceClass != "" {
+		f.add("source_class = ?", q.SourceClass)
+	}
+	query := `SELECT ` + store.LogEventColumns + ` FROM log_data` + f.where() +
+		` ORDER BY timestamp DESC LIMIT ` + f.arg(q.Limit) + ` OFFSET ` + f.arg(q.Offset)
+	rows, err := s.DB.Query(query, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := []parser.LogEvent{}
+	for rows.Next() {
+		ev, err := store.ScanLogEvent(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log entry: %w", err)
+		}
+		logs = append(logs, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	return logs, nil
+}
+
+// Geo returns the geolocation data stored for ip.
+func (s *Store) Geo(ip, sensor string) (*geo.Data, error) {
+	query := `SELECT ` + strings.Join(store.GeoColumns, ", ") + ` FROM ip_geo g WHERE g.ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+	var row store.GeoRow
+	err := s.DB.QueryRow(query, args...).Scan(row.Dest()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geolocation data for IP %s: %w", ip, err)
+	}
+	return row.Data(), nil
+}
+
+// Sensors returns each sensor with its log count and the time range of its logs.
+func (s *Store) Sensors(sensor string) ([]store.Sensor, error) {
+	f := sensorFilter(sensor, "s.id")
+	rows, err := s.DB.Query(`
+		SELECT s.id, s.created_at, COUNT(l.id), MIN(l.timestamp), MAX(l.timestamp)
+		FROM sensors s
+		LEFT JOIN log_data l ON l.sensor_id = s.id`+f.where()+`
+		GROUP BY s.id, s.created_at
+		ORDER BY s.id`, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []store.Sensor{}
+	for rows.Next() {
+		var sensor store.Sensor
+		var first, last sql.NullTime
+		if err := rows.Scan(&sensor.ID, &sensor.CreatedAt, &sensor.LogCount, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to read sensor: %w", err)
+		}
+		if first.Valid {
+			sensor.FirstLog, sensor.LastLog = &first.Time, &last.Time
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	return sensors, nil
+}
+
+// Stats reports the size of the database, the live row count and size of each
+// table, and the log_data partitions.
+func (s *Store) Stats() (store.Stats, error) {
+	var stats store.Stats
+	if err := s.DB.QueryRow(`SELECT pg_database_size(current_database())`).Scan(&stats.DatabaseSize); err != nil {
+		return stats, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT c.relname, COALESCE(s.n_live_tup, 0), pg_total_relation_size(c.oid)
+		FROM pg_class c
+		JOIN pg_stat_user_tables s ON c.relname = s.relname
+		ORDER BY pg_total_relation_size(c.oid) DESC`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get table statistics: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t store.TableStats
+		if err := rows.Scan(&t.Name, &t.Rows, &t.Size); err != nil {
+			return stats, fmt.Errorf("failed to get table statistics: %w", err)
+		}
+		stats.Tables = append(stats.Tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to get table statistics: %w", err)
+	}
+
+	if stats.Partitions, err = ListPartitions(s.DB); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// CheckSchema checks that every embedded migration has been applied.
+func (s *Store) CheckSchema() error {
+	return CheckSchema(s.DB)
+}
+
+// MigrateUp applies every pending migration.
+func (s *Store) MigrateUp() ([]store.Migration, error) {
+	return MigrateUp(s.DB)
+}
+
+// MigrateDown reverts the last steps migrations.
+func (s *Store) MigrateDown(steps int) ([]store.Migration, error) {
+	return MigrateDown(s.DB, steps)
+}
+
+// MigrationStatuses returns every embedded migration and when it was applied.
+func (s *Store) MigrationStatuses() ([]store.MigrationStatus, error) {
+	return MigrationStatuses(s.DB)
+}
+
+// Maintain creates the partition for now and the one after it, so that they
+// exist before entries arrive.
+func (s *Store) Maintain(now time.Time) error {
+	return s.partitions.EnsureUpcoming(now, 1)
+}
+
+// Partitions returns the partitions of log_data in time order.
+func (s *Store) Partitions() ([]store.Partition, error) {
+	return ListPartitions(s.DB)
+}
+
+// DropPartition drops a partition of log_data along with its rows.
+func (s *Store) DropPartition(p store.Partition) error {
+	defer s.partitions.Forget()
+	return DropPartition(s.DB, p.Name)
+}
+
+// Close closes the database connection.
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
@@ -0,0 +1,386 @@
+package follow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPollInterval       = time.Second
+	defaultCheckpointInterval = 10 * time.Second
+
+	// checkpointEvery is how many lines are read between checkpoint interval checks.
+	checkpointEvery = 1000
+
+	// storeRetries is how many times lines that were not stored are read
+	// again before they are given up.
+	storeRetries = 3
+)
+
+// Follower tails a log file like `tail -F`, saving its position so a restart
+// resumes where the previous run stopped. It detects rotation by rename
+// (finishing the old file first) and by truncation, including copytruncate.
+type Follower struct {
+	path  string
+	state *StateFile
+	emit  func(line string)
+	flush func() error
+
+	// PollInterval is how long to wait at the end of the file before checking for more data.
+	PollInterval time.Duration
+	// CheckpointInterval is the minimum time between saved positions while lines are flowing.
+	CheckpointInterval time.Duration
+
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64  // end of the last complete line read from file
+	partial []byte // an incomplete trailing line, held until its newline arrives
+	headLen int64
+	head    string
+
+	dirty          bool // lines emitted since the last checkpoint
+	lastCheckpoint time.Time
+
+	// held is set while flush reports lines that were not stored. The saved
+	// position then stays before the first of them, and the lines from there
+	// on are read again, up to storeRetries times; lines that were stored are
+	// skipped as duplicates. It is cleared once a flush succeeds, or when the
+	// file is rotated or truncated.
+	held     bool
+	failures int // Failed flushes since the position was last saved
+}
+
+// New creates a Follower for path. Every complete line is passed to emit.
+// Before a position is saved, flush is called and must block until all lines
+// emitted so far have been fully processed, so a crash never skips a line. If
+// flush returns an error because some of them could not be stored, the lines
+// since the saved position are read again, and no later position is saved
+// until a flush succeeds.
+func New(path string, state *StateFile, emit func(line string), flush func() error) *Follower {
+	return &Follower{
+		path:               path,
+		state:              state,
+		emit:               emit,
+		flush:              flush,
+		PollInterval:       defaultPollInterval,
+		CheckpointInterval: defaultCheckpointInterval,
+	}
+}
+
+// Run follows the file until ctx is cancelled, then saves its final position.
+func (f *Follower) Run(ctx context.Context) error {
+	if err := f.start(); err != nil {
+		return err
+	}
+	defer func() {
+		if f.file != nil {
+			f.file.Close()
+		}
+	}()
+
+	for {
+		// Look for rotation before reading, so data written to a truncated
+		// file is never read from the stale offset.
+		if err := f.checkRotation(); err != nil {
+			return err
+		}
+		if err := f.readAvailable(); err != nil {
+			return err
+		}
+		if f.dirty && time.Since(f.lastCheckpoint) >= f.CheckpointInterval {
+			if err := f.checkpoint(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return f.checkpoint()
+		case <-time.After(f.PollInterval):
+		}
+	}
+}
+
+// start opens the file and positions it from the saved state.
+func (f *Follower) start() error {
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	pos, ok := f.state.Get(f.path)
+	if !ok {
+		return f.checkpoint()
+	}
+
+	current := inode(f.info)
+	if pos.Inode == current {
+		same, err := sameHead(f.file, f.info.Size(), pos)
+		if err != nil {
+			return err
+		}
+		if same && pos.Offset <= f.info.Size() {
+			if _, err := f.file.Seek(pos.Offset, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to seek %s: %w", f.path, err)
+			}
+			f.reader.Reset(f.file)
+			f.offset = pos.Offset
+		}
+		// Otherwise the file was truncated while we were stopped; start over.
+		return f.checkpoint()
+	}
+
+	// The file was rotated while we were stopped. Finish the previous file
+	// first if logrotate left it next to the current one.
+	if err := f.finishRotated(pos); err != nil {
+		return err
+	}
+	return f.checkpoint()
+}
+
+// open opens the followed path from its beginning.
+func (f *Follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.info = info
+	f.reader = bufio.NewReader(file)
+	f.offset = 0
+	f.partial = nil
+	f.headLen, f.head = 0, ""
+	return f.updateHead()
+}
+
+// updateHead fingerprints the start of the file until a full head is recorded.
+func (f *Follower) updateHead() error {
+	if f.headLen >= headSize {
+		return nil
+	}
+	info, err := f.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	f.headLen, f.head, err = fingerprint(f.file, info.Size())
+	return err
+}
+
+// finishRotated emits the unread remainder of the rotated-away file described by pos.
+func (f *Follower) finishRotated(pos Position) error {
+	if pos.Inode == 0 {
+		return nil
+	}
+	// Rotated names are syslog.1 (numbered) or syslog-20250101 (dateext).
+	var candidates []string
+	for _, pattern := range []string{f.path + ".*", f.path + "-*"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, matches...)
+	}
+	for _, candidate := range candidates {
+		if strings.HasSuffix(candidate, ".gz") {
+			continue
+		}
+		info, err := os.Stat(candidate)
+		if err != nil || inode(info) != pos.Inode || info.Size() < pos.Offset {
+			continue
+		}
+
+		old, err := os.Open(candidate)
+		if err != nil {
+			return fmt.Errorf("failed to open rotated file %s: %w", candidate, err)
+		}
+		defer old.Close()
+		if _, err := old.Seek(pos.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek rotated file %s: %w", candidate, err)
+		}
+		scanner := bufio.NewScanner(old)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			f.emitLine(scanner.Bytes())
+		}
+		return scanner.Err()
+	}
+	return nil
+}
+
+// readAvailable emits every complete line up to the current end of the file.
+func (f *Follower) readAvailable() error {
+	for n := 1; ; n++ {
+		chunk, err := f.reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if chunk[len(chunk)-1] == '\n' {
+				line := chunk
+				if len(f.partial) > 0 {
+					line = append(f.partial, chunk...)
+					f.partial = nil
+				}
+				f.offset += int64(len(line))
+				f.emitLine(line)
+			} else {
+				f.partial = append(f.partial, chunk...)
+			}
+		}
+
+		switch err {
+		case nil, bufio.ErrBufferFull:
+		case io.EOF:
+			return nil
+		default:
+			return fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+
+		if n%checkpointEvery == 0 && time.Since(f.lastCheckpoint) >= f.CheckpointInterval {
+			if err := f.checkpoint(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// emitLine passes a line to the consumer without its line terminator.
+func (f *Follower) emitLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	f.emit(string(line))
+	f.dirty = true
+}
+
+// checkRotation detects a renamed or truncated file and reopens or rewinds it.
+func (f *Follower) checkRotation() error {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		// Renamed away and not recreated yet; keep reading the old file.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	if !os.SameFile(info, f.info) {
+		// Renamed: finish the old file, including a final unterminated line.
+		if err := f.readAvailable(); err != nil {
+			return err
+		}
+		if len(f.partial) > 0 {
+			f.emitLine(f.partial)
+			f.partial = nil
+		}
+		if err := f.open(); err != nil {
+			return err
+		}
+		// Lines held back in the old file cannot be read again.
+		f.held, f.failures = false, 0
+		return f.checkpoint()
+	}
+
+	size := info.Size()
+	truncated := size < f.offset+int64(len(f.partial))
+	if !truncated {
+		same, err := sameHead(f.file, size, Position{HeadLen: f.headLen, Head: f.head})
+		if err != nil {
+			return err
+		}
+		truncated = !same
+	}
+	if truncated {
+		// Truncated in place (copytruncate): start again from the beginning.
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek %s: %w", f.path, err)
+		}
+		f.reader.Reset(f.file)
+		f.offset = 0
+		f.partial = nil
+		f.headLen, f.head = 0, ""
+		// Lines held back are gone with the old contents.
+		f.held, f.failures = false, 0
+		if err := f.updateHead(); err != nil {
+			return err
+		}
+		return f.checkpoint()
+	}
+	return nil
+}
+
+// checkpoint waits for emitted lines to be processed and saves the position.
+// If some were not stored, the position is held and they are read again.
+func (f *Follower) checkpoint() error {
+	if f.flush != nil {
+		if err := f.flush(); err != nil {
+			f.failures++
+			if !f.held {
+				log.Printf("Keeping the saved position in %s before lines that were not stored: %v", f.path, err)
+				f.held = true
+			}
+			if err := f.retry(); err != nil {
+				return err
+			}
+		} else if f.held {
+			log.Printf("Lines of %s are stored again", f.path)
+			f.held, f.failures = false, 0
+		}
+	}
+	if err := f.updateHead(); err != nil {
+		return err
+	}
+	if f.held {
+		f.dirty = false
+		f.lastCheckpoint = time.Now()
+		return nil
+	}
+	pos := Position{
+		Inode:   inode(f.info),
+		Offset:  f.offset,
+		HeadLen: f.headLen,
+		Head:    f.head,
+	}
+	if err := f.state.Set(f.path, pos); err != nil {
+		return err
+	}
+	f.dirty = false
+	f.lastCheckpoint = time.Now()
+	return nil
+}
+
+// retry rewinds the file to the saved position, so that the lines since then
+// are emitted again, unless they have already been retried storeRetries times
+// or the saved position is in a file that has been rotated away.
+func (f *Follower) retry() error {
+	if f.failures > storeRetries {
+		if f.failures == storeRetries+1 {
+			log.Printf("Giving up on lines of %s not stored after %d attempts", f.path, f.failures)
+		}
+		return nil
+	}
+	pos, ok := f.state.Get(f.path)
+	if !ok || pos.Inode != inode(f.info) || pos.Offset > f.offset {
+		return nil
+	}
+	if _, err := f.file.Seek(pos.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek %s: %w", f.path, err)
+	}
+	f.reader.Reset(f.file)
+	f.offset = pos.Offset
+	f.partial = nil
+	return nil
+}
@@ -0,0 +1,261 @@
+package follow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lineCollector records emitted lines for follower tests. flush reports the
+// lines in unstored as not stored, as many times as each is mapped to.
+type lineCollector struct {
+	mu       sync.Mutex
+	lines    []string
+	unstored map[string]int
+	failed   int
+}
+
+func (c *lineCollector) emit(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, line)
+	if c.unstored[line] > 0 {
+		c.unstored[line]--
+		c.failed++
+	}
+}
+
+func (c *lineCollector) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed > 0 {
+		n := c.failed
+		c.failed = 0
+		return fmt.Errorf("%d line(s) not stored", n)
+	}
+	return nil
+}
+
+func (c *lineCollector) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.lines) >= n {
+			lines := append([]string(nil), c.lines...)
+			c.mu.Unlock()
+			return lines
+		}
+		c.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.Fatalf("Timed out waiting for %d lines, got %q", n, c.lines)
+	return nil
+}
+
+// startFollower runs a follower in the background and returns a function that stops it.
+func startFollower(t *testing.T, logPath, statePath string, c *lineCollector) func() {
+	t.Helper()
+	state, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	f := New(logPath, state, c.emit, c.flush)
+	f.PollInterval = 10 * time.Millisecond
+	f.CheckpointInterval = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Follower returned an error: %v", err)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func expectLines(t *testing.T, got, expected []string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d lines %q, got %d lines %q", len(expected), expected, len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected line %d to be %q, got %q", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestFollower_ResumeFromState(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "syslog")
+	statePath := filepath.Join(dir, "state.json")
+
+	appendFile(t, logPath, "line1\nline2\n")
+
+	c := &lineCollector{}
+	stop := startFollower(t, logPath, statePath, c)
+	c.waitFor(t, 2)
+	stop()
+
+	// Lines written while stopped are picked up on restart, without repeats.
+	appendFile(t, logPath, "line3\n")
+
+	c2 := &lineCollector{}
+	stop = startFollower(t, logPath, statePath, c2)
+	lines := c2.waitFor(t, 1)
+	stop()
+
+	expectLines(t, lines, []string{"line3"})
+}
+
+func TestFollower_PartialLine(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "syslog")
+
+	appendFile(t, logPath, "line1\nli")
+
+	c := &lineCollector{}
+	stop := startFollower(t, logPath, filepath.Join(dir, "state.json"), c)
+	c.waitFor(t, 1)
+
+	// The unterminated line is only emitted once its newline arrives.
+	appendFile(t, logPath, "ne2\n")
+	lines := c.waitFor(t, 2)
+	stop()
+
+	expectLines(t, lines, []string{"line1", "line2"})
+}
+
+func TestFollower_UnstoredLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int // Times line2 is not stored
+		expected []string
+	}{
+		{"Stored once retried", 1, []string{"line1", "line2", "line3", "line2", "line3"}},
+		{"Given up after retries", 100, []string{"line1", "line2", "line3", "line2", "line3", "line2", "line3", "line2", "line3"}},
+	}
+	for _, tc := range tests {
+		dir := t.TempDir()
+		logPath := filepath.Join(dir, "syslog")
+		statePath := filepath.Join(dir, "state.json")
+
+		appendFile(t, logPath, "line1\n")
+		c := &lineCollector{unstored: map[string]int{"line2": tc.failures}}
+		stop := startFollower(t, logPath, statePath, c)
+		c.waitFor(t, 1)
+		time.Sleep(50 * time.Millisecond) // Let the position after line1 be saved
+
+		// Lines after the saved position are read again until they are
+		// stored or given up.
+		appendFile(t, logPath, "line2\nline3\n")
+		c.waitFor(t, len(tc.expected))
+		time.Sleep(50 * time.Millisecond)
+		stop()
+		expectLines(t, c.lines, tc.expected)
+
+		// Positions are saved again afterwards, so a restart does not read
+		// the same lines again.
+		appendFile(t, logPath, "line4\n")
+		c2 := &lineCollector{}
+		stop = startFollower(t, logPath, statePath, c2)
+		lines := c2.waitFor(t, 1)
+		time.Sleep(50 * time.Millisecond)
+		stop()
+		expectLines(t, lines, []string{"line4"})
+	}
+}
+
+func TestFollower_RenameRotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "syslog")
+
+	appendFile(t, logPath, "line1\n")
+
+	c := &lineCollector{}
+	stop := startFollower(t, logPath, filepath.Join(dir, "state.json"), c)
+	c.waitFor(t, 1)
+
+	// Write to the old file, rotate it away, and start a new one.
+	appendFile(t, logPath, "line2\n")
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	appendFile(t, logPath, "line3\n")
+
+	lines := c.waitFor(t, 3)
+	stop()
+
+	expectLines(t, lines, []string{"line1", "line2", "line3"})
+}
+
+func TestFollower_RotationWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "syslog")
+	statePath := filepath.Join(dir, "state.json")
+
+	appendFile(t, logPath, "line1\n")
+
+	c := &lineCollector{}
+	stop := startFollower(t, logPath, statePath, c)
+	c.waitFor(t, 1)
+	stop()
+
+	appendFile(t, logPath, "line2\n")
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	appendFile(t, logPath, "line3\n")
+
+	c2 := &lineCollector{}
+	stop = startFollower(t, logPath, statePath, c2)
+	lines := c2.waitFor(t, 2)
+	stop()
+
+	expectLines(t, lines, []string{"line2", "line3"})
+}
+
+func TestFollower_CopyTruncate(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "syslog")
+
+	appendFile(t, logPath, "first line of the old log\n")
+
+	c := &lineCollector{}
+	stop := startFollower(t, logPath, filepath.Join(dir, "state.json"), c)
+	c.waitFor(t, 1)
+
+	// Truncate in place and write a longer line than was there before, so the
+	// size alone does not reveal the truncation.
+	if err := os.Truncate(logPath, 0); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	appendFile(t, logPath, "new log line that is longer than the old one\n")
+
+	lines := c.waitFor(t, 2)
+	stop()
+
+	expectLines(t, lines, []string{"first line of the old log", "new log line that is longer than the old one"})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package follow
+
+import "os"
+
+// inode is not available on this platform; files are recognised by their head only.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
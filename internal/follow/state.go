@@ -0,0 +1,110 @@
+package follow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// headSize is how many leading bytes of a file are fingerprinted to recognise it.
+const headSize = 512
+
+// Position records how far a followed file has been processed.
+type Position struct {
+	Inode   uint64 `json:"inode"`
+	Offset  int64  `json:"offset"`
+	HeadLen int64  `json:"head_len"` // number of leading bytes covered by Head
+	Head    string `json:"head"`     // SHA-256 of the first HeadLen bytes
+}
+
+// StateFile persists positions for any number of followed files, keyed by path.
+type StateFile struct {
+	path      string
+	positions map[string]Position
+}
+
+// LoadState reads the state file at path. A missing file yields empty state.
+func LoadState(path string) (*StateFile, error) {
+	s := &StateFile{path: path, positions: make(map[string]Position)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.positions); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the saved position for a followed file.
+func (s *StateFile) Get(file string) (Position, bool) {
+	pos, ok := s.positions[file]
+	return pos, ok
+}
+
+// Set records a position and writes the state file atomically.
+func (s *StateFile) Set(file string, pos Position) error {
+	s.positions[file] = pos
+
+	data, err := json.MarshalIndent(s.positions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// fingerprint hashes the first n bytes of f, where n is at most headSize.
+func fingerprint(f io.ReaderAt, size int64) (int64, string, error) {
+	n := min(size, headSize)
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return 0, "", fmt.Errorf("failed to read file head: %w", err)
+	}
+	sum := sha256.Sum256(buf)
+	return n, hex.EncodeToString(sum[:]), nil
+}
+
+// sameHead reports whether f still starts with the bytes recorded in pos.
+// Files shorter than the recorded head cannot match.
+func sameHead(f io.ReaderAt, size int64, pos Position) (bool, error) {
+	if pos.Head == "" {
+		return true, nil
+	}
+	if size < pos.HeadLen {
+		return false, nil
+	}
+	_, head, err := fingerprint(f, pos.HeadLen)
+	if err != nil {
+		return false, err
+	}
+	return head == pos.Head, nil
+}
@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"fmt"
+	"minerva/internal/config"
+)
+
+// NewProvider returns the chain of providers listed in conf. Close the chain
+// when done with it.
+func NewProvider(conf config.GeoConfig) (Chain, error) {
+	if len(conf.Providers) == 0 {
+		return nil, fmt.Errorf("no geolocation providers configured")
+	}
+
+	var chain Chain
+	for _, name := range conf.Providers {
+		var p Provider
+		switch name {
+		case "ip-api":
+			p = NewIPAPI(conf.IPAPI.URL, conf.IPAPI.PerMinute)
+		case "mmdb":
+			if conf.MMDB.CityPath == "" {
+				chain.Close()
+				return nil, fmt.Errorf("the mmdb provider needs city_path")
+			}
+			m, err := OpenMMDB(conf.MMDB.CityPath, conf.MMDB.ASNPath)
+			if err != nil {
+				chain.Close()
+				return nil, err
+			}
+			p = m
+		case "http":
+			fields := FieldMap{
+				Country:   conf.HTTP.Fields.Country,
+				Region:    conf.HTTP.Fields.Region,
+				City:      conf.HTTP.Fields.City,
+				ISP:       conf.HTTP.Fields.ISP,
+				Latitude:  conf.HTTP.Fields.Latitude,
+				Longitude: conf.HTTP.Fields.Longitude,
+				ASN:       conf.HTTP.Fields.ASN,
+				ASOrg:     conf.HTTP.Fields.ASOrg,
+				ASPrefix:  conf.HTTP.Fields.ASPrefix,
+				Hosting:   conf.HTTP.Fields.Hosting,
+			}
+			h, err := NewHTTPJSON(conf.HTTP.URL, conf.HTTP.Headers, fields, conf.HTTP.PerMinute)
+			if err != nil {
+				chain.Close()
+				return nil, fmt.Errorf("invalid http provider: %w", err)
+			}
+			p = h
+		default:
+			chain.Close()
+			return nil, fmt.Errorf("unknown geolocation provider %q", name)
+		}
+		chain = append(chain, p)
+	}
+	return chain, nil
+}
@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"errors"
+	"time"
+)
+
+// PermanentError is returned by a Provider for a lookup that would fail the
+// same way if retried, such as an address in a private or reserved range, or
+// one its database has no record of.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err is, or wraps, a PermanentError. Errors
+// joined with errors.Join are not searched, since one permanent failure among
+// them does not make the whole permanent; Chain wraps them when it does.
+func IsPermanent(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if _, ok := err.(*PermanentError); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Failure is a failed lookup of an address. The address is not looked up
+// again before RetryAfter, so that addresses no provider has data for are not
+// queried on every run.
+type Failure struct {
+	Reason      string
+	Permanent   bool
+	Attempts    int // Failed lookups in a row
+	LastAttempt time.Time
+	RetryAfter  time.Time // The zero Time never retries
+}
+
+// Due reports whether the address may be looked up again at now.
+func (f *Failure) Due(now time.Time) bool {
+	return f == nil || (!f.RetryAfter.IsZero() && !now.Before(f.RetryAfter))
+}
+
+// FailureHandler stores failed lookups.
+type FailureHandler interface {
+	// GeoFailure returns the last failed lookup of ip, or nil if it has not
+	// failed since its data was last stored.
+	GeoFailure(ip string) (*Failure, error)
+	// RecordGeoFailure stores a failed lookup of ip, replacing the previous
+	// one. Storing data for ip with InsertOrUpdateGeoData clears it.
+	RecordGeoFailure(ip string, f Failure) error
+}
+
+const (
+	// minRetry and maxRetry bound the wait after a transient failure, which
+	// doubles with each failed attempt.
+	minRetry = time.Hour
+	maxRetry = 24 * time.Hour
+)
+
+// newFailure returns the Failure of a lookup that failed with err at now,
+// after prev. Permanent failures are retried after ttl, like stored data, or
+// never if ttl is 0; transient ones sooner, backing off while they keep
+// failing.
+func newFailure(err error, prev *Failure, ttl time.Duration, now time.Time) Failure {
+	f := Failure{Reason: err.Error(), Permanent: IsPermanent(err), Attempts: 1, LastAttempt: now}
+	if prev != nil {
+		f.Attempts = prev.Attempts + 1
+	}
+	if f.Permanent {
+		if ttl > 0 {
+			f.RetryAfter = now.Add(ttl)
+		}
+		return f
+	}
+	wait := maxRetry
+	if f.Attempts <= 5 {
+		wait = min(minRetry<<(f.Attempts-1), maxRetry)
+	}
+	if ttl > 0 && ttl < wait {
+		wait = ttl
+	}
+	f.RetryAfter = now.Add(wait)
+	return f
+}
+
+// recordFailure records that looking ip up failed with lookupErr.
+func recordFailure(handler FailureHandler, ip string, lookupErr error, ttl time.Duration) error {
+	prev, err := handler.GeoFailure(ip)
+	if err != nil {
+		return err
+	}
+	return handler.RecordGeoFailure(ip, newFailure(lookupErr, prev, ttl, time.Now()))
+}
@@ -1,29 +1,32 @@
+// Package geo looks up and stores geolocation data for source addresses.
+//
+// Lookups go through a Provider: ip-api.com, an offline MaxMind or DB-IP
+// .mmdb database, or any HTTP JSON API, chained so that each address is
+// looked up in the next provider when the previous one has no data for it.
 package geo
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
-var apiURL = "http://ip-api.com/json" // Default URL for geolocation API. Can be overridden using SetAPIURL.
-
-// client is a reusable HTTP client with a timeout.
+// client is a reusable HTTP client with a timeout, shared by the HTTP providers.
 var client = &http.Client{Timeout: 10 * time.Second}
 
-// SetAPIURL allows overriding the default geolocation API URL.
-func SetAPIURL(url string) {
-	apiURL = url
-}
-
 // SetHTTPClient allows overriding the default HTTP client.
 func SetHTTPClient(c *http.Client) {
 	client = c
 }
 
-// Data represents geolocation information for an IP address.
+// ErrNoData is returned by a Provider that has no data for an address.
+var ErrNoData = errors.New("no geolocation data")
+
+// Data represents geolocation information for an IP address, and the network
+// that announces it.
 type Data struct {
 	Country   string  `json:"country"`
 	Region    string  `json:"regionName"`
@@ -31,38 +34,84 @@ type Data struct {
 	ISP       string  `json:"isp"`
 	Latitude  float64 `json:"lat"`
 	Longitude float64 `json:"lon"`
+
+	ASN      uint32 `json:"asn"`       // Autonomous system number; 0 if unknown
+	ASOrg    string `json:"as_org"`    // Organization that owns the AS
+	ASPrefix string `json:"as_prefix"` // Announced prefix containing the address, if known
+	Hosting  bool   `json:"hosting"`   // Hosting, colocation or data center address
 }
 
-// FetchGeolocation retrieves geolocation data for the given IP address by querying the geolocation API.
-func FetchGeolocation(ip string) (*Data, error) {
-	url := fmt.Sprintf("%s/%s", apiURL, ip)
+// Provider looks up geolocation data.
+type Provider interface {
+	// Name identifies the provider in configuration and logs.
+	Name() string
+	// Lookup returns the data for ip, or an error wrapping ErrNoData if the
+	// provider has none. Errors that a retry would not fix, including those,
+	// are PermanentErrors. Providers backed by a rate-limited service wait for
+	// their turn; offline providers return at once.
+	Lookup(ip string) (*Data, error)
+}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch geolocation data: %w", err)
+// Chain is a Provider that tries each of its providers in order and returns
+// the first data found.
+type Chain []Provider
+
+// Name returns the names of the providers, in order.
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
 	}
-	defer resp.Body.Close()
+	return strings.Join(names, ",")
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status code %d", resp.StatusCode)
+// Lookup returns the data of the first provider that has some for ip. If none
+// does, the error joins the error of every provider, and is permanent if each
+// of them is.
+func (c Chain) Lookup(ip string) (*Data, error) {
+	var errs []error
+	for _, p := range c {
+		data, err := p.Lookup(ip)
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
 	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no geolocation provider: %w", ErrNoData)
+	}
+	err := errors.Join(errs...)
+	for _, e := range errs {
+		if !IsPermanent(e) {
+			return nil, err
+		}
+	}
+	return nil, &PermanentError{Err: err}
+}
 
-	var geoData Data
-	if err := json.NewDecoder(resp.Body).Decode(&geoData); err != nil {
-		return nil, fmt.Errorf("failed to decode geolocation data: %w", err)
+// Close closes the providers that hold resources, such as open database files.
+func (c Chain) Close() error {
+	var errs []error
+	for _, p := range c {
+		if closer, ok := p.(interface{ Close() error }); ok {
+			errs = append(errs, closer.Close())
+		}
 	}
-	return &geoData, nil
+	return errors.Join(errs...)
 }
 
 // DataHandler defines methods for geolocation data handling.
 type DataHandler interface {
 	IsIPInGeoTable(ip string) (bool, error)
 	InsertOrUpdateGeoData(ip string, geoData *Data) error
+	FailureHandler
 }
 
 // ProcessIP handles the full lifecycle of fetching and storing geolocation data for an IP.
-// If the IP already exists in the geo table or an error occurs, it logs the error and returns.
-func ProcessIP(handler DataHandler, ip string) (err error) {
+// If the IP already exists in the geo table, or a failed lookup of it is not due
+// for a retry, it returns. A failed lookup is logged and recorded, to be retried
+// after ttl if it is permanent, or sooner if not.
+func ProcessIP(provider Provider, handler DataHandler, ip string, ttl time.Duration) (err error) {
 	// Check if the IP already exists in the ip_geo table.
 	exists, err := handler.IsIPInGeoTable(ip)
 	if err != nil {
@@ -71,11 +120,21 @@ func ProcessIP(handler DataHandler, ip string) (err error) {
 	if exists {
 		return
 	}
+	prev, err := handler.GeoFailure(ip)
+	if err != nil {
+		return
+	}
+	if !prev.Due(time.Now()) {
+		return
+	}
 
 	// Fetch geolocation data.
-	geoData, err := FetchGeolocation(ip)
+	geoData, err := provider.Lookup(ip)
 	if err != nil {
 		log.Printf("Error fetching geolocation for IP %s: %v", ip, err)
+		if err := handler.RecordGeoFailure(ip, newFailure(err, prev, ttl, time.Now())); err != nil {
+			log.Printf("Error recording failed geolocation lookup for IP %s: %v", ip, err)
+		}
 		return
 	}
 
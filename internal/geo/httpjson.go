@@ -0,0 +1,192 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"minerva/internal/ratelimit"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FieldMap names where each Data field is found in a JSON response, as a
+// dotted path such as "location.city". Fields with an empty path are left
+// empty.
+type FieldMap struct {
+	Country   string
+	Region    string
+	City      string
+	ISP       string
+	Latitude  string
+	Longitude string
+	ASN       string // A number, or a string such as "AS15169"
+	ASOrg     string
+	ASPrefix  string
+	Hosting   string // A boolean, or a string such as "true"
+}
+
+// HTTPJSON is a Provider for any HTTP API that returns a JSON object per
+// address, with its fields mapped by a FieldMap.
+type HTTPJSON struct {
+	url     string
+	headers map[string]string
+	fields  FieldMap
+	limiter *ratelimit.Limiter
+}
+
+// NewHTTPJSON returns a Provider that queries urlTemplate, in which "{ip}" is
+// replaced by the address, with the given request headers, at most perMinute
+// times a minute; 0 does not limit the rate.
+func NewHTTPJSON(urlTemplate string, headers map[string]string, fields FieldMap, perMinute int) (*HTTPJSON, error) {
+	if !strings.Contains(urlTemplate, "{ip}") {
+		return nil, fmt.Errorf("URL %q has no {ip} placeholder", urlTemplate)
+	}
+	return &HTTPJSON{url: urlTemplate, headers: headers, fields: fields, limiter: ratelimit.New(perMinute)}, nil
+}
+
+// Name returns "http".
+func (p *HTTPJSON) Name() string {
+	return "http"
+}
+
+// Lookup waits for the rate limit, queries the API and maps its response.
+func (p *HTTPJSON) Lookup(ip string) (*Data, error) {
+	p.limiter.Wait()
+
+	req, err := http.NewRequest(http.MethodGet, strings.ReplaceAll(p.url, "{ip}", url.PathEscape(ip)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build geolocation request: %w", err)
+	}
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch geolocation data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &PermanentError{Err: ErrNoData}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status code %d", resp.StatusCode)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode geolocation data: %w", err)
+	}
+	data := &Data{
+		Country:  stringField(body, p.fields.Country),
+		Region:   stringField(body, p.fields.Region),
+		City:     stringField(body, p.fields.City),
+		ISP:      stringField(body, p.fields.ISP),
+		ASOrg:    stringField(body, p.fields.ASOrg),
+		ASPrefix: stringField(body, p.fields.ASPrefix),
+	}
+	if data.Latitude, err = floatField(body, p.fields.Latitude); err != nil {
+		return nil, err
+	}
+	if data.Longitude, err = floatField(body, p.fields.Longitude); err != nil {
+		return nil, err
+	}
+	if data.ASN, err = asnField(body, p.fields.ASN); err != nil {
+		return nil, err
+	}
+	if data.Hosting, err = boolField(body, p.fields.Hosting); err != nil {
+		return nil, err
+	}
+	if *data == (Data{}) {
+		return nil, &PermanentError{Err: ErrNoData}
+	}
+	return data, nil
+}
+
+// field returns the value at the dotted path in a decoded JSON document, or
+// nil if there is none.
+func field(body interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := body.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		body = object[key]
+	}
+	return body
+}
+
+// stringField returns the string at path, or "" if it is missing or not a
+// string.
+func stringField(body interface{}, path string) string {
+	s, _ := field(body, path).(string)
+	return s
+}
+
+// floatField returns the number at path, which may also be a numeric string.
+// A missing value is 0.
+func floatField(body interface{}, path string) (float64, error) {
+	switch v := field(body, path).(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number at %s: %q", path, v)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("invalid number at %s: %v", path, v)
+	}
+}
+
+// asnField returns the AS number at path, which may be a number or a string
+// with or without the "AS" prefix. A missing value is 0.
+func asnField(body interface{}, path string) (uint32, error) {
+	switch v := field(body, path).(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if v < 0 || v > math.MaxUint32 || v != math.Trunc(v) {
+			return 0, fmt.Errorf("invalid AS number at %s: %v", path, v)
+		}
+		return uint32(v), nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		n, err := parseASN(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid AS number at %s: %q", path, v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid AS number at %s: %v", path, v)
+	}
+}
+
+// boolField returns the boolean at path, which may also be a string such as
+// "true". A missing value is false.
+func boolField(body interface{}, path string) (bool, error) {
+	switch v := field(body, path).(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("invalid boolean at %s: %q", path, v)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("invalid boolean at %s: %v", path, v)
+	}
+}
@@ -0,0 +1,208 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minerva/internal/ratelimit"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var apiURL = "http://ip-api.com/json" // Default URL for geolocation API. Can be overridden using SetAPIURL.
+
+// SetAPIURL allows overriding the default geolocation API URL.
+func SetAPIURL(url string) {
+	apiURL = url
+}
+
+// FetchGeolocation retrieves geolocation data for the given IP address by querying the geolocation API.
+func FetchGeolocation(ip string) (*Data, error) {
+	data, _, err := fetchIPAPI(apiURL, ip)
+	return data, err
+}
+
+// ipAPIFields are the fields requested from ip-api.com. hosting is only
+// returned when asked for.
+const ipAPIFields = "status,message,country,regionName,city,isp,lat,lon,as,asname,hosting"
+
+// ipAPIResponse is the JSON returned by ip-api.com. Status is "fail" for
+// queries it cannot answer, such as private or reserved addresses. AS is the
+// AS number and organization, as in "AS15169 Google LLC".
+type ipAPIResponse struct {
+	Data
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	AS      string `json:"as"`
+	ASName  string `json:"asname"`
+}
+
+// statusError is an unexpected HTTP status from an API.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned status code %d", e.code)
+}
+
+// rateWindow is the state of ip-api.com's rate limit, from the X-Rl and X-Ttl
+// headers of a response: the requests left in the current window and the time
+// until the next one.
+type rateWindow struct {
+	remaining int
+	reset     time.Duration
+	known     bool
+}
+
+// parseRateWindow reads the rate limit headers of h.
+func parseRateWindow(h http.Header) rateWindow {
+	remaining, err := strconv.Atoi(h.Get("X-Rl"))
+	if err != nil {
+		return rateWindow{}
+	}
+	ttl, err := strconv.Atoi(h.Get("X-Ttl"))
+	if err != nil || ttl < 0 {
+		return rateWindow{}
+	}
+	return rateWindow{remaining: remaining, reset: time.Duration(ttl) * time.Second, known: true}
+}
+
+// fetchIPAPI queries the ip-api.com compatible API at url for ip. The rate
+// window is returned even with an error.
+func fetchIPAPI(url, ip string) (*Data, rateWindow, error) {
+	resp, err := client.Get(fmt.Sprintf("%s/%s?fields=%s", url, ip, ipAPIFields))
+	if err != nil {
+		return nil, rateWindow{}, fmt.Errorf("failed to fetch geolocation data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	window := parseRateWindow(resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		return nil, window, &statusError{code: resp.StatusCode}
+	}
+
+	var geoData ipAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geoData); err != nil {
+		return nil, window, fmt.Errorf("failed to decode geolocation data: %w", err)
+	}
+	if geoData.Status == "fail" {
+		return nil, window, &PermanentError{Err: fmt.Errorf("%s: %w", geoData.Message, ErrNoData)}
+	}
+	geoData.ASN, geoData.ASOrg = parseAS(geoData.AS)
+	if geoData.ASOrg == "" {
+		geoData.ASOrg = geoData.ASName
+	}
+	return &geoData.Data, window, nil
+}
+
+// retryable reports whether a request that failed with err may succeed if
+// sent again: it was rate limited, the server failed, or it never arrived.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	var urlErr *neturl.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseAS splits an AS description such as "AS15169 Google LLC" into its
+// number and organization. It returns 0 and "" if as has no AS number.
+func parseAS(as string) (asn uint32, org string) {
+	number, org, _ := strings.Cut(as, " ")
+	n, err := parseASN(number)
+	if err != nil {
+		return 0, ""
+	}
+	return n, strings.TrimSpace(org)
+}
+
+// parseASN parses an AS number, with or without its "AS" prefix.
+func parseASN(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid AS number %q", s)
+	}
+	return uint32(n), nil
+}
+
+// ipAPIAttempts is how many times a lookup is sent before giving up.
+const ipAPIAttempts = 4
+
+// IPAPI is the Provider for ip-api.com, or another service with its URL
+// scheme and response format. Besides keeping to its configured rate, it
+// pauses when the X-Rl header says no requests are left until X-Ttl seconds
+// have passed, and retries rate-limited and failed requests with exponential
+// backoff.
+type IPAPI struct {
+	url     string
+	limiter *ratelimit.Limiter
+	backoff time.Duration // Wait before the first retry, doubled for each one after it
+
+	mu     sync.Mutex
+	resume time.Time // No requests are sent before then
+}
+
+// NewIPAPI returns a Provider that queries url, by default
+// "http://ip-api.com/json", at most perMinute times a minute. The free tier
+// of ip-api.com allows 45.
+func NewIPAPI(url string, perMinute int) *IPAPI {
+	if url == "" {
+		url = apiURL
+	}
+	return &IPAPI{url: url, limiter: ratelimit.New(perMinute), backoff: time.Second}
+}
+
+// Name returns "ip-api".
+func (p *IPAPI) Name() string {
+	return "ip-api"
+}
+
+// Lookup waits for the rate limit and queries the API, retrying requests that
+// were rate limited or failed on the server or on the way there.
+func (p *IPAPI) Lookup(ip string) (*Data, error) {
+	for attempt := 1; ; attempt++ {
+		p.limiter.Wait()
+		p.wait()
+
+		data, window, err := fetchIPAPI(p.url, ip)
+		if window.known && window.remaining <= 0 {
+			p.pause(window.reset)
+		}
+		if err == nil || !retryable(err) || attempt == ipAPIAttempts {
+			return data, err
+		}
+		p.pause(p.backoff << (attempt - 1))
+	}
+}
+
+// wait blocks until requests may be sent again.
+func (p *IPAPI) wait() {
+	p.mu.Lock()
+	d := time.Until(p.resume)
+	p.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// pause holds back requests for d, unless they are already held back longer.
+func (p *IPAPI) pause(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if resume := time.Now().Add(d); resume.After(p.resume) {
+		p.resume = resume
+	}
+}
+
+// Close stops the rate limiter.
+func (p *IPAPI) Close() error {
+	p.limiter.Stop()
+	return nil
+}
@@ -0,0 +1,115 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDB is an offline Provider that reads MaxMind GeoLite2/GeoIP2 or DB-IP
+// .mmdb files. Lookups need no network and are not rate limited.
+type MMDB struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader // Nil without an ASN database
+}
+
+// mmdbCity is the part of a City database record that Data holds.
+type mmdbCity struct {
+	Country struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// mmdbASN is a record of an ASN database. Its organization also stands in for
+// the ISP.
+type mmdbASN struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// OpenMMDB opens the City database at cityPath and, if asnPath is not empty,
+// the ASN database at asnPath.
+func OpenMMDB(cityPath, asnPath string) (*MMDB, error) {
+	city, err := maxminddb.Open(cityPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", cityPath, err)
+	}
+	m := &MMDB{city: city}
+	if asnPath != "" {
+		if m.asn, err = maxminddb.Open(asnPath); err != nil {
+			city.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", asnPath, err)
+		}
+	}
+	return m, nil
+}
+
+// Name returns "mmdb".
+func (m *MMDB) Name() string {
+	return "mmdb"
+}
+
+// Lookup reads the data for ip from the databases. Names are in English, and
+// the AS prefix is the network of the ASN database's record.
+func (m *MMDB) Lookup(ip string) (*Data, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	var city mmdbCity
+	_, found, err := m.city.LookupNetwork(addr, &city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read City database: %w", err)
+	}
+	data := &Data{
+		Country:   city.Country.Names["en"],
+		City:      city.City.Names["en"],
+		Latitude:  city.Location.Latitude,
+		Longitude: city.Location.Longitude,
+	}
+	if len(city.Subdivisions) > 0 {
+		data.Region = city.Subdivisions[0].Names["en"]
+	}
+
+	if m.asn != nil {
+		var asn mmdbASN
+		network, foundASN, err := m.asn.LookupNetwork(addr, &asn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ASN database: %w", err)
+		}
+		if foundASN {
+			data.ISP = asn.Organization
+			data.ASN, data.ASOrg = asn.Number, asn.Organization
+			data.ASPrefix = network.String()
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, &PermanentError{Err: ErrNoData}
+	}
+	return data, nil
+}
+
+// Close closes the databases.
+func (m *MMDB) Close() error {
+	var errs []error
+	errs = append(errs, m.city.Close())
+	if m.asn != nil {
+		errs = append(errs, m.asn.Close())
+	}
+	return errors.Join(errs...)
+}
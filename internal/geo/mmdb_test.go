@@ -0,0 +1,105 @@
+package geo
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeMMDB writes a database with the given records, keyed by network, to a
+// temporary file and returns its path.
+func writeMMDB(t *testing.T, records map[string]mmdbtype.Map) string {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "Test", IncludeReservedNetworks: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	for cidr, record := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("Invalid network %s: %v", cidr, err)
+		}
+		if err := tree.Insert(network, record); err != nil {
+			t.Fatalf("Failed to insert %s: %v", cidr, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create database file: %v", err)
+	}
+	defer f.Close()
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	return path
+}
+
+func names(en string) mmdbtype.Map {
+	return mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(en), "de": mmdbtype.String("?")}}
+}
+
+func TestMMDB(t *testing.T) {
+	cityPath := writeMMDB(t, map[string]mmdbtype.Map{
+		"192.0.2.0/24": {
+			"country":      names("Germany"),
+			"subdivisions": mmdbtype.Slice{names("Berlin")},
+			"city":         names("Berlin"),
+			"location":     mmdbtype.Map{"latitude": mmdbtype.Float64(52.5), "longitude": mmdbtype.Float64(13.4)},
+		},
+		"2001:db8::/32": {"country": names("France")},
+	})
+	asnPath := writeMMDB(t, map[string]mmdbtype.Map{
+		"192.0.2.0/24": {
+			"autonomous_system_number":       mmdbtype.Uint32(64500),
+			"autonomous_system_organization": mmdbtype.String("Example Networks"),
+		},
+		"198.51.100.0/24": {"autonomous_system_organization": mmdbtype.String("Other Networks")},
+	})
+
+	m, err := OpenMMDB(cityPath, asnPath)
+	if err != nil {
+		t.Fatalf("Failed to open databases: %v", err)
+	}
+	defer m.Close()
+
+	tests := []struct {
+		ip       string
+		expected Data
+		notFound bool
+	}{
+		{"192.0.2.7", Data{
+			Country: "Germany", Region: "Berlin", City: "Berlin", ISP: "Example Networks", Latitude: 52.5, Longitude: 13.4,
+			ASN: 64500, ASOrg: "Example Networks", ASPrefix: "192.0.2.0/24",
+		}, false},
+		{"2001:db8::1", Data{Country: "France"}, false},
+		{"198.51.100.1", Data{ISP: "Other Networks", ASOrg: "Other Networks", ASPrefix: "198.51.100.0/24"}, false},
+		{"203.0.113.1", Data{}, true},
+	}
+	for _, tc := range tests {
+		data, err := m.Lookup(tc.ip)
+		if tc.notFound {
+			if !errors.Is(err, ErrNoData) {
+				t.Errorf("Lookup(%s): expected ErrNoData, got %v, %v", tc.ip, data, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Lookup(%s): unexpected error: %v", tc.ip, err)
+			continue
+		}
+		if *data != tc.expected {
+			t.Errorf("Lookup(%s): expected %+v, got %+v", tc.ip, tc.expected, *data)
+		}
+	}
+
+	if _, err := OpenMMDB(filepath.Join(t.TempDir(), "missing.mmdb"), ""); err == nil {
+		t.Error("Expected an error for a missing database")
+	}
+}
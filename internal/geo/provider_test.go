@@ -0,0 +1,269 @@
+package geo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"minerva/internal/config"
+)
+
+// staticProvider returns the same data for every address, or err, or a
+// permanent ErrNoData if it has neither.
+type staticProvider struct {
+	name  string
+	data  *Data
+	err   error
+	calls int
+}
+
+func (p *staticProvider) Name() string { return p.name }
+
+func (p *staticProvider) Lookup(ip string) (*Data, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.data == nil {
+		return nil, &PermanentError{Err: ErrNoData}
+	}
+	return p.data, nil
+}
+
+func TestChain(t *testing.T) {
+	offline := &staticProvider{name: "offline"}
+	online := &staticProvider{name: "online", data: &Data{Country: "Germany"}}
+	unused := &staticProvider{name: "unused", data: &Data{Country: "France"}}
+
+	chain := Chain{offline, online, unused}
+	data, err := chain.Lookup("192.0.2.1")
+	if err != nil || data.Country != "Germany" {
+		t.Fatalf("Expected the second provider's data, got %+v, %v", data, err)
+	}
+	if offline.calls != 1 || unused.calls != 0 {
+		t.Errorf("Expected the chain to stop at the first data, got %d and %d calls", offline.calls, unused.calls)
+	}
+	if chain.Name() != "offline,online,unused" {
+		t.Errorf("Unexpected name %q", chain.Name())
+	}
+
+	if _, err := (Chain{offline}).Lookup("192.0.2.1"); !errors.Is(err, ErrNoData) || !IsPermanent(err) {
+		t.Errorf("Expected a permanent ErrNoData, got %v", err)
+	}
+	down := &staticProvider{name: "down", err: errors.New("API returned status code 503")}
+	if _, err := (Chain{offline, down}).Lookup("192.0.2.1"); err == nil || IsPermanent(err) {
+		t.Errorf("Expected a transient error while a provider is down, got %v", err)
+	}
+}
+
+func TestHTTPJSON(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/lookup/192.0.2.1":
+			w.Write([]byte(`{"country_name":"Germany","location":{"city":"Berlin","lat":"52.5","lon":13.4},"org":"Example","asn":{"asn":"AS64500","name":"Example Networks","route":"192.0.2.0/24","type":"hosting"},"hosting":"true"}`))
+		case "/lookup/192.0.2.2":
+			w.Write([]byte(`{"location":{"lat":"north"}}`))
+		case "/lookup/192.0.2.3":
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	fields := FieldMap{
+		Country: "country_name", City: "location.city", ISP: "org", Latitude: "location.lat", Longitude: "location.lon",
+		ASN: "asn.asn", ASOrg: "asn.name", ASPrefix: "asn.route", Hosting: "hosting",
+	}
+	p, err := NewHTTPJSON(mockServer.URL+"/lookup/{ip}", map[string]string{"Authorization": "Bearer token"}, fields, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := p.Lookup("192.0.2.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Data{
+		Country: "Germany", City: "Berlin", ISP: "Example", Latitude: 52.5, Longitude: 13.4,
+		ASN: 64500, ASOrg: "Example Networks", ASPrefix: "192.0.2.0/24", Hosting: true,
+	}
+	if *data != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *data)
+	}
+
+	if _, err := p.Lookup("192.0.2.2"); err == nil {
+		t.Error("Expected an error for an invalid number")
+	}
+	for _, ip := range []string{"192.0.2.3", "192.0.2.4"} {
+		if _, err := p.Lookup(ip); !errors.Is(err, ErrNoData) {
+			t.Errorf("Lookup(%s): expected ErrNoData, got %v", ip, err)
+		}
+	}
+
+	if _, err := NewHTTPJSON("https://example.com/lookup", nil, fields, 0); err == nil {
+		t.Error("Expected an error for a URL without {ip}")
+	}
+}
+
+func TestIPAPI_AS(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("fields"), "hosting") {
+			t.Errorf("Expected the hosting field to be requested, got %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"status":"success","country":"United States","isp":"Google LLC","as":"AS15169 Google LLC","asname":"GOOGLE","hosting":true}`))
+	}))
+	defer mockServer.Close()
+
+	data, err := NewIPAPI(mockServer.URL, 0).Lookup("8.8.8.8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Data{Country: "United States", ISP: "Google LLC", ASN: 15169, ASOrg: "Google LLC", Hosting: true}
+	if *data != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *data)
+	}
+}
+
+func TestIPAPI_IPv6(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2001:db8::1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status":"success","country":"Germany","as":"AS64500 Example Networks"}`))
+	}))
+	defer mockServer.Close()
+
+	data, err := NewIPAPI(mockServer.URL, 0).Lookup("2001:db8::1")
+	if err != nil || data.Country != "Germany" || data.ASN != 64500 {
+		t.Errorf("Expected data for the IPv6 address, got %+v, %v", data, err)
+	}
+}
+
+func TestParseAS(t *testing.T) {
+	tests := []struct {
+		as          string
+		expectedASN uint32
+		expectedOrg string
+	}{
+		{"AS15169 Google LLC", 15169, "Google LLC"},
+		{"AS64500", 64500, ""},
+		{"as13335 Cloudflare, Inc.", 13335, "Cloudflare, Inc."},
+		{"", 0, ""},
+		{"Google LLC", 0, ""},
+		{"AS99999999999 Too Big", 0, ""},
+	}
+	for _, tc := range tests {
+		asn, org := parseAS(tc.as)
+		if asn != tc.expectedASN || org != tc.expectedOrg {
+			t.Errorf("parseAS(%q): expected %d %q, got %d %q", tc.as, tc.expectedASN, tc.expectedOrg, asn, org)
+		}
+	}
+}
+
+func TestIPAPI_RateLimitHeaders(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Rl", "0")
+		w.Header().Set("X-Ttl", "30")
+		w.Write([]byte(`{"status":"success","country":"Germany"}`))
+	}))
+	defer mockServer.Close()
+
+	p := NewIPAPI(mockServer.URL, 0)
+	if _, err := p.Lookup("192.0.2.1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if wait := time.Until(p.resume); wait < 29*time.Second || wait > 30*time.Second {
+		t.Errorf("Expected requests to pause for 30s once none are left, got %v", wait)
+	}
+}
+
+func TestIPAPI_Retry(t *testing.T) {
+	var requests int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/192.0.2.1":
+			if requests < 3 {
+				w.Header().Set("X-Rl", "0")
+				w.Header().Set("X-Ttl", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`{"status":"success","country":"Germany"}`))
+		case "/192.0.2.2":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer mockServer.Close()
+
+	p := NewIPAPI(mockServer.URL, 0)
+	p.backoff = time.Millisecond
+
+	data, err := p.Lookup("192.0.2.1")
+	if err != nil || data.Country != "Germany" || requests != 3 {
+		t.Errorf("Expected success on the third request, got %+v, %v after %d", data, err, requests)
+	}
+
+	requests = 0
+	if _, err := p.Lookup("192.0.2.2"); err == nil || IsPermanent(err) || requests != 1 {
+		t.Errorf("Expected a client error without retries, got %v after %d requests", err, requests)
+	}
+
+	requests = 0
+	if _, err := p.Lookup("192.0.2.3"); err == nil || IsPermanent(err) || requests != ipAPIAttempts {
+		t.Errorf("Expected a transient error after %d requests, got %v after %d", ipAPIAttempts, err, requests)
+	}
+}
+
+func TestIPAPI_Fail(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"fail","message":"private range"}`))
+	}))
+	defer mockServer.Close()
+
+	p := NewIPAPI(mockServer.URL, 0)
+	defer p.Close()
+	if _, err := p.Lookup("10.0.0.1"); !errors.Is(err, ErrNoData) || !IsPermanent(err) {
+		t.Errorf("Expected a permanent ErrNoData, got %v", err)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      config.GeoConfig
+		expected  string
+		expectErr bool
+	}{
+		{"Default", config.GeoConfig{Providers: []string{"ip-api"}}, "ip-api", false},
+		{"HTTP fallback", config.GeoConfig{Providers: []string{"http", "ip-api"}, HTTP: config.GeoHTTPConfig{URL: "https://example.com/{ip}"}}, "http,ip-api", false},
+		{"None", config.GeoConfig{}, "", true},
+		{"Unknown", config.GeoConfig{Providers: []string{"carrier-pigeon"}}, "", true},
+		{"MMDB without path", config.GeoConfig{Providers: []string{"ip-api", "mmdb"}}, "", true},
+		{"HTTP without placeholder", config.GeoConfig{Providers: []string{"http"}, HTTP: config.GeoHTTPConfig{URL: "https://example.com"}}, "", true},
+	}
+	for _, tc := range tests {
+		chain, err := NewProvider(tc.conf)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+			continue
+		}
+		if err == nil {
+			if chain.Name() != tc.expected {
+				t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, chain.Name())
+			}
+			chain.Close()
+		}
+	}
+}
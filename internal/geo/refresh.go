@@ -0,0 +1,44 @@
+package geo
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// RefreshHandler is a DataHandler that can list stale geolocation data.
+type RefreshHandler interface {
+	DataHandler
+	// StaleGeoIPs returns up to limit addresses, or all of them if limit is
+	// 0, whose geolocation data was last updated before cutoff. The most
+	// recently active addresses come first. Addresses that are not public,
+	// and those whose last failed lookup is not due for a retry, are left out.
+	StaleGeoIPs(cutoff time.Time, limit int) ([]string, error)
+}
+
+// Refresh looks up again, with provider, up to limit addresses whose
+// geolocation data is older than ttl, most recently active first. Failed
+// lookups are logged and recorded, and leave the old data in place; a storage
+// error stops the refresh. It returns the number of addresses refreshed.
+func Refresh(handler RefreshHandler, provider Provider, ttl time.Duration, limit int) (refreshed int, err error) {
+	ips, err := handler.StaleGeoIPs(time.Now().Add(-ttl), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ip := range ips {
+		geoData, err := provider.Lookup(ip)
+		if err != nil {
+			log.Printf("Error refreshing geolocation for IP %s: %v", ip, err)
+			if err := recordFailure(handler, ip, err, ttl); err != nil {
+				return refreshed, fmt.Errorf("failed to record failed geolocation lookup: %w", err)
+			}
+			continue
+		}
+		if err := handler.InsertOrUpdateGeoData(ip, geoData); err != nil {
+			return refreshed, fmt.Errorf("failed to refresh geolocation data: %w", err)
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mockRefreshHandler stores geolocation data and failed lookups in memory,
+// with the time each address was last updated.
+type mockRefreshHandler struct {
+	updated  map[string]time.Time
+	data     map[string]*Data
+	failures map[string]Failure
+	failAt   string // Address whose update fails
+}
+
+func (m *mockRefreshHandler) IsIPInGeoTable(ip string) (bool, error) {
+	_, ok := m.data[ip]
+	return ok, nil
+}
+
+func (m *mockRefreshHandler) InsertOrUpdateGeoData(ip string, geoData *Data) error {
+	if ip == m.failAt {
+		return errors.New("database unavailable")
+	}
+	m.data[ip] = geoData
+	m.updated[ip] = time.Now()
+	delete(m.failures, ip)
+	return nil
+}
+
+func (m *mockRefreshHandler) GeoFailure(ip string) (*Failure, error) {
+	if f, ok := m.failures[ip]; ok {
+		return &f, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRefreshHandler) RecordGeoFailure(ip string, f Failure) error {
+	m.failures[ip] = f
+	return nil
+}
+
+func (m *mockRefreshHandler) StaleGeoIPs(cutoff time.Time, limit int) ([]string, error) {
+	var ips []string
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		f, failed := m.failures[ip]
+		if t, ok := m.updated[ip]; ok && t.Before(cutoff) && (!failed || f.Due(time.Now())) && (limit == 0 || len(ips) < limit) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+func TestRefresh(t *testing.T) {
+	requests := map[string]int{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Path == "/192.0.2.3" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"country":"Germany","city":"Berlin"}`))
+	}))
+	defer mockServer.Close()
+
+	provider := NewIPAPI(mockServer.URL, 60000)
+	provider.backoff = time.Millisecond
+	defer provider.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	handler := &mockRefreshHandler{
+		updated:  map[string]time.Time{"192.0.2.1": old, "192.0.2.2": time.Now(), "192.0.2.3": old},
+		data:     map[string]*Data{},
+		failures: map[string]Failure{},
+	}
+
+	// 192.0.2.2 is fresh and the lookup of 192.0.2.3 fails.
+	refreshed, err := Refresh(handler, provider, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if refreshed != 1 || handler.data["192.0.2.1"] == nil || handler.data["192.0.2.1"].City != "Berlin" {
+		t.Errorf("Expected 192.0.2.1 to be refreshed, got %d, %+v", refreshed, handler.data)
+	}
+	if handler.data["192.0.2.3"] != nil {
+		t.Error("Expected the failed lookup to leave 192.0.2.3 untouched")
+	}
+	if f := handler.failures["192.0.2.3"]; f.Permanent || f.Attempts != 1 || requests["/192.0.2.3"] != ipAPIAttempts {
+		t.Errorf("Expected one transient failure after %d requests, got %+v after %d", ipAPIAttempts, f, requests["/192.0.2.3"])
+	}
+
+	// The failure is not due for a retry yet.
+	handler.updated["192.0.2.1"] = time.Now()
+	if refreshed, err := Refresh(handler, provider, 24*time.Hour, 0); err != nil || refreshed != 0 || requests["/192.0.2.3"] != ipAPIAttempts {
+		t.Errorf("Expected nothing to refresh, got %d, %v after %d requests", refreshed, err, requests["/192.0.2.3"])
+	}
+
+	handler.updated["192.0.2.1"] = old
+	handler.failAt = "192.0.2.1"
+	if _, err := Refresh(handler, provider, 24*time.Hour, 1); err == nil {
+		t.Error("Expected a storage error to stop the refresh")
+	}
+}
+
+func TestProcessIP(t *testing.T) {
+	handler := &mockRefreshHandler{
+		updated:  map[string]time.Time{},
+		data:     map[string]*Data{},
+		failures: map[string]Failure{},
+	}
+	provider := &staticProvider{name: "static"}
+
+	// A permanent failure is not looked up again within the TTL.
+	for i := 0; i < 2; i++ {
+		if err := ProcessIP(provider, handler, "10.0.0.1", 24*time.Hour); err == nil && i == 0 {
+			t.Error("Expected the failed lookup to be reported")
+		}
+	}
+	if f := handler.failures["10.0.0.1"]; provider.calls != 1 || !f.Permanent || f.Due(time.Now().Add(23*time.Hour)) {
+		t.Errorf("Expected one lookup and a permanent failure, got %d lookups and %+v", provider.calls, f)
+	}
+
+	// Once due, the address is looked up again, and stored data clears the failure.
+	f := handler.failures["10.0.0.1"]
+	f.RetryAfter = time.Now().Add(-time.Minute)
+	handler.failures["10.0.0.1"] = f
+	provider.data = &Data{Country: "Germany"}
+	if err := ProcessIP(provider, handler, "10.0.0.1", 24*time.Hour); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := handler.failures["10.0.0.1"]; provider.calls != 2 || ok || handler.data["10.0.0.1"] == nil {
+		t.Errorf("Expected the address to be stored, got %d lookups and %+v", provider.calls, handler.failures)
+	}
+}
+
+func TestNewFailure(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	transient := errors.New("API returned status code 503")
+	permanent := &PermanentError{Err: ErrNoData}
+
+	tests := []struct {
+		name          string
+		err           error
+		prev          *Failure
+		ttl           time.Duration
+		expectedRetry time.Duration // 0 for never
+	}{
+		{"First transient", transient, nil, 30 * 24 * time.Hour, time.Hour},
+		{"Third transient", transient, &Failure{Attempts: 2}, 30 * 24 * time.Hour, 4 * time.Hour},
+		{"Capped transient", transient, &Failure{Attempts: 40}, 30 * 24 * time.Hour, 24 * time.Hour},
+		{"Transient within TTL", transient, &Failure{Attempts: 9}, 2 * time.Hour, 2 * time.Hour},
+		{"Permanent", permanent, nil, 7 * 24 * time.Hour, 7 * 24 * time.Hour},
+		{"Permanent without TTL", permanent, nil, 0, 0},
+	}
+	for _, tc := range tests {
+		f := newFailure(tc.err, tc.prev, tc.ttl, now)
+		var retry time.Duration
+		if !f.RetryAfter.IsZero() {
+			retry = f.RetryAfter.Sub(now)
+		}
+		if retry != tc.expectedRetry || f.Permanent != IsPermanent(tc.err) {
+			t.Errorf("%s: expected a retry after %v, got %+v", tc.name, tc.expectedRetry, f)
+		}
+	}
+}
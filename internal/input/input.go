@@ -2,9 +2,18 @@ package input
 
 import (
 	"bufio"
+	"bytes"
+	"fmt"
 	"io"
+	"os"
 )
 
+// maxLineSize is the longest single line StreamLines will accept.
+const maxLineSize = 1024 * 1024
+
+// defaultChunkSize is how much ReverseReader reads from the end of the input at a time.
+const defaultChunkSize = 64 * 1024
+
 // ReadLines reads all lines from the provided reader.
 func ReadLines(r io.Reader) ([]string, error) {
 	var lines []string
@@ -25,3 +34,149 @@ func ReverseLines(lines []string) []string {
 	}
 	return lines
 }
+
+// StreamLines calls fn for each line read from r, oldest (first) line first.
+// Only the current line is held in memory.
+func StreamLines(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// StreamLinesReverse calls fn for each line read from r, newest (last) line first.
+// Regular files are read backwards in place. Any other reader, such as a pipe,
+// is first spilled to a temporary file so memory use stays bounded.
+func StreamLinesReverse(r io.Reader, fn func(line string)) error {
+	if f, ok := r.(*os.File); ok {
+		if section, ok := regularFileSection(f); ok {
+			return streamReverse(section, section.Size(), fn)
+		}
+	}
+
+	spill, err := os.CreateTemp("", "minerva-input-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %w", err)
+	}
+	defer os.Remove(spill.Name())
+	defer spill.Close()
+
+	size, err := io.Copy(spill, r)
+	if err != nil {
+		return fmt.Errorf("failed to spill input to disk: %w", err)
+	}
+	return streamReverse(spill, size, fn)
+}
+
+// regularFileSection returns the unread remainder of f if it is a regular file.
+func regularFileSection(f *os.File) (*io.SectionReader, bool) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return io.NewSectionReader(f, start, info.Size()-start), true
+}
+
+func streamReverse(r io.ReaderAt, size int64, fn func(line string)) error {
+	reader := NewReverseReader(r, size)
+	for {
+		line, err := reader.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(line)
+	}
+}
+
+// ReverseReader returns the lines of an io.ReaderAt from last to first,
+// reading the underlying data backwards one chunk at a time.
+type ReverseReader struct {
+	r         io.ReaderAt
+	pos       int64  // start of the data that has not been loaded yet
+	buf       []byte // loaded data that has not been returned yet
+	chunkSize int
+	started   bool
+	done      bool
+}
+
+// NewReverseReader creates a ReverseReader over the first size bytes of r.
+func NewReverseReader(r io.ReaderAt, size int64) *ReverseReader {
+	return &ReverseReader{r: r, pos: size, chunkSize: defaultChunkSize}
+}
+
+// SetChunkSize overrides how many bytes are read per step.
+func (rr *ReverseReader) SetChunkSize(n int) {
+	if n > 0 {
+		rr.chunkSize = n
+	}
+}
+
+// ReadLine returns the previous line, without its line terminator.
+// It returns io.EOF once the start of the input has been reached.
+func (rr *ReverseReader) ReadLine() (string, error) {
+	if rr.done {
+		return "", io.EOF
+	}
+	if !rr.started {
+		rr.started = true
+		if rr.pos == 0 {
+			rr.done = true
+			return "", io.EOF
+		}
+		if err := rr.load(); err != nil {
+			return "", err
+		}
+		// A trailing newline terminates the last line; it does not start a new one.
+		if n := len(rr.buf); n > 0 && rr.buf[n-1] == '\n' {
+			rr.buf = rr.buf[:n-1]
+		}
+	}
+
+	for {
+		if idx := bytes.LastIndexByte(rr.buf, '\n'); idx >= 0 {
+			line := dropCR(rr.buf[idx+1:])
+			rr.buf = rr.buf[:idx]
+			return string(line), nil
+		}
+		if rr.pos == 0 {
+			rr.done = true
+			return string(dropCR(rr.buf)), nil
+		}
+		if err := rr.load(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// load prepends the previous chunk of input to the buffer.
+func (rr *ReverseReader) load() error {
+	n := int64(rr.chunkSize)
+	if n > rr.pos {
+		n = rr.pos
+	}
+	rr.pos -= n
+
+	chunk := make([]byte, n, int(n)+len(rr.buf))
+	if _, err := rr.r.ReadAt(chunk, rr.pos); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read input at offset %d: %w", rr.pos, err)
+	}
+	rr.buf = append(chunk, rr.buf...)
+	return nil
+}
+
+// dropCR drops a terminal \r from the data, matching bufio.ScanLines.
+func dropCR(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		return data[:len(data)-1]
+	}
+	return data
+}
@@ -1,6 +1,8 @@
 package input
 
 import (
+	"io"
+	"os"
 	"strings"
 	"testing"
 )
@@ -68,3 +70,116 @@ func TestReverseLines_SingleElement(t *testing.T) {
 		t.Errorf("Expected element %q, got %q", expected[0], reversed[0])
 	}
 }
+
+func TestStreamLines(t *testing.T) {
+	reader := strings.NewReader("line1\nline2\r\nline3\n")
+
+	var lines []string
+	if err := StreamLines(reader, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"line1", "line2", "line3"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("Expected line %d to be %q, got %q", i, expected[i], line)
+		}
+	}
+}
+
+func TestStreamLinesReverse(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{"TrailingNewline", "line1\nline2\nline3\n", []string{"line3", "line2", "line1"}},
+		{"NoTrailingNewline", "line1\nline2\nline3", []string{"line3", "line2", "line1"}},
+		{"CRLF", "line1\r\nline2\r\n", []string{"line2", "line1"}},
+		{"BlankLines", "line1\n\nline3\n", []string{"line3", "", "line1"}},
+		{"Empty", "", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var lines []string
+			err := StreamLinesReverse(strings.NewReader(tc.data), func(line string) { lines = append(lines, line) })
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(lines) != len(tc.expected) {
+				t.Fatalf("Expected %d lines, got %d (%q)", len(tc.expected), len(lines), lines)
+			}
+			for i, line := range lines {
+				if line != tc.expected[i] {
+					t.Errorf("Expected line %d to be %q, got %q", i, tc.expected[i], line)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamLinesReverse_RegularFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "input")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("skipped\nline1\nline2\n"); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	// Position the file after the first line; only the remainder should be read.
+	if _, err := f.Seek(int64(len("skipped\n")), io.SeekStart); err != nil {
+		t.Fatalf("Failed to seek temp file: %v", err)
+	}
+
+	var lines []string
+	if err := StreamLinesReverse(f, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"line2", "line1"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d (%q)", len(expected), len(lines), lines)
+	}
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("Expected line %d to be %q, got %q", i, expected[i], line)
+		}
+	}
+}
+
+func TestReverseReader_SmallChunks(t *testing.T) {
+	data := "first line\nsecond, somewhat longer line\n\nlast\n"
+	expected := []string{"last", "", "second, somewhat longer line", "first line"}
+
+	for _, chunkSize := range []int{1, 3, 7, 64} {
+		reader := NewReverseReader(strings.NewReader(data), int64(len(data)))
+		reader.SetChunkSize(chunkSize)
+
+		var lines []string
+		for {
+			line, err := reader.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("chunk size %d: unexpected error: %v", chunkSize, err)
+			}
+			lines = append(lines, line)
+		}
+
+		if len(lines) != len(expected) {
+			t.Fatalf("chunk size %d: expected %d lines, got %d (%q)", chunkSize, len(expected), len(lines), lines)
+		}
+		for i, line := range lines {
+			if line != expected[i] {
+				t.Errorf("chunk size %d: expected line %d to be %q, got %q", chunkSize, i, expected[i], line)
+			}
+		}
+	}
+}
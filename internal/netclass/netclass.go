@@ -0,0 +1,132 @@
+// Package netclass classifies source addresses, so that only public ones are
+// sent to rate-limited geolocation services.
+package netclass
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Class is the kind of network an address belongs to.
+type Class string
+
+const (
+	Public    Class = "public"
+	Private   Class = "private"   // RFC 1918, shared (CGNAT), unique local and link-local addresses
+	Loopback  Class = "loopback"  // 127.0.0.0/8 and ::1
+	Multicast Class = "multicast" // 224.0.0.0/4 and ff00::/8
+	Bogon     Class = "bogon"     // Reserved, documentation and other unroutable addresses
+	Own       Class = "own"       // A configured network of our own
+)
+
+// Classes lists every class.
+var Classes = []Class{Public, Private, Loopback, Multicast, Bogon, Own}
+
+// Parse returns the class named s.
+func Parse(s string) (Class, error) {
+	for _, c := range Classes {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown address class %q", s)
+}
+
+var (
+	// shared is the shared address space of carrier-grade NAT, RFC 6598.
+	shared = netip.MustParsePrefix("100.64.0.0/10")
+
+	// bogons are reserved ranges that never appear as public sources, beyond
+	// those netip.Addr recognizes itself.
+	bogons = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+		netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+		netip.MustParsePrefix("192.0.2.0/24"),    // Documentation (TEST-NET-1)
+		netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+		netip.MustParsePrefix("198.51.100.0/24"), // Documentation (TEST-NET-2)
+		netip.MustParsePrefix("203.0.113.0/24"),  // Documentation (TEST-NET-3)
+		netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, and the broadcast address
+		netip.MustParsePrefix("100::/64"),        // Discard-only
+		netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	}
+
+	// globalUnicast holds every IPv6 address currently allocated for use on
+	// the internet.
+	globalUnicast = netip.MustParsePrefix("2000::/3")
+)
+
+// Classifier classifies addresses. The zero Classifier, and a nil one, know
+// no networks of our own.
+type Classifier struct {
+	own []netip.Prefix
+}
+
+// New returns a Classifier for which the networks in own, given as CIDRs such
+// as 203.0.113.0/24 or as single addresses, are our own.
+func New(own []string) (*Classifier, error) {
+	c := &Classifier{}
+	for _, s := range own {
+		p, err := parsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid own network %q: %w", s, err)
+		}
+		c.own = append(c.own, p)
+	}
+	return c, nil
+}
+
+// parsePrefix parses a CIDR or a single address, which is a full-length
+// prefix.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Classify returns the class of addr, or "" for the zero Addr. Our own
+// networks take precedence over the other classes, and IPv4-mapped IPv6
+// addresses are classified as IPv4.
+func (c *Classifier) Classify(addr netip.Addr) Class {
+	if !addr.IsValid() {
+		return ""
+	}
+	addr = addr.Unmap()
+	if c != nil {
+		for _, p := range c.own {
+			if p.Contains(addr) {
+				return Own
+			}
+		}
+	}
+	switch {
+	case addr.IsLoopback():
+		return Loopback
+	case addr.IsMulticast():
+		return Multicast
+	case addr.IsPrivate() || addr.IsLinkLocalUnicast() || shared.Contains(addr):
+		return Private
+	case addr.IsUnspecified() || (addr.Is6() && !globalUnicast.Contains(addr)):
+		return Bogon
+	}
+	for _, p := range bogons {
+		if p.Contains(addr) {
+			return Bogon
+		}
+	}
+	return Public
+}
+
+// Classify returns the class of addr without any networks of our own.
+func Classify(addr netip.Addr) Class {
+	return (*Classifier)(nil).Classify(addr)
+}
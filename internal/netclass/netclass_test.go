@@ -0,0 +1,75 @@
+package netclass
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	c, err := New([]string{"198.51.100.0/24", "2001:db8:1::/48", "8.8.4.4"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		addr     string
+		expected Class
+	}{
+		{"8.8.8.8", Public},
+		{"2606:4700::1111", Public},
+		{"::ffff:1.1.1.1", Public},
+		{"10.1.2.3", Private},
+		{"172.31.255.255", Private},
+		{"192.168.1.1", Private},
+		{"100.64.0.1", Private},
+		{"169.254.169.254", Private},
+		{"fd12:3456::1", Private},
+		{"fe80::1", Private},
+		{"::ffff:192.168.0.1", Private},
+		{"127.0.0.1", Loopback},
+		{"::1", Loopback},
+		{"224.0.0.251", Multicast},
+		{"ff02::1", Multicast},
+		{"0.0.0.0", Bogon},
+		{"192.0.2.1", Bogon},
+		{"203.0.113.9", Bogon},
+		{"198.18.0.1", Bogon},
+		{"240.0.0.1", Bogon},
+		{"255.255.255.255", Bogon},
+		{"::", Bogon},
+		{"2001:db8::1", Bogon},
+		{"4000::1", Bogon},
+		{"198.51.100.7", Own},
+		{"2001:db8:1::5", Own},
+		{"8.8.4.4", Own},
+	}
+	for _, tc := range tests {
+		if got := c.Classify(netip.MustParseAddr(tc.addr)); got != tc.expected {
+			t.Errorf("Classify(%s): expected %s, got %s", tc.addr, tc.expected, got)
+		}
+	}
+
+	if got := Classify(netip.MustParseAddr("198.51.100.7")); got != Bogon {
+		t.Errorf("Expected a documentation address without own networks to be a bogon, got %s", got)
+	}
+	if got := Classify(netip.Addr{}); got != "" {
+		t.Errorf("Expected no class for the zero Addr, got %q", got)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, own := range []string{"10.0.0.0/33", "example.com", ""} {
+		if _, err := New([]string{own}); err == nil {
+			t.Errorf("New(%q): expected an error", own)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if c, err := Parse("bogon"); err != nil || c != Bogon {
+		t.Errorf("Expected bogon, got %q, %v", c, err)
+	}
+	if _, err := Parse("martian"); err == nil {
+		t.Error("Expected an error for an unknown class")
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"minerva/internal/parser"
+	"net"
+	"strconv"
 	"text/tabwriter"
+	"time"
 )
 
 // WriteJSONOutput writes the given data as JSON to the provided writer.
@@ -39,3 +43,34 @@ func WriteIPSummaryTable(summary []map[string]interface{}, w io.Writer) error {
 	// Flush the tab writer
 	return writer.Flush()
 }
+
+// WriteLogEventsTable writes parsed log events as an aligned table to the provided writer.
+func WriteLogEventsTable(events []parser.LogEvent, w io.Writer) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "Timestamp\tSource\tDestination\tProtocol\tAction\tReason\tLength\tTTL")
+
+	for _, ev := range events {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+			ev.Timestamp.Format(time.RFC3339),
+			endpoint(ev.SourceIP.String(), ev.SourcePort),
+			endpoint(ev.DestinationIP.String(), ev.DestinationPort),
+			ev.Protocol,
+			ev.Action,
+			ev.Reason,
+			ev.PacketLength,
+			ev.TTL,
+		)
+	}
+
+	return writer.Flush()
+}
+
+// endpoint formats an address and port as "addr:port", or just addr when the
+// port is unknown. IPv6 addresses are bracketed when a port is shown.
+func endpoint(addr string, port uint16) string {
+	if port == 0 {
+		return addr
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(int(port)))
+}
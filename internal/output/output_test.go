@@ -3,7 +3,11 @@ package output
 import (
 	"bytes"
 	"encoding/json"
+	"minerva/internal/parser"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWriteJSONOutput(t *testing.T) {
@@ -38,3 +42,46 @@ func TestWriteJSONOutput(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteLogEventsTable(t *testing.T) {
+	events := []parser.LogEvent{
+		{
+			Timestamp:       time.Date(2025, 1, 5, 0, 1, 8, 0, time.UTC),
+			SourceIP:        netip.MustParseAddr("192.0.2.1"),
+			DestinationIP:   netip.MustParseAddr("192.0.2.2"),
+			SourcePort:      12345,
+			DestinationPort: 80,
+			Protocol:        "TCP",
+			Action:          "DROP",
+			Reason:          "PORTSCAN",
+			PacketLength:    500,
+			TTL:             64,
+		},
+		{
+			Timestamp:     time.Date(2025, 1, 5, 0, 1, 9, 0, time.UTC),
+			SourceIP:      netip.MustParseAddr("2001:db8::1"),
+			DestinationIP: netip.MustParseAddr("2001:db8::2"),
+			Protocol:      "ICMPV6",
+			Action:        "DROP",
+			Reason:        "DROP-IN",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLogEventsTable(events, &buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d lines:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"2025-01-05T00:01:08Z", "192.0.2.1:12345", "192.0.2.2:80", "PORTSCAN"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("Expected row %q to contain %q", lines[1], want)
+		}
+	}
+	if fields := strings.Fields(lines[2]); fields[1] != "2001:db8::1" || fields[2] != "2001:db8::2" {
+		t.Errorf("Expected IPv6 addresses without ports, got %q", lines[2])
+	}
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"minerva/internal/syslog"
+	"time"
+)
+
+// Envelope holds the syslog header of a log line: the device and program that
+// reported it and, for messages received over the network, the priority.
+type Envelope struct {
+	Hostname string `json:"hostname,omitempty"`
+	Program  string `json:"program,omitempty"`
+	PID      int    `json:"pid,omitempty"`
+	Facility *uint8 `json:"facility,omitempty"` // Nil when the line had no <PRI>
+	Severity *uint8 `json:"severity,omitempty"` // Syslog severity, 0 (emergency) to 7 (debug)
+}
+
+// WithEnvelope wraps p so that every event also carries the line's syslog header.
+//
+// Timestamps in the header take precedence over those the format parser finds.
+// BSD-style dates ("Jan  8 00:01:08") are completed with the current year, or
+// the previous one for dates more than a month ahead, and are interpreted in
+// loc, as are ISO 8601 header timestamps without an offset. Lines with a BSD
+// date are handed to p with the date rewritten as ISO 8601, so formats that
+// expect one still recognise them.
+func WithEnvelope(p Parser, loc *time.Location) Parser {
+	return envelopeParser{Parser: p, loc: loc}
+}
+
+type envelopeParser struct {
+	Parser
+	loc *time.Location
+}
+
+func (e envelopeParser) Match(line string) bool {
+	_, line, _ = e.header(line)
+	return e.Parser.Match(line)
+}
+
+func (e envelopeParser) Parse(line string) (LogEvent, error) {
+	msg, line, ok := e.header(line)
+	ev, err := e.Parser.Parse(line)
+	if err != nil || !ok {
+		return ev, err
+	}
+
+	ev.Timestamp = msg.Timestamp
+	ev.Syslog = Envelope{
+		Hostname: msg.Hostname,
+		Program:  msg.AppName,
+		PID:      atoiSafe(msg.ProcID),
+	}
+	if msg.Priority >= 0 {
+		facility, severity := uint8(msg.Facility), uint8(msg.Severity)
+		ev.Syslog.Facility, ev.Syslog.Severity = &facility, &severity
+	}
+	return ev, nil
+}
+
+// header parses the syslog header of line. It reports whether the header had
+// a timestamp, and returns the line to hand to the format parser.
+func (e envelopeParser) header(line string) (syslog.Message, string, bool) {
+	now := time.Now().In(e.loc)
+	msg, err := syslog.ParseLine(line, now)
+	// Without a header timestamp, ParseLine falls back to the receive time.
+	if err != nil || msg.Timestamp.Equal(now) {
+		return msg, line, false
+	}
+	if findTimestamp(line) == "" {
+		// A BSD date: present it to the format parser as ISO 8601.
+		return msg, msg.LineWithPriority(), true
+	}
+	return msg, line, true
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const attMessage = "SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=PORTSCAN LEN=500 TTL=64"
+
+func TestWithEnvelope(t *testing.T) {
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	att, err := ForFormat("att")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p := WithEnvelope(att, loc)
+
+	// A BSD date has no year or zone, so build one an hour in the past.
+	bsdTime := time.Now().In(loc).Add(-time.Hour).Truncate(time.Second)
+	bsdLine := bsdTime.Format(time.Stamp) + " dsldevice.attlocal.net L4 FIREWALL[7567]: " + attMessage
+
+	tests := []struct {
+		name      string
+		line      string
+		timestamp time.Time
+		syslog    Envelope
+	}{
+		{
+			name:      "ISO timestamp",
+			line:      attLine,
+			timestamp: fixtureTime,
+			syslog:    Envelope{Hostname: "dsldevice.attlocal.net", Program: "L4 FIREWALL", PID: 7567},
+		},
+		{
+			name:      "BSD date",
+			line:      bsdLine,
+			timestamp: bsdTime,
+			syslog:    Envelope{Hostname: "dsldevice.attlocal.net", Program: "L4 FIREWALL", PID: 7567},
+		},
+		{
+			name:      "Priority",
+			line:      "<134>" + bsdLine,
+			timestamp: bsdTime,
+			syslog:    Envelope{Hostname: "dsldevice.attlocal.net", Program: "L4 FIREWALL", PID: 7567, Facility: u8(16), Severity: u8(6)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if !p.Match(tc.line) {
+				t.Fatalf("Expected %q to match", tc.line)
+			}
+			ev, err := p.Parse(tc.line)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !ev.Timestamp.Equal(tc.timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", tc.timestamp, ev.Timestamp)
+			}
+			if !reflect.DeepEqual(ev.Syslog, tc.syslog) {
+				t.Errorf("Expected syslog header %+v, got %+v", tc.syslog, ev.Syslog)
+			}
+			if ev.Action != "DROP" || ev.Reason != "PORTSCAN" || ev.DestinationPort != 80 {
+				t.Errorf("Expected the message fields to be parsed, got %+v", ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// LogEvent holds the fields of interest extracted from one log line.
+type LogEvent struct {
+	Sensor          string     `json:"sensor,omitempty"` // Site or device that collected the event
+	Format          string     `json:"format,omitempty"` // Name of the parser that produced the event
+	Timestamp       time.Time  `json:"timestamp"`
+	SourceIP        netip.Addr `json:"source_ip"`
+	DestinationIP   netip.Addr `json:"destination_ip"`
+	SourcePort      uint16     `json:"source_port"`
+	DestinationPort uint16     `json:"destination_port"`
+	Protocol        string     `json:"protocol"`
+	Action          string     `json:"action"`
+	Reason          string     `json:"reason"`
+	PacketLength    int        `json:"packet_length"`
+	TTL             int        `json:"ttl"`
+
+	// Optional header fields, left empty when a format does not report them.
+	Header `json:"header"`
+
+	// The syslog header of the line; see WithEnvelope.
+	Syslog Envelope `json:"syslog"`
+
+	// The class of SourceIP, such as "public" or "private"; see package
+	// netclass. Set before rules are applied.
+	SourceClass string `json:"source_class,omitempty"`
+
+	// Set when the event is flagged: the names of the matching rules and the
+	// highest severity among them.
+	Rules    []string `json:"rules,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+
+	// The blocklists that SourceIP is on, sorted; see package blocklist. Set
+	// for flagged events from public addresses.
+	Blocklists []string `json:"blocklists,omitempty"`
+
+	// The highest threat intelligence score of SourceIP, when listing stored
+	// events; nil if no provider has reported on it.
+	ThreatScore *int `json:"threat_score,omitempty"`
+}
+
+// timestampLayoutNoZone parses ISO-8601 timestamps that carry no UTC offset.
+const timestampLayoutNoZone = "2006-01-02T15:04:05.999999999"
+
+// parseTimestamp parses an ISO-8601 timestamp, assuming local time when it has
+// no offset. It returns the zero time if s is empty or invalid.
+func parseTimestamp(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation(timestampLayoutNoZone, s, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// ParseAddr parses an IPv4 or IPv6 address into its canonical form, so that
+// every way of writing an address yields the same Addr and the same String:
+// IPv6 addresses are compressed and lower case, and IPv4-mapped IPv6 addresses
+// such as ::ffff:192.0.2.1 become IPv4 addresses. Scoped addresses with a zone
+// ("fe80::1%eth0") are rejected, since the zone only means something on the
+// host that logged them.
+func ParseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if addr.Zone() != "" {
+		return netip.Addr{}, fmt.Errorf("address %q has a zone", s)
+	}
+	return addr.Unmap(), nil
+}
+
+// parseAddr parses an IP address with ParseAddr, returning the zero Addr if s
+// is not one.
+func parseAddr(s string) netip.Addr {
+	if s == "" {
+		return netip.Addr{}
+	}
+	addr, err := ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr
+}
+
+// parsePortNumber parses a port, returning 0 if s is missing or out of range.
+func parsePortNumber(s string) uint16 {
+	if s == "" {
+		return 0
+	}
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0
+	}
+	return uint16(port)
+}
+
+// parseUint8 parses a small unsigned field such as an ICMP type.
+func parseUint8(s string) (uint8, bool) {
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, false
+	}
+	return uint8(v), true
+}
@@ -0,0 +1,283 @@
+package parser
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Sample lines for every built-in format.
+const (
+	attLine       = "2025-01-05T00:01:08.143626-05:00 dsldevice.attlocal.net L4 FIREWALL[7567]: SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=PORTSCAN LEN=500 TTL=64"
+	netfilterLine = "2025-01-05T00:01:08.143626-05:00 gw kernel: [ 1234.567890] DROP-IN: IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=198.51.100.7 DST=203.0.113.2 LEN=60 TOS=0x00 PREC=0x00 TTL=50 ID=54321 DF PROTO=TCP SPT=51234 DPT=22 WINDOW=29200 RES=0x00 SYN URGP=0"
+	ufwLine       = "2025-01-05T00:01:08.143626-05:00 host kernel: [ 1234.567890] [UFW BLOCK] IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=198.51.100.8 DST=203.0.113.2 LEN=40 TOS=0x00 PREC=0x00 TTL=244 ID=1 PROTO=TCP SPT=40000 DPT=3389 WINDOW=1024 RES=0x00 SYN URGP=0"
+	openwrtLine   = "2025-01-05T00:01:08.143626-05:00 OpenWrt kernel: [ 1234.567890] drop wan in: IN=eth1 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=198.51.100.9 DST=203.0.113.3 LEN=44 TOS=0x00 PREC=0x00 TTL=240 ID=2 PROTO=UDP SPT=5353 DPT=161 LEN=24"
+	pfsenseLine   = "2025-01-05T00:01:08.143626-05:00 pfsense filterlog[1234]: 5,,,1000000103,igb0,match,block,in,4,0x0,,64,0,0,DF,6,tcp,60,198.51.100.10,203.0.113.4,51234,22,0,S,1234567,,64240,,mss"
+	icmpLine      = "2025-01-05T00:01:08.143626-05:00 gw kernel: [ 1234.567890] DROP-IN: IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=198.51.100.11 DST=203.0.113.2 LEN=84 TOS=0x00 PREC=0x00 TTL=55 ID=0 DF PROTO=ICMP TYPE=8 CODE=0 ID=1 SEQ=1"
+	pfsenseV6Line = "2025-01-05T00:01:08.143626-05:00 pfsense filterlog[1234]: 5,,,1000000103,igb0,match,pass,in,6,0x00,0x00000,64,udp,17,56,2001:db8::1,2001:db8::2,5353,53,56"
+	netfilterV6   = "2025-01-05T00:01:08.143626-05:00 gw kernel: [ 1234.567890] DROP-IN: IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:86:dd SRC=2001:0DB8:0000:0000:0000:0000:0000:0007 DST=2001:db8:0:1::2 LEN=80 TC=0 HOPLIMIT=57 FLOWLBL=654321 PROTO=TCP SPT=51234 DPT=22 WINDOW=64800 RES=0x00 SYN URGP=0"
+	icmpv6Line    = "2025-01-05T00:01:08.143626-05:00 gw kernel: [ 1234.567890] DROP-IN: IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:86:dd SRC=2001:db8::11 DST=2001:db8:0:1::2 LEN=104 TC=0 HOPLIMIT=64 FLOWLBL=0 PROTO=ICMPv6 TYPE=128 CODE=0 ID=4242 SEQ=1"
+	pfsenseICMPv6 = "2025-01-05T00:01:08.143626-05:00 pfsense filterlog[1234]: 5,,,1000000103,igb0,match,block,in,6,0x00,0x9a0f1,255,ipv6-icmp,58,32,::ffff:198.51.100.12,2001:db8::2,"
+)
+
+// fixtureTime is the timestamp of every fixture line.
+var fixtureTime = time.Date(2025, 1, 5, 0, 1, 8, 143626000, time.FixedZone("", -5*60*60))
+
+func u8(v uint8) *uint8    { return &v }
+func u16(v uint16) *uint16 { return &v }
+func u32(v uint32) *uint32 { return &v }
+
+func TestFormats_Parse(t *testing.T) {
+	tests := []struct {
+		format   string
+		line     string
+		expected LogEvent
+	}{
+		{"att", attLine, LogEvent{
+			Format: "att", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("192.0.2.1"), DestinationIP: netip.MustParseAddr("192.0.2.2"), SourcePort: 12345, DestinationPort: 80,
+			Protocol: "TCP", Action: "DROP", Reason: "PORTSCAN", PacketLength: 500, TTL: 64,
+		}},
+		{"netfilter", netfilterLine, LogEvent{
+			Format: "netfilter", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.7"), DestinationIP: netip.MustParseAddr("203.0.113.2"), SourcePort: 51234, DestinationPort: 22,
+			Protocol: "TCP", Action: "DROP", Reason: "DROP-IN", PacketLength: 60, TTL: 50,
+			Header: Header{
+				Interface: "eth0", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00", TOS: "0x00", Precedence: "0x00",
+				ID: u16(54321), IPFlags: "DF", TCPFlags: "SYN", Window: u16(29200), Reserved: "0x00", Urgent: u16(0),
+			},
+		}},
+		{"ufw", ufwLine, LogEvent{
+			Format: "ufw", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.8"), DestinationIP: netip.MustParseAddr("203.0.113.2"), SourcePort: 40000, DestinationPort: 3389,
+			Protocol: "TCP", Action: "DROP", Reason: "UFW-BLOCK", PacketLength: 40, TTL: 244,
+			Header: Header{
+				Interface: "eth0", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00", TOS: "0x00", Precedence: "0x00",
+				ID: u16(1), TCPFlags: "SYN", Window: u16(1024), Reserved: "0x00", Urgent: u16(0),
+			},
+		}},
+		{"openwrt", openwrtLine, LogEvent{
+			Format: "openwrt", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.9"), DestinationIP: netip.MustParseAddr("203.0.113.3"), SourcePort: 5353, DestinationPort: 161,
+			Protocol: "UDP", Action: "DROP", Reason: "DROP-WAN-IN", PacketLength: 44, TTL: 240,
+			Header: Header{Interface: "eth1", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00", TOS: "0x00", Precedence: "0x00", ID: u16(2)},
+		}},
+		{"netfilter", icmpLine, LogEvent{
+			Format: "netfilter", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.11"), DestinationIP: netip.MustParseAddr("203.0.113.2"),
+			Protocol: "ICMP", Action: "DROP", Reason: "DROP-IN", PacketLength: 84, TTL: 55,
+			Header: Header{
+				Interface: "eth0", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00", TOS: "0x00", Precedence: "0x00",
+				ID: u16(0), IPFlags: "DF", ICMPType: u8(8), ICMPCode: u8(0),
+			},
+		}},
+		{"pfsense", pfsenseLine, LogEvent{
+			Format: "pfsense", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.10"), DestinationIP: netip.MustParseAddr("203.0.113.4"), SourcePort: 51234, DestinationPort: 22,
+			Protocol: "TCP", Action: "DROP", Reason: "RULE-1000000103", PacketLength: 60, TTL: 64,
+			Header: Header{
+				Interface: "igb0", TOS: "0x0", ID: u16(0), IPFlags: "DF", TCPFlags: "SYN", Window: u16(64240),
+			},
+		}},
+		{"pfsense", pfsenseV6Line, LogEvent{
+			Format: "pfsense", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("2001:db8::1"), DestinationIP: netip.MustParseAddr("2001:db8::2"), SourcePort: 5353, DestinationPort: 53,
+			Protocol: "UDP", Action: "ACCEPT", Reason: "RULE-1000000103", PacketLength: 56, TTL: 64,
+			Header: Header{Interface: "igb0", TOS: "0x00", FlowLabel: u32(0)},
+		}},
+		{"netfilter", netfilterV6, LogEvent{
+			Format: "netfilter", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("2001:db8::7"), DestinationIP: netip.MustParseAddr("2001:db8:0:1::2"), SourcePort: 51234, DestinationPort: 22,
+			Protocol: "TCP", Action: "DROP", Reason: "DROP-IN", PacketLength: 80, TTL: 57,
+			Header: Header{
+				Interface: "eth0", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:86:dd", TOS: "0",
+				TCPFlags: "SYN", Window: u16(64800), Reserved: "0x00", Urgent: u16(0), FlowLabel: u32(654321),
+			},
+		}},
+		{"netfilter", icmpv6Line, LogEvent{
+			Format: "netfilter", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("2001:db8::11"), DestinationIP: netip.MustParseAddr("2001:db8:0:1::2"),
+			Protocol: "ICMPv6", Action: "DROP", Reason: "DROP-IN", PacketLength: 104, TTL: 64,
+			Header: Header{
+				Interface: "eth0", MAC: "00:11:22:33:44:55:66:77:88:99:aa:bb:86:dd", TOS: "0",
+				ICMPType: u8(128), ICMPCode: u8(0), FlowLabel: u32(0),
+			},
+		}},
+		{"pfsense", pfsenseICMPv6, LogEvent{
+			Format: "pfsense", Timestamp: fixtureTime,
+			SourceIP: netip.MustParseAddr("198.51.100.12"), DestinationIP: netip.MustParseAddr("2001:db8::2"),
+			Protocol: "ICMPv6", Action: "DROP", Reason: "RULE-1000000103", PacketLength: 32, TTL: 255,
+			Header: Header{Interface: "igb0", TOS: "0x00", FlowLabel: u32(0x9a0f1)},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			p, ok := Lookup(tc.format)
+			if !ok {
+				t.Fatalf("Parser %q is not registered", tc.format)
+			}
+			if !p.Match(tc.line) {
+				t.Fatalf("Expected %s to match %q", tc.format, tc.line)
+			}
+			ev, err := p.Parse(tc.line)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			checkEvent(t, tc.line, ev, tc.expected)
+		})
+	}
+}
+
+func TestFormats_RejectOtherFormats(t *testing.T) {
+	lines := map[string]string{
+		"att": attLine, "netfilter": netfilterLine, "ufw": ufwLine, "openwrt": openwrtLine, "pfsense": pfsenseLine,
+	}
+
+	// The specific formats must not claim each other's lines. The generic
+	// netfilter parser accepts UFW and OpenWrt lines by design.
+	for _, name := range []string{"att", "ufw", "openwrt", "pfsense"} {
+		p, _ := Lookup(name)
+		for other, line := range lines {
+			if other == name {
+				continue
+			}
+			if p.Match(line) {
+				t.Errorf("Expected %s not to match the %s line", name, other)
+			}
+			if _, err := p.Parse(line); err == nil {
+				t.Errorf("Expected %s to fail parsing the %s line", name, other)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   []string
+		expected string
+	}{
+		{"Router", []string{attLine, attLine, "garbage"}, "att"},
+		{"UFW", []string{ufwLine, ufwLine, netfilterLine}, "ufw"},
+		{"Netfilter", []string{netfilterLine, netfilterLine, ufwLine}, "netfilter"},
+		{"PfSense", []string{pfsenseLine, pfsenseV6Line}, "pfsense"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Detect(tc.sample)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.Name() != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, p.Name())
+			}
+		})
+	}
+
+	if _, err := Detect([]string{"garbage", ""}); err != ErrNoMatch {
+		t.Errorf("Expected ErrNoMatch for an unrecognised sample, got %v", err)
+	}
+}
+
+func TestAuto(t *testing.T) {
+	auto := NewAuto(3)
+
+	for i := 0; i < 3; i++ {
+		if _, err := auto.Parse(ufwLine); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if detected := auto.Detected(); detected == nil || detected.Name() != "ufw" {
+		t.Fatalf("Expected ufw to be detected, got %v", detected)
+	}
+
+	// Lines in other formats are still parsed after detection settles.
+	ev, err := auto.Parse(attLine)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ev.Format != "att" {
+		t.Errorf("Expected att event, got %q", ev.Format)
+	}
+	if ev, err := auto.Parse(netfilterLine); err != nil || ev.Format != "netfilter" {
+		t.Errorf("Expected netfilter event, got %q, %v", ev.Format, err)
+	}
+	// A line that the detected format matches but cannot parse keeps its error.
+	if _, err := auto.Parse("host kernel: [UFW BLOCK] IN=eth0 SRC=bad DST=203.0.113.2 PROTO=TCP"); err == nil || err == ErrNoMatch {
+		t.Errorf("Expected the ufw error, got %v", err)
+	}
+
+	if _, err := auto.Parse("garbage"); err != ErrNoMatch {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestForFormat(t *testing.T) {
+	if p, err := ForFormat(""); err != nil || p.Name() != AutoFormat {
+		t.Errorf("Expected auto parser for empty format, got %v, %v", p, err)
+	}
+	if p, err := ForFormat("pfsense"); err != nil || p.Name() != "pfsense" {
+		t.Errorf("Expected pfsense parser, got %v, %v", p, err)
+	}
+	if _, err := ForFormat("no-such-format"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestHeaderFlags(t *testing.T) {
+	const prefix = "2025-01-05T00:01:08Z gw kernel: DROP-IN: IN=eth0 OUT= SRC=198.51.100.7 DST=203.0.113.2 LEN=40 TTL=50 ID=54321 PROTO=TCP SPT=40000 DPT=22 WINDOW=1024 RES=0x00 "
+	tests := []struct {
+		name     string
+		line     string
+		ipFlags  string
+		tcpFlags string
+	}{
+		{"SYN scan", prefix + "SYN URGP=0", "", "SYN"},
+		{"ACK scan", prefix + "ACK URGP=0", "", "ACK"},
+		{"XMAS scan", prefix + "URG PSH FIN URGP=1", "", "URG PSH FIN"},
+		{"NULL scan", prefix + "URGP=0", "", ""},
+		{"Fragment flags", "2025-01-05T00:01:08Z gw kernel: DROP-IN: IN=eth0 OUT= SRC=198.51.100.7 DST=203.0.113.2 LEN=40 TTL=50 ID=1 CE DF MF PROTO=UDP SPT=1 DPT=2 LEN=20", "CE DF MF", ""},
+		{"ICMP error quoting a packet", "2025-01-05T00:01:08Z gw kernel: DROP-IN: IN=eth0 OUT= SRC=198.51.100.7 DST=203.0.113.2 LEN=68 TTL=50 ID=7 PROTO=ICMP TYPE=3 CODE=3 [SRC=203.0.113.2 DST=198.51.100.7 LEN=40 TTL=64 ID=9 DF PROTO=TCP SPT=22 DPT=40000 WINDOW=0 RES=0x00 ACK RST URGP=0 ]", "", ""},
+	}
+
+	p, _ := Lookup("netfilter")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ev, err := p.Parse(tc.line)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ev.IPFlags != tc.ipFlags {
+				t.Errorf("Expected IP flags %q, got %q", tc.ipFlags, ev.IPFlags)
+			}
+			if ev.TCPFlags != tc.tcpFlags {
+				t.Errorf("Expected TCP flags %q, got %q", tc.tcpFlags, ev.TCPFlags)
+			}
+
+			// The router parser reads the same header fields.
+			att := ExtractFields(tc.line)
+			if att.IPFlags != tc.ipFlags || att.TCPFlags != tc.tcpFlags {
+				t.Errorf("Router parser: expected flags %q/%q, got %q/%q", tc.ipFlags, tc.tcpFlags, att.IPFlags, att.TCPFlags)
+			}
+		})
+	}
+}
+
+func TestExtractFields_Header(t *testing.T) {
+	line := "2025-01-05T00:01:08.143626-05:00 dsldevice.attlocal.net L4 FIREWALL[7567]: IN=eth0 OUT= MAC=00:11:22:33:44:55 SRC=192.0.2.1 DST=192.0.2.2 LEN=60 TOS=0x10 PREC=0x00 TTL=64 ID=0 DF PROTO=TCP SPT=12345 DPT=80 WINDOW=0 RES=0x00 ACK RST URGP=0 action=DROP reason=PORTSCAN"
+	expected := Header{
+		Interface: "eth0", MAC: "00:11:22:33:44:55", TOS: "0x10", Precedence: "0x00",
+		ID: u16(0), IPFlags: "DF", TCPFlags: "ACK RST", Window: u16(0), Reserved: "0x00", Urgent: u16(0),
+	}
+
+	ev, err := attParser{}.Parse(line)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ev.Header, expected) {
+		t.Errorf("Expected header %+v, got %+v", expected, ev.Header)
+	}
+}
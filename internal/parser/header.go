@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Header holds the packet header fields beyond LogEvent's core fields. Formats
+// fill in what they report and leave the rest empty; numeric fields are nil
+// when absent, since zero is a meaningful value (an IP ID or window of 0 is a
+// common scanner fingerprint).
+type Header struct {
+	Interface    string  `json:"in,omitempty"`        // Inbound interface
+	OutInterface string  `json:"out,omitempty"`       // Outbound interface
+	MAC          string  `json:"mac,omitempty"`       // MAC header as logged
+	TOS          string  `json:"tos,omitempty"`       // Type of service / traffic class, e.g. "0x00"
+	Precedence   string  `json:"prec,omitempty"`      // IP precedence, e.g. "0x00"
+	ID           *uint16 `json:"id,omitempty"`        // IPv4 identification
+	IPFlags      string  `json:"ip_flags,omitempty"`  // Set IP flags, space separated (e.g. "DF")
+	TCPFlags     string  `json:"tcp_flags,omitempty"` // Set TCP flags, space separated (e.g. "ACK SYN")
+	Window       *uint16 `json:"window,omitempty"`    // TCP window
+	Reserved     string  `json:"res,omitempty"`       // TCP reserved bits, e.g. "0x00"
+	Urgent       *uint16 `json:"urgp,omitempty"`      // TCP urgent pointer
+	ICMPType     *uint8  `json:"icmp_type,omitempty"` // ICMP or ICMPv6 type
+	ICMPCode     *uint8  `json:"icmp_code,omitempty"`
+	FlowLabel    *uint32 `json:"flowlbl,omitempty"` // IPv6 flow label
+}
+
+// IsZero reports whether no header field is set.
+func (h Header) IsZero() bool {
+	return h == Header{}
+}
+
+// flagSet is a set of IP or TCP flags, one bit per name in ipFlagNames or tcpFlagNames.
+type flagSet uint16
+
+// ipFlagNames and tcpFlagNames are the bare flag tokens netfilter logs, in the
+// order it logs them.
+var (
+	ipFlagNames  = []string{"CE", "DF", "MF"}
+	tcpFlagNames = []string{"CWR", "ECE", "URG", "ACK", "PSH", "RST", "SYN", "FIN"}
+)
+
+// flagBit returns the bit for token in names, or 0 if it is not one of them.
+func flagBit(token string, names []string) flagSet {
+	for i, name := range names {
+		if token == name {
+			return 1 << i
+		}
+	}
+	return 0
+}
+
+// format returns the names of the set flags, space separated.
+func (f flagSet) format(names []string) string {
+	if f == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, name := range names {
+		if f&(1<<i) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+// netfilterFlags collects the bare IP and TCP flag tokens of a netfilter field
+// list. It stops at a bracketed token, where ICMP errors quote the offending
+// packet's own header.
+func netfilterFlags(s string) (ip, tcp flagSet) {
+	for _, token := range strings.Fields(s) {
+		if strings.HasPrefix(token, "[") {
+			break
+		}
+		ip |= flagBit(token, ipFlagNames)
+		tcp |= flagBit(token, tcpFlagNames)
+	}
+	return ip, tcp
+}
+
+// parseUint16Ptr parses an optional 16-bit field, returning nil if s is not one.
+func parseUint16Ptr(s string) *uint16 {
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return nil
+	}
+	n := uint16(v)
+	return &n
+}
+
+// ipv4ID parses the IP ID of a packet from src. IPv6 headers have none, so
+// the only ID in an IPv6 line is an ICMPv6 echo's, which is not kept.
+func ipv4ID(src netip.Addr, s string) *uint16 {
+	if src.Is6() {
+		return nil
+	}
+	return parseUint16Ptr(s)
+}
+
+// parseFlowLabel parses an optional IPv6 flow label, logged in decimal by
+// netfilter and in hex ("0x00000") by pfSense, returning nil if s is not one.
+func parseFlowLabel(s string) *uint32 {
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 0, 20)
+	if err != nil {
+		return nil
+	}
+	n := uint32(v)
+	return &n
+}
+
+// parseUint8Ptr parses an optional 8-bit field, returning nil if s is not one.
+func parseUint8Ptr(s string) *uint8 {
+	if v, ok := parseUint8(s); ok {
+		return &v
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// openwrtPrefix matches the log prefixes of OpenWrt's fw3 ("REJECT(src wan)")
+// and fw4 ("drop wan in:") firewalls.
+var openwrtPrefix = regexp.MustCompile(`(?i)^(reject|drop|accept)(\((src|dest) [\w-]+\)| [\w-]+ (in|out|forward))$`)
+
+// netfilterParser handles kernel packet logs written by iptables' LOG target or
+// nftables' log statement, for example:
+//
+//	kernel: [ 1234.567890] DROP-IN: IN=eth0 OUT= MAC=... SRC=198.51.100.1 DST=203.0.113.2 LEN=60 TOS=0x00 PREC=0x00 TTL=50 ID=54321 DF PROTO=TCP SPT=51234 DPT=22 WINDOW=1024 RES=0x00 SYN URGP=0
+//
+// IPv6 packets log TC, HOPLIMIT and FLOWLBL in place of TOS, PREC, TTL and ID,
+// which are read as the TOS, TTL and flow label, and ICMPv6 as PROTO=ICMPv6.
+//
+// The action and reason are derived from the configured log prefix ("DROP-IN").
+// Front-ends such as UFW and OpenWrt are registered separately by restricting
+// the prefixes they accept.
+type netfilterParser struct {
+	name        string
+	matchPrefix func(prefix string) bool // nil accepts any prefix
+}
+
+func (p netfilterParser) Name() string { return p.name }
+
+func (p netfilterParser) Match(line string) bool {
+	prefix, body, ok := splitNetfilter(line)
+	if !ok || (p.matchPrefix != nil && !p.matchPrefix(prefix)) {
+		return false
+	}
+	return strings.Contains(body, "SRC=") && strings.Contains(body, "DST=") && strings.Contains(body, "PROTO=")
+}
+
+func (p netfilterParser) Parse(line string) (LogEvent, error) {
+	prefix, body, ok := splitNetfilter(line)
+	if !ok {
+		return LogEvent{}, errors.New("not a netfilter log line")
+	}
+	if p.matchPrefix != nil && !p.matchPrefix(prefix) {
+		return LogEvent{}, errors.New("log prefix does not match " + p.name)
+	}
+
+	fields := keyValues(body)
+	ipFlags, tcpFlags := netfilterFlags(body)
+	ev := LogEvent{
+		Format:          p.name,
+		Timestamp:       parseTimestamp(findTimestamp(line)),
+		SourceIP:        parseAddr(fields["SRC"]),
+		DestinationIP:   parseAddr(fields["DST"]),
+		SourcePort:      parsePortNumber(fields["SPT"]),
+		DestinationPort: parsePortNumber(fields["DPT"]),
+		Protocol:        fields["PROTO"],
+		Action:          prefixAction(prefix),
+		Reason:          nonEmpty(prefixReason(prefix), "unknown"),
+		PacketLength:    atoiSafe(fields["LEN"]),
+		TTL:             atoiSafe(nonEmpty(fields["TTL"], fields["HOPLIMIT"])),
+		Header: Header{
+			Interface:    fields["IN"],
+			OutInterface: fields["OUT"],
+			MAC:          fields["MAC"],
+			TOS:          nonEmpty(fields["TOS"], fields["TC"]),
+			Precedence:   fields["PREC"],
+			FlowLabel:    parseFlowLabel(fields["FLOWLBL"]),
+			IPFlags:      ipFlags.format(ipFlagNames),
+			TCPFlags:     tcpFlags.format(tcpFlagNames),
+			Window:       parseUint16Ptr(fields["WINDOW"]),
+			Reserved:     fields["RES"],
+			Urgent:       parseUint16Ptr(fields["URGP"]),
+		},
+	}
+	ev.ID = ipv4ID(ev.SourceIP, fields["ID"])
+	if strings.HasPrefix(ev.Protocol, "ICMP") {
+		ev.ICMPType = parseUint8Ptr(fields["TYPE"])
+		ev.ICMPCode = parseUint8Ptr(fields["CODE"])
+	}
+	if !ev.SourceIP.IsValid() || !ev.DestinationIP.IsValid() || ev.Protocol == "" {
+		return LogEvent{}, errors.New("netfilter log line is missing SRC, DST or PROTO")
+	}
+	return ev, nil
+}
+
+// isUFWPrefix matches the prefixes UFW logs with, such as "[UFW BLOCK]".
+func isUFWPrefix(prefix string) bool {
+	return strings.HasPrefix(prefix, "[UFW ")
+}
+
+// splitNetfilter splits a line into the log prefix and the "IN=..." field list.
+func splitNetfilter(line string) (string, string, bool) {
+	idx := strings.Index(line, "IN=")
+	for idx > 0 && line[idx-1] != ' ' && line[idx-1] != ']' {
+		next := strings.Index(line[idx+3:], "IN=")
+		if next < 0 {
+			return "", "", false
+		}
+		idx += 3 + next
+	}
+	if idx < 0 {
+		return "", "", false
+	}
+	return logPrefix(line[:idx]), line[idx:], true
+}
+
+// logPrefix extracts the user-configured prefix from the text before "IN=",
+// dropping the syslog header and the kernel's uptime stamp.
+func logPrefix(s string) string {
+	if i := strings.Index(s, "kernel: "); i >= 0 {
+		s = s[i+len("kernel: "):]
+	}
+	s = strings.TrimSpace(s)
+
+	// Drop the kernel's uptime stamp, e.g. "[ 1234.567890]".
+	if strings.HasPrefix(s, "[") {
+		if end := strings.IndexByte(s, ']'); end > 0 && strings.Trim(s[1:end], " 0123456789.") == "" {
+			s = strings.TrimSpace(s[end+1:])
+		}
+	}
+	return strings.TrimSpace(strings.TrimSuffix(s, ":"))
+}
+
+// prefixAction maps a log prefix to the action that was taken on the packet.
+func prefixAction(prefix string) string {
+	upper := strings.ToUpper(prefix)
+	switch {
+	case strings.Contains(upper, "REJECT"):
+		return "REJECT"
+	case strings.Contains(upper, "DROP"), strings.Contains(upper, "BLOCK"), strings.Contains(upper, "DENY"):
+		return "DROP"
+	case strings.Contains(upper, "ACCEPT"), strings.Contains(upper, "ALLOW"), strings.Contains(upper, "PASS"):
+		return "ACCEPT"
+	}
+	return "LOG"
+}
+
+// prefixReason turns a log prefix such as "[UFW BLOCK]" into a reason code ("UFW-BLOCK").
+func prefixReason(prefix string) string {
+	prefix = strings.NewReplacer("[", "", "]", "").Replace(prefix)
+	return strings.ToUpper(strings.Join(strings.Fields(prefix), "-"))
+}
+
+// keyValues collects the KEY=value tokens of a netfilter field list. Bare flags
+// such as SYN or DF are ignored, and the first occurrence of a key wins, so the
+// IP header's ID is kept over an ICMP echo's.
+func keyValues(s string) map[string]string {
+	fields := make(map[string]string, 24)
+	for _, token := range strings.Fields(s) {
+		if key, value, ok := strings.Cut(token, "="); ok {
+			if _, seen := fields[key]; !seen {
+				fields[key] = value
+			}
+		}
+	}
+	return fields
+}
@@ -1,48 +1,33 @@
 package parser
 
 import (
-	"regexp"
+	"errors"
 	"strconv"
 	"strings"
 )
 
-// Precompiled regex patterns for performance.
-var (
-	timestampRegex = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?([+\-]\d{2}:\d{2}|Z)?\b`)
-	ipRegex        = regexp.MustCompile(`SRC=(([0-9]{1,3}\.){3}[0-9]{1,3}|([a-fA-F0-9:]+))`)
-	dstRegex       = regexp.MustCompile(`DST=(([0-9]{1,3}\.){3}[0-9]{1,3}|([a-fA-F0-9:]+))`)
-	sptRegex       = regexp.MustCompile(`SPT=(\d+)`)
-	dptRegex       = regexp.MustCompile(`DPT=(\d+)`)
-	protoRegex     = regexp.MustCompile(`PROTO=(\w+)`)
-	actionRegex    = regexp.MustCompile(`action=(\w+)`)
-	reasonRegex    = regexp.MustCompile(`reason=([\w\-]+)`)
-	lengthRegex    = regexp.MustCompile(`LEN=(\d+)`)
-	ttlRegex       = regexp.MustCompile(`TTL=(\d+)`)
-)
-
-// IsValidLine checks if a log line is well-formed.
+// IsValidLine checks if a log line is a well-formed router log line, with a
+// timestamp and every SRC, DST, SPT, DPT, PROTO, action, reason, LEN and TTL
+// (or IPv6 HOPLIMIT) field.
 func IsValidLine(line string) bool {
-	return timestampRegex.MatchString(line) &&
-		ipRegex.MatchString(line) &&
-		dstRegex.MatchString(line) &&
-		sptRegex.MatchString(line) &&
-		dptRegex.MatchString(line) &&
-		protoRegex.MatchString(line) &&
-		actionRegex.MatchString(line) &&
-		reasonRegex.MatchString(line) &&
-		lengthRegex.MatchString(line) &&
-		ttlRegex.MatchString(line)
+	f := scanRouterFields(line)
+	return f.complete()
 }
 
-// IsFlaggedLog checks if a log line indicates a potential threat.
-func IsFlaggedLog(line string) bool {
-	FlaggedReasons := []string{
-		"POLICY-INPUT-GEN-DISCARD",
-		"PORTSCAN",
-		"INTRUSION-DETECTED",
-		"MALFORMED-PACKET",
-	}
+// FlaggedReasons are the router reasons that mark a dropped packet as a potential
+// threat. They make up the default rules when no rules are configured.
+var FlaggedReasons = []string{
+	"POLICY-INPUT-GEN-DISCARD",
+	"PORTSCAN",
+	"INTRUSION-DETECTED",
+	"MALFORMED-PACKET",
+}
 
+// IsFlaggedLog checks if a router log line indicates a potential threat.
+//
+// Deprecated: the pipeline flags parsed events with the configurable rules in
+// package rules, whose defaults include this check.
+func IsFlaggedLog(line string) bool {
 	if !strings.Contains(line, "action=DROP") {
 		return false
 	}
@@ -55,41 +40,39 @@ func IsFlaggedLog(line string) bool {
 	return false
 }
 
-// ExtractFields extracts fields of interest from a log line.
-func ExtractFields(line string) (string, string, string, int, int, string, string, string, int, int) {
-	timestamp := timestampRegex.FindString(line)
-	srcIP := getFirstGroup(ipRegex.FindStringSubmatch(line))
-	dstIP := getFirstGroup(dstRegex.FindStringSubmatch(line))
-	spt := parsePort(sptRegex.FindStringSubmatch(line))
-	dpt := parsePort(dptRegex.FindStringSubmatch(line))
-	proto := getFirstGroup(protoRegex.FindStringSubmatch(line))
-	action := getFirstGroup(actionRegex.FindStringSubmatch(line))
-	reason := getFirstGroup(reasonRegex.FindStringSubmatch(line))
-	packetLength := parsePort(lengthRegex.FindStringSubmatch(line))
-	ttl := parsePort(ttlRegex.FindStringSubmatch(line))
-
-	return nonEmpty(timestamp, "unknown"),
-		nonEmpty(srcIP, "unknown"),
-		nonEmpty(dstIP, "unknown"),
-		spt,
-		dpt,
-		nonEmpty(proto, "unknown"),
-		nonEmpty(action, "unknown"),
-		nonEmpty(reason, "unknown"),
-		packetLength,
-		ttl
+// ExtractFields extracts fields of interest from a router log line. Fields that
+// are missing are left at their zero value, or "unknown" for text fields.
+func ExtractFields(line string) LogEvent {
+	f := scanRouterFields(line)
+	return f.event()
 }
 
-// parsePort safely parses a port or numeric field. Returns 0 if missing or invalid.
-func parsePort(match []string) int {
-	if len(match) > 1 {
-		return atoiSafe(match[1])
+// attFormat is the registered name of the AT&T router format.
+const attFormat = "att"
+
+// errInvalidRouterLine is returned for lines that are not complete router log lines.
+var errInvalidRouterLine = errors.New("not a valid router log line")
+
+// attParser handles the AT&T router's "SRC= DST= SPT= action= reason=" firewall format.
+type attParser struct{}
+
+func (attParser) Name() string { return attFormat }
+
+func (attParser) Match(line string) bool { return IsValidLine(line) }
+
+func (attParser) Parse(line string) (LogEvent, error) {
+	f := scanRouterFields(line)
+	if !f.complete() {
+		return LogEvent{}, errInvalidRouterLine
 	}
-	return 0
+	return f.event(), nil
 }
 
 // atoiSafe converts a string to an integer, returning 0 if conversion fails.
 func atoiSafe(s string) int {
+	if s == "" {
+		return 0
+	}
 	port, err := strconv.Atoi(s)
 	if err != nil {
 		return 0
@@ -97,14 +80,6 @@ func atoiSafe(s string) int {
 	return port
 }
 
-// getFirstGroup retrieves the first capturing group from a regex match.
-func getFirstGroup(match []string) string {
-	if len(match) > 1 {
-		return match[1]
-	}
-	return ""
-}
-
 // nonEmpty returns the default value if the input is an empty string.
 func nonEmpty(input, defaultValue string) string {
 	if input == "" {
@@ -1,6 +1,11 @@
 package parser
 
-import "testing"
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestIsFlaggedLog(t *testing.T) {
 	tests := []struct {
@@ -23,293 +28,209 @@ func TestIsFlaggedLog(t *testing.T) {
 
 func TestExtractFields(t *testing.T) {
 	tests := []struct {
-		line                    string
-		expectedTimestamp       string
-		expectedSourceIP        string
-		expectedDestinationIP   string
-		expectedSourcePort      int
-		expectedDestinationPort int
-		expectedProtocol        string
-		expectedAction          string
-		expectedReason          string
-		expectedPacketLength    int
-		expectedTTL             int
+		line     string
+		expected LogEvent
 	}{
 		{
 			"2025-01-05T00:01:08.143626-05:00 SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=PORTSCAN LEN=500 TTL=64",
-			"2025-01-05T00:01:08.143626-05:00",
-			"192.0.2.1",
-			"192.0.2.2",
-			12345,
-			80,
-			"TCP",
-			"DROP",
-			"PORTSCAN",
-			500,
-			64,
+			LogEvent{
+				Format:          "att",
+				Timestamp:       time.Date(2025, 1, 5, 0, 1, 8, 143626000, time.FixedZone("", -5*60*60)),
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				DestinationIP:   netip.MustParseAddr("192.0.2.2"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "DROP",
+				Reason:          "PORTSCAN",
+				PacketLength:    500,
+				TTL:             64,
+			},
 		},
 	}
 
 	for _, test := range tests {
-		timestamp, srcIP, dstIP, spt, dpt, proto, action, reason, packetLength, ttl := ExtractFields(test.line)
-
-		if timestamp != test.expectedTimestamp {
-			t.Errorf("Expected timestamp %q, got %q", test.expectedTimestamp, timestamp)
-		}
-		if srcIP != test.expectedSourceIP {
-			t.Errorf("Expected source IP %q, got %q", test.expectedSourceIP, srcIP)
-		}
-		if dstIP != test.expectedDestinationIP {
-			t.Errorf("Expected destination IP %q, got %q", test.expectedDestinationIP, dstIP)
-		}
-		if spt != test.expectedSourcePort {
-			t.Errorf("Expected source port %d, got %d", test.expectedSourcePort, spt)
-		}
-		if dpt != test.expectedDestinationPort {
-			t.Errorf("Expected destination port %d, got %d", test.expectedDestinationPort, dpt)
-		}
-		if proto != test.expectedProtocol {
-			t.Errorf("Expected protocol %q, got %q", test.expectedProtocol, proto)
-		}
-		if action != test.expectedAction {
-			t.Errorf("Expected action %q, got %q", test.expectedAction, action)
-		}
-		if reason != test.expectedReason {
-			t.Errorf("Expected reason %q, got %q", test.expectedReason, reason)
-		}
-		if packetLength != test.expectedPacketLength {
-			t.Errorf("Expected packet length %d, got %d", test.expectedPacketLength, packetLength)
-		}
-		if ttl != test.expectedTTL {
-			t.Errorf("Expected TTL %d, got %d", test.expectedTTL, ttl)
-		}
+		checkEvent(t, test.line, ExtractFields(test.line), test.expected)
 	}
 }
 
 func TestExtractFields_EdgeCases(t *testing.T) {
 	tests := []struct {
-		line                    string
-		expectedSourceIP        string
-		expectedDestinationIP   string
-		expectedSourcePort      int
-		expectedDestinationPort int
-		expectedProtocol        string
-		expectedAction          string
-		expectedReason          string
-		expectedPacketLength    int
-		expectedTTL             int
+		line     string
+		expected LogEvent
 	}{
 		{
-			line:                    "SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=TEST LEN=512 TTL=128",
-			expectedSourceIP:        "192.0.2.1",
-			expectedDestinationIP:   "192.0.2.2",
-			expectedSourcePort:      12345,
-			expectedDestinationPort: 80,
-			expectedProtocol:        "TCP",
-			expectedAction:          "DROP",
-			expectedReason:          "TEST",
-			expectedPacketLength:    512,
-			expectedTTL:             128,
+			line: "SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=TEST LEN=512 TTL=128",
+			expected: LogEvent{
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				DestinationIP:   netip.MustParseAddr("192.0.2.2"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "DROP",
+				Reason:          "TEST",
+				PacketLength:    512,
+				TTL:             128,
+			},
 		},
 		{
-			line:                    "PROTO=UDP SPT=12345 DPT=443",
-			expectedSourceIP:        "unknown",
-			expectedDestinationIP:   "unknown",
-			expectedSourcePort:      12345,
-			expectedDestinationPort: 443,
-			expectedProtocol:        "UDP",
-			expectedAction:          "unknown",
-			expectedReason:          "unknown",
-			expectedPacketLength:    0,
-			expectedTTL:             0,
+			line: "PROTO=UDP SPT=12345 DPT=443",
+			expected: LogEvent{
+				SourcePort:      12345,
+				DestinationPort: 443,
+				Protocol:        "UDP",
+				Action:          "unknown",
+				Reason:          "unknown",
+			},
 		},
 		{
-			line:                    "Invalid log entry",
-			expectedSourceIP:        "unknown",
-			expectedDestinationIP:   "unknown",
-			expectedSourcePort:      0,
-			expectedDestinationPort: 0,
-			expectedProtocol:        "unknown",
-			expectedAction:          "unknown",
-			expectedReason:          "unknown",
-			expectedPacketLength:    0,
-			expectedTTL:             0,
+			line: "Invalid log entry",
+			expected: LogEvent{
+				Protocol: "unknown",
+				Action:   "unknown",
+				Reason:   "unknown",
+			},
 		},
 	}
 
 	for _, test := range tests {
-		_, srcIP, dstIP, spt, dpt, proto, action, reason, packetLength, ttl := ExtractFields(test.line)
-
-		if srcIP != test.expectedSourceIP {
-			t.Errorf("Expected SRC IP %q, got %q", test.expectedSourceIP, srcIP)
-		}
-		if dstIP != test.expectedDestinationIP {
-			t.Errorf("Expected DST IP %q, got %q", test.expectedDestinationIP, dstIP)
-		}
-		if spt != test.expectedSourcePort {
-			t.Errorf("Expected SRC port %d, got %d", test.expectedSourcePort, spt)
-		}
-		if dpt != test.expectedDestinationPort {
-			t.Errorf("Expected DST port %d, got %d", test.expectedDestinationPort, dpt)
-		}
-		if proto != test.expectedProtocol {
-			t.Errorf("Expected protocol %q, got %q", test.expectedProtocol, proto)
-		}
-		if action != test.expectedAction {
-			t.Errorf("Expected action %q, got %q", test.expectedAction, action)
-		}
-		if reason != test.expectedReason {
-			t.Errorf("Expected reason %q, got %q", test.expectedReason, reason)
-		}
-		if packetLength != test.expectedPacketLength {
-			t.Errorf("Expected packet length %d, got %d", test.expectedPacketLength, packetLength)
-		}
-		if ttl != test.expectedTTL {
-			t.Errorf("Expected TTL %d, got %d", test.expectedTTL, ttl)
-		}
+		test.expected.Format = "att"
+		checkEvent(t, test.line, ExtractFields(test.line), test.expected)
 	}
 }
 
 func TestExtractFields_MissingFields(t *testing.T) {
 	tests := []struct {
-		line                    string
-		expectedSourceIP        string
-		expectedDestinationIP   string
-		expectedSourcePort      int
-		expectedDestinationPort int
-		expectedProtocol        string
-		expectedAction          string
-		expectedReason          string
-		expectedPacketLength    int
-		expectedTTL             int
+		line     string
+		expected LogEvent
 	}{
 		{
-			line:                    "DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80",
-			expectedSourceIP:        "unknown",
-			expectedDestinationIP:   "192.0.2.2",
-			expectedSourcePort:      12345,
-			expectedDestinationPort: 80,
-			expectedProtocol:        "TCP",
-			expectedAction:          "unknown",
-			expectedReason:          "unknown",
-			expectedPacketLength:    0,
-			expectedTTL:             0,
+			line: "DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80",
+			expected: LogEvent{
+				DestinationIP:   netip.MustParseAddr("192.0.2.2"),
+				SourcePort:      12345,
+				DestinationPort: 80,
+				Protocol:        "TCP",
+				Action:          "unknown",
+				Reason:          "unknown",
+			},
 		},
 		{
-			line:                    "SRC=192.0.2.1 PROTO=UDP DPT=443",
-			expectedSourceIP:        "192.0.2.1",
-			expectedDestinationIP:   "unknown",
-			expectedSourcePort:      0,
-			expectedDestinationPort: 443,
-			expectedProtocol:        "UDP",
-			expectedAction:          "unknown",
-			expectedReason:          "unknown",
-			expectedPacketLength:    0,
-			expectedTTL:             0,
+			line: "SRC=192.0.2.1 PROTO=UDP DPT=443",
+			expected: LogEvent{
+				SourceIP:        netip.MustParseAddr("192.0.2.1"),
+				DestinationPort: 443,
+				Protocol:        "UDP",
+				Action:          "unknown",
+				Reason:          "unknown",
+			},
 		},
 		{
-			line:                    "SRC=192.0.2.1 DST=192.0.2.2 SPT=54321",
-			expectedSourceIP:        "192.0.2.1",
-			expectedDestinationIP:   "192.0.2.2",
-			expectedSourcePort:      54321,
-			expectedDestinationPort: 0,
-			expectedProtocol:        "unknown",
-			expectedAction:          "unknown",
-			expectedReason:          "unknown",
-			expectedPacketLength:    0,
-			expectedTTL:             0,
+			line: "SRC=192.0.2.1 DST=192.0.2.2 SPT=54321",
+			expected: LogEvent{
+				SourceIP:      netip.MustParseAddr("192.0.2.1"),
+				DestinationIP: netip.MustParseAddr("192.0.2.2"),
+				SourcePort:    54321,
+				Protocol:      "unknown",
+				Action:        "unknown",
+				Reason:        "unknown",
+			},
 		},
 	}
 
 	for _, test := range tests {
-		_, srcIP, dstIP, spt, dpt, proto, action, reason, packetLength, ttl := ExtractFields(test.line)
-
-		if srcIP != test.expectedSourceIP {
-			t.Errorf("Expected SRC IP %q, got %q", test.expectedSourceIP, srcIP)
-		}
-		if dstIP != test.expectedDestinationIP {
-			t.Errorf("Expected DST IP %q, got %q", test.expectedDestinationIP, dstIP)
-		}
-		if spt != test.expectedSourcePort {
-			t.Errorf("Expected SRC port %d, got %d", test.expectedSourcePort, spt)
-		}
-		if dpt != test.expectedDestinationPort {
-			t.Errorf("Expected DST port %d, got %d", test.expectedDestinationPort, dpt)
-		}
-		if proto != test.expectedProtocol {
-			t.Errorf("Expected protocol %q, got %q", test.expectedProtocol, proto)
-		}
-		if action != test.expectedAction {
-			t.Errorf("Expected action %q, got %q", test.expectedAction, action)
-		}
-		if reason != test.expectedReason {
-			t.Errorf("Expected reason %q, got %q", test.expectedReason, reason)
-		}
-		if packetLength != test.expectedPacketLength {
-			t.Errorf("Expected packet length %d, got %d", test.expectedPacketLength, packetLength)
-		}
-		if ttl != test.expectedTTL {
-			t.Errorf("Expected TTL %d, got %d", test.expectedTTL, ttl)
-		}
+		test.expected.Format = "att"
+		checkEvent(t, test.line, ExtractFields(test.line), test.expected)
 	}
 }
 
 func TestExtractFields_IPv6(t *testing.T) {
 	tests := []struct {
-		line                  string
-		expectedSourceIP      string
-		expectedDestinationIP string
-		expectedProtocol      string
-		expectedAction        string
-		expectedReason        string
-		expectedPacketLength  int
-		expectedTTL           int
+		line     string
+		expected LogEvent
 	}{
 		{
-			line:                  "SRC=2001:0db8::1 DST=2001:0db8::2 PROTO=TCP action=DROP reason=PORTSCAN LEN=400 TTL=64",
-			expectedSourceIP:      "2001:0db8::1",
-			expectedDestinationIP: "2001:0db8::2",
-			expectedProtocol:      "TCP",
-			expectedAction:        "DROP",
-			expectedReason:        "PORTSCAN",
-			expectedPacketLength:  400,
-			expectedTTL:           64,
+			line: "SRC=2001:0db8::1 DST=2001:0db8::2 PROTO=TCP action=DROP reason=PORTSCAN LEN=400 TTL=64",
+			expected: LogEvent{
+				SourceIP:      netip.MustParseAddr("2001:db8::1"),
+				DestinationIP: netip.MustParseAddr("2001:db8::2"),
+				Protocol:      "TCP",
+				Action:        "DROP",
+				Reason:        "PORTSCAN",
+				PacketLength:  400,
+				TTL:           64,
+			},
+		},
+		{
+			line: "2025-01-05T00:01:08Z SRC=2001:DB8:0:0:0:0:0:1 DST=::ffff:192.0.2.2 PROTO=ICMPv6 SPT=0 DPT=0 action=DROP reason=PORTSCAN LEN=72 TC=0 HOPLIMIT=255 FLOWLBL=12 TYPE=135 CODE=0",
+			expected: LogEvent{
+				Timestamp:     time.Date(2025, 1, 5, 0, 1, 8, 0, time.UTC),
+				SourceIP:      netip.MustParseAddr("2001:db8::1"),
+				DestinationIP: netip.MustParseAddr("192.0.2.2"),
+				Protocol:      "ICMPv6",
+				Action:        "DROP",
+				Reason:        "PORTSCAN",
+				PacketLength:  72,
+				TTL:           255,
+				Header:        Header{TOS: "0", ICMPType: u8(135), ICMPCode: u8(0), FlowLabel: u32(12)},
+			},
 		},
 		{
-			line:                  "SRC=INVALID_IP DST=2001:0db8::1 PROTO=UDP",
-			expectedSourceIP:      "unknown",
-			expectedDestinationIP: "2001:0db8::1",
-			expectedProtocol:      "UDP",
-			expectedAction:        "unknown",
-			expectedReason:        "unknown",
-			expectedPacketLength:  0,
-			expectedTTL:           0,
+			line: "SRC=INVALID_IP DST=2001:0db8::1 PROTO=UDP",
+			expected: LogEvent{
+				DestinationIP: netip.MustParseAddr("2001:db8::1"),
+				Protocol:      "UDP",
+				Action:        "unknown",
+				Reason:        "unknown",
+			},
 		},
 	}
 
 	for _, test := range tests {
-		_, srcIP, dstIP, _, _, proto, action, reason, packetLength, ttl := ExtractFields(test.line)
+		test.expected.Format = "att"
+		checkEvent(t, test.line, ExtractFields(test.line), test.expected)
+	}
+}
 
-		if srcIP != test.expectedSourceIP {
-			t.Errorf("Expected SRC IP %q, got %q", test.expectedSourceIP, srcIP)
-		}
-		if dstIP != test.expectedDestinationIP {
-			t.Errorf("Expected DST IP %q, got %q", test.expectedDestinationIP, dstIP)
-		}
-		if proto != test.expectedProtocol {
-			t.Errorf("Expected protocol %q, got %q", test.expectedProtocol, proto)
-		}
-		if action != test.expectedAction {
-			t.Errorf("Expected action %q, got %q", test.expectedAction, action)
-		}
-		if reason != test.expectedReason {
-			t.Errorf("Expected reason %q, got %q", test.expectedReason, reason)
-		}
-		if packetLength != test.expectedPacketLength {
-			t.Errorf("Expected packet length %d, got %d", test.expectedPacketLength, packetLength)
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string // "" for an error
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{"2001:0DB8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"2001:db8:0:0:1:0:0:1", "2001:db8::1:0:0:1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"::FFFF:c000:0201", "192.0.2.1"},
+		{"::192.0.2.1", "::c000:201"},
+		{"fe80::1%eth0", ""},
+		{"2001:db8::1::2", ""},
+		{"192.0.2.256", ""},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		addr, err := ParseAddr(tc.s)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("ParseAddr(%q): expected an error, got %v", tc.s, addr)
+			}
+			continue
 		}
-		if ttl != test.expectedTTL {
-			t.Errorf("Expected TTL %d, got %d", test.expectedTTL, ttl)
+		if err != nil || addr.String() != tc.expected {
+			t.Errorf("ParseAddr(%q): expected %s, got %v, %v", tc.s, tc.expected, addr, err)
 		}
 	}
 }
+
+// checkEvent reports an error if got differs from expected. Timestamps are
+// compared as instants so the location they were parsed in does not matter.
+func checkEvent(t *testing.T, line string, got, expected LogEvent) {
+	t.Helper()
+	if !got.Timestamp.Equal(expected.Timestamp) {
+		t.Errorf("%q: expected timestamp %v, got %v", line, expected.Timestamp, got.Timestamp)
+	}
+	got.Timestamp, expected.Timestamp = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("%q: expected %+v, got %+v", line, expected, got)
+	}
+}
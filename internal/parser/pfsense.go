@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+)
+
+// pfsenseParser handles pfSense and OPNsense "filterlog" CSV lines, for example:
+//
+//	filterlog[1234]: 5,,,1000000103,igb0,match,block,in,4,0x0,,64,0,0,DF,6,tcp,60,198.51.100.1,203.0.113.2,51234,22,0,S,...
+//
+// The field layout after the direction depends on the IP version.
+type pfsenseParser struct{}
+
+func (pfsenseParser) Name() string { return "pfsense" }
+
+func (pfsenseParser) Match(line string) bool {
+	_, err := filterlogFields(line)
+	return err == nil
+}
+
+func (pfsenseParser) Parse(line string) (LogEvent, error) {
+	fields, err := filterlogFields(line)
+	if err != nil {
+		return LogEvent{}, err
+	}
+
+	ev := LogEvent{
+		Format:    "pfsense",
+		Timestamp: parseTimestamp(findTimestamp(line)),
+		Action:    filterlogAction(fields[6]),
+		Reason:    "RULE-" + nonEmpty(fields[3], fields[0]),
+		Header: Header{
+			Interface: fields[4],
+			TOS:       fields[9],
+		},
+	}
+
+	var ports []string
+	switch fields[8] {
+	case "4":
+		// ...,4,tos,ecn,ttl,id,offset,flags,protonum,protoname,length,src,dst,[sport,dport,...]
+		ev.TTL = atoiSafe(fields[11])
+		ev.ID = parseUint16Ptr(fields[12])
+		if fields[14] != "none" {
+			ev.IPFlags = strings.ReplaceAll(fields[14], "+", " ")
+		}
+		ev.Protocol = filterlogProtocol(fields[16])
+		ev.PacketLength = atoiSafe(fields[17])
+		ev.SourceIP = parseAddr(fields[18])
+		ev.DestinationIP = parseAddr(fields[19])
+		ports = fields[20:]
+	case "6":
+		// ...,6,class,flowlabel,hoplimit,protoname,protonum,length,src,dst,[sport,dport,...]
+		ev.FlowLabel = parseFlowLabel(fields[10])
+		ev.TTL = atoiSafe(fields[11])
+		ev.Protocol = filterlogProtocol(fields[12])
+		ev.PacketLength = atoiSafe(fields[14])
+		ev.SourceIP = parseAddr(fields[15])
+		ev.DestinationIP = parseAddr(fields[16])
+		ports = fields[17:]
+	default:
+		return LogEvent{}, errors.New("unknown IP version in filterlog line")
+	}
+
+	if (ev.Protocol == "TCP" || ev.Protocol == "UDP") && len(ports) >= 2 {
+		ev.SourcePort = parsePortNumber(ports[0])
+		ev.DestinationPort = parsePortNumber(ports[1])
+	}
+	// TCP: sport,dport,datalen,flags,seq,ack,window,urg,options
+	if ev.Protocol == "TCP" && len(ports) >= 4 {
+		ev.TCPFlags = filterlogTCPFlags(ports[3])
+	}
+	if ev.Protocol == "TCP" && len(ports) >= 8 {
+		ev.Window = parseUint16Ptr(ports[6])
+		ev.Urgent = parseUint16Ptr(ports[7])
+	}
+	if !ev.SourceIP.IsValid() || !ev.DestinationIP.IsValid() {
+		return LogEvent{}, errors.New("filterlog line is missing addresses")
+	}
+	return ev, nil
+}
+
+// filterlogFields returns the CSV fields of a filterlog line.
+func filterlogFields(line string) ([]string, error) {
+	idx := strings.Index(line, "filterlog")
+	if idx < 0 {
+		return nil, errors.New("not a filterlog line")
+	}
+	_, csv, ok := strings.Cut(line[idx:], ": ")
+	if !ok {
+		return nil, errors.New("filterlog line has no message")
+	}
+	fields := strings.Split(strings.TrimSpace(csv), ",")
+	if len(fields) < 17 || (fields[8] == "4" && len(fields) < 20) {
+		return nil, errors.New("filterlog line has too few fields")
+	}
+	return fields, nil
+}
+
+// filterlogTCPFlags expands pfSense's compact TCP flags ("SA") into netfilter's
+// space separated names ("ACK SYN"), in the same order as netfilter logs them.
+func filterlogTCPFlags(flags string) string {
+	letters := map[string]byte{"CWR": 'W', "ECE": 'E', "URG": 'U', "ACK": 'A', "PSH": 'P', "RST": 'R', "SYN": 'S', "FIN": 'F'}
+	var set flagSet
+	for i, name := range tcpFlagNames {
+		if strings.IndexByte(flags, letters[name]) >= 0 {
+			set |= 1 << i
+		}
+	}
+	return set.format(tcpFlagNames)
+}
+
+// filterlogProtocol maps pfSense protocol names onto netfilter's, which are
+// upper case except for ICMPv6 (pfSense's "ipv6-icmp").
+func filterlogProtocol(name string) string {
+	if strings.EqualFold(name, "ipv6-icmp") {
+		return "ICMPv6"
+	}
+	return strings.ToUpper(name)
+}
+
+// filterlogAction maps pfSense actions onto the router's action names.
+func filterlogAction(action string) string {
+	switch action {
+	case "block":
+		return "DROP"
+	case "reject":
+		return "REJECT"
+	case "pass":
+		return "ACCEPT"
+	}
+	return strings.ToUpper(action)
+}
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Parser recognises and decodes one log format.
+type Parser interface {
+	// Name is the identifier used to select the parser in config.
+	Name() string
+	// Match reports whether line looks like this format.
+	Match(line string) bool
+	// Parse extracts the event from a line. It returns an error if the line is not
+	// in this format or lacks required fields.
+	Parse(line string) (LogEvent, error)
+}
+
+// AutoFormat is the format name that selects automatic detection.
+const AutoFormat = "auto"
+
+// ErrNoMatch is returned when no registered parser recognises a line.
+var ErrNoMatch = errors.New("no parser matches the log line")
+
+var (
+	registryMu sync.RWMutex
+	registry   []Parser // in registration order; earlier parsers win ties
+)
+
+// The built-in formats, most specific first: UFW and OpenWrt logs are also
+// valid generic netfilter logs.
+func init() {
+	Register(attParser{})
+	Register(netfilterParser{name: "ufw", matchPrefix: isUFWPrefix})
+	Register(netfilterParser{name: "openwrt", matchPrefix: openwrtPrefix.MatchString})
+	Register(pfsenseParser{})
+	Register(netfilterParser{name: "netfilter"})
+}
+
+// Register makes a parser available by name. More specific formats should be
+// registered before general ones, since detection prefers earlier parsers.
+func Register(p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, existing := range registry {
+		if existing.Name() == p.Name() {
+			panic(fmt.Sprintf("parser: Register called twice for %q", p.Name()))
+		}
+	}
+	registry = append(registry, p)
+}
+
+// Lookup returns the registered parser with the given name.
+func Lookup(name string) (Parser, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, p := range registry {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names of all registered parsers, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for _, p := range registry {
+		names = append(names, p.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered returns a snapshot of the registry.
+func registered() []Parser {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Parser(nil), registry...)
+}
+
+// ForFormat returns the parser for a configured format name. An empty name or
+// AutoFormat selects automatic detection.
+func ForFormat(name string) (Parser, error) {
+	if name == "" || name == AutoFormat {
+		return NewAuto(defaultSampleSize), nil
+	}
+	if p, ok := Lookup(name); ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (available: %v)", name, Names())
+}
+
+// Detect returns the registered parser that matches the most lines of sample.
+func Detect(sample []string) (Parser, error) {
+	parsers := registered()
+	counts := make([]int, len(parsers))
+	for _, line := range sample {
+		if i := firstMatch(parsers, line); i >= 0 {
+			counts[i]++
+		}
+	}
+	best := bestIndex(counts)
+	if best < 0 {
+		return nil, ErrNoMatch
+	}
+	return parsers[best], nil
+}
+
+// firstMatch returns the index of the first parser that matches line, or -1.
+func firstMatch(parsers []Parser, line string) int {
+	for i, p := range parsers {
+		if p.Match(line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// bestIndex returns the index of the highest non-zero count, preferring earlier entries.
+func bestIndex(counts []int) int {
+	best := -1
+	for i, n := range counts {
+		if n > 0 && (best < 0 || n > counts[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// defaultSampleSize is how many lines Auto inspects before settling on a format.
+const defaultSampleSize = 100
+
+// Auto is a Parser that detects the format from the lines it is given.
+//
+// For the first sampleSize lines it tries every registered parser on each line and
+// counts which format matched. It then prefers the format that matched most of the
+// sample, while still trying the others for lines that format does not match, so
+// inputs that mix several devices keep working. It is safe for concurrent use.
+type Auto struct {
+	sampleSize int
+
+	mu        sync.Mutex
+	parsers   []Parser
+	counts    []int
+	seen      int
+	preferred Parser
+}
+
+// NewAuto creates an Auto parser that samples sampleSize lines before settling.
+func NewAuto(sampleSize int) *Auto {
+	parsers := registered()
+	return &Auto{
+		sampleSize: sampleSize,
+		parsers:    parsers,
+		counts:     make([]int, len(parsers)),
+	}
+}
+
+// Name implements Parser.
+func (a *Auto) Name() string { return AutoFormat }
+
+// Match implements Parser. It does not count towards the sample.
+func (a *Auto) Match(line string) bool {
+	if preferred := a.Detected(); preferred != nil && preferred.Match(line) {
+		return true
+	}
+	return a.choose(line, false) != nil
+}
+
+// Parse implements Parser. Once a format is detected, lines are parsed with it
+// straight away, so that they are only scanned once, and the other formats are
+// only tried for lines it fails to parse.
+func (a *Auto) Parse(line string) (LogEvent, error) {
+	preferred := a.Detected()
+	var err error
+	if preferred != nil {
+		var ev LogEvent
+		if ev, err = preferred.Parse(line); err == nil {
+			return ev, nil
+		}
+	}
+
+	p := a.choose(line, true)
+	switch {
+	case p == nil:
+		return LogEvent{}, ErrNoMatch
+	case preferred != nil && p.Name() == preferred.Name():
+		return LogEvent{}, err
+	}
+	return p.Parse(line)
+}
+
+// Detected returns the format settled on after sampling, or nil while still sampling.
+func (a *Auto) Detected() Parser {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.preferred
+}
+
+// choose picks the first parser that matches a line, updating the sample counts
+// if sample is set.
+func (a *Auto) choose(line string, sample bool) Parser {
+	i := firstMatch(a.parsers, line)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if sample && a.preferred == nil {
+		if i >= 0 {
+			a.counts[i]++
+		}
+		a.seen++
+		if a.seen >= a.sampleSize {
+			if best := bestIndex(a.counts); best >= 0 {
+				a.preferred = a.parsers[best]
+			}
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+	return a.parsers[i]
+}
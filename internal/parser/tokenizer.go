@@ -0,0 +1,274 @@
+package parser
+
+// routerFields holds the raw values of the router format's fields. Every value
+// is a substring of the scanned line, so scanning does not allocate.
+type routerFields struct {
+	timestamp string
+	src, dst  string
+	spt, dpt  string
+	proto     string
+	action    string
+	reason    string
+	length    string
+	ttl       string // TTL, or the IPv6 hop limit
+
+	// Optional header fields.
+	in, out, mac  string
+	tos, prec, id string
+	window, res   string
+	urgp          string
+	icmpType      string
+	icmpCode      string
+	flowLabel     string
+	ipFlags       flagSet
+	tcpFlags      flagSet
+	quotedPacket  bool // past the "[...]" header an ICMP error quotes
+}
+
+// complete reports whether every field was found, which is what makes a line valid.
+func (f *routerFields) complete() bool {
+	return f.timestamp != "" && f.src != "" && f.dst != "" && f.spt != "" && f.dpt != "" &&
+		f.proto != "" && f.action != "" && f.reason != "" && f.length != "" && f.ttl != ""
+}
+
+// event converts the raw values into a LogEvent. Missing fields are left at
+// their zero value, or "unknown" for text fields.
+func (f *routerFields) event() LogEvent {
+	ev := LogEvent{
+		Format:          attFormat,
+		Timestamp:       parseTimestamp(f.timestamp),
+		SourceIP:        parseAddr(f.src),
+		DestinationIP:   parseAddr(f.dst),
+		SourcePort:      parsePortNumber(f.spt),
+		DestinationPort: parsePortNumber(f.dpt),
+		Protocol:        nonEmpty(f.proto, "unknown"),
+		Action:          nonEmpty(f.action, "unknown"),
+		Reason:          nonEmpty(f.reason, "unknown"),
+		PacketLength:    atoiSafe(f.length),
+		TTL:             atoiSafe(f.ttl),
+	}
+	ev.Header = Header{
+		Interface:    f.in,
+		OutInterface: f.out,
+		MAC:          f.mac,
+		TOS:          f.tos,
+		Precedence:   f.prec,
+		ID:           ipv4ID(ev.SourceIP, f.id),
+		IPFlags:      f.ipFlags.format(ipFlagNames),
+		TCPFlags:     f.tcpFlags.format(tcpFlagNames),
+		Window:       parseUint16Ptr(f.window),
+		Reserved:     f.res,
+		Urgent:       parseUint16Ptr(f.urgp),
+		ICMPType:     parseUint8Ptr(f.icmpType),
+		ICMPCode:     parseUint8Ptr(f.icmpCode),
+		FlowLabel:    parseFlowLabel(f.flowLabel),
+	}
+	return ev
+}
+
+// scanRouterFields extracts the router format's fields from line in a single
+// pass over its space separated tokens. The first valid occurrence of each key
+// wins. Values are trimmed to the characters the field allows, so
+// "SPT=80," yields "80".
+func scanRouterFields(line string) routerFields {
+	var f routerFields
+	for start := 0; start < len(line); {
+		end := start
+		for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+			end++
+		}
+		if end > start {
+			f.token(line[start:end])
+		}
+		start = end + 1
+	}
+	return f
+}
+
+// token records the value of one token if it is a field that is still unset.
+func (f *routerFields) token(tok string) {
+	if f.timestamp == "" {
+		f.timestamp = findTimestamp(tok)
+	}
+
+	eq := 0
+	for eq < len(tok) && tok[eq] != '=' {
+		eq++
+	}
+	if eq == len(tok) {
+		// A bare flag such as DF or SYN.
+		if !f.quotedPacket {
+			f.ipFlags |= flagBit(tok, ipFlagNames)
+			f.tcpFlags |= flagBit(tok, tcpFlagNames)
+		}
+		return
+	}
+	if eq == 0 {
+		return
+	}
+	if tok[0] == '[' {
+		f.quotedPacket = true
+	}
+	value := tok[eq+1:]
+
+	switch tok[:eq] {
+	case "SRC":
+		setOnce(&f.src, addrPrefix(value))
+	case "DST":
+		setOnce(&f.dst, addrPrefix(value))
+	case "SPT":
+		setOnce(&f.spt, prefixWhile(value, isDigit))
+	case "DPT":
+		setOnce(&f.dpt, prefixWhile(value, isDigit))
+	case "PROTO":
+		setOnce(&f.proto, prefixWhile(value, isWordByte))
+	case "action":
+		setOnce(&f.action, prefixWhile(value, isWordByte))
+	case "reason":
+		setOnce(&f.reason, prefixWhile(value, isReasonByte))
+	case "LEN":
+		setOnce(&f.length, prefixWhile(value, isDigit))
+	case "TTL", "HOPLIMIT":
+		setOnce(&f.ttl, prefixWhile(value, isDigit))
+	case "IN":
+		setOnce(&f.in, value)
+	case "OUT":
+		setOnce(&f.out, value)
+	case "MAC":
+		setOnce(&f.mac, value)
+	case "TOS", "TC":
+		setOnce(&f.tos, value)
+	case "PREC":
+		setOnce(&f.prec, value)
+	case "ID":
+		setOnce(&f.id, prefixWhile(value, isDigit))
+	case "WINDOW":
+		setOnce(&f.window, prefixWhile(value, isDigit))
+	case "RES":
+		setOnce(&f.res, value)
+	case "URGP":
+		setOnce(&f.urgp, prefixWhile(value, isDigit))
+	case "TYPE":
+		setOnce(&f.icmpType, prefixWhile(value, isDigit))
+	case "CODE":
+		setOnce(&f.icmpCode, prefixWhile(value, isDigit))
+	case "FLOWLBL":
+		setOnce(&f.flowLabel, prefixWhile(value, isDigit))
+	}
+}
+
+// setOnce stores value in field unless the field is already set or value is empty.
+func setOnce(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// addrPrefix returns the IPv4 or IPv6 address at the start of s, or "" if s
+// does not start with a valid one. The address may be followed by punctuation,
+// as in "SRC=192.0.2.1,", but not by more of a word, so "192.0.2.1234" and
+// "cafe:babe" followed by letters are rejected rather than truncated.
+func addrPrefix(s string) string {
+	addr := prefixWhile(s, isAddrByte)
+	if addr == "" || len(addr) < len(s) && (isWordByte(s[len(addr)]) || s[len(addr)] == '%') {
+		return ""
+	}
+	if _, err := ParseAddr(addr); err != nil {
+		return ""
+	}
+	return addr
+}
+
+// timestampAt returns the length of the ISO-8601 timestamp at the start of s,
+// such as "2025-01-05T00:01:08.143626-05:00", or 0 if s does not start with one.
+// The timestamp must end at a word boundary.
+func timestampAt(s string) int {
+	const layout = "dddd-dd-ddTdd:dd:dd"
+	if len(s) < len(layout) {
+		return 0
+	}
+	for i := 0; i < len(layout); i++ {
+		if layout[i] == 'd' {
+			if !isDigit(s[i]) {
+				return 0
+			}
+		} else if s[i] != layout[i] {
+			return 0
+		}
+	}
+
+	// Candidate ends, longest first: with the optional fraction and zone, then without.
+	ends := [3]int{}
+	n := 0
+	end := len(layout)
+	if end+1 < len(s) && s[end] == '.' && isDigit(s[end+1]) {
+		end += 2
+		for end < len(s) && isDigit(s[end]) {
+			end++
+		}
+	}
+	if zone := zoneAt(s[end:]); zone > 0 {
+		ends[n] = end + zone
+		n++
+	}
+	ends[n] = end
+	n++
+	if end != len(layout) {
+		ends[n] = len(layout)
+		n++
+	}
+
+	for _, e := range ends[:n] {
+		if e == len(s) || !isWordByte(s[e]) {
+			return e
+		}
+	}
+	return 0
+}
+
+// zoneAt returns the length of the UTC offset ("Z" or "+hh:mm") at the start of s, or 0.
+func zoneAt(s string) int {
+	if len(s) > 0 && s[0] == 'Z' {
+		return 1
+	}
+	if len(s) >= 6 && (s[0] == '+' || s[0] == '-') &&
+		isDigit(s[1]) && isDigit(s[2]) && s[3] == ':' && isDigit(s[4]) && isDigit(s[5]) {
+		return 6
+	}
+	return 0
+}
+
+// findTimestamp returns the first ISO-8601 timestamp in line that starts at a
+// word boundary, or "" if there is none.
+func findTimestamp(line string) string {
+	for i := 0; i < len(line); i++ {
+		if !isDigit(line[i]) || (i > 0 && isWordByte(line[i-1])) {
+			continue
+		}
+		if n := timestampAt(line[i:]); n > 0 {
+			return line[i : i+n]
+		}
+	}
+	return ""
+}
+
+// prefixWhile returns the longest prefix of s whose bytes all satisfy ok.
+func prefixWhile(s string, ok func(byte) bool) string {
+	i := 0
+	for i < len(s) && ok(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isWordByte(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isReasonByte(c byte) bool { return isWordByte(c) || c == '-' }
+
+func isAddrByte(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' || c == ':' || c == '.'
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"regexp"
+	"testing"
+)
+
+// The regex implementation the tokenizer replaced, kept as a reference for
+// the equivalence test and the benchmarks.
+var (
+	timestampRegex = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?([+\-]\d{2}:\d{2}|Z)?\b`)
+	ipRegex        = regexp.MustCompile(`SRC=(([0-9]{1,3}\.){3}[0-9]{1,3}|([a-fA-F0-9:]+))`)
+	dstRegex       = regexp.MustCompile(`DST=(([0-9]{1,3}\.){3}[0-9]{1,3}|([a-fA-F0-9:]+))`)
+	sptRegex       = regexp.MustCompile(`SPT=(\d+)`)
+	dptRegex       = regexp.MustCompile(`DPT=(\d+)`)
+	protoRegex     = regexp.MustCompile(`PROTO=(\w+)`)
+	actionRegex    = regexp.MustCompile(`action=(\w+)`)
+	reasonRegex    = regexp.MustCompile(`reason=([\w\-]+)`)
+	lengthRegex    = regexp.MustCompile(`LEN=(\d+)`)
+	ttlRegex       = regexp.MustCompile(`TTL=(\d+)`)
+)
+
+func regexIsValidLine(line string) bool {
+	return timestampRegex.MatchString(line) &&
+		ipRegex.MatchString(line) &&
+		dstRegex.MatchString(line) &&
+		sptRegex.MatchString(line) &&
+		dptRegex.MatchString(line) &&
+		protoRegex.MatchString(line) &&
+		actionRegex.MatchString(line) &&
+		reasonRegex.MatchString(line) &&
+		lengthRegex.MatchString(line) &&
+		ttlRegex.MatchString(line)
+}
+
+func regexExtractFields(line string) LogEvent {
+	group := func(re *regexp.Regexp) string {
+		if match := re.FindStringSubmatch(line); len(match) > 1 {
+			return match[1]
+		}
+		return ""
+	}
+	return LogEvent{
+		Format:          attFormat,
+		Timestamp:       parseTimestamp(timestampRegex.FindString(line)),
+		SourceIP:        parseAddr(group(ipRegex)),
+		DestinationIP:   parseAddr(group(dstRegex)),
+		SourcePort:      parsePortNumber(group(sptRegex)),
+		DestinationPort: parsePortNumber(group(dptRegex)),
+		Protocol:        nonEmpty(group(protoRegex), "unknown"),
+		Action:          nonEmpty(group(actionRegex), "unknown"),
+		Reason:          nonEmpty(group(reasonRegex), "unknown"),
+		PacketLength:    atoiSafe(group(lengthRegex)),
+		TTL:             atoiSafe(group(ttlRegex)),
+	}
+}
+
+// routerFixtures are the router lines from parser_test.go and formats_test.go.
+var routerFixtures = []string{
+	attLine,
+	"2025-01-05T00:01:08.143626-05:00 SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=PORTSCAN LEN=500 TTL=64",
+	"action=DROP reason=PORTSCAN SRC=192.0.2.1 DST=192.0.2.2",
+	"action=ALLOW reason=WHITELIST SRC=192.0.2.5 DST=192.0.2.6",
+	"SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80 action=DROP reason=TEST LEN=512 TTL=128",
+	"PROTO=UDP SPT=12345 DPT=443",
+	"Invalid log entry",
+	"DST=192.0.2.2 PROTO=TCP SPT=12345 DPT=80",
+	"SRC=192.0.2.1 PROTO=UDP DPT=443",
+	"SRC=192.0.2.1 DST=192.0.2.2 SPT=54321",
+	"SRC=2001:0db8::1 DST=2001:0db8::2 PROTO=TCP action=DROP reason=PORTSCAN LEN=400 TTL=64",
+	"SRC=INVALID_IP DST=2001:0db8::1 PROTO=UDP",
+}
+
+func TestTokenizer_MatchesRegex(t *testing.T) {
+	lines := append([]string{
+		"2025-01-05T00:01:08Z SRC=192.0.2.1, DST=192.0.2.2, PROTO=TCP, SPT=1, DPT=2, action=DROP, reason=A-B, LEN=3, TTL=4",
+		"2025-01-05T00:01:08.5abc SRC=192.0.2.1 DST=192.0.2.2 PROTO=TCP SPT=1 DPT=2 action=DROP reason=X LEN=3 TTL=4",
+		"<4>2025-01-05T00:01:08+01:00 SRC=192.0.2.1 DST=192.0.2.2 PROTO=UDP SPT=x SPT=7 DPT=70000 action=DROP reason=X LEN=3 TTL=4",
+		"20250-01-05T00:01:08 SRC=192.0.2.1 DST=192.0.2.2",
+		"",
+	}, routerFixtures...)
+
+	for _, line := range lines {
+		if got, want := IsValidLine(line), regexIsValidLine(line); got != want {
+			t.Errorf("IsValidLine(%q) = %v, regex implementation says %v", line, got, want)
+		}
+		checkEvent(t, line, ExtractFields(line), regexExtractFields(line))
+	}
+}
+
+// TestTokenizer_StrictAddresses covers the lines the regex implementation
+// accepted with a truncated or made-up address, which the tokenizer rejects.
+func TestTokenizer_StrictAddresses(t *testing.T) {
+	lines := []string{
+		"<4>2025-01-05T00:01:08+01:00 SRC=192.0.2 DST=abc PROTO=UDP SPT=7 DPT=70 action=DROP reason=X LEN=3 TTL=4",
+		"2025-01-05T00:01:08Z SRC=192.0.2.1234 DST=192.0.2.2 PROTO=ICMP SPT=0 DPT=0 action=DROP reason=X LEN=3 TTL=4",
+		"2025-01-05T00:01:08Z SRC=2001:db8::1::2 DST=192.0.2.2 PROTO=TCP SPT=1 DPT=2 action=DROP reason=X LEN=3 TTL=4",
+		"2025-01-05T00:01:08Z SRC=deadbeef DST=192.0.2.2 PROTO=TCP SPT=1 DPT=2 action=DROP reason=X LEN=3 TTL=4",
+		"2025-01-05T00:01:08Z SRC=fe80::1%eth0 DST=192.0.2.2 PROTO=TCP SPT=1 DPT=2 action=DROP reason=X LEN=3 TTL=4",
+	}
+	for _, line := range lines {
+		if !regexIsValidLine(line) {
+			t.Errorf("Expected the regex implementation to accept %q", line)
+		}
+		if IsValidLine(line) {
+			t.Errorf("Expected %q to be invalid", line)
+		}
+		if ev := ExtractFields(line); ev.SourceIP.IsValid() {
+			t.Errorf("%q: expected no source address, got %v", line, ev.SourceIP)
+		}
+	}
+}
+
+func TestAddrPrefix(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{"192.0.2.1,", "192.0.2.1"},
+		{"2001:DB8::1", "2001:DB8::1"},
+		{"2001:db8::1)", "2001:db8::1"},
+		{"::ffff:192.0.2.1", "::ffff:192.0.2.1"},
+		{"::", "::"},
+		{"192.0.2.1234", ""},
+		{"192.0.2.", ""},
+		{"2001:db8:::1", ""},
+		{"2001:db8::1g", ""},
+		{"fe80::1%eth0", ""},
+		{"abc", ""},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		if got := addrPrefix(tc.value); got != tc.expected {
+			t.Errorf("addrPrefix(%q): expected %q, got %q", tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestScanRouterFields_NoAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		f := scanRouterFields(attLine)
+		if !f.complete() {
+			t.Fatal("Expected the fixture to be complete")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+// BenchmarkParse compares validating and extracting every fixture with the
+// regex implementation (twenty scans per valid line) and the tokenizer.
+func BenchmarkParse(b *testing.B) {
+	b.Run("Regex", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				if regexIsValidLine(line) {
+					regexExtractFields(line)
+				}
+			}
+		}
+	})
+	b.Run("Tokenizer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				attParser{}.Parse(line)
+			}
+		}
+	})
+}
+
+func BenchmarkIsValidLine(b *testing.B) {
+	b.Run("Regex", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				regexIsValidLine(line)
+			}
+		}
+	})
+	b.Run("Tokenizer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				IsValidLine(line)
+			}
+		}
+	})
+}
+
+func BenchmarkExtractFields(b *testing.B) {
+	b.Run("Regex", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				regexExtractFields(line)
+			}
+		}
+	})
+	b.Run("Tokenizer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range routerFixtures {
+				ExtractFields(line)
+			}
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package pipeline
+
+import (
+	"fmt"
+	"minerva/internal/blocklist"
+	"minerva/internal/geo"
+	"minerva/internal/netclass"
+	"minerva/internal/parser"
+	"minerva/internal/progress"
+	"minerva/internal/rules"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"minerva/internal/threatintel"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	workerCount = 20
+	queueSize   = 10000
+
+	// Flagged events are inserted in batches of up to batchSize, or whatever
+	// has arrived within batchWindow of the first event in a batch.
+	batchSize   = 1000
+	batchWindow = time.Second
+
+	// How often scans whose source has gone quiet are stored.
+	scanExpiryInterval = 10 * time.Second
+)
+
+// Pipeline moves log lines through filtering, database insertion, scan
+// detection, geo lookups and threat intelligence lookups.
+//
+// Lines are handed to Feed from any number of goroutines. Flush waits for the
+// lines fed so far to be stored, which may take up to one batch window. Close
+// stops intake and blocks until every accepted line has been inserted, every
+// queued IP looked up and every open scan stored.
+type Pipeline struct {
+	store  store.Store
+	writer *store.BatchWriter
+	parser parser.Parser
+	rules  *rules.Engine
+	sensor string
+	class  *netclass.Classifier
+	geo    geo.Provider
+	geoTTL time.Duration
+	// Each threat intelligence provider has its own queue and goroutine, so
+	// that one with a small quota does not hold up the others.
+	intel    threatintel.Providers
+	intelTTL time.Duration
+	// Blocklists that flagged public source addresses are tagged with.
+	blocklists *blocklist.Matcher
+	// Finds scans in flagged events, whose incidents are stored as they
+	// complete.
+	scans *scan.Detector
+	stats *progress.Stats
+	prog  *progress.Progress
+
+	// Channels to move data through pipeline.
+	lineChan   chan string
+	logChan    chan parser.LogEvent
+	geoChan    chan string
+	intelChans []chan string // One per provider in intel
+	stopScans  chan struct{} // Closed once every event has been observed
+	doneChan   chan struct{}
+
+	// Sensors already registered in the database. Only used by filter.
+	sensors map[string]bool
+
+	// We’ll keep track of IPs we’ve already queued for geo so we don’t re-queue them.
+	seenIPs sync.Map
+	// Likewise for threat intelligence lookups.
+	intelIPs sync.Map
+
+	// pending counts lines that have been fed but not yet filtered out or inserted.
+	pending sync.WaitGroup
+	// failed counts flagged events that could not be stored since the last
+	// Flush.
+	failed atomic.Int64
+
+	closeOnce sync.Once
+}
+
+// Options configures a Pipeline.
+type Options struct {
+	Store  store.Store
+	Parser parser.Parser // Decodes lines
+	Rules  *rules.Engine // Flags events
+	// Events are stored under Sensor, or under the hostname from their syslog
+	// header when it is empty.
+	Sensor string
+	// Classifies source addresses. Public ones are looked up with Geo, which
+	// keeps to its own rate limit; addresses it permanently fails to look up
+	// are retried after GeoTTL.
+	Classifier *netclass.Classifier
+	Geo        geo.Provider
+	GeoTTL     time.Duration
+	// Public addresses are also checked with each of the Intel providers,
+	// whose reports are refreshed after IntelTTL. Their quotas are small, so
+	// addresses that arrive while a provider's queue is full are skipped
+	// rather than holding up insertion.
+	Intel    threatintel.Providers
+	IntelTTL time.Duration
+	// Flagged events from public addresses are tagged with the lists they are
+	// on.
+	Blocklists *blocklist.Matcher
+	// Observes every flagged event, and every dropped or rejected one.
+	Scans    *scan.Detector
+	Stats    *progress.Stats
+	Progress *progress.Progress
+}
+
+// New creates a Pipeline configured by opts and starts its goroutines.
+func New(opts Options) *Pipeline {
+	p := &Pipeline{
+		store:      opts.Store,
+		parser:     opts.Parser,
+		rules:      opts.Rules,
+		sensor:     opts.Sensor,
+		class:      opts.Classifier,
+		geo:        opts.Geo,
+		geoTTL:     opts.GeoTTL,
+		intel:      opts.Intel,
+		intelTTL:   opts.IntelTTL,
+		blocklists: opts.Blocklists,
+		scans:      opts.Scans,
+		sensors:    make(map[string]bool),
+		stats:      opts.Stats,
+		prog:       opts.Progress,
+		lineChan:   make(chan string, queueSize),
+		logChan:    make(chan parser.LogEvent, queueSize),
+		geoChan:    make(chan string, queueSize),
+		stopScans:  make(chan struct{}),
+		doneChan:   make(chan struct{}),
+	}
+	p.writer = store.NewBatchWriter(opts.Store, batchSize, batchWindow, p.written)
+
+	go p.filter()
+
+	var wg sync.WaitGroup
+	wg.Add(workerCount)
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			defer wg.Done()
+			p.insert()
+		}()
+	}
+
+	var geoWG sync.WaitGroup
+	geoWG.Add(2 + len(opts.Intel))
+	go func() {
+		defer geoWG.Done()
+		p.lookup()
+	}()
+	go func() {
+		defer geoWG.Done()
+		p.expireScans()
+	}()
+	for _, provider := range opts.Intel {
+		ch := make(chan string, queueSize)
+		p.intelChans = append(p.intelChans, ch)
+		go func(provider threatintel.Provider) {
+			defer geoWG.Done()
+			p.lookupIntel(provider, ch)
+		}(provider)
+	}
+
+	// Write the last batch, store open scans and close the lookup queues when
+	// DB workers finish
+	go func() {
+		wg.Wait()
+		p.writer.Close()
+		close(p.stopScans)
+		close(p.geoChan)
+		for _, ch := range p.intelChans {
+			close(ch)
+		}
+	}()
+
+	// Signal doneChan when geo and intel lookups and scan detection finish
+	go func() {
+		geoWG.Wait()
+		close(p.doneChan)
+	}()
+
+	return p
+}
+
+// Feed queues a raw log line for processing. It must not be called after Close.
+func (p *Pipeline) Feed(line string) {
+	p.pending.Add(1)
+	p.lineChan <- line
+}
+
+// Flush blocks until every line fed so far has been discarded or stored in the
+// database. It returns an error if any flagged event fed since the previous
+// Flush could not be stored. It must not be called concurrently with Feed.
+func (p *Pipeline) Flush() error {
+	p.pending.Wait()
+	if n := p.failed.Swap(0); n > 0 {
+		return fmt.Errorf("%d flagged event(s) could not be stored", n)
+	}
+	return nil
+}
+
+// Close stops accepting lines and waits until all queued work has drained.
+func (p *Pipeline) Close() {
+	p.closeOnce.Do(func() { close(p.lineChan) })
+	<-p.doneChan
+}
+
+// Done is closed once all queued work has drained after Close.
+func (p *Pipeline) Done() <-chan struct{} {
+	return p.doneChan
+}
+
+// filter pre-filters logs:
+//   - If line cannot be parsed → stats.IncrementMalformed()
+//   - Else if a rule matches → send to logChan
+//   - Else increment benign, and observe dropped packets for scans
+func (p *Pipeline) filter() {
+	for line := range p.lineChan {
+		p.stats.IncrementLinesRead()
+
+		ev, err := p.parser.Parse(line)
+		if err != nil {
+			p.stats.IncrementMalformed()
+			p.pending.Done()
+			continue
+		}
+		ev.SourceClass = string(p.class.Classify(ev.SourceIP))
+		if p.rules.Apply(&ev) {
+			p.stats.IncrementFlagged()
+			if err := p.assignSensor(&ev); err != nil {
+				p.stats.IncrementErrors()
+				p.failed.Add(1)
+				p.prog.BufferMessage(fmt.Sprintf("DB error registering sensor: %v", err))
+				p.pending.Done()
+				continue
+			}
+			p.logChan <- ev
+		} else {
+			p.stats.IncrementBenign()
+			p.observeDrop(ev)
+			p.pending.Done()
+		}
+	}
+	close(p.logChan)
+}
+
+// observeDrop observes an event that no rule flagged for scans if its packet
+// was dropped or rejected, since routers do not reliably flag scans.
+func (p *Pipeline) observeDrop(ev parser.LogEvent) {
+	if ev.Action != "DROP" && ev.Action != "REJECT" {
+		return
+	}
+	// Incidents reference their sensor.
+	if err := p.assignSensor(&ev); err != nil {
+		p.stats.IncrementErrors()
+		p.prog.BufferMessage(fmt.Sprintf("DB error registering sensor: %v", err))
+		return
+	}
+	p.storeIncidents(p.scans.Observe(ev))
+}
+
+// assignSensor sets the sensor of a flagged event and registers sensors seen
+// for the first time. Registering from the single filter goroutine ensures a
+// sensor exists before any insert worker stores an event that references it.
+func (p *Pipeline) assignSensor(ev *parser.LogEvent) error {
+	ev.Sensor = p.sensor
+	if ev.Sensor == "" {
+		ev.Sensor = ev.Syslog.Hostname
+	}
+	if ev.Sensor == "" {
+		ev.Sensor = store.DefaultSensor
+	}
+	if p.sensors[ev.Sensor] {
+		return nil
+	}
+	if err := p.store.InsertSensor(ev.Sensor); err != nil {
+		return err
+	}
+	p.sensors[ev.Sensor] = true
+	return nil
+}
+
+// insert queues flagged logs for the DB and dispatches new IP lookups.
+func (p *Pipeline) insert() {
+	for ev := range p.logChan {
+		if !p.insertEvent(ev) {
+			p.done(1)
+		}
+	}
+}
+
+// insertEvent queues one flagged event for insertion and observes it for scans.
+// If it is public, it also tags it with the blocklists its source IP is on and
+// queues the IP for geo and threat intelligence lookups. It reports whether the
+// event was queued.
+func (p *Pipeline) insertEvent(ev parser.LogEvent) bool {
+	if !ev.SourceIP.IsValid() || !ev.DestinationIP.IsValid() {
+		// Additional malformed check; log_data requires both addresses
+		p.prog.BufferMessage(fmt.Sprintf("Skipping malformed log event: %+v", ev))
+		p.stats.IncrementMalformed()
+		return false
+	}
+
+	// Check for IP lookups. Only public addresses have geolocation data.
+	if ev.SourceClass == string(netclass.Public) {
+		ev.Blocklists = p.blocklists.Match(ev.SourceIP)
+		if _, loaded := p.seenIPs.LoadOrStore(ev.SourceIP, struct{}{}); !loaded {
+			srcIP := ev.SourceIP.String()
+			exists, err := p.store.IsIPInGeoTable(srcIP)
+			if err != nil {
+				p.stats.IncrementErrors()
+				p.prog.BufferMessage(fmt.Sprintf("DB error checking IP: %v", err))
+			} else if !exists {
+				p.stats.IncrementGeoQueued()
+				p.geoChan <- srcIP
+			} // IP not seen yet, queue it
+		}
+		if _, loaded := p.intelIPs.LoadOrStore(ev.SourceIP, struct{}{}); !loaded && !p.queueIntel(ev.SourceIP.String()) {
+			p.intelIPs.Delete(ev.SourceIP) // Queue it again with its next event
+		}
+	}
+
+	p.storeIncidents(p.scans.Observe(ev))
+	p.writer.Add(ev)
+	return true
+}
+
+// expireScans stores scans whose source has gone quiet until stopScans is
+// closed, and then stores those still open.
+func (p *Pipeline) expireScans() {
+	ticker := time.NewTicker(scanExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.storeIncidents(p.scans.Expire())
+		case <-p.stopScans:
+			p.storeIncidents(p.scans.Flush())
+			return
+		}
+	}
+}
+
+// storeIncidents stores completed scans.
+func (p *Pipeline) storeIncidents(incidents []scan.Incident) {
+	for _, inc := range incidents {
+		if err := p.store.InsertIncident(inc); err != nil {
+			p.stats.IncrementErrors()
+			p.prog.BufferMessage(fmt.Sprintf("DB error storing %s scan from IP=%s: %v", inc.Type, inc.SourceIP, err))
+			continue
+		}
+		p.stats.IncrementIncidents()
+	}
+}
+
+// written records the outcome of a batch insert.
+func (p *Pipeline) written(result store.BatchResult) {
+	p.stats.AddInserted(result.Inserted)
+	p.stats.AddDuplicates(result.Duplicates)
+	if result.Failed > 0 {
+		p.stats.AddErrors(result.Failed)
+		p.failed.Add(result.Failed)
+		p.prog.BufferMessage(fmt.Sprintf("Insert error for %d of %d events: %v", result.Failed, len(result.Events), result.Err))
+	}
+	p.done(len(result.Events))
+}
+
+// done marks n flagged events as processed.
+func (p *Pipeline) done(n int) {
+	for i := 0; i < n; i++ {
+		p.pending.Done()
+		p.prog.IncrementProcessed()
+	}
+	p.prog.DisplayIfNeeded(2 * time.Second) // Show updates periodically
+}
+
+// lookup handles geo lookups. Rate-limited providers throttle them.
+func (p *Pipeline) lookup() {
+	for ip := range p.geoChan {
+		err := geo.ProcessIP(p.geo, p.store, ip, p.geoTTL)
+
+		// Decrement from the “in queue” count
+		p.stats.DecrementGeoQueued()
+
+		if err != nil {
+			p.stats.IncrementGeoErrors()
+			p.prog.BufferMessage(fmt.Sprintf("Geo lookup failed for IP=%s: %v", ip, err))
+			continue
+		}
+		p.stats.IncrementGeoCompleted()
+	}
+}
+
+// queueIntel queues ip for a lookup with each threat intelligence provider,
+// skipping providers whose queue is full. It reports whether every provider
+// took it; providers whose report is still fresh do not look it up again.
+func (p *Pipeline) queueIntel(ip string) bool {
+	queued := true
+	for i, ch := range p.intelChans {
+		p.stats.IncrementIntelQueued()
+		select {
+		case ch <- ip:
+		default:
+			p.stats.DecrementIntelQueued()
+			p.stats.IncrementIntelSkipped()
+			p.prog.BufferMessage(fmt.Sprintf("Intel queue of %s full, skipping IP=%s", p.intel[i].Name(), ip))
+			queued = false
+		}
+	}
+	return queued
+}
+
+// lookupIntel handles the threat intelligence lookups of one provider, which
+// throttles them to its quota.
+func (p *Pipeline) lookupIntel(provider threatintel.Provider, ips <-chan string) {
+	for ip := range ips {
+		err := threatintel.ProcessIP(provider, p.store, ip, p.intelTTL)
+		p.stats.DecrementIntelQueued()
+
+		if err != nil {
+			p.stats.IncrementIntelErrors()
+			p.prog.BufferMessage(fmt.Sprintf("%s lookup failed for IP=%s: %v", provider.Name(), ip, err))
+			continue
+		}
+		p.stats.IncrementIntelCompleted()
+	}
+}
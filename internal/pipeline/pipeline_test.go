@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"minerva/internal/blocklist"
+	"minerva/internal/config"
+	"minerva/internal/netclass"
+	"minerva/internal/parser"
+	"minerva/internal/progress"
+	"minerva/internal/rules"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"sync"
+	"testing"
+)
+
+// badPort is the destination port of events that fakeStore fails to insert.
+const badPort = 666
+
+// fakeStore keeps inserted events in memory. Methods the pipeline does not
+// call are left to the nil Store and panic.
+type fakeStore struct {
+	store.Store
+
+	mu        sync.Mutex
+	events    []parser.LogEvent
+	incidents []scan.Incident
+}
+
+func (s *fakeStore) InsertSensor(id string) error { return nil }
+
+func (s *fakeStore) InsertLogEntry(ev parser.LogEvent) (int64, error) {
+	return s.InsertLogEntries([]parser.LogEvent{ev})
+}
+
+func (s *fakeStore) InsertLogEntries(events []parser.LogEvent) (int64, error) {
+	for _, ev := range events {
+		if ev.DestinationPort == badPort {
+			return 0, errors.New("invalid event")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return int64(len(events)), nil
+}
+
+func (s *fakeStore) InsertIncident(inc scan.Incident) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.incidents = append(s.incidents, inc)
+	return nil
+}
+
+func (s *fakeStore) stored() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// newTestPipeline returns a Pipeline that flags netfilter packets dropped on
+// ports below 1024, stores them in a fakeStore, and detects scans with conf.
+func newTestPipeline(t *testing.T, conf config.ScanConfig) (*Pipeline, *fakeStore, *progress.Stats) {
+	t.Helper()
+	lp, _ := parser.Lookup("netfilter")
+	engine, err := rules.New([]config.RuleConfig{{Name: "low-ports", When: `action == "DROP" and dst_port < 1024`}})
+	if err != nil {
+		t.Fatalf("Failed to compile the rules: %v", err)
+	}
+	class, err := netclass.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create a classifier: %v", err)
+	}
+	s := &fakeStore{}
+	stats := &progress.Stats{}
+	p := New(Options{
+		Store:      s,
+		Parser:     lp,
+		Rules:      engine,
+		Classifier: class,
+		Blocklists: blocklist.NewMatcher(),
+		Scans:      scan.NewDetector(conf),
+		Stats:      stats,
+		Progress:   progress.NewProgress(0, stats),
+	})
+	return p, s, stats
+}
+
+// line returns a netfilter line for a packet from a documentation address,
+// which is never looked up, to port.
+func line(prefix string, i, port int) string {
+	return fmt.Sprintf("2025-01-05T00:01:08Z gw kernel: %s: IN=eth0 OUT= SRC=192.0.2.%d DST=198.51.100.1 LEN=60 TTL=50 PROTO=TCP SPT=%d DPT=%d",
+		prefix, i%250+1, 10000+i, port)
+}
+
+func TestPipeline_Flush(t *testing.T) {
+	p, s, stats := newTestPipeline(t, config.ScanConfig{})
+	defer p.Close()
+
+	for i := 0; i < 10; i++ {
+		p.Feed(line("DROP-IN", i, 22))
+	}
+	p.Feed(line("ACCEPT-IN", 0, 22))
+	p.Feed("garbage")
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.stored() != 10 || stats.Flagged() != 10 || stats.Benign() != 1 || stats.Malformed() != 1 || stats.Inserted() != 10 {
+		t.Errorf("Expected 10 events stored, got %d (flagged %d, benign %d, malformed %d, inserted %d)",
+			s.stored(), stats.Flagged(), stats.Benign(), stats.Malformed(), stats.Inserted())
+	}
+
+	// Events that cannot be stored fail the next Flush only, and the others
+	// of their batch are still stored.
+	for i := 0; i < 5; i++ {
+		p.Feed(line("DROP-IN", i, 23))
+	}
+	p.Feed(line("DROP-IN", 0, badPort))
+	p.Feed(line("DROP-IN", 1, badPort))
+	if err := p.Flush(); err == nil || err.Error() != "2 flagged event(s) could not be stored" {
+		t.Errorf("Expected two events not to be stored, got %v", err)
+	}
+	if s.stored() != 15 || stats.Errors() != 2 {
+		t.Errorf("Expected 15 events stored and 2 errors, got %d and %d", s.stored(), stats.Errors())
+	}
+	p.Feed(line("DROP-IN", 0, 24))
+	if err := p.Flush(); err != nil {
+		t.Errorf("Expected failures to be reported once, got %v", err)
+	}
+}
+
+func TestPipeline_Close(t *testing.T) {
+	p, s, _ := newTestPipeline(t, config.ScanConfig{})
+
+	const n = 2500 // More than fit in one batch
+	for i := 0; i < n; i++ {
+		p.Feed(line("DROP-IN", i, 22))
+	}
+	p.Close()
+
+	select {
+	case <-p.Done():
+	default:
+		t.Error("Expected Done to be closed after Close")
+	}
+	if s.stored() != n {
+		t.Errorf("Expected %d events stored, got %d", n, s.stored())
+	}
+}
+
+func TestPipeline_Scans(t *testing.T) {
+	p, s, stats := newTestPipeline(t, config.ScanConfig{WindowSeconds: 60, VerticalPorts: 20})
+
+	// Scans are detected from dropped packets that no rule flags, but
+	// accepted packets are not counted.
+	for i := 0; i < 25; i++ {
+		p.Feed(line("DROP-IN", 0, 2000+i))
+		p.Feed(line("ACCEPT-IN", 1, 3000+i))
+	}
+	p.Close()
+
+	if s.stored() != 0 || stats.Benign() != 50 {
+		t.Errorf("Expected nothing to be flagged, got %d stored and %d benign", s.stored(), stats.Benign())
+	}
+	if len(s.incidents) != 1 || s.incidents[0].SourceIP.String() != "192.0.2.1" || len(s.incidents[0].Ports) != 25 ||
+		s.incidents[0].Sensor != store.DefaultSensor {
+		t.Errorf("Expected a vertical scan from 192.0.2.1, got %+v", s.incidents)
+	}
+}
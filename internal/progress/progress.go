@@ -15,13 +15,22 @@ type Stats struct {
 	benign    int64 // lines that were valid but not flagged
 	malformed int64 // lines that can’t be parsed or are incomplete
 
-	inserted int64 // how many were successfully inserted to DB
-	errors   int64 // how many errors occurred overall
+	inserted   int64 // how many were successfully inserted to DB
+	duplicates int64 // how many were already in the DB and skipped
+	errors     int64 // how many errors occurred overall
 
 	// Geo lookup details
 	geoQueued    int64 // how many IPs are queued for geo lookup (not processed yet)
 	geoCompleted int64 // how many IPs have had geo lookup completed
 	geoErrors    int64 // how many IPs failed geo lookup
+
+	// Threat intelligence details, counting one lookup per IP and provider
+	intelQueued    int64 // how many lookups are queued (not processed yet)
+	intelCompleted int64 // how many lookups have completed
+	intelErrors    int64 // how many lookups failed
+	intelSkipped   int64 // how many lookups were dropped because the queue was full
+
+	incidents int64 // how many scan incidents were detected and stored
 }
 
 // Atomic incrementers
@@ -36,23 +45,44 @@ func (s *Stats) IncrementMalformed() {
 func (s *Stats) IncrementInserted() { atomic.AddInt64(&s.inserted, 1) }
 func (s *Stats) IncrementErrors()   { atomic.AddInt64(&s.errors, 1) }
 
+// Batch adders, for counts reported once per batch insert
+func (s *Stats) AddInserted(n int64)   { atomic.AddInt64(&s.inserted, n) }
+func (s *Stats) AddDuplicates(n int64) { atomic.AddInt64(&s.duplicates, n) }
+func (s *Stats) AddErrors(n int64)     { atomic.AddInt64(&s.errors, n) }
+
 func (s *Stats) IncrementGeoQueued()    { atomic.AddInt64(&s.geoQueued, 1) }
 func (s *Stats) DecrementGeoQueued()    { atomic.AddInt64(&s.geoQueued, -1) }
 func (s *Stats) IncrementGeoCompleted() { atomic.AddInt64(&s.geoCompleted, 1) }
 func (s *Stats) IncrementGeoErrors()    { atomic.AddInt64(&s.geoErrors, 1) }
 
+func (s *Stats) IncrementIntelQueued()    { atomic.AddInt64(&s.intelQueued, 1) }
+func (s *Stats) DecrementIntelQueued()    { atomic.AddInt64(&s.intelQueued, -1) }
+func (s *Stats) IncrementIntelCompleted() { atomic.AddInt64(&s.intelCompleted, 1) }
+func (s *Stats) IncrementIntelErrors()    { atomic.AddInt64(&s.intelErrors, 1) }
+func (s *Stats) IncrementIntelSkipped()   { atomic.AddInt64(&s.intelSkipped, 1) }
+
+func (s *Stats) IncrementIncidents() { atomic.AddInt64(&s.incidents, 1) }
+
 // Atomic getters
-func (s *Stats) LinesRead() int64 { return atomic.LoadInt64(&s.linesRead) }
-func (s *Stats) Flagged() int64   { return atomic.LoadInt64(&s.flagged) }
-func (s *Stats) Benign() int64    { return atomic.LoadInt64(&s.benign) }
-func (s *Stats) Malformed() int64 { return atomic.LoadInt64(&s.malformed) }
-func (s *Stats) Inserted() int64  { return atomic.LoadInt64(&s.inserted) }
-func (s *Stats) Errors() int64    { return atomic.LoadInt64(&s.errors) }
+func (s *Stats) LinesRead() int64  { return atomic.LoadInt64(&s.linesRead) }
+func (s *Stats) Flagged() int64    { return atomic.LoadInt64(&s.flagged) }
+func (s *Stats) Benign() int64     { return atomic.LoadInt64(&s.benign) }
+func (s *Stats) Malformed() int64  { return atomic.LoadInt64(&s.malformed) }
+func (s *Stats) Inserted() int64   { return atomic.LoadInt64(&s.inserted) }
+func (s *Stats) Duplicates() int64 { return atomic.LoadInt64(&s.duplicates) }
+func (s *Stats) Errors() int64     { return atomic.LoadInt64(&s.errors) }
 
 func (s *Stats) GeoQueued() int64    { return atomic.LoadInt64(&s.geoQueued) }
 func (s *Stats) GeoCompleted() int64 { return atomic.LoadInt64(&s.geoCompleted) }
 func (s *Stats) GeoErrors() int64    { return atomic.LoadInt64(&s.geoErrors) }
 
+func (s *Stats) IntelQueued() int64    { return atomic.LoadInt64(&s.intelQueued) }
+func (s *Stats) IntelCompleted() int64 { return atomic.LoadInt64(&s.intelCompleted) }
+func (s *Stats) IntelErrors() int64    { return atomic.LoadInt64(&s.intelErrors) }
+func (s *Stats) IntelSkipped() int64   { return atomic.LoadInt64(&s.intelSkipped) }
+
+func (s *Stats) Incidents() int64 { return atomic.LoadInt64(&s.incidents) }
+
 // Progress tracks how many lines have actually been “processed,” in addition to the Stats above.
 type Progress struct {
 	totalLines     int64
@@ -145,12 +175,16 @@ func (p *Progress) Display() {
 	fmt.Printf("  Lines:    read=%d    flagged=%d    benign=%d    malformed=%d\n",
 		p.stats.LinesRead(), p.stats.Flagged(), p.stats.Benign(), p.stats.Malformed(),
 	)
-	fmt.Printf("  Processed: %d (DB inserted=%d)   Errors=%d\n",
-		curProcessed, p.stats.Inserted(), p.stats.Errors(),
+	fmt.Printf("  Processed: %d (DB inserted=%d duplicates=%d)   Errors=%d\n",
+		curProcessed, p.stats.Inserted(), p.stats.Duplicates(), p.stats.Errors(),
 	)
 	fmt.Printf("  Geo:       queued=%d   completed=%d   errors=%d\n",
 		p.stats.GeoQueued(), curGeoCompleted, p.stats.GeoErrors(),
 	)
+	fmt.Printf("  Intel:     queued=%d   completed=%d   errors=%d   skipped=%d\n",
+		p.stats.IntelQueued(), p.stats.IntelCompleted(), p.stats.IntelErrors(), p.stats.IntelSkipped(),
+	)
+	fmt.Printf("  Scans:     incidents=%d\n", p.stats.Incidents())
 
 	fmt.Printf("  Rates:     lines/s=%.2f   geo/s=%.2f\n", linesRate, geoRate)
 	fmt.Println("---------------------------------------------------------------")
@@ -185,11 +219,18 @@ func (p *Progress) finalSummary() {
 	fmt.Printf("Benign:             %d\n", p.stats.Benign())
 	fmt.Printf("Malformed:          %d\n", p.stats.Malformed())
 	fmt.Printf("DB Inserted:        %d\n", p.stats.Inserted())
+	fmt.Printf("Duplicates Skipped: %d\n", p.stats.Duplicates())
 	fmt.Printf("Errors Encountered: %d\n", p.stats.Errors())
 
 	fmt.Printf("Geo Lookups Queued:     %d\n", p.stats.GeoQueued()) // should be zero if fully processed
 	fmt.Printf("Geo Lookups Completed:  %d\n", p.stats.GeoCompleted())
 	fmt.Printf("Geo Lookup Errors:      %d\n", p.stats.GeoErrors())
 
+	fmt.Printf("Intel Lookups Completed: %d\n", p.stats.IntelCompleted())
+	fmt.Printf("Intel Lookup Errors:     %d\n", p.stats.IntelErrors())
+	fmt.Printf("Intel Lookups Skipped:   %d\n", p.stats.IntelSkipped())
+
+	fmt.Printf("Scan Incidents:     %d\n", p.stats.Incidents())
+
 	fmt.Printf("=================================================\n\n")
 }
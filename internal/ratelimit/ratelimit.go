@@ -0,0 +1,44 @@
+// Package ratelimit spaces out requests to services with a quota, such as
+// geolocation and threat intelligence providers.
+package ratelimit
+
+import "time"
+
+// Limiter spaces out the requests to a rate-limited service. Every goroutine
+// that uses the service shares its Limiter, so that together they stay within
+// the limit. A nil Limiter does not limit.
+type Limiter struct {
+	ticker *time.Ticker
+}
+
+// New returns a Limiter that allows perMinute requests a minute, or nil if
+// perMinute is not positive.
+func New(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{ticker: time.NewTicker(time.Minute / time.Duration(perMinute))}
+}
+
+// NewPerDay returns a Limiter that allows perDay requests a day, for services
+// whose quota is daily, or nil if perDay is not positive.
+func NewPerDay(perDay int) *Limiter {
+	if perDay <= 0 {
+		return nil
+	}
+	return &Limiter{ticker: time.NewTicker(24 * time.Hour / time.Duration(perDay))}
+}
+
+// Wait blocks until the next request is allowed.
+func (l *Limiter) Wait() {
+	if l != nil {
+		<-l.ticker.C
+	}
+}
+
+// Stop releases the Limiter. Wait must not be called afterwards.
+func (l *Limiter) Stop() {
+	if l != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	if New(0) != nil || NewPerDay(-1) != nil {
+		t.Error("Expected no Limiter without a positive rate")
+	}
+	var unlimited *Limiter
+	unlimited.Wait() // Returns at once
+	unlimited.Stop()
+
+	l := New(6000) // One request every 10ms
+	defer l.Stop()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.Wait()
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("Expected three requests to take at least 30ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,425 @@
+package rules
+
+import (
+	"fmt"
+	"minerva/internal/parser"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// A rule condition is a boolean expression over the fields of a parsed event:
+//
+//	action == "DROP" and reason in ["PORTSCAN", "INTRUSION-DETECTED"]
+//	protocol == "TCP" and dst_port in [22, 3389, 6000..6100] and ttl < 64
+//	not src_ip in ["10.0.0.0/8", "192.168.0.0/16"] or length > 1400
+//
+// Comparisons are ==, !=, <, <=, > and >=, or "in" / "not in" a list. Text is
+// compared case-insensitively. Numbers support ranges ("lo..hi") in lists.
+// Addresses are compared against IPs or CIDR prefixes, so == "10.0.0.0/8" is
+// true for any address in that network. Conditions combine with and, or, not
+// and parentheses; and binds tighter than or. A header field that an event
+// does not have, such as window on a UDP packet, matches no ==, <, <=, > or >=
+// comparison and no "in" list, and so every != and "not in".
+
+// node is a compiled expression.
+type node interface {
+	eval(ev *parser.LogEvent) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(ev *parser.LogEvent) bool { return n.left.eval(ev) && n.right.eval(ev) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(ev *parser.LogEvent) bool { return n.left.eval(ev) || n.right.eval(ev) }
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(ev *parser.LogEvent) bool { return !n.operand.eval(ev) }
+
+// kind is the type of an event field.
+type kind int
+
+const (
+	kindText kind = iota
+	kindNumber
+	kindAddr
+)
+
+// field describes an event field that conditions can refer to. Exactly one of
+// the getters is set, matching kind.
+type field struct {
+	kind   kind
+	text   func(ev *parser.LogEvent) string
+	number func(ev *parser.LogEvent) (int, bool) // false if the event lacks the field
+	addr   func(ev *parser.LogEvent) netip.Addr
+}
+
+// fields are the event fields available to conditions, by name.
+var fields = map[string]field{
+	"format":     {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.Format }},
+	"action":     {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.Action }},
+	"reason":     {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.Reason }},
+	"protocol":   {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.Protocol }},
+	"interface":  {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.Interface }},
+	"ip_flags":   {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.IPFlags }},
+	"tcp_flags":  {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.TCPFlags }},
+	"src_class":  {kind: kindText, text: func(ev *parser.LogEvent) string { return ev.SourceClass }},
+	"src_ip":     {kind: kindAddr, addr: func(ev *parser.LogEvent) netip.Addr { return ev.SourceIP }},
+	"dst_ip":     {kind: kindAddr, addr: func(ev *parser.LogEvent) netip.Addr { return ev.DestinationIP }},
+	"src_port":   {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return int(ev.SourcePort), true }},
+	"dst_port":   {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return int(ev.DestinationPort), true }},
+	"ttl":        {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return ev.TTL, true }},
+	"length":     {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return ev.PacketLength, true }},
+	"window":     {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return optional(ev.Window) }},
+	"ip_id":      {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return optional(ev.ID) }},
+	"icmp_type":  {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return optional(ev.ICMPType) }},
+	"flow_label": {kind: kindNumber, number: func(ev *parser.LogEvent) (int, bool) { return optional(ev.FlowLabel) }},
+}
+
+// optional returns the value of an optional header field, and whether it is
+// present.
+func optional[T uint8 | uint16 | uint32](v *T) (int, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return int(*v), true
+}
+
+// textCompare compares a text field with a literal.
+type textCompare struct {
+	get    func(ev *parser.LogEvent) string
+	values []string // any of, for "in"
+	negate bool
+}
+
+func (n textCompare) eval(ev *parser.LogEvent) bool {
+	got := n.get(ev)
+	for _, v := range n.values {
+		if strings.EqualFold(got, v) {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+// numberRange is an inclusive range of numbers; a single number has lo == hi.
+type numberRange struct{ lo, hi int }
+
+// numberCompare compares a numeric field with a literal or a list of ranges.
+type numberCompare struct {
+	get    func(ev *parser.LogEvent) (int, bool)
+	op     string
+	ranges []numberRange
+}
+
+func (n numberCompare) eval(ev *parser.LogEvent) bool {
+	got, ok := n.get(ev)
+	if !ok {
+		return n.op == "!=" || n.op == "not in"
+	}
+	switch n.op {
+	case "<":
+		return got < n.ranges[0].lo
+	case "<=":
+		return got <= n.ranges[0].lo
+	case ">":
+		return got > n.ranges[0].lo
+	case ">=":
+		return got >= n.ranges[0].lo
+	}
+	in := false
+	for _, r := range n.ranges {
+		if got >= r.lo && got <= r.hi {
+			in = true
+			break
+		}
+	}
+	return in == (n.op == "==" || n.op == "in")
+}
+
+// addrCompare tests whether an address field falls in any of a list of prefixes.
+type addrCompare struct {
+	get      func(ev *parser.LogEvent) netip.Addr
+	prefixes []netip.Prefix
+	negate   bool
+}
+
+func (n addrCompare) eval(ev *parser.LogEvent) bool {
+	got := n.get(ev)
+	if !got.IsValid() {
+		return n.negate
+	}
+	got = got.Unmap()
+	for _, p := range n.prefixes {
+		if p.Contains(got) {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+// compile parses a condition into an expression tree.
+func compile(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+	return n, nil
+}
+
+// exprParser is a recursive descent parser over the tokens of a condition.
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *exprParser) peek() token { return p.toks[p.pos] }
+
+func (p *exprParser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword consumes the next token if it is the given keyword.
+func (p *exprParser) keyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokIdent && tok.text == word {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %s at offset %d, found %s", what, tok.pos, tok)
+	}
+	return tok, nil
+}
+
+func (p *exprParser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (node, error) {
+	if p.keyword("not") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return p.comparison()
+}
+
+// comparison parses "field op literal", "field in [list]" or "field not in [list]".
+func (p *exprParser) comparison() (node, error) {
+	name, err := p.expect(tokIdent, "a field name")
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fields[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at offset %d", name.text, name.pos)
+	}
+
+	var op string
+	var literals []token
+	switch {
+	case p.keyword("in"):
+		op = "in"
+		literals, err = p.list()
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			return nil, fmt.Errorf(`expected "in" after "not" at offset %d`, p.peek().pos)
+		}
+		op = "not in"
+		literals, err = p.list()
+	default:
+		tok := p.next()
+		if tok.kind != tokOp {
+			return nil, fmt.Errorf("expected a comparison after %s at offset %d, found %s", name.text, tok.pos, tok)
+		}
+		op = tok.text
+		var lit token
+		lit, err = p.literal()
+		literals = []token{lit}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return build(name.text, f, op, literals)
+}
+
+// list parses "[literal, ...]".
+func (p *exprParser) list() ([]token, error) {
+	if _, err := p.expect(tokLBracket, `"["`); err != nil {
+		return nil, err
+	}
+	var literals []token
+	for {
+		lit, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		literals = append(literals, lit)
+		if p.peek().kind == tokComma {
+			p.next()
+			continue
+		}
+		if _, err := p.expect(tokRBracket, `"," or "]"`); err != nil {
+			return nil, err
+		}
+		return literals, nil
+	}
+}
+
+func (p *exprParser) literal() (token, error) {
+	tok := p.next()
+	if tok.kind != tokString && tok.kind != tokNumber && tok.kind != tokRange {
+		return tok, fmt.Errorf("expected a value at offset %d, found %s", tok.pos, tok)
+	}
+	return tok, nil
+}
+
+// build type-checks a comparison and creates its node.
+func build(name string, f field, op string, literals []token) (node, error) {
+	negate := op == "!=" || op == "not in"
+	ordered := op == "<" || op == "<=" || op == ">" || op == ">="
+
+	switch f.kind {
+	case kindText:
+		if ordered {
+			return nil, fmt.Errorf("%s is text and does not support %s", name, op)
+		}
+		n := textCompare{get: f.text, negate: negate}
+		for _, lit := range literals {
+			if lit.kind != tokString {
+				return nil, fmt.Errorf("%s must be compared with quoted text, found %s", name, lit)
+			}
+			n.values = append(n.values, lit.text)
+		}
+		return n, nil
+
+	case kindNumber:
+		n := numberCompare{get: f.number, op: op}
+		for _, lit := range literals {
+			r, err := parseRange(lit)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if ordered && lit.kind == tokRange {
+				return nil, fmt.Errorf("%s: %s needs a single number, found %s", name, op, lit)
+			}
+			n.ranges = append(n.ranges, r)
+		}
+		return n, nil
+
+	case kindAddr:
+		if ordered {
+			return nil, fmt.Errorf("%s is an address and does not support %s", name, op)
+		}
+		n := addrCompare{get: f.addr, negate: negate}
+		for _, lit := range literals {
+			if lit.kind != tokString {
+				return nil, fmt.Errorf("%s must be compared with a quoted IP or CIDR, found %s", name, lit)
+			}
+			prefix, err := parsePrefix(lit.text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			n.prefixes = append(n.prefixes, prefix)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported field %s", name)
+}
+
+// parseRange converts a number or "lo..hi" token into a range.
+func parseRange(lit token) (numberRange, error) {
+	switch lit.kind {
+	case tokNumber:
+		v, err := strconv.Atoi(lit.text)
+		if err != nil {
+			return numberRange{}, fmt.Errorf("invalid number %q", lit.text)
+		}
+		return numberRange{v, v}, nil
+	case tokRange:
+		lo, hi, _ := strings.Cut(lit.text, "..")
+		l, err1 := strconv.Atoi(lo)
+		h, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || l > h {
+			return numberRange{}, fmt.Errorf("invalid range %q", lit.text)
+		}
+		return numberRange{l, h}, nil
+	}
+	return numberRange{}, fmt.Errorf("expected a number, found %s", lit)
+}
+
+// parsePrefix parses a CIDR prefix, or a single IP as a full-length prefix.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokRange // "lo..hi"
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+// token is one lexical element of a condition.
+type token struct {
+	kind tokenKind
+	text string // identifier, unquoted string, number, range or operator
+	pos  int    // byte offset in the condition
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of condition"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a condition into tokens, ending with a tokEOF token.
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, ',': tokComma}[c]
+			toks = append(toks, token{kind: kind, text: string(c), pos: i})
+			i++
+
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("unexpected %q at offset %d (use == or !=)", op, i)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %w", i, err)
+			}
+			toks = append(toks, token{kind: tokString, text: text, pos: i})
+			i = end + 1
+
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+				end++
+			}
+			kind := tokNumber
+			if strings.HasPrefix(src[end:], "..") {
+				kind = tokRange
+				end += 2
+				start := end
+				for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+					end++
+				}
+				if end == start {
+					return nil, fmt.Errorf("incomplete range at offset %d", i)
+				}
+			}
+			toks = append(toks, token{kind: kind, text: src[i:end], pos: i})
+			i = end
+
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := i
+			for end < len(src) && (src[end] == '_' || src[end] >= 'a' && src[end] <= 'z' ||
+				src[end] >= 'A' && src[end] <= 'Z' || src[end] >= '0' && src[end] <= '9') {
+				end++
+			}
+			toks = append(toks, token{kind: tokIdent, text: strings.ToLower(src[i:end]), pos: i})
+			i = end
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,150 @@
+// Package rules decides which parsed log events are flagged, using named rules
+// with boolean conditions loaded from the configuration.
+package rules
+
+import (
+	"fmt"
+	"minerva/internal/config"
+	"minerva/internal/parser"
+	"strings"
+)
+
+// Severity ranks how serious a rule match is.
+type Severity int
+
+const (
+	Low Severity = iota + 1
+	Medium
+	High
+	Critical
+)
+
+var severityNames = map[Severity]string{Low: "low", Medium: "medium", High: "high", Critical: "critical"}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ParseSeverity converts a severity name to a Severity. An empty name is Medium.
+func ParseSeverity(name string) (Severity, error) {
+	if name == "" {
+		return Medium, nil
+	}
+	for s, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (use low, medium, high or critical)", name)
+}
+
+// Rule is a compiled flagging rule.
+type Rule struct {
+	Name     string
+	Severity Severity
+	When     string // the condition as written in the config
+	cond     node
+}
+
+// Match reports whether ev satisfies the rule's condition.
+func (r *Rule) Match(ev *parser.LogEvent) bool {
+	return r.cond.eval(ev)
+}
+
+// Compile checks a rule definition and compiles its condition. Names cannot
+// contain commas, which separate the names stored with a flagged event.
+func Compile(def config.RuleConfig) (*Rule, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("rule has no name")
+	}
+	if strings.Contains(def.Name, ",") {
+		return nil, fmt.Errorf("invalid rule name %q: names cannot contain commas", def.Name)
+	}
+	severity, err := ParseSeverity(def.Severity)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", def.Name, err)
+	}
+	cond, err := compile(def.When)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", def.Name, err)
+	}
+	return &Rule{Name: def.Name, Severity: severity, When: def.When, cond: cond}, nil
+}
+
+// DefaultRules reproduces the built-in flagging used when no rules are configured:
+// router lines dropped for one of parser.FlaggedReasons, and every dropped or
+// rejected packet from the other formats, which have no comparable reason codes.
+func DefaultRules() []config.RuleConfig {
+	reasons := make([]string, len(parser.FlaggedReasons))
+	for i, reason := range parser.FlaggedReasons {
+		reasons[i] = fmt.Sprintf("%q", reason)
+	}
+	return []config.RuleConfig{
+		{
+			Name:     "router-threat",
+			Severity: "high",
+			When:     `format == "att" and action == "DROP" and reason in [` + strings.Join(reasons, ", ") + `]`,
+		},
+		{
+			Name:     "firewall-block",
+			Severity: "medium",
+			When:     `format != "att" and action in ["DROP", "REJECT"]`,
+		},
+	}
+}
+
+// Engine evaluates a set of rules against parsed events. It is safe for
+// concurrent use.
+type Engine struct {
+	rules []*Rule
+}
+
+// New compiles the given rule definitions, or DefaultRules if there are none.
+func New(defs []config.RuleConfig) (*Engine, error) {
+	if len(defs) == 0 {
+		defs = DefaultRules()
+	}
+	e := &Engine{}
+	seen := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		rule, err := Compile(def)
+		if err != nil {
+			return nil, err
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+		e.rules = append(e.rules, rule)
+	}
+	return e, nil
+}
+
+// Rules returns the engine's rules in evaluation order.
+func (e *Engine) Rules() []*Rule {
+	return e.rules
+}
+
+// Apply evaluates every rule against ev, recording the names of the matching
+// rules and the highest matching severity on the event. It reports whether any
+// rule matched, meaning the event is flagged.
+func (e *Engine) Apply(ev *parser.LogEvent) bool {
+	ev.Rules, ev.Severity = nil, ""
+	var highest Severity
+	for _, rule := range e.rules {
+		if !rule.Match(ev) {
+			continue
+		}
+		ev.Rules = append(ev.Rules, rule.Name)
+		if rule.Severity > highest {
+			highest = rule.Severity
+		}
+	}
+	if highest > 0 {
+		ev.Severity = highest.String()
+	}
+	return len(ev.Rules) > 0
+}
@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"minerva/internal/config"
+	"minerva/internal/parser"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// sampleEvent is a dropped TCP packet from the router.
+func sampleEvent() parser.LogEvent {
+	return parser.LogEvent{
+		Format:          "att",
+		SourceIP:        netip.MustParseAddr("198.51.100.7"),
+		DestinationIP:   netip.MustParseAddr("203.0.113.2"),
+		SourcePort:      51234,
+		DestinationPort: 22,
+		Protocol:        "TCP",
+		Action:          "DROP",
+		Reason:          "PORTSCAN",
+		PacketLength:    60,
+		TTL:             50,
+		Header:          parser.Header{TCPFlags: "SYN", IPFlags: "DF"},
+		SourceClass:     "public",
+	}
+}
+
+func TestCondition(t *testing.T) {
+	tests := []struct {
+		when     string
+		expected bool
+	}{
+		{`action == "DROP"`, true},
+		{`action == "drop"`, true},
+		{`action != "DROP"`, false},
+		{`reason in ["PORTSCAN", "INTRUSION-DETECTED"]`, true},
+		{`reason not in ["PORTSCAN"]`, false},
+		{`protocol == "UDP"`, false},
+		{`dst_port == 22`, true},
+		{`dst_port in [80, 443, 1..1024]`, true},
+		{`dst_port in [1025..65535]`, false},
+		{`dst_port not in [1025..65535]`, true},
+		{`src_port >= 1024 and src_port < 65535`, true},
+		{`ttl < 64`, true},
+		{`ttl <= 49`, false},
+		{`length > 1400`, false},
+		{`src_ip in ["198.51.100.0/24"]`, true},
+		{`src_ip == "198.51.100.7"`, true},
+		{`src_ip not in ["10.0.0.0/8", "192.168.0.0/16"]`, true},
+		{`dst_ip == "203.0.113.0/25"`, true},
+		{`action == "DROP" and protocol == "UDP"`, false},
+		{`action == "ACCEPT" or protocol == "TCP"`, true},
+		{`not protocol == "TCP"`, false},
+		{`action == "ACCEPT" or protocol == "TCP" and ttl > 100`, false},
+		{`(action == "ACCEPT" or protocol == "TCP") and ttl < 100`, true},
+		{`NOT (reason == "PORTSCAN" AND dst_port == 22)`, false},
+		{`tcp_flags == "SYN" and ip_flags == "DF"`, true},
+		{`tcp_flags in ["FIN PSH URG", ""]`, false},
+		{`src_class == "public"`, true},
+		{`src_class in ["private", "bogon"]`, false},
+		{`window == 0`, false},
+		{`window < 100`, false},
+		{`window >= 0`, false},
+		{`ip_id in [0..65535]`, false},
+		{`ip_id not in [0..65535]`, true},
+		{`icmp_type != 8`, true},
+		{`flow_label == 0`, false},
+		{`flow_label <= 1048575`, false},
+	}
+
+	ev := sampleEvent()
+	for _, tc := range tests {
+		cond, err := compile(tc.when)
+		if err != nil {
+			t.Errorf("compile(%q): unexpected error: %v", tc.when, err)
+			continue
+		}
+		if result := cond.eval(&ev); result != tc.expected {
+			t.Errorf("Expected %q to be %v, got %v", tc.when, tc.expected, result)
+		}
+	}
+}
+
+func TestCondition_OptionalFields(t *testing.T) {
+	cond, err := compile(`window < 100`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ev := sampleEvent()
+	if cond.eval(&ev) {
+		t.Error("Expected an event without a window not to match")
+	}
+	window := uint16(64)
+	ev.Window = &window
+	if !cond.eval(&ev) {
+		t.Error("Expected an event with a small window to match")
+	}
+}
+
+func TestCondition_Errors(t *testing.T) {
+	tests := []string{
+		``,
+		`action`,
+		`action = "DROP"`,
+		`action == DROP`,
+		`action == "DROP" and`,
+		`colour == "red"`,
+		`action < "DROP"`,
+		`dst_port == "22"`,
+		`dst_port in [10..1]`,
+		`ttl > 1..5`,
+		`src_ip == "not-an-ip"`,
+		`src_ip in ["10.0.0.0/33"]`,
+		`src_ip > "10.0.0.1"`,
+		`(action == "DROP"`,
+		`reason in ["PORTSCAN"`,
+		`reason == "unterminated`,
+		`action == "DROP" protocol == "TCP"`,
+		`dst_port == 5..`,
+	}
+
+	for _, when := range tests {
+		if _, err := compile(when); err == nil {
+			t.Errorf("Expected an error compiling %q", when)
+		}
+	}
+}
+
+func TestEngine_Apply(t *testing.T) {
+	engine, err := New([]config.RuleConfig{
+		{Name: "ssh", Severity: "medium", When: `dst_port == 22`},
+		{Name: "low-ttl", Severity: "critical", When: `ttl < 64`},
+		{Name: "udp", Severity: "low", When: `protocol == "UDP"`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ev := sampleEvent()
+	if !engine.Apply(&ev) {
+		t.Fatal("Expected the event to be flagged")
+	}
+	if !reflect.DeepEqual(ev.Rules, []string{"ssh", "low-ttl"}) {
+		t.Errorf("Expected rules [ssh low-ttl], got %v", ev.Rules)
+	}
+	if ev.Severity != "critical" {
+		t.Errorf("Expected severity critical, got %q", ev.Severity)
+	}
+
+	ev.DestinationPort, ev.TTL = 80, 128
+	if engine.Apply(&ev) {
+		t.Errorf("Expected the event not to be flagged, got rules %v", ev.Rules)
+	}
+	if ev.Rules != nil || ev.Severity != "" {
+		t.Errorf("Expected rules and severity to be cleared, got %v, %q", ev.Rules, ev.Severity)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []config.RuleConfig
+	}{
+		{"Missing name", []config.RuleConfig{{When: `ttl < 5`}}},
+		{"Comma in name", []config.RuleConfig{{Name: "ssh,telnet", When: `dst_port in [22, 23]`}}},
+		{"Bad severity", []config.RuleConfig{{Name: "a", Severity: "extreme", When: `ttl < 5`}}},
+		{"Bad condition", []config.RuleConfig{{Name: "a", When: `ttl <`}}},
+		{"Duplicate name", []config.RuleConfig{{Name: "a", When: `ttl < 5`}, {Name: "a", When: `ttl > 5`}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(tc.defs); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	engine, err := New(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error compiling the default rules: %v", err)
+	}
+
+	tests := []struct {
+		ev       parser.LogEvent
+		expected bool
+	}{
+		{parser.LogEvent{Format: "att", Action: "DROP", Reason: "PORTSCAN"}, true},
+		{parser.LogEvent{Format: "att", Action: "DROP", Reason: "WHITELIST"}, false},
+		{parser.LogEvent{Format: "att", Action: "ALLOW", Reason: "PORTSCAN"}, false},
+		{parser.LogEvent{Format: "ufw", Action: "DROP", Reason: "UFW-BLOCK"}, true},
+		{parser.LogEvent{Format: "netfilter", Action: "REJECT", Reason: "REJECT-IN"}, true},
+		{parser.LogEvent{Format: "pfsense", Action: "ACCEPT", Reason: "RULE-1"}, false},
+	}
+
+	for _, tc := range tests {
+		if result := engine.Apply(&tc.ev); result != tc.expected {
+			t.Errorf("Expected %+v to be flagged: %v, got %v", tc.ev, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package scan
+
+import (
+	"minerva/internal/config"
+	"minerva/internal/parser"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxObservations bounds the events remembered per source. A fast scan
+// crosses any threshold long before this many, and the oldest are forgotten
+// first.
+const maxObservations = 2000
+
+// observation is one event of a source.
+type observation struct {
+	at   time.Time
+	host netip.Addr
+	port uint16
+	fast bool // Part of a vertical scan or horizontal sweep
+}
+
+// target is a destination address and port.
+type target struct {
+	host netip.Addr
+	port uint16
+}
+
+// key identifies a source address as seen by one sensor.
+type key struct {
+	sensor string
+	ip     netip.Addr
+}
+
+// source is what a Detector remembers of one source address.
+type source struct {
+	observations []observation // Sorted by timestamp
+	open         map[Type]*incident
+	touched      time.Time // When its last event arrived
+}
+
+// incident is an Incident still being collected.
+type incident struct {
+	Incident
+	ports   map[uint16]bool
+	hosts   map[netip.Addr]bool
+	touched time.Time // When its last event arrived
+}
+
+// Detector finds scans in the events it observes. Windows are measured in
+// event timestamps, and scans are found whether events arrive oldest or newest
+// first, so that batch imports are read the same way as live logs. Whether a
+// source has gone quiet is measured in wall clock time, so incidents complete
+// during a batch import only once it ends. A Detector is safe for concurrent
+// use.
+type Detector struct {
+	window          time.Duration
+	verticalPorts   int
+	horizontalHosts int
+	slowWindow      time.Duration
+	slowTargets     int
+	now             func() time.Time
+
+	mu      sync.Mutex
+	sources map[key]*source
+}
+
+// NewDetector returns a Detector with the thresholds in conf.
+func NewDetector(conf config.ScanConfig) *Detector {
+	return &Detector{
+		window:          conf.Window(),
+		verticalPorts:   conf.VerticalPorts,
+		horizontalHosts: conf.HorizontalHosts,
+		slowWindow:      conf.SlowWindow(),
+		slowTargets:     conf.SlowTargets,
+		now:             time.Now,
+		sources:         make(map[key]*source),
+	}
+}
+
+// Observe records an event and returns the incidents it completes: a
+// new scan of a type whose previous incident from the same source lies more
+// than a window away completes that incident. Events without a destination
+// port, such as ICMP, are ignored.
+func (d *Detector) Observe(ev parser.LogEvent) []Incident {
+	fast := d.window > 0 && (d.verticalPorts > 0 || d.horizontalHosts > 0)
+	slow := d.slowWindow > 0 && d.slowTargets > 0
+	if !fast && !slow || ev.DestinationPort == 0 || !ev.SourceIP.IsValid() || !ev.DestinationIP.IsValid() {
+		return nil
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := key{sensor: ev.Sensor, ip: ev.SourceIP}
+	src := d.sources[k]
+	if src == nil {
+		src = &source{open: make(map[Type]*incident)}
+		d.sources[k] = src
+	}
+	src.touched = now
+	o := src.add(observation{at: ev.Timestamp, host: ev.DestinationIP, port: ev.DestinationPort}, d.horizon())
+
+	var done []Incident
+	if d.window > 0 && d.verticalPorts > 0 && !src.extend(Vertical, o, d.window, now) {
+		matched := detect(src, o.at, d.window, d.verticalPorts, func(x *observation) (uint16, bool) {
+			return x.port, true
+		})
+		done = append(done, src.start(k, Vertical, matched, now)...)
+	}
+	if d.window > 0 && d.horizontalHosts > 0 && !src.extend(Horizontal, o, d.window, now) {
+		matched := detect(src, o.at, d.window, d.horizontalHosts, func(x *observation) (netip.Addr, bool) {
+			return x.host, x.port == o.port
+		})
+		done = append(done, src.start(k, Horizontal, matched, now)...)
+	}
+	// Slow scans are made of what faster scans leave over, so that a fast scan
+	// is not reported twice.
+	if slow && !o.fast && !src.extend(Slow, o, d.slowWindow, now) {
+		matched := detect(src, o.at, d.slowWindow, d.slowTargets, func(x *observation) (target, bool) {
+			return target{x.host, x.port}, !x.fast
+		})
+		done = append(done, src.start(k, Slow, matched, now)...)
+	}
+	return done
+}
+
+// Expire returns the incidents whose source has sent nothing for a window, or
+// a slow window for slow scans, and forgets sources that have been quiet for
+// longer than either.
+func (d *Detector) Expire() []Incident {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var done []Incident
+	for k, src := range d.sources {
+		for t, inc := range src.open {
+			if now.Sub(inc.touched) > d.gap(t) {
+				done = append(done, inc.complete())
+				delete(src.open, t)
+			}
+		}
+		if len(src.open) == 0 && now.Sub(src.touched) > d.horizon() {
+			delete(d.sources, k)
+		}
+	}
+	return done
+}
+
+// Flush returns every incident still being collected, and forgets all
+// sources.
+func (d *Detector) Flush() []Incident {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var done []Incident
+	for _, src := range d.sources {
+		for _, inc := range src.open {
+			done = append(done, inc.complete())
+		}
+	}
+	d.sources = make(map[key]*source)
+	return done
+}
+
+// gap returns how far apart, in event time and in wall clock time, two events
+// of an incident of type t can be.
+func (d *Detector) gap(t Type) time.Duration {
+	if t == Slow {
+		return d.slowWindow
+	}
+	return d.window
+}
+
+// horizon returns how long observations are remembered.
+func (d *Detector) horizon() time.Duration {
+	return max(d.window, d.slowWindow)
+}
+
+// add inserts o in time order and forgets observations more than horizon
+// before or after it. It returns the inserted observation, which stays valid
+// until the next call.
+func (s *source) add(o observation, horizon time.Duration) *observation {
+	obs := s.observations
+	lo := sort.Search(len(obs), func(i int) bool { return !obs[i].at.Before(o.at.Add(-horizon)) })
+	hi := sort.Search(len(obs), func(i int) bool { return obs[i].at.After(o.at.Add(horizon)) })
+	obs = obs[lo:hi]
+
+	i := sort.Search(len(obs), func(i int) bool { return obs[i].at.After(o.at) })
+	obs = append(obs, observation{})
+	copy(obs[i+1:], obs[i:])
+	obs[i] = o
+
+	// Keep the observations nearest in time to the latest arrival: the newest
+	// when events arrive oldest first, and the oldest otherwise.
+	for len(obs) > maxObservations {
+		if i >= len(obs)/2 {
+			obs = obs[1:]
+			i--
+		} else {
+			obs = obs[:len(obs)-1]
+		}
+	}
+	s.observations = append(s.observations[:0], obs...)
+	return &s.observations[i]
+}
+
+// within returns the observations timestamped from from to to, inclusive.
+func (s *source) within(from, to time.Time) []observation {
+	obs := s.observations
+	lo := sort.Search(len(obs), func(i int) bool { return !obs[i].at.Before(from) })
+	hi := sort.Search(len(obs), func(i int) bool { return obs[i].at.After(to) })
+	return obs[lo:hi]
+}
+
+// extend adds o to the open incident of type t if o is within gap of it, and
+// reports whether it did.
+func (s *source) extend(t Type, o *observation, gap time.Duration, now time.Time) bool {
+	inc := s.open[t]
+	if inc == nil || o.at.Before(inc.Start.Add(-gap)) || o.at.After(inc.End.Add(gap)) {
+		return false
+	}
+	inc.add(o, now)
+	return true
+}
+
+// start opens an incident of type t made of matched, if there are any, and
+// returns the incident of that type it replaces.
+func (s *source) start(k key, t Type, matched []*observation, now time.Time) []Incident {
+	if len(matched) == 0 {
+		return nil
+	}
+	var done []Incident
+	if prev := s.open[t]; prev != nil {
+		done = append(done, prev.complete())
+	}
+	inc := &incident{
+		Incident: Incident{Sensor: k.sensor, SourceIP: k.ip, Type: t, Start: matched[0].at, End: matched[0].at},
+		ports:    make(map[uint16]bool),
+		hosts:    make(map[netip.Addr]bool),
+	}
+	for _, o := range matched {
+		inc.add(o, now)
+	}
+	s.open[t] = inc
+	return done
+}
+
+// detect looks for a window of length w, ending or starting at at, in which
+// the observations that key accepts have at least threshold distinct keys.
+// It returns those observations, or nil if neither window has enough.
+func detect[K comparable](s *source, at time.Time, w time.Duration, threshold int, key func(*observation) (K, bool)) []*observation {
+	for _, obs := range [][]observation{s.within(at.Add(-w), at), s.within(at, at.Add(w))} {
+		if len(obs) < threshold {
+			continue
+		}
+		seen := make(map[K]bool)
+		var matched []*observation
+		for i := range obs {
+			if k, ok := key(&obs[i]); ok {
+				seen[k] = true
+				matched = append(matched, &obs[i])
+			}
+		}
+		if len(seen) >= threshold {
+			return matched
+		}
+	}
+	return nil
+}
+
+// add records o as part of the incident. Observations of a vertical scan or
+// horizontal sweep are marked as fast.
+func (inc *incident) add(o *observation, now time.Time) {
+	if inc.Type != Slow {
+		o.fast = true
+	}
+	if o.at.Before(inc.Start) {
+		inc.Start = o.at
+	}
+	if o.at.After(inc.End) {
+		inc.End = o.at
+	}
+	inc.ports[o.port] = true
+	inc.hosts[o.host] = true
+	inc.Hits++
+	inc.touched = now
+}
+
+// complete returns the collected Incident.
+func (inc *incident) complete() Incident {
+	done := inc.Incident
+	done.Ports = make([]int, 0, len(inc.ports))
+	for port := range inc.ports {
+		done.Ports = append(done.Ports, int(port))
+	}
+	sort.Ints(done.Ports)
+	done.Hosts = len(inc.hosts)
+	return done
+}
@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"fmt"
+	"minerva/internal/config"
+	"minerva/internal/parser"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+var (
+	scanConfig = config.ScanConfig{WindowSeconds: 60, VerticalPorts: 20, HorizontalHosts: 10, SlowWindowHours: 6, SlowTargets: 30}
+	start      = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// newTestDetector returns a Detector whose wall clock is *clock.
+func newTestDetector(clock *time.Time) *Detector {
+	d := NewDetector(scanConfig)
+	d.now = func() time.Time { return *clock }
+	return d
+}
+
+// event returns an event from 203.0.113.7 to host and port, offset after start.
+func event(offset time.Duration, host string, port uint16) parser.LogEvent {
+	return parser.LogEvent{
+		Sensor:          "edge",
+		Timestamp:       start.Add(offset),
+		SourceIP:        netip.MustParseAddr("203.0.113.7"),
+		DestinationIP:   netip.MustParseAddr(host),
+		DestinationPort: port,
+		Protocol:        "TCP",
+	}
+}
+
+// verticalScan returns n events to consecutive ports of one host, a second
+// apart.
+func verticalScan(offset time.Duration, n int) []parser.LogEvent {
+	var events []parser.LogEvent
+	for i := 0; i < n; i++ {
+		events = append(events, event(offset+time.Duration(i)*time.Second, "198.51.100.1", uint16(1000+i)))
+	}
+	return events
+}
+
+func observeAll(d *Detector, events []parser.LogEvent) []Incident {
+	var done []Incident
+	for _, ev := range events {
+		done = append(done, d.Observe(ev)...)
+	}
+	return done
+}
+
+func TestDetector(t *testing.T) {
+	var sweep, slow, quiet, icmp []parser.LogEvent
+	for i := 0; i < 12; i++ {
+		sweep = append(sweep, event(time.Duration(i)*2*time.Second, fmt.Sprintf("198.51.100.%d", i+1), 22))
+	}
+	for i := 0; i < 40; i++ {
+		slow = append(slow, event(time.Duration(i)*10*time.Minute, fmt.Sprintf("198.51.100.%d", i%5+1), uint16(8000+i)))
+	}
+	for i := 0; i < 19; i++ {
+		quiet = append(quiet, event(time.Duration(i)*time.Second, "198.51.100.1", uint16(1000+i)))
+	}
+	for i := 0; i < 30; i++ {
+		ev := event(time.Duration(i)*time.Second, fmt.Sprintf("198.51.100.%d", i+1), 0)
+		ev.Protocol = "ICMP"
+		icmp = append(icmp, ev)
+	}
+
+	tests := []struct {
+		name     string
+		events   []parser.LogEvent
+		expected string
+	}{
+		{"Vertical", verticalScan(0, 25), "vertical 12:00:00-12:00:24 ports=25 [1000..1024] hosts=1 hits=25"},
+		{"Horizontal", sweep, "horizontal 12:00:00-12:00:22 ports=1 [22..22] hosts=12 hits=12"},
+		{"Slow", slow, "slow 12:00:00-18:30:00 ports=40 [8000..8039] hosts=5 hits=40"},
+		{"Below thresholds", quiet, ""},
+		{"ICMP", icmp, ""},
+	}
+	for _, tc := range tests {
+		for _, reverse := range []bool{false, true} {
+			clock := start
+			d := newTestDetector(&clock)
+			events := slices.Clone(tc.events)
+			if reverse {
+				slices.Reverse(events)
+			}
+			if done := observeAll(d, events); len(done) != 0 {
+				t.Errorf("%s: expected nothing to complete while observing, got %+v", tc.name, done)
+			}
+			got := ""
+			for _, inc := range d.Flush() {
+				got += fmt.Sprintf("%s %s-%s ports=%d [%d..%d] hosts=%d hits=%d", inc.Type, inc.Start.Format("15:04:05"),
+					inc.End.Format("15:04:05"), len(inc.Ports), inc.Ports[0], inc.Ports[len(inc.Ports)-1], inc.Hosts, inc.Hits)
+				if inc.Sensor != "edge" || inc.SourceIP.String() != "203.0.113.7" {
+					t.Errorf("%s: expected the incident of edge and 203.0.113.7, got %+v", tc.name, inc)
+				}
+			}
+			if got != tc.expected {
+				t.Errorf("%s (reverse %v): expected %q, got %q", tc.name, reverse, tc.expected, got)
+			}
+		}
+	}
+}
+
+func TestDetector_Incidents(t *testing.T) {
+	clock := start
+	d := newTestDetector(&clock)
+
+	// A second scan an hour later completes the first.
+	if done := observeAll(d, verticalScan(0, 20)); len(done) != 0 {
+		t.Fatalf("Expected the first scan to stay open, got %+v", done)
+	}
+	done := observeAll(d, verticalScan(time.Hour, 20))
+	if len(done) != 1 || done[0].Type != Vertical || !done[0].End.Equal(start.Add(19*time.Second)) {
+		t.Fatalf("Expected the first scan to complete, got %+v", done)
+	}
+
+	// The same source seen by another sensor is tracked separately.
+	for _, ev := range verticalScan(time.Hour, 20) {
+		ev.Sensor = "branch"
+		d.Observe(ev)
+	}
+
+	// Both open scans complete once their source is quiet for a window, and
+	// the sources are forgotten after the slow window.
+	clock = clock.Add(30 * time.Second)
+	if done := d.Expire(); len(done) != 0 {
+		t.Errorf("Expected scans to stay open within the window, got %+v", done)
+	}
+	clock = clock.Add(time.Minute)
+	done = d.Expire()
+	if len(done) != 2 || done[0].Sensor == done[1].Sensor || done[0].Hits != 20 || done[1].Hits != 20 {
+		t.Errorf("Expected a scan for each sensor, got %+v", done)
+	}
+	clock = clock.Add(7 * time.Hour)
+	d.Expire()
+	if len(d.sources) != 0 {
+		t.Errorf("Expected quiet sources to be forgotten, got %d", len(d.sources))
+	}
+
+	// With every threshold disabled, nothing is remembered.
+	d = NewDetector(config.ScanConfig{})
+	if done := observeAll(d, verticalScan(0, 100)); len(done) != 0 || len(d.sources) != 0 {
+		t.Errorf("Expected no detection when disabled, got %+v", done)
+	}
+}
+
+func BenchmarkDetectorObserve(b *testing.B) {
+	d := NewDetector(scanConfig)
+	events := verticalScan(0, 65535)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Observe(events[i%len(events)])
+	}
+}
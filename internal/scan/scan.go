@@ -0,0 +1,60 @@
+// Package scan detects port scans and sweeps from the flagged and dropped
+// events of each source address, rather than trusting the scan detection of
+// the device that logged them.
+//
+// A Detector keeps a sliding window of the destinations each source has
+// recently reached. A source that reaches many ports within a short window is
+// running a vertical scan, one that reaches many hosts on the same port a
+// horizontal sweep, and one that reaches many host and port pairs over hours,
+// too slowly for either, a slow scan. The events of a scan are collected into
+// one Incident, which is complete once its source has been quiet for a window.
+package scan
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Type is a kind of scan.
+type Type string
+
+const (
+	Vertical   Type = "vertical"   // Many ports within a window
+	Horizontal Type = "horizontal" // Many hosts on one port within a window
+	Slow       Type = "slow"       // Many host and port pairs within a slow window
+)
+
+// Types lists every type.
+var Types = []Type{Vertical, Horizontal, Slow}
+
+// ParseType returns the type named s.
+func ParseType(s string) (Type, error) {
+	for _, t := range Types {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scan type %q", s)
+}
+
+// Incident is a scan by one source address, as seen by one sensor.
+type Incident struct {
+	ID       int64      `json:"id"`
+	Sensor   string     `json:"sensor"`
+	SourceIP netip.Addr `json:"source_ip"`
+	Type     Type       `json:"scan_type"`
+	Start    time.Time  `json:"start"` // Timestamp of the first event
+	End      time.Time  `json:"end"`   // Timestamp of the last event
+	Ports    []int      `json:"ports"` // Distinct destination ports, sorted
+	Hosts    int        `json:"hosts"` // Distinct destination addresses
+	Hits     int64      `json:"hits"`  // Events
+}
+
+// DataHandler defines methods for storing incidents.
+type DataHandler interface {
+	// InsertIncident stores a completed incident. Its ID is ignored. An
+	// incident with the same sensor, source, type and start is merged into
+	// the one already stored; hosts and hits are then lower bounds.
+	InsertIncident(inc Incident) error
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"minerva/internal/store"
+	"strings"
+)
+
+// TopASNs returns the autonomous systems with the most flagged entries. Without
+// a sensor or start time the ip_intel counters are summed; otherwise the
+// matching log_data entries are counted.
+func (s *Store) TopASNs(q store.ASNQuery) ([]store.ASNStats, error) {
+	var query string
+	var args []interface{}
+	if q.Sensor == "" && q.Since.IsZero() {
+		query = `
+			SELECT g.asn, MAX(g.as_org), COUNT(*), COUNT(*) FILTER (WHERE g.hosting), SUM(i.total_hits)
+			FROM ip_intel i
+			JOIN ip_geo g ON g.ip_address = i.ip_address
+			WHERE g.asn IS NOT NULL AND i.total_hits > 0`
+	} else {
+		conds := []string{"g.asn IS NOT NULL"}
+		if q.Sensor != "" {
+			args = append(args, q.Sensor)
+			conds = append(conds, fmt.Sprintf("l.sensor_id = $%d", len(args)))
+		}
+		if !q.Since.IsZero() {
+			args = append(args, timeText(q.Since))
+			conds = append(conds, fmt.Sprintf("l.timestamp >= $%d", len(args)))
+		}
+		query = `
+			SELECT g.asn, MAX(g.as_org), COUNT(DISTINCT l.source_ip),
+				COUNT(DISTINCT l.source_ip) FILTER (WHERE g.hosting), COUNT(*)
+			FROM log_data l
+			JOIN ip_geo g ON g.ip_address = l.source_ip
+			WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(`
+		GROUP BY g.asn
+		ORDER BY 5 DESC, g.asn
+		LIMIT $%d`, len(args)+1)
+
+	rows, err := s.db.Query(query, append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ASNs: %w", err)
+	}
+	defer rows.Close()
+
+	asns := []store.ASNStats{}
+	for rows.Next() {
+		var a store.ASNStats
+		var org sql.NullString
+		if err := rows.Scan(&a.ASN, &org, &a.Sources, &a.HostingSources, &a.Hits); err != nil {
+			return nil, fmt.Errorf("failed to read ASN: %w", err)
+		}
+		a.Org = org.String
+		asns = append(asns, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ASNs: %w", err)
+	}
+	return asns, nil
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"fmt"
+	"minerva/internal/blocklist"
+	"net/netip"
+	"time"
+)
+
+// BlocklistsUpdatedAt returns when each stored list was last fetched, by name.
+func (s *Store) BlocklistsUpdatedAt() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT source, MAX(updated_at) FROM blocklist_entries GROUP BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	defer rows.Close()
+
+	updated := map[string]time.Time{}
+	for rows.Next() {
+		var name, at string
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("failed to read blocklists: %w", err)
+		}
+		if updated[name], err = parseTime(at); err != nil {
+			return nil, fmt.Errorf("failed to read blocklist %s: %w", name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	return updated, nil
+}
+
+// Blocklists returns the stored lists with their networks, ordered by name.
+func (s *Store) Blocklists() ([]blocklist.List, error) {
+	rows, err := s.db.Query(`SELECT source, network, CAST(updated_at AS TEXT) FROM blocklist_entries ORDER BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []blocklist.List
+	for rows.Next() {
+		var name, network, updated string
+		if err := rows.Scan(&name, &network, &updated); err != nil {
+			return nil, fmt.Errorf("failed to read blocklists: %w", err)
+		}
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blocklist %s: %w", name, err)
+		}
+		at, err := parseTime(updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blocklist %s: %w", name, err)
+		}
+		if len(lists) == 0 || lists[len(lists)-1].Name != name {
+			lists = append(lists, blocklist.List{Name: name})
+		}
+		l := &lists[len(lists)-1]
+		l.Networks = append(l.Networks, prefix)
+		if at.After(l.UpdatedAt) {
+			l.UpdatedAt = at
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query blocklists: %w", err)
+	}
+	return lists, nil
+}
+
+// ReplaceBlocklist replaces the stored networks of a list in one transaction.
+// A list without networks is deleted.
+func (s *Store) ReplaceBlocklist(l blocklist.List) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to replace blocklist %s: %w", l.Name, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`DELETE FROM blocklist_entries WHERE source = $1`, l.Name); err != nil {
+		return fmt.Errorf("failed to delete blocklist %s: %w", l.Name, err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO blocklist_entries (source, network, updated_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare blocklist insert: %w", err)
+	}
+	defer stmt.Close()
+	updated := timeText(l.UpdatedAt)
+	for _, network := range l.Networks {
+		if _, err := stmt.Exec(l.Name, network.String(), updated); err != nil {
+			return fmt.Errorf("failed to insert blocklist %s: %w", l.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace blocklist %s: %w", l.Name, err)
+	}
+	return nil
+}
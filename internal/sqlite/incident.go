@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"minerva/internal/parser"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"strings"
+)
+
+// InsertIncident stores a completed incident. An incident already stored with
+// the same sensor, source, type and start, found again when logs are read
+// again, is merged with it: their ports are combined and the later end is
+// kept, but the events behind the counts are not, so hosts and hits are the
+// larger of the two. They are lower bounds for incidents that only overlap.
+func (s *Store) InsertIncident(inc scan.Incident) error {
+	ports, err := json.Marshal(inc.Ports)
+	if err != nil {
+		return fmt.Errorf("failed to encode ports: %w", err)
+	}
+	if inc.Ports == nil {
+		ports = []byte("[]")
+	}
+	_, err = s.db.Exec(`
+    INSERT INTO incidents (sensor_id, source_ip, source_key, scan_type, start_time, end_time, ports, hosts, hits)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    ON CONFLICT (sensor_id, source_ip, scan_type, start_time) DO UPDATE SET
+        end_time = MAX(end_time, excluded.end_time),
+        ports = (SELECT json_group_array(value) FROM (
+            SELECT value FROM json_each(incidents.ports)
+            UNION SELECT value FROM json_each(excluded.ports)
+            ORDER BY value)),
+        hosts = MAX(hosts, excluded.hosts),
+        hits = MAX(hits, excluded.hits)`,
+		inc.Sensor, inc.SourceIP.String(), addrKey(inc.SourceIP), string(inc.Type),
+		timeText(inc.Start), timeText(inc.End), string(ports), inc.Hosts, inc.Hits)
+	if err != nil {
+		return fmt.Errorf("failed to insert incident for IP %s: %w", inc.SourceIP, err)
+	}
+	return nil
+}
+
+// Incidents returns detected scans, latest start first. A source prefix is
+// matched as a range of source_key.
+func (s *Store) Incidents(q store.IncidentQuery) ([]scan.Incident, error) {
+	var conds []string
+	var args []interface{}
+	if q.Sensor != "" {
+		args = append(args, q.Sensor)
+		conds = append(conds, fmt.Sprintf("sensor_id = $%d", len(args)))
+	}
+	if q.SourceCIDR.IsValid() {
+		first, last := prefixRange(q.SourceCIDR)
+		args = append(args, first, last)
+		conds = append(conds, fmt.Sprintf("source_key BETWEEN $%d AND $%d", len(args)-1, len(args)))
+	}
+	if q.Type != "" {
+		args = append(args, string(q.Type))
+		conds = append(conds, fmt.Sprintf("scan_type = $%d", len(args)))
+	}
+	if !q.Since.IsZero() {
+		args = append(args, timeText(q.Since))
+		conds = append(conds, fmt.Sprintf("end_time >= $%d", len(args)))
+	}
+
+	query := `
+		SELECT id, sensor_id, source_ip, scan_type, CAST(start_time AS TEXT), CAST(end_time AS TEXT), ports, hosts, hits
+		FROM incidents`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY start_time DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []scan.Incident{}
+	for rows.Next() {
+		var inc scan.Incident
+		var ip, scanType, start, end, ports string
+		if err := rows.Scan(&inc.ID, &inc.Sensor, &ip, &scanType, &start, &end, &ports, &inc.Hosts, &inc.Hits); err != nil {
+			return nil, fmt.Errorf("failed to read incident: %w", err)
+		}
+		inc.Type = scan.Type(scanType)
+		if inc.SourceIP, err = parser.ParseAddr(ip); err != nil {
+			return nil, fmt.Errorf("failed to read incident %d: %w", inc.ID, err)
+		}
+		if inc.Start, err = parseTime(start); err != nil {
+			return nil, fmt.Errorf("failed to read incident %d: %w", inc.ID, err)
+		}
+		if inc.End, err = parseTime(end); err != nil {
+			return nil, fmt.Errorf("failed to read incident %d: %w", inc.ID, err)
+		}
+		if err := json.Unmarshal([]byte(ports), &inc.Ports); err != nil {
+			return nil, fmt.Errorf("failed to read incident %d: %w", inc.ID, err)
+		}
+		incidents = append(incidents, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	return incidents, nil
+}
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"minerva/internal/netclass"
+	"minerva/internal/parser"
+	"net/netip"
+
+	sqlitedriver "modernc.org/sqlite"
+)
+
+func init() {
+	// inet_key(text) is addrKey for SQL, used to fill in source_key for rows
+	// stored before it existed.
+	sqlitedriver.MustRegisterDeterministicScalarFunction("inet_key", 1, func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, _ := args[0].(string)
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			return nil, nil
+		}
+		return addrKey(addr), nil
+	})
+	// inet_class(text) is netclass.Classify for SQL, used to classify rows
+	// stored before source_class existed.
+	sqlitedriver.MustRegisterDeterministicScalarFunction("inet_class", 1, func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, _ := args[0].(string)
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			return nil, nil
+		}
+		return string(netclass.Classify(addr)), nil
+	})
+	// inet_text(text) is parser.ParseAddr for SQL, used to rewrite addresses
+	// stored before they were canonical. Text that is not an address is
+	// returned as it is.
+	sqlitedriver.MustRegisterDeterministicScalarFunction("inet_text", 1, func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, _ := args[0].(string)
+		addr, err := parser.ParseAddr(text)
+		if err != nil {
+			return args[0], nil
+		}
+		return addr.String(), nil
+	})
+}
+
+// addrKey returns the 16-byte form of addr, with IPv4 addresses mapped into
+// IPv6, or nil for the zero Addr. Keys compare as bytes in address order.
+func addrKey(addr netip.Addr) []byte {
+	if !addr.IsValid() {
+		return nil
+	}
+	key := addr.As16()
+	return key[:]
+}
+
+// prefixRange returns the first and last keys of the addresses in p.
+func prefixRange(p netip.Prefix) (first, last []byte) {
+	p = p.Masked()
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		bits += 96
+	}
+	first = addrKey(p.Addr())
+	last = append([]byte(nil), first...)
+	for i := bits; i < 128; i++ {
+		last[i/8] |= 0x80 >> (i % 8)
+	}
+	return first, last
+}
@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixRange(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		first, last string
+	}{
+		{"203.0.113.0/24", "203.0.113.0", "203.0.113.255"},
+		{"203.0.113.77/24", "203.0.113.0", "203.0.113.255"},
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255"},
+		{"192.0.2.1/32", "192.0.2.1", "192.0.2.1"},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tc := range tests {
+		first, last := prefixRange(netip.MustParsePrefix(tc.prefix))
+		if !bytes.Equal(first, addrKey(netip.MustParseAddr(tc.first))) {
+			t.Errorf("%s: expected the range to start at %s", tc.prefix, tc.first)
+		}
+		if !bytes.Equal(last, addrKey(netip.MustParseAddr(tc.last))) {
+			t.Errorf("%s: expected the range to end at %s", tc.prefix, tc.last)
+		}
+	}
+}
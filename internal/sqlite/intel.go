@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trackIntelSQL adds one inserted entry to the ip_intel counters of its source
+// address, merging its port and reason into the stored sets. A new port, of
+// which $3 holds at most one, is appended until there are store.MaxIntelPorts.
+// The class of an address is that of its entry with the latest timestamp,
+// whatever order they arrive in.
+var trackIntelSQL = `
+    INSERT INTO ip_intel (ip_address, class, first_seen, last_seen, total_hits, destination_ports, reasons)
+    VALUES ($1, $5, $2, $2, 1, $3, $4)
+    ON CONFLICT (ip_address) DO UPDATE SET
+        class = CASE WHEN last_seen IS NULL OR excluded.last_seen >= last_seen
+            THEN COALESCE(excluded.class, class) ELSE COALESCE(class, excluded.class) END,
+        first_seen = COALESCE(MIN(first_seen, excluded.first_seen), excluded.first_seen),
+        last_seen = COALESCE(MAX(last_seen, excluded.last_seen), excluded.last_seen),
+        total_hits = total_hits + 1,
+        destination_ports = CASE
+            WHEN json_array_length(excluded.destination_ports) = 0
+                OR json_array_length(ip_intel.destination_ports) >= ` + strconv.Itoa(store.MaxIntelPorts) + `
+                OR EXISTS (SELECT 1 FROM json_each(ip_intel.destination_ports)
+                    WHERE value = json_extract(excluded.destination_ports, '$[0]'))
+            THEN ip_intel.destination_ports
+            ELSE json_insert(ip_intel.destination_ports, '$[#]', json_extract(excluded.destination_ports, '$[0]'))
+        END,
+        reasons = (SELECT json_group_array(value) FROM (
+            SELECT value FROM json_each(ip_intel.reasons)
+            UNION SELECT value FROM json_each(excluded.reasons)
+            ORDER BY value))`
+
+// trackIntel adds ev, which has just been inserted, to the ip_intel counters.
+// Port 0, as logged for ICMP, is not a targeted port.
+func trackIntel(tx *sql.Tx, ev parser.LogEvent) error {
+	ports, reasons := "[]", "[]"
+	if ev.DestinationPort != 0 {
+		ports = fmt.Sprintf("[%d]", ev.DestinationPort)
+	}
+	if ev.Reason != "" {
+		b, err := json.Marshal([]string{ev.Reason})
+		if err != nil {
+			return fmt.Errorf("failed to encode reason: %w", err)
+		}
+		reasons = string(b)
+	}
+	if _, err := tx.Exec(trackIntelSQL, store.Inet(ev.SourceIP), timeText(ev.Timestamp), ports, reasons, sql.NullString{String: ev.SourceClass, Valid: ev.SourceClass != ""}); err != nil {
+		return fmt.Errorf("failed to update intel for IP %s: %w", ev.SourceIP, err)
+	}
+	return nil
+}
+
+// IPProfile returns the activity and geolocation data recorded for ip.
+func (s *Store) IPProfile(ip, sensor string) (*store.IPProfile, error) {
+	query := `
+		SELECT i.class, CAST(i.first_seen AS TEXT), CAST(i.last_seen AS TEXT), i.total_hits, i.destination_ports, i.reasons,
+			g.ip_address IS NOT NULL, ` + strings.Join(store.GeoColumns, ", ") + `
+		FROM ip_intel i
+		LEFT JOIN ip_geo g ON g.ip_address = i.ip_address
+		WHERE i.ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+
+	profile := store.IPProfile{IP: ip}
+	var class, first, last sql.NullString
+	var ports, reasons string
+	var hasGeo bool
+	var row store.GeoRow
+	dest := append([]interface{}{&class, &first, &last, &profile.TotalHits, &ports, &reasons, &hasGeo}, row.Dest()...)
+	err := s.db.QueryRow(query, args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intel for IP %s: %w", ip, err)
+	}
+
+	profile.Class = class.String
+	if first.Valid {
+		from, err := parseTime(first.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read intel for IP %s: %w", ip, err)
+		}
+		to, err := parseTime(last.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read intel for IP %s: %w", ip, err)
+		}
+		profile.FirstSeen, profile.LastSeen = &from, &to
+	}
+	if err := json.Unmarshal([]byte(ports), &profile.DestinationPorts); err != nil {
+		return nil, fmt.Errorf("failed to read intel for IP %s: %w", ip, err)
+	}
+	sort.Ints(profile.DestinationPorts) // Kept in the order they were first seen
+	if err := json.Unmarshal([]byte(reasons), &profile.Reasons); err != nil {
+		return nil, fmt.Errorf("failed to read intel for IP %s: %w", ip, err)
+	}
+	if hasGeo {
+		profile.Geo = row.Data()
+	}
+	return &profile, nil
+}
+
+// StaleGeoIPs returns up to limit addresses, or all if limit is 0, whose
+// geolocation data was last updated before cutoff, most recently seen first.
+// Addresses that are not public, and those whose last failed lookup is not due
+// for a retry, are left out.
+func (s *Store) StaleGeoIPs(cutoff time.Time, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	rows, err := s.db.Query(`
+		SELECT g.ip_address
+		FROM ip_geo g
+		JOIN ip_intel i ON i.ip_address = g.ip_address
+		LEFT JOIN geo_failures f ON f.ip_address = g.ip_address
+		WHERE (g.last_updated IS NULL OR g.last_updated < $1)
+			AND COALESCE(i.class, 'public') = 'public'
+			AND (f.ip_address IS NULL OR f.retry_after <= $2)
+		ORDER BY i.last_seen DESC NULLS LAST, g.last_updated NULLS FIRST
+		LIMIT $3`, timeText(cutoff), timeText(time.Now()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale geolocation data: %w", err)
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, fmt.Errorf("failed to read stale geolocation data: %w", err)
+		}
+		ips = append(ips, ip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stale geolocation data: %w", err)
+	}
+	return ips, nil
+}
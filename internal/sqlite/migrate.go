@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"time"
+
+	"minerva/internal/store"
+)
+
+// migrationFiles holds the SQLite schema migrations, named like those of the
+// PostgreSQL backend. They are versioned separately from it.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]store.Migration, error) {
+	return store.LoadMigrations(migrationFiles, "migrations")
+}
+
+// createMigrationsTable creates the table recording applied migrations.
+const createMigrationsTable = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`
+
+// schemaVersion returns the version of the last migration applied, or 0 for a
+// database that has never been migrated.
+func (s *Store) schemaVersion() (int, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchema returns an error wrapping store.ErrSchemaOutdated unless every
+// embedded migration has been applied.
+func (s *Store) CheckSchema() error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	version, err := s.schemaVersion()
+	if err != nil {
+		return err
+	}
+	return store.CheckVersion(version, migrations)
+}
+
+// MigrateUp applies every pending migration, each in its own transaction, and
+// returns those it applied.
+func (s *Store) MigrateUp() ([]store.Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []store.Migration
+	for _, m := range migrations {
+		ok, err := s.migrate(m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps migrations, newest first, and returns
+// those it reverted.
+func (s *Store) MigrateDown(steps int) ([]store.Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this binary", version)
+	}
+
+	var reverted []store.Migration
+	for v := version; v > 0 && len(reverted) < steps; v-- {
+		m := migrations[v-1]
+		ok, err := s.migrate(m, false)
+		if err != nil {
+			return reverted, err
+		}
+		if ok {
+			reverted = append(reverted, m)
+		}
+	}
+	return reverted, nil
+}
+
+// migrate applies (up) or reverts m in a transaction. Transactions take the
+// write lock when they begin, so other migrators wait, and it reports false if
+// one already did the work.
+func (s *Store) migrate(m store.Migration, up bool) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var applied bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	script, record := m.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, datetime('now'))`
+	if !up {
+		script, record = m.Down, `DELETE FROM schema_migrations WHERE version = $1 AND name = $2`
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return false, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(record, m.Version, m.Name); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// MigrationStatuses returns every embedded migration with the time it was
+// applied, if it was.
+func (s *Store) MigrationStatuses() ([]store.MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[int]time.Time{}
+	if version, err := s.schemaVersion(); err != nil {
+		return nil, err
+	} else if version > 0 {
+		rows, err := s.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+			}
+			appliedAt[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+
+	statuses := make([]store.MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if at, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
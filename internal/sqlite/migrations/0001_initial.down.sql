@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ip_geo;
+DROP TABLE IF EXISTS log_data;
+DROP TABLE IF EXISTS sensors;
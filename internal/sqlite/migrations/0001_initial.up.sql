@@ -0,0 +1,60 @@
+-- The schema of the PostgreSQL migrations up to log_data partitioning, in one
+-- step. Timestamps are stored as wall-clock text, "YYYY-MM-DD HH:MM:SS.ffffff",
+-- which sorts in time order.
+
+-- Sites or devices whose logs are collected in this database
+CREATE TABLE sensors (
+    id TEXT PRIMARY KEY,              -- Sensor ID from the [sensor] config, -sensor flag or syslog hostname
+    created_at TIMESTAMP NOT NULL DEFAULT (datetime('now', 'localtime'))
+);
+
+-- Entries without a sensor belong to the default one.
+INSERT INTO sensors (id) VALUES ('default');
+
+CREATE TABLE log_data (
+    id INTEGER PRIMARY KEY,           -- Unique identifier for each log entry
+    timestamp TIMESTAMP NOT NULL,     -- The exact time the log entry was recorded
+    source_ip TEXT NOT NULL,          -- The IP address from which the packet originated
+    destination_ip TEXT NOT NULL,     -- The IP address to which the packet was directed
+    protocol TEXT NOT NULL,           -- The protocol used (e.g., TCP, UDP, ICMP)
+    source_port INTEGER,              -- The source port of the packet
+    destination_port INTEGER,         -- The destination port of the packet
+    action TEXT,                      -- Tracks what was done to the packet
+    reason TEXT,                      -- Categorizes the attack or packet handling.
+    packet_length INTEGER,            -- The size of the packet. Useful for traffic pattern analysis
+    ttl INTEGER,                      -- Time-to-Live (TTL) value. Indicates distance or latency to the source
+    rules TEXT,                       -- Comma-separated names of the rules that flagged the entry
+    severity TEXT,                    -- Highest severity among those rules (low, medium, high, critical)
+    header TEXT,                      -- Optional header fields as JSON
+    hostname TEXT,                    -- Device that reported the entry, from the syslog header
+    program TEXT,                     -- Program or tag that logged it (e.g. "kernel", "L4 FIREWALL")
+    pid INTEGER,                      -- Process ID from the syslog tag, if any
+    facility INTEGER,                 -- Syslog facility, when received with a <PRI>
+    syslog_severity INTEGER,          -- Syslog severity (0-7), when received with a <PRI>
+    sensor_id TEXT NOT NULL DEFAULT 'default' REFERENCES sensors(id),
+    -- Prevents duplicate log entries
+    CONSTRAINT unique_log_entry UNIQUE (sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)
+);
+
+CREATE INDEX idx_log_timestamp ON log_data(timestamp);
+CREATE INDEX idx_log_source_ip ON log_data(source_ip);
+CREATE INDEX idx_log_destination_ip ON log_data(destination_ip);
+CREATE INDEX idx_log_action ON log_data(action);
+CREATE INDEX idx_log_reason ON log_data(reason);
+CREATE INDEX idx_log_severity ON log_data(severity);
+CREATE INDEX idx_log_tcp_flags ON log_data(json_extract(header, '$.tcp_flags'));
+CREATE INDEX idx_log_hostname ON log_data(hostname);
+CREATE INDEX idx_log_sensor_timestamp ON log_data(sensor_id, timestamp);
+
+-- Geolocation data for source IP addresses
+CREATE TABLE ip_geo (
+    id INTEGER PRIMARY KEY,
+    ip_address TEXT UNIQUE NOT NULL,
+    country TEXT,
+    region TEXT,
+    city TEXT,
+    isp TEXT,
+    latitude REAL,
+    longitude REAL,
+    last_updated TIMESTAMP DEFAULT (datetime('now', 'localtime'))
+);
@@ -0,0 +1,6 @@
+DROP INDEX idx_log_source_key;
+ALTER TABLE log_data DROP COLUMN source_key;
+
+UPDATE ip_geo SET last_updated = datetime(last_updated, 'localtime');
+UPDATE sensors SET created_at = datetime(created_at, 'localtime');
+UPDATE log_data SET timestamp = strftime('%Y-%m-%d %H:%M:%S', substr(timestamp, 1, 19), 'localtime') || substr(timestamp, 20);
@@ -0,0 +1,12 @@
+-- Store times in UTC instead of local wall-clock time, as PostgreSQL does with
+-- TIMESTAMPTZ. Only whole seconds are converted, since offsets are whole
+-- minutes, which keeps the microseconds of log timestamps.
+UPDATE log_data SET timestamp = strftime('%Y-%m-%d %H:%M:%S', substr(timestamp, 1, 19), 'utc') || substr(timestamp, 20);
+UPDATE sensors SET created_at = datetime(created_at, 'utc');
+UPDATE ip_geo SET last_updated = datetime(last_updated, 'utc');
+
+-- The 16-byte form of source_ip, IPv4 addresses mapped into IPv6, which sorts
+-- in address order so that a CIDR filter is an index range scan.
+ALTER TABLE log_data ADD COLUMN source_key BLOB;
+UPDATE log_data SET source_key = inet_key(source_ip);
+CREATE INDEX idx_log_source_key ON log_data(source_key);
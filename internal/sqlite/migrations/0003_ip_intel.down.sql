@@ -0,0 +1,16 @@
+CREATE TABLE ip_geo_old (
+    id INTEGER PRIMARY KEY,
+    ip_address TEXT UNIQUE NOT NULL,
+    country TEXT,
+    region TEXT,
+    city TEXT,
+    isp TEXT,
+    latitude REAL,
+    longitude REAL,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO ip_geo_old SELECT * FROM ip_geo;
+DROP TABLE ip_geo;
+ALTER TABLE ip_geo_old RENAME TO ip_geo;
+
+DROP TABLE IF EXISTS ip_intel;
@@ -0,0 +1,45 @@
+-- Activity of each source address, as in the PostgreSQL migration 0007. The
+-- port and reason sets are sorted JSON arrays.
+CREATE TABLE ip_intel (
+    ip_address TEXT PRIMARY KEY,
+    first_seen TIMESTAMP,                           -- Earliest flagged entry from the address
+    last_seen TIMESTAMP,                            -- Latest flagged entry from the address
+    total_hits INTEGER NOT NULL DEFAULT 0,          -- Number of flagged entries
+    destination_ports TEXT NOT NULL DEFAULT '[]',   -- Distinct ports targeted
+    reasons TEXT NOT NULL DEFAULT '[]'              -- Distinct reasons
+);
+
+CREATE INDEX idx_ip_intel_last_seen ON ip_intel(last_seen);
+
+INSERT INTO ip_intel (ip_address, first_seen, last_seen, total_hits, destination_ports, reasons)
+SELECT source_ip, MIN(timestamp), MAX(timestamp), COUNT(*),
+       (SELECT json_group_array(destination_port) FROM (
+           SELECT DISTINCT destination_port FROM log_data p
+           WHERE p.source_ip = l.source_ip AND destination_port > 0 ORDER BY destination_port)),
+       (SELECT json_group_array(reason) FROM (
+           SELECT DISTINCT reason FROM log_data r
+           WHERE r.source_ip = l.source_ip AND reason IS NOT NULL ORDER BY reason))
+FROM log_data l
+GROUP BY source_ip;
+
+-- WHERE true keeps SQLite from reading ON CONFLICT as part of the SELECT.
+INSERT INTO ip_intel (ip_address)
+SELECT ip_address FROM ip_geo
+WHERE true
+ON CONFLICT DO NOTHING;
+
+-- SQLite cannot add a foreign key to an existing table, so ip_geo is rebuilt.
+CREATE TABLE ip_geo_new (
+    id INTEGER PRIMARY KEY,
+    ip_address TEXT UNIQUE NOT NULL REFERENCES ip_intel(ip_address) ON DELETE CASCADE,
+    country TEXT,
+    region TEXT,
+    city TEXT,
+    isp TEXT,
+    latitude REAL,
+    longitude REAL,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO ip_geo_new SELECT * FROM ip_geo;
+DROP TABLE ip_geo;
+ALTER TABLE ip_geo_new RENAME TO ip_geo;
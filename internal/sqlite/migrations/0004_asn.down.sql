@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_ip_geo_asn;
+ALTER TABLE ip_geo DROP COLUMN asn;
+ALTER TABLE ip_geo DROP COLUMN as_org;
+ALTER TABLE ip_geo DROP COLUMN as_prefix;
+ALTER TABLE ip_geo DROP COLUMN hosting;
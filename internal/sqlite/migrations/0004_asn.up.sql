@@ -0,0 +1,11 @@
+-- The autonomous system announcing each address, and whether it belongs to a
+-- hosting provider or data center.
+ALTER TABLE ip_geo ADD COLUMN asn INTEGER;         -- NULL if unknown
+ALTER TABLE ip_geo ADD COLUMN as_org TEXT;
+ALTER TABLE ip_geo ADD COLUMN as_prefix TEXT;      -- Announced prefix containing the address, if known
+ALTER TABLE ip_geo ADD COLUMN hosting BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_ip_geo_asn ON ip_geo(asn);
+
+-- Existing data has no ASN yet; mark it stale so the refresher looks it up again.
+UPDATE ip_geo SET last_updated = NULL;
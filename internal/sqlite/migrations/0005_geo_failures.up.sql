@@ -0,0 +1,11 @@
+-- Failed geolocation lookups, so that addresses no provider has data for, such
+-- as private ones, are not looked up again on every run. A row is removed when
+-- data for its address is stored.
+CREATE TABLE geo_failures (
+    ip_address TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,
+    permanent BOOLEAN NOT NULL DEFAULT 0,       -- Retrying would fail the same way
+    attempts INTEGER NOT NULL DEFAULT 1,        -- Failed lookups in a row
+    last_attempt TIMESTAMP NOT NULL,
+    retry_after TIMESTAMP                       -- NULL never retries
+);
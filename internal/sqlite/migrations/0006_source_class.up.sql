@@ -0,0 +1,14 @@
+-- The class of each source address (public, private, loopback, multicast,
+-- bogon or own), so that only public addresses are looked up and entries can
+-- be filtered by it. Existing rows are classified by inet_class, without own
+-- networks, which are only known from the configuration.
+ALTER TABLE log_data ADD COLUMN source_class TEXT;
+UPDATE log_data SET source_class = inet_class(source_ip);
+CREATE INDEX idx_log_data_source_class ON log_data(source_class);
+
+ALTER TABLE ip_intel ADD COLUMN class TEXT; -- Class of the latest entries; NULL before any
+UPDATE ip_intel SET class = inet_class(ip_address) WHERE total_hits > 0;
+
+-- Lookups of addresses that are not public only returned empty data.
+DELETE FROM ip_geo WHERE inet_class(ip_address) <> 'public';
+DELETE FROM geo_failures WHERE inet_class(ip_address) <> 'public';
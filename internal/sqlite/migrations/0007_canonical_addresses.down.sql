@@ -0,0 +1,2 @@
+-- Canonical addresses are valid in the previous schema too; their original
+-- forms are not restored.
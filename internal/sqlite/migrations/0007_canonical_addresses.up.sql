@@ -0,0 +1,58 @@
+-- Addresses are stored in the canonical form of parser.ParseAddr, so that an
+-- IPv4 packet logged with an IPv4-mapped IPv6 address (::ffff:192.0.2.1) has
+-- one row per address. inet_text rewrites the rows stored before: the
+-- counters of a mapped address are merged into those of its IPv4 address, and
+-- its geolocation data is dropped to be looked up again under that address.
+INSERT INTO ip_intel (ip_address, class, first_seen, last_seen, total_hits, destination_ports, reasons)
+SELECT inet_text(ip_address), class, first_seen, last_seen, total_hits, destination_ports, reasons
+FROM ip_intel
+WHERE ip_address <> inet_text(ip_address)
+ON CONFLICT (ip_address) DO UPDATE SET
+    class = COALESCE(ip_intel.class, excluded.class),
+    first_seen = COALESCE(MIN(first_seen, excluded.first_seen), first_seen, excluded.first_seen),
+    last_seen = COALESCE(MAX(last_seen, excluded.last_seen), last_seen, excluded.last_seen),
+    total_hits = total_hits + excluded.total_hits,
+    destination_ports = (SELECT json_group_array(value) FROM (
+        SELECT value FROM json_each(ip_intel.destination_ports)
+        UNION SELECT value FROM json_each(excluded.destination_ports)
+        ORDER BY value)),
+    reasons = (SELECT json_group_array(value) FROM (
+        SELECT value FROM json_each(ip_intel.reasons)
+        UNION SELECT value FROM json_each(excluded.reasons)
+        ORDER BY value));
+
+-- Deleting from ip_intel cascades to ip_geo.
+DELETE FROM ip_intel WHERE ip_address <> inet_text(ip_address);
+DELETE FROM geo_failures WHERE ip_address <> inet_text(ip_address);
+
+-- Rewriting the addresses of an entry can make it a duplicate of another, so
+-- of each set of entries that only differ in the form of their addresses the
+-- one already canonical is kept, or else the first stored. Those dropped are
+-- taken out of the hits of their source.
+UPDATE ip_intel SET total_hits = total_hits - (
+    SELECT COUNT(*) FROM log_data m
+    WHERE inet_text(m.source_ip) = ip_intel.ip_address
+      AND (m.source_ip <> inet_text(m.source_ip) OR m.destination_ip <> inet_text(m.destination_ip))
+      AND EXISTS (
+        SELECT 1 FROM log_data l
+        WHERE l.sensor_id = m.sensor_id AND l.timestamp = m.timestamp
+          AND inet_text(l.source_ip) = inet_text(m.source_ip)
+          AND inet_text(l.destination_ip) = inet_text(m.destination_ip)
+          AND l.protocol = m.protocol AND l.source_port = m.source_port AND l.destination_port = m.destination_port
+          AND (l.source_ip = inet_text(l.source_ip) AND l.destination_ip = inet_text(l.destination_ip) OR l.id < m.id)))
+WHERE ip_address IN (
+    SELECT inet_text(source_ip) FROM log_data
+    WHERE source_ip <> inet_text(source_ip) OR destination_ip <> inet_text(destination_ip));
+
+DELETE FROM log_data AS m
+WHERE (m.source_ip <> inet_text(m.source_ip) OR m.destination_ip <> inet_text(m.destination_ip))
+  AND EXISTS (
+    SELECT 1 FROM log_data l
+    WHERE l.sensor_id = m.sensor_id AND l.timestamp = m.timestamp
+      AND inet_text(l.source_ip) = inet_text(m.source_ip)
+      AND inet_text(l.destination_ip) = inet_text(m.destination_ip)
+      AND l.protocol = m.protocol AND l.source_port = m.source_port AND l.destination_port = m.destination_port
+      AND (l.source_ip = inet_text(l.source_ip) AND l.destination_ip = inet_text(l.destination_ip) OR l.id < m.id));
+
+UPDATE log_data SET source_ip = inet_text(source_ip) WHERE source_ip <> inet_text(source_ip);
+UPDATE log_data SET destination_ip = inet_text(destination_ip) WHERE destination_ip <> inet_text(destination_ip);
@@ -0,0 +1,11 @@
+-- Threat intelligence reports, as in the PostgreSQL migration 0012. The
+-- categories are a sorted JSON array.
+CREATE TABLE ip_reputation (
+    ip_address TEXT NOT NULL REFERENCES ip_intel(ip_address) ON DELETE CASCADE,
+    provider TEXT NOT NULL,                     -- Such as "abuseipdb"
+    score INTEGER NOT NULL,                     -- 0 (no sign of abuse) to 100
+    categories TEXT NOT NULL DEFAULT '[]',      -- Kinds of abuse reported
+    last_reported TIMESTAMP,                    -- Latest report of abuse
+    fetched_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (ip_address, provider)
+);
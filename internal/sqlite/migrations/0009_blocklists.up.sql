@@ -0,0 +1,12 @@
+-- Blocklist feeds, as in the PostgreSQL migration 0013. Networks are stored
+-- as text, such as "203.0.113.0/24".
+CREATE TABLE blocklist_entries (
+    source TEXT NOT NULL,                       -- Feed name, such as "spamhaus_drop"
+    network TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,              -- When the feed was last fetched
+    PRIMARY KEY (source, network)
+);
+
+-- Comma-separated names of the lists the source address was on when the
+-- entry was inserted
+ALTER TABLE log_data ADD COLUMN blocklists TEXT;
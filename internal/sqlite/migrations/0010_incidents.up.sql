@@ -0,0 +1,19 @@
+-- Detected scans, as in the PostgreSQL migration 0014. Ports are stored as a
+-- JSON array, and source_key is the source address as a sortable key.
+CREATE TABLE incidents (
+    id INTEGER PRIMARY KEY,
+    sensor_id TEXT NOT NULL REFERENCES sensors(id),
+    source_ip TEXT NOT NULL,
+    source_key BLOB NOT NULL,
+    scan_type TEXT NOT NULL,                    -- vertical, horizontal or slow
+    start_time TIMESTAMP NOT NULL,              -- Timestamp of the first entry
+    end_time TIMESTAMP NOT NULL,                -- Timestamp of the last entry
+    ports TEXT NOT NULL,                        -- Distinct destination ports
+    hosts INTEGER NOT NULL,                     -- Distinct destination addresses
+    hits INTEGER NOT NULL,
+    -- A scan found again when logs are read again is merged into the same row
+    CONSTRAINT unique_incident UNIQUE (sensor_id, source_ip, scan_type, start_time)
+);
+
+CREATE INDEX idx_incidents_start_time ON incidents(start_time);
+CREATE INDEX idx_incidents_source_key ON incidents(source_key);
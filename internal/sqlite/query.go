@@ -0,0 +1,254 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"strconv"
+	"strings"
+)
+
+// Logs returns stored events, newest first. A source prefix is matched as a
+// range of source_key.
+func (s *Store) Logs(q store.LogQuery) ([]parser.LogEvent, error) {
+	var conds []string
+	var args []interface{}
+	if q.Sensor != "" {
+		args = append(args, q.Sensor)
+		conds = append(conds, fmt.Sprintf("sensor_id = $%d", len(args)))
+	}
+	if q.SourceCIDR.IsValid() {
+		first, last := prefixRange(q.SourceCIDR)
+		args = append(args, first, last)
+		conds = append(conds, fmt.Sprintf("source_key BETWEEN $%d AND $%d", len(args)-1, len(args)))
+	}
+	if q.SourceClass != "" {
+		args = append(args, q.SourceClass)
+		conds = append(conds, fmt.Sprintf("source_class = $%d", len(args)))
+	}
+
+	query := `SELECT ` + store.LogEventColumns + ` FROM log_data`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY timestamp DESC LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := []parser.LogEvent{}
+	for rows.Next() {
+		ev, err := store.ScanLogEvent(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log entry: %w", err)
+		}
+		logs = append(logs, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	return logs, nil
+}
+
+// Geo returns the geolocation data stored for ip.
+func (s *Store) Geo(ip, sensor string) (*geo.Data, error) {
+	query := `SELECT ` + strings.Join(store.GeoColumns, ", ") + ` FROM ip_geo g WHERE g.ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+	var row store.GeoRow
+	err := s.db.QueryRow(query, args...).Scan(row.Dest()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geolocation data for IP %s: %w", ip, err)
+	}
+	return row.Data(), nil
+}
+
+// Sensors returns each sensor with its log count and the time range of its logs.
+func (s *Store) Sensors(sensor string) ([]store.Sensor, error) {
+	query := `
+		SELECT s.id, s.created_at, COUNT(l.id), MIN(l.timestamp), MAX(l.timestamp)
+		FROM sensors s
+		LEFT JOIN log_data l ON l.sensor_id = s.id`
+	var args []interface{}
+	if sensor != "" {
+		query += ` WHERE s.id = $1`
+		args = append(args, sensor)
+	}
+	rows, err := s.db.Query(query+`
+		GROUP BY s.id, s.created_at
+		ORDER BY s.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []store.Sensor{}
+	for rows.Next() {
+		var sensor store.Sensor
+		var first, last sql.NullString
+		if err := rows.Scan(&sensor.ID, &sensor.CreatedAt, &sensor.LogCount, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to read sensor: %w", err)
+		}
+		if first.Valid {
+			from, err := parseTime(first.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read sensor %s: %w", sensor.ID, err)
+			}
+			to, err := parseTime(last.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read sensor %s: %w", sensor.ID, err)
+			}
+			sensor.FirstLog, sensor.LastLog = &from, &to
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	return sensors, nil
+}
+
+// Stats reports the size of the database file, the row count and size of each
+// table, and the log_data partitions. Table sizes, including their indexes,
+// are -1 if SQLite was built without the dbstat table.
+func (s *Store) Stats() (store.Stats, error) {
+	var stats store.Stats
+	err := s.db.QueryRow(`SELECT page_count * page_size FROM pragma_page_count, pragma_page_size`).Scan(&stats.DatabaseSize)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("failed to list tables: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	for _, name := range names {
+		t := store.TableStats{Name: name, Size: -1}
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM ` + quoteIdentifier(name)).Scan(&t.Rows); err != nil {
+			return stats, fmt.Errorf("failed to count rows of %s: %w", name, err)
+		}
+		var size sql.NullInt64
+		err := s.db.QueryRow(`
+			SELECT SUM(pgsize) FROM dbstat
+			WHERE name IN (SELECT name FROM sqlite_master WHERE tbl_name = $1)`, name).Scan(&size)
+		if err == nil && size.Valid {
+			t.Size = size.Int64
+		}
+		stats.Tables = append(stats.Tables, t)
+	}
+
+	if stats.Partitions, err = s.Partitions(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// quoteIdentifier quotes a table name for use in SQL.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Partitions returns the intervals that hold log entries, in time order, with
+// their row counts. Intervals without entries are left out.
+func (s *Store) Partitions() ([]store.Partition, error) {
+	var first, last sql.NullString
+	if err := s.db.QueryRow(`SELECT MIN(timestamp), MAX(timestamp) FROM log_data`).Scan(&first, &last); err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	if !first.Valid {
+		return nil, nil
+	}
+	from, err := parseTime(first.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	end, err := parseTime(last.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var partitions []store.Partition
+	for from = s.interval.Start(from); !from.After(end); from = s.interval.Next(from) {
+		p := store.Partition{Name: "log_data_p" + from.Format("20060102"), From: from, To: s.interval.Next(from), Size: -1}
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM log_data WHERE timestamp >= $1 AND timestamp < $2`,
+			timeText(p.From), timeText(p.To)).Scan(&p.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", p.Name, err)
+		}
+		if p.Rows > 0 {
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions, nil
+}
+
+// untrackIntelSQL recomputes the ip_intel counters of the source addresses
+// with entries from $1 to $2 from their other entries, before those are
+// deleted, keeping the lowest store.MaxIntelPorts ports. Addresses left
+// without entries keep their class and geolocation data, with no hits.
+var untrackIntelSQL = `
+    UPDATE ip_intel SET
+        first_seen = (SELECT MIN(timestamp) FROM log_data l
+            WHERE l.source_ip = ip_intel.ip_address AND NOT (l.timestamp >= $1 AND l.timestamp < $2)),
+        last_seen = (SELECT MAX(timestamp) FROM log_data l
+            WHERE l.source_ip = ip_intel.ip_address AND NOT (l.timestamp >= $1 AND l.timestamp < $2)),
+        total_hits = (SELECT COUNT(*) FROM log_data l
+            WHERE l.source_ip = ip_intel.ip_address AND NOT (l.timestamp >= $1 AND l.timestamp < $2)),
+        destination_ports = (SELECT json_group_array(destination_port) FROM (
+            SELECT DISTINCT destination_port FROM log_data l
+            WHERE l.source_ip = ip_intel.ip_address AND NOT (l.timestamp >= $1 AND l.timestamp < $2)
+                AND destination_port > 0
+            ORDER BY destination_port LIMIT ` + strconv.Itoa(store.MaxIntelPorts) + `)),
+        reasons = (SELECT json_group_array(reason) FROM (
+            SELECT DISTINCT reason FROM log_data l
+            WHERE l.source_ip = ip_intel.ip_address AND NOT (l.timestamp >= $1 AND l.timestamp < $2)
+                AND reason IS NOT NULL
+            ORDER BY reason))
+    WHERE ip_address IN (SELECT source_ip FROM log_data WHERE timestamp >= $1 AND timestamp < $2)`
+
+// DropPartition deletes the log entries of a partition, and takes them out of
+// the ip_intel counters.
+func (s *Store) DropPartition(p store.Partition) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	from, to := timeText(p.From), timeText(p.To)
+	if _, err := tx.Exec(untrackIntelSQL, from, to); err != nil {
+		return fmt.Errorf("failed to update intel for partition %s: %w", p.Name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM log_data WHERE timestamp >= $1 AND timestamp < $2`, from, to); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+	return nil
+}
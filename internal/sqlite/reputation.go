@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minerva/internal/threatintel"
+	"time"
+)
+
+// ReputationFetchedAt returns when provider's report on ip was fetched, or the
+// zero Time if there is none.
+func (s *Store) ReputationFetchedAt(ip, provider string) (time.Time, error) {
+	var fetched string
+	err := s.db.QueryRow(`SELECT CAST(fetched_at AS TEXT) FROM ip_reputation WHERE ip_address = $1 AND provider = $2`, ip, provider).Scan(&fetched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	t, err := parseTime(fetched)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+	}
+	return t, nil
+}
+
+// InsertOrUpdateReputation stores a report on ip, replacing the previous
+// report of the same provider. The address is added to ip_intel first if its
+// entries have not been inserted yet.
+func (s *Store) InsertOrUpdateReputation(ip string, r *threatintel.Report) error {
+	categories, err := json.Marshal(r.Categories)
+	if err != nil {
+		return fmt.Errorf("failed to encode categories: %w", err)
+	}
+	if r.Categories == nil {
+		categories = []byte("[]")
+	}
+	var lastReported sql.NullString
+	if r.LastReported != nil {
+		lastReported = sql.NullString{String: timeText(*r.LastReported), Valid: true}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO ip_intel (ip_address) VALUES ($1) ON CONFLICT DO NOTHING`, ip); err != nil {
+		return fmt.Errorf("failed to insert intel for IP %s: %w", ip, err)
+	}
+	_, err = tx.Exec(`
+    INSERT INTO ip_reputation (ip_address, provider, score, categories, last_reported, fetched_at)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (ip_address, provider) DO UPDATE SET
+        score = excluded.score,
+        categories = excluded.categories,
+        last_reported = excluded.last_reported,
+        fetched_at = excluded.fetched_at`,
+		ip, r.Provider, r.Score, string(categories), lastReported, timeText(r.FetchedAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert or update reputation of IP %s: %w", ip, err)
+	}
+	return nil
+}
+
+// Reputation returns the threat intelligence reports on ip, ordered by
+// provider.
+func (s *Store) Reputation(ip, sensor string) ([]threatintel.Report, error) {
+	query := `
+		SELECT provider, score, categories, CAST(last_reported AS TEXT), CAST(fetched_at AS TEXT)
+		FROM ip_reputation WHERE ip_address = $1`
+	args := []interface{}{ip}
+	if sensor != "" {
+		query += ` AND EXISTS (SELECT 1 FROM log_data WHERE sensor_id = $2 AND source_ip = $1)`
+		args = append(args, sensor)
+	}
+	rows, err := s.db.Query(query+` ORDER BY provider`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	defer rows.Close()
+
+	reports := []threatintel.Report{}
+	for rows.Next() {
+		var r threatintel.Report
+		var categories, fetched string
+		var lastReported sql.NullString
+		if err := rows.Scan(&r.Provider, &r.Score, &categories, &lastReported, &fetched); err != nil {
+			return nil, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+		}
+		if err := json.Unmarshal([]byte(categories), &r.Categories); err != nil {
+			return nil, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+		}
+		if r.FetchedAt, err = parseTime(fetched); err != nil {
+			return nil, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+		}
+		if lastReported.Valid {
+			t, err := parseTime(lastReported.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read reputation of IP %s: %w", ip, err)
+			}
+			r.LastReported = &t
+		}
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query reputation of IP %s: %w", ip, err)
+	}
+	return reports, nil
+}
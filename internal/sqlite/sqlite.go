@@ -0,0 +1,275 @@
+// Package sqlite stores minerva's data in a single SQLite file, for small
+// deployments that run without a database server. It uses a pure-Go driver,
+// so it needs no C toolchain.
+//
+// SQLite has no table partitioning. Partitions are instead the ranges of
+// log_data covered by each retention interval, and dropping one deletes its
+// rows.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minerva/internal/config"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // SQLite driver
+)
+
+func init() {
+	store.Register("sqlite", Open)
+}
+
+var _ store.Store = (*Store)(nil)
+
+// timeLayout is the format of stored timestamps. It is fixed-width so that
+// timestamps compare and sort as text.
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+// Store is the SQLite implementation of store.Store.
+type Store struct {
+	db       *sql.DB
+	interval store.PartitionInterval
+}
+
+// Open opens the SQLite database at the [storage] path, creating the file and
+// its directory if needed.
+func Open(conf *config.Config) (store.Store, error) {
+	interval, err := store.ParsePartitionInterval(conf.Retention.PartitionInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention configuration: %w", err)
+	}
+	return OpenFile(conf.Storage.Path, interval)
+}
+
+// OpenFile opens the SQLite database at path. Retention drops entries in
+// ranges of interval.
+func OpenFile(path string, interval store.PartitionInterval) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// Transactions take the write lock up front, so that concurrent writers wait
+	// for each other instead of failing to upgrade a read lock.
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection serializes them
+	// within this process. Queries must therefore not be nested.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return &Store{db: db, interval: interval}, nil
+}
+
+// timeText returns the stored text of a timestamp, in UTC.
+func timeText(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseTime parses a stored timestamp returned as text, such as the result of
+// MIN or MAX, which the driver does not convert. The driver itself reads
+// timestamp columns as UTC.
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(timeLayout, s)
+}
+
+// InsertSensor registers a sensor in the sensors table if it is not there yet.
+func (s *Store) InsertSensor(id string) error {
+	_, err := s.db.Exec(`INSERT INTO sensors (id, created_at) VALUES ($1, datetime('now')) ON CONFLICT (id) DO NOTHING`, id)
+	if err != nil {
+		return fmt.Errorf("failed to insert sensor %s: %w", id, err)
+	}
+	return nil
+}
+
+// logEntryColumns are store.LogEntryColumns and the SQLite-only source_key.
+var logEntryColumns = append(store.LogEntryColumns[:len(store.LogEntryColumns):len(store.LogEntryColumns)], "source_key")
+
+// insertLogEntrySQL inserts one event, skipping it if it is already stored.
+var insertLogEntrySQL = func() string {
+	placeholders := make([]string, len(logEntryColumns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return `INSERT INTO log_data (` + strings.Join(logEntryColumns, ", ") + `)
+        VALUES (` + strings.Join(placeholders, ", ") + `)
+        ON CONFLICT ` + store.LogEntryConflict + ` DO NOTHING`
+}()
+
+// logEntryValues returns the values of logEntryColumns for ev, with the
+// timestamp as text.
+func logEntryValues(ev parser.LogEvent) ([]interface{}, error) {
+	values, err := store.LogEntryValues(ev)
+	if err != nil {
+		return nil, err
+	}
+	values[0] = timeText(ev.Timestamp)
+	return append(values, addrKey(ev.SourceIP)), nil
+}
+
+// InsertLogEntry inserts a parsed log event into log_data and adds it to the
+// ip_intel counters. It returns 0 if the event was already stored.
+func (s *Store) InsertLogEntry(ev parser.LogEvent) (int64, error) {
+	return s.InsertLogEntries([]parser.LogEvent{ev})
+}
+
+// InsertLogEntries inserts a batch of events in one transaction, skipping
+// duplicates, and adds those inserted to the ip_intel counters. It returns the
+// number of rows inserted. Any invalid event fails the whole batch.
+func (s *Store) InsertLogEntries(events []parser.LogEvent) (rowsInserted int64, err error) {
+	rows := make([][]interface{}, len(events))
+	for i, ev := range events {
+		if rows[i], err = logEntryValues(ev); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin batch insert: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	stmt, err := tx.Prepare(insertLogEntrySQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+	for i, row := range rows {
+		result, err := stmt.Exec(row...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert log entry: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to retrieve affected row count: %w", err)
+		}
+		if n > 0 {
+			if err := trackIntel(tx, events[i]); err != nil {
+				return 0, err
+			}
+		}
+		rowsInserted += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit batch insert: %w", err)
+	}
+	return rowsInserted, nil
+}
+
+// IsIPInGeoTable checks whether the given IP address exists in the ip_geo table.
+func (s *Store) IsIPInGeoTable(ip string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM ip_geo WHERE ip_address = $1)`, ip).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check geo table for IP %s: %w", ip, err)
+	}
+	return exists, nil
+}
+
+// InsertOrUpdateGeoData inserts or updates geolocation data for an IP address,
+// and clears its failed lookup. The address is added to ip_intel first if its
+// entries have not been inserted yet.
+func (s *Store) InsertOrUpdateGeoData(ip string, geoData *geo.Data) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO ip_intel (ip_address) VALUES ($1) ON CONFLICT DO NOTHING`, ip); err != nil {
+		return fmt.Errorf("failed to insert intel for IP %s: %w", ip, err)
+	}
+
+	_, err = tx.Exec(`
+    INSERT INTO ip_geo (
+        ip_address, country, region, city, isp, latitude, longitude,
+        asn, as_org, as_prefix, hosting, last_updated
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, datetime('now'))
+    ON CONFLICT (ip_address) DO UPDATE SET
+        country = excluded.country,
+        region = excluded.region,
+        city = excluded.city,
+        isp = excluded.isp,
+        latitude = excluded.latitude,
+        longitude = excluded.longitude,
+        asn = excluded.asn,
+        as_org = excluded.as_org,
+        as_prefix = excluded.as_prefix,
+        hosting = excluded.hosting,
+        last_updated = excluded.last_updated`,
+		append([]interface{}{ip}, store.GeoValues(geoData)...)...)
+	if err != nil {
+		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM geo_failures WHERE ip_address = $1`, ip); err != nil {
+		return fmt.Errorf("failed to clear failed lookup of IP %s: %w", ip, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert or update geolocation data for IP %s: %w", ip, err)
+	}
+	return nil
+}
+
+// GeoFailure returns the last failed lookup of ip, or nil if there is none.
+func (s *Store) GeoFailure(ip string) (*geo.Failure, error) {
+	var f geo.Failure
+	var retryAfter sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT reason, permanent, attempts, last_attempt, retry_after
+		FROM geo_failures WHERE ip_address = $1`, ip).Scan(&f.Reason, &f.Permanent, &f.Attempts, &f.LastAttempt, &retryAfter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed lookup of IP %s: %w", ip, err)
+	}
+	f.RetryAfter = retryAfter.Time
+	return &f, nil
+}
+
+// RecordGeoFailure stores a failed lookup of ip, replacing the previous one.
+func (s *Store) RecordGeoFailure(ip string, f geo.Failure) error {
+	var retryAfter sql.NullString
+	if !f.RetryAfter.IsZero() {
+		retryAfter = sql.NullString{String: timeText(f.RetryAfter), Valid: true}
+	}
+	_, err := s.db.Exec(`
+    INSERT INTO geo_failures (ip_address, reason, permanent, attempts, last_attempt, retry_after)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (ip_address) DO UPDATE SET
+        reason = excluded.reason,
+        permanent = excluded.permanent,
+        attempts = excluded.attempts,
+        last_attempt = excluded.last_attempt,
+        retry_after = excluded.retry_after`,
+		ip, f.Reason, f.Permanent, f.Attempts, timeText(f.LastAttempt), retryAfter)
+	if err != nil {
+		return fmt.Errorf("failed to record failed lookup of IP %s: %w", ip, err)
+	}
+	return nil
+}
+
+// Maintain does nothing: entries need no partitions to be created ahead.
+func (s *Store) Maintain(now time.Time) error {
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,796 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"minerva/internal/blocklist"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/scan"
+	"minerva/internal/store"
+	"minerva/internal/threatintel"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// openTestStore returns a migrated store in a temporary file.
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := OpenFile(filepath.Join(t.TempDir(), "minerva.db"), store.Monthly)
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate the database: %v", err)
+	}
+	return s
+}
+
+// migrateDownTo reverts migrations until version is the latest one applied.
+func migrateDownTo(t *testing.T, s *Store, version int) {
+	t.Helper()
+	statuses, err := s.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.MigrateDown(len(statuses) - version); err != nil {
+		t.Fatalf("Failed to revert to migration %d: %v", version, err)
+	}
+}
+
+func testEvent(ts time.Time) parser.LogEvent {
+	return parser.LogEvent{
+		Timestamp:       ts,
+		SourceIP:        netip.MustParseAddr("192.0.2.1"),
+		DestinationIP:   netip.MustParseAddr("203.0.113.5"),
+		SourcePort:      12345,
+		DestinationPort: 80,
+		Protocol:        "TCP",
+		Action:          "DROP",
+		Rules:           []string{"ssh", "scan"},
+		Severity:        "high",
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	s, err := OpenFile(filepath.Join(t.TempDir(), "minerva.db"), store.Monthly)
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CheckSchema(); !errors.Is(err, store.ErrSchemaOutdated) {
+		t.Errorf("Expected a new database to be outdated, got %v", err)
+	}
+	applied, err := s.MigrateUp()
+	if err != nil || len(applied) == 0 {
+		t.Fatalf("Expected migrations to be applied, got %v, %v", applied, err)
+	}
+	if err := s.CheckSchema(); err != nil {
+		t.Errorf("Expected the schema to be current, got %v", err)
+	}
+	if applied, err := s.MigrateUp(); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing left to apply, got %v, %v", applied, err)
+	}
+
+	statuses, err := s.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, st := range statuses {
+		if st.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", st.Version)
+		}
+	}
+
+	if reverted, err := s.MigrateDown(len(statuses)); err != nil || len(reverted) != len(statuses) {
+		t.Fatalf("Expected every migration to be reverted, got %v, %v", reverted, err)
+	}
+	if _, err := s.MigrateUp(); err != nil {
+		t.Errorf("Expected the migrations to apply again, got %v", err)
+	}
+}
+
+func TestInsertLogEntries(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 123456000, time.UTC)
+	ev := testEvent(ts)
+	other := ev
+	other.DestinationPort = 443
+
+	inserted, err := s.InsertLogEntries([]parser.LogEvent{ev, ev, other})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("Expected 2 rows inserted, got %d", inserted)
+	}
+	if n, err := s.InsertLogEntry(ev); err != nil || n != 0 {
+		t.Errorf("Expected the stored event to be skipped, got %d, %v", n, err)
+	}
+	if _, err := s.InsertLogEntries([]parser.LogEvent{ev, {}}); err == nil {
+		t.Error("Expected an error for a batch with an invalid event")
+	}
+
+	unknown := ev
+	unknown.Sensor = "no-such-site"
+	if _, err := s.InsertLogEntry(unknown); err == nil {
+		t.Error("Expected an error for an unregistered sensor")
+	}
+
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(logs))
+	}
+	got := logs[0]
+	if !got.Timestamp.Equal(ts) || got.Sensor != store.DefaultSensor || got.DestinationIP != ev.DestinationIP ||
+		len(got.Rules) != 2 || got.Severity != "high" {
+		t.Errorf("Unexpected log %+v", got)
+	}
+}
+
+func TestSensors(t *testing.T) {
+	s := openTestStore(t)
+
+	if err := s.InsertSensor("home"); err != nil {
+		t.Fatalf("Failed to register sensor: %v", err)
+	}
+	if err := s.InsertSensor("home"); err != nil {
+		t.Fatalf("Expected registering a sensor twice to succeed, got %v", err)
+	}
+	ev := testEvent(time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC))
+	ev.Sensor = "home"
+	if _, err := s.InsertLogEntry(ev); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sensors, err := s.Sensors("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sensors) != 2 || sensors[0].ID != store.DefaultSensor || sensors[1].ID != "home" {
+		t.Fatalf("Expected the default and home sensors, got %+v", sensors)
+	}
+	home := sensors[1]
+	if home.LogCount != 1 || home.FirstLog == nil || !home.FirstLog.Equal(ev.Timestamp) || home.CreatedAt.IsZero() {
+		t.Errorf("Unexpected sensor %+v", home)
+	}
+
+	if sensors, err := s.Sensors("none"); err != nil || len(sensors) != 0 {
+		t.Errorf("Expected no sensors, got %+v, %v", sensors, err)
+	}
+	if logs, err := s.Logs(store.LogQuery{Sensor: "home", Limit: 10}); err != nil || len(logs) != 1 {
+		t.Errorf("Expected one log for home, got %d, %v", len(logs), err)
+	}
+}
+
+func TestGeo(t *testing.T) {
+	s := openTestStore(t)
+
+	data := &geo.Data{Country: "United States", City: "San Francisco", Latitude: 37.7, ASN: 64500, ASOrg: "Example", ASPrefix: "192.0.2.0/24", Hosting: true}
+	if err := s.InsertOrUpdateGeoData("192.0.2.1", data); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	data.City, data.ASN, data.ASOrg, data.ASPrefix, data.Hosting = "Los Angeles", 0, "", "", false
+	if err := s.InsertOrUpdateGeoData("192.0.2.1", data); err != nil {
+		t.Fatalf("Failed to update geolocation data: %v", err)
+	}
+	if ok, err := s.IsIPInGeoTable("192.0.2.1"); err != nil || !ok {
+		t.Errorf("Expected the IP to be in the geo table, got %v, %v", ok, err)
+	}
+
+	got, err := s.Geo("192.0.2.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *got != *data {
+		t.Errorf("Expected %+v, got %+v", data, got)
+	}
+	if _, err := s.Geo("192.0.2.1", store.DefaultSensor); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an IP not in the sensor's logs, got %v", err)
+	}
+	if _, err := s.Geo("198.51.100.7", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	s := openTestStore(t)
+
+	jan := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	mar := time.Date(2025, 3, 2, 8, 0, 0, 0, time.UTC)
+	if _, err := s.InsertLogEntries([]parser.LogEvent{testEvent(jan), testEvent(jan.Add(time.Hour)), testEvent(mar)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	partitions, err := s.Partitions()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(partitions) != 2 || partitions[0].Name != "log_data_p20250101" || partitions[0].Rows != 2 ||
+		partitions[1].Name != "log_data_p20250301" || partitions[1].Rows != 1 {
+		t.Fatalf("Unexpected partitions %+v", partitions)
+	}
+
+	expired, err := store.ExpiredPartitions(s, time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("Expected January to be expired, got %+v, %v", expired, err)
+	}
+	if err := s.DropPartition(expired[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs, err := s.Logs(store.LogQuery{Limit: 10}); err != nil || len(logs) != 1 || !logs[0].Timestamp.Equal(mar) {
+		t.Errorf("Expected only the March entry to remain, got %+v, %v", logs, err)
+	}
+
+	// The profile of the source address only covers the remaining entry.
+	profile, err := s.IPProfile("192.0.2.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.TotalHits != 1 || profile.FirstSeen == nil || !profile.FirstSeen.Equal(mar) || !profile.LastSeen.Equal(mar) ||
+		fmt.Sprint(profile.DestinationPorts) != "[80]" {
+		t.Errorf("Expected the profile of the March entry, got %+v", profile)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.DatabaseSize <= 0 || len(stats.Tables) == 0 || len(stats.Partitions) != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	for _, table := range stats.Tables {
+		if table.Name == "log_data" && table.Rows != 1 {
+			t.Errorf("Expected 1 row in log_data, got %d", table.Rows)
+		}
+	}
+
+	// Without entries left, an address has no hits.
+	if err := s.DropPartition(partitions[1]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile, err := s.IPProfile("192.0.2.1", ""); err != nil || profile.TotalHits != 0 || profile.FirstSeen != nil ||
+		len(profile.DestinationPorts) != 0 || len(profile.Reasons) != 0 {
+		t.Errorf("Expected an empty profile, got %+v, %v", profile, err)
+	}
+}
+
+func TestLogsSourceCIDR(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	var events []parser.LogEvent
+	for _, src := range []string{"203.0.113.7", "203.0.113.200", "203.0.114.1", "2001:db8::1"} {
+		ev := testEvent(ts)
+		ev.SourceIP = netip.MustParseAddr(src)
+		events = append(events, ev)
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		cidr     string
+		expected int
+	}{
+		{"203.0.113.0/24", 2},
+		{"203.0.113.7/32", 1},
+		{"203.0.0.0/16", 3},
+		{"0.0.0.0/0", 3},
+		{"2001:db8::/32", 1},
+		{"198.51.100.0/24", 0},
+	}
+	for _, tc := range tests {
+		logs, err := s.Logs(store.LogQuery{SourceCIDR: netip.MustParsePrefix(tc.cidr), Limit: 10})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(logs) != tc.expected {
+			t.Errorf("%s: expected %d logs, got %d", tc.cidr, tc.expected, len(logs))
+		}
+	}
+}
+
+func TestTimestampZone(t *testing.T) {
+	s := openTestStore(t)
+
+	// 23:30 in New York is already the next day in UTC.
+	ts := time.Date(2025, 1, 31, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	if _, err := s.InsertLogEntry(testEvent(ts)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 1 || !logs[0].Timestamp.Equal(ts) {
+		t.Fatalf("Expected the entry to keep its instant, got %+v, %v", logs, err)
+	}
+	partitions, err := s.Partitions()
+	if err != nil || len(partitions) != 1 || partitions[0].Name != "log_data_p20250201" {
+		t.Errorf("Expected the entry in the February partition, got %+v, %v", partitions, err)
+	}
+}
+
+func TestIPProfile(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	ev := testEvent(ts)
+	ev.Reason = "PORTSCAN"
+	later := testEvent(ts.Add(time.Hour))
+	later.DestinationPort = 22
+	later.Reason = "DROP-IN"
+	icmp := testEvent(ts.Add(-time.Hour))
+	icmp.Protocol, icmp.DestinationPort, icmp.Reason = "ICMP", 0, "PORTSCAN"
+
+	// The duplicate is not counted.
+	if _, err := s.InsertLogEntries([]parser.LogEvent{ev, ev, later}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.InsertLogEntry(icmp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	profile, err := s.IPProfile("192.0.2.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.TotalHits != 3 || !profile.FirstSeen.Equal(icmp.Timestamp) || !profile.LastSeen.Equal(later.Timestamp) || profile.Geo != nil {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+	if fmt.Sprint(profile.DestinationPorts) != "[22 80]" || fmt.Sprint(profile.Reasons) != "[DROP-IN PORTSCAN]" {
+		t.Errorf("Unexpected ports %v and reasons %v", profile.DestinationPorts, profile.Reasons)
+	}
+
+	// The class is that of the latest entry, not of the last one inserted.
+	newer, older := testEvent(ts), testEvent(ts.Add(-time.Hour))
+	newer.SourceIP, newer.SourceClass = netip.MustParseAddr("198.51.100.9"), "bogon"
+	older.SourceIP, older.SourceClass = newer.SourceIP, "public"
+	if _, err := s.InsertLogEntries([]parser.LogEvent{newer, older}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile, err := s.IPProfile("198.51.100.9", ""); err != nil || profile.Class != "bogon" {
+		t.Errorf("Expected the class of the latest entry, got %+v, %v", profile, err)
+	}
+
+	// Ports are recorded up to store.MaxIntelPorts.
+	var events []parser.LogEvent
+	for port := store.MaxIntelPorts + 10; port > 0; port-- {
+		e := testEvent(ts)
+		e.SourceIP, e.DestinationPort = netip.MustParseAddr("198.51.100.10"), uint16(port)
+		events = append(events, e)
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	profile, err = s.IPProfile("198.51.100.10", "")
+	if err != nil || len(profile.DestinationPorts) != store.MaxIntelPorts || !sort.IntsAreSorted(profile.DestinationPorts) {
+		t.Errorf("Expected %d sorted ports, got %v, %v", store.MaxIntelPorts, profile, err)
+	}
+
+	if err := s.InsertOrUpdateGeoData("192.0.2.1", &geo.Data{Country: "Germany"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	if profile, err := s.IPProfile("192.0.2.1", store.DefaultSensor); err != nil || profile.Geo == nil || profile.Geo.Country != "Germany" {
+		t.Errorf("Expected the profile with its geolocation, got %+v, %v", profile, err)
+	}
+
+	// Geolocation data can arrive before the entries are inserted.
+	if err := s.InsertOrUpdateGeoData("198.51.100.7", &geo.Data{Country: "France"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	profile, err = s.IPProfile("198.51.100.7", "")
+	if err != nil || profile.TotalHits != 0 || profile.FirstSeen != nil || len(profile.DestinationPorts) != 0 {
+		t.Errorf("Expected a profile without hits, got %+v, %v", profile, err)
+	}
+	if _, err := s.IPProfile("198.51.100.7", store.DefaultSensor); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an IP not in the sensor's logs, got %v", err)
+	}
+}
+
+func TestStaleGeoIPs(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	var events []parser.LogEvent
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		ev := testEvent(ts.Add(time.Duration(i) * time.Hour))
+		ev.SourceIP = netip.MustParseAddr(ip)
+		events = append(events, ev)
+		if err := s.InsertOrUpdateGeoData(ip, &geo.Data{Country: "Germany"}); err != nil {
+			t.Fatalf("Failed to insert geolocation data: %v", err)
+		}
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.db.Exec(`UPDATE ip_geo SET last_updated = '2025-01-01 00:00:00' WHERE ip_address != '192.0.2.2'`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cutoff := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	ips, err := s.StaleGeoIPs(cutoff, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The most recently seen address comes first.
+	if fmt.Sprint(ips) != "[192.0.2.3 192.0.2.1]" {
+		t.Errorf("Expected the stale addresses, most recent first, got %v", ips)
+	}
+	if ips, err := s.StaleGeoIPs(cutoff, 1); err != nil || fmt.Sprint(ips) != "[192.0.2.3]" {
+		t.Errorf("Expected one stale address, got %v, %v", ips, err)
+	}
+}
+
+func TestGeoFailures(t *testing.T) {
+	s := openTestStore(t)
+
+	if f, err := s.GeoFailure("10.0.0.1"); err != nil || f != nil {
+		t.Fatalf("Expected no failure, got %+v, %v", f, err)
+	}
+
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	failure := geo.Failure{Reason: "private range", Permanent: true, Attempts: 2, LastAttempt: now}
+	if err := s.RecordGeoFailure("10.0.0.1", failure); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	failure.RetryAfter = now.Add(time.Hour)
+	if err := s.RecordGeoFailure("10.0.0.1", failure); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f, err := s.GeoFailure("10.0.0.1")
+	if err != nil || f == nil || *f != failure {
+		t.Fatalf("Expected %+v, got %+v, %v", failure, f, err)
+	}
+
+	// A stale address is skipped until its failed lookup is due.
+	if err := s.InsertOrUpdateGeoData("192.0.2.1", &geo.Data{Country: "Germany"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	failure.RetryAfter = time.Now().Add(time.Hour)
+	if err := s.RecordGeoFailure("192.0.2.1", failure); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ips, err := s.StaleGeoIPs(time.Now().Add(time.Minute), 0); err != nil || len(ips) != 0 {
+		t.Errorf("Expected no stale address due, got %v, %v", ips, err)
+	}
+	failure.RetryAfter = time.Now().Add(-time.Hour)
+	if err := s.RecordGeoFailure("192.0.2.1", failure); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ips, err := s.StaleGeoIPs(time.Now().Add(time.Minute), 0); err != nil || fmt.Sprint(ips) != "[192.0.2.1]" {
+		t.Errorf("Expected the stale address once due, got %v, %v", ips, err)
+	}
+
+	// Storing data clears the failure.
+	if err := s.InsertOrUpdateGeoData("192.0.2.1", &geo.Data{Country: "Germany"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	if f, err := s.GeoFailure("192.0.2.1"); err != nil || f != nil {
+		t.Errorf("Expected the failure to be cleared, got %+v, %v", f, err)
+	}
+}
+
+func TestTopASNs(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	var events []parser.LogEvent
+	for i, ip := range []string{"192.0.2.1", "192.0.2.1", "192.0.2.2", "198.51.100.1", "203.0.113.1"} {
+		ev := testEvent(ts.Add(time.Duration(i) * time.Hour))
+		ev.SourceIP = netip.MustParseAddr(ip)
+		if ip == "198.51.100.1" {
+			ev.Sensor = "office"
+		}
+		events = append(events, ev)
+	}
+	if err := s.InsertSensor("office"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.InsertLogEntries(events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for ip, data := range map[string]*geo.Data{
+		"192.0.2.1":    {ASN: 64500, ASOrg: "Example Hosting", Hosting: true},
+		"192.0.2.2":    {ASN: 64500, ASOrg: "Example Hosting"},
+		"198.51.100.1": {ASN: 64501, ASOrg: "Other"},
+		"203.0.113.1":  {Country: "France"},
+	} {
+		if err := s.InsertOrUpdateGeoData(ip, data); err != nil {
+			t.Fatalf("Failed to insert geolocation data: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    store.ASNQuery
+		expected []store.ASNStats
+	}{
+		{"All", store.ASNQuery{Limit: 10}, []store.ASNStats{
+			{ASN: 64500, Org: "Example Hosting", Sources: 2, HostingSources: 1, Hits: 3},
+			{ASN: 64501, Org: "Other", Sources: 1, Hits: 1},
+		}},
+		{"Limit", store.ASNQuery{Limit: 1}, []store.ASNStats{
+			{ASN: 64500, Org: "Example Hosting", Sources: 2, HostingSources: 1, Hits: 3},
+		}},
+		{"Sensor", store.ASNQuery{Sensor: "office", Limit: 10}, []store.ASNStats{
+			{ASN: 64501, Org: "Other", Sources: 1, Hits: 1},
+		}},
+		{"Since", store.ASNQuery{Since: ts.Add(90 * time.Minute), Limit: 10}, []store.ASNStats{
+			{ASN: 64500, Org: "Example Hosting", Sources: 1, Hits: 1},
+			{ASN: 64501, Org: "Other", Sources: 1, Hits: 1},
+		}},
+	}
+	for _, tc := range tests {
+		asns, err := s.TopASNs(tc.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if fmt.Sprint(asns) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.expected, asns)
+		}
+	}
+}
+
+func TestSourceClass(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	public := testEvent(ts)
+	public.SourceIP, public.SourceClass = netip.MustParseAddr("8.8.8.8"), "public"
+	private := testEvent(ts.Add(time.Minute))
+	private.SourceIP, private.SourceClass = netip.MustParseAddr("10.0.0.1"), "private"
+	if _, err := s.InsertLogEntries([]parser.LogEvent{public, private}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	logs, err := s.Logs(store.LogQuery{SourceClass: "public", Limit: 10})
+	if err != nil || len(logs) != 1 || logs[0].SourceIP != public.SourceIP || logs[0].SourceClass != "public" {
+		t.Errorf("Expected the public entry, got %+v, %v", logs, err)
+	}
+	if profile, err := s.IPProfile("10.0.0.1", ""); err != nil || profile.Class != "private" {
+		t.Errorf("Expected a private profile, got %+v, %v", profile, err)
+	}
+
+	// The migration classifies existing rows and drops the geolocation data of
+	// addresses that are not public.
+	if err := s.InsertOrUpdateGeoData("10.0.0.1", &geo.Data{}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+	migrateDownTo(t, s, 5)
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logs, err = s.Logs(store.LogQuery{SourceClass: "private", Limit: 10})
+	if err != nil || len(logs) != 1 || logs[0].SourceIP != private.SourceIP {
+		t.Errorf("Expected the private entry to be classified again, got %+v, %v", logs, err)
+	}
+	if ok, err := s.IsIPInGeoTable("10.0.0.1"); err != nil || ok {
+		t.Errorf("Expected the private address's geolocation data to be dropped, got %v, %v", ok, err)
+	}
+}
+
+func TestCanonicalAddresses(t *testing.T) {
+	s := openTestStore(t)
+
+	// Entries stored before addresses were canonical, one of them with an
+	// IPv4-mapped source address that has geolocation data.
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	plain := testEvent(ts)
+	plain.SourceIP, plain.Reason = netip.MustParseAddr("8.8.8.8"), "PORTSCAN"
+	mapped := testEvent(ts.Add(time.Minute))
+	mapped.SourceIP, mapped.DestinationIP = netip.MustParseAddr("::ffff:8.8.8.8"), netip.MustParseAddr("::ffff:203.0.113.5")
+	mapped.DestinationPort, mapped.Reason = 22, "MALFORMED-PACKET"
+	// Duplicates of both, which only differ in the form of their addresses.
+	dupSource, dupDestination := plain, mapped
+	dupSource.SourceIP = netip.MustParseAddr("::ffff:8.8.8.8")
+	dupDestination.DestinationIP = plain.DestinationIP
+	if _, err := s.InsertLogEntries([]parser.LogEvent{plain, mapped, dupSource, dupDestination}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.InsertOrUpdateGeoData("::ffff:8.8.8.8", &geo.Data{Country: "United States"}); err != nil {
+		t.Fatalf("Failed to insert geolocation data: %v", err)
+	}
+
+	migrateDownTo(t, s, 6)
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 2 {
+		t.Fatalf("Expected two entries, got %+v, %v", logs, err)
+	}
+	for _, l := range logs {
+		if l.SourceIP != plain.SourceIP || l.DestinationIP != plain.DestinationIP {
+			t.Errorf("Expected canonical addresses, got %v and %v", l.SourceIP, l.DestinationIP)
+		}
+	}
+	profile, err := s.IPProfile("8.8.8.8", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.TotalHits != 2 || profile.LastSeen == nil || !profile.LastSeen.Equal(mapped.Timestamp) || fmt.Sprint(profile.DestinationPorts) != "[22 80]" {
+		t.Errorf("Expected the mapped address's counters to be merged, got %+v", profile)
+	}
+	if _, err := s.IPProfile("::ffff:8.8.8.8", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the mapped address to be gone, got %v", err)
+	}
+	if ok, err := s.IsIPInGeoTable("::ffff:8.8.8.8"); err != nil || ok {
+		t.Errorf("Expected the mapped address's geolocation data to be dropped, got %v, %v", ok, err)
+	}
+}
+
+func TestReputation(t *testing.T) {
+	s := openTestStore(t)
+
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	if _, err := s.InsertLogEntry(testEvent(ts)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fetched, err := s.ReputationFetchedAt("192.0.2.1", "abuseipdb"); err != nil || !fetched.IsZero() {
+		t.Errorf("Expected no report yet, got %v, %v", fetched, err)
+	}
+
+	reported := ts.Add(-time.Hour)
+	reports := []*threatintel.Report{
+		{Provider: "virustotal", Score: 10, Categories: []string{"malware"}, FetchedAt: ts},
+		{Provider: "abuseipdb", Score: 40, Categories: []string{"port-scan"}, LastReported: &reported, FetchedAt: ts},
+		{Provider: "abuseipdb", Score: 87, Categories: []string{"port-scan", "ssh"}, LastReported: &reported, FetchedAt: ts.Add(time.Hour)},
+	}
+	for _, r := range reports {
+		if err := s.InsertOrUpdateReputation("192.0.2.1", r); err != nil {
+			t.Fatalf("Failed to insert reputation: %v", err)
+		}
+	}
+
+	if fetched, err := s.ReputationFetchedAt("192.0.2.1", "abuseipdb"); err != nil || !fetched.Equal(ts.Add(time.Hour)) {
+		t.Errorf("Expected the latest fetch time, got %v, %v", fetched, err)
+	}
+	got, err := s.Reputation("192.0.2.1", store.DefaultSensor)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected a report per provider, got %+v, %v", got, err)
+	}
+	if got[0].Provider != "abuseipdb" || got[0].Score != 87 || fmt.Sprint(got[0].Categories) != "[port-scan ssh]" ||
+		got[0].LastReported == nil || !got[0].LastReported.Equal(reported) {
+		t.Errorf("Expected the replaced AbuseIPDB report, got %+v", got[0])
+	}
+	if got[1].Provider != "virustotal" || got[1].LastReported != nil {
+		t.Errorf("Expected the VirusTotal report, got %+v", got[1])
+	}
+
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 1 || logs[0].ThreatScore == nil || *logs[0].ThreatScore != 87 {
+		t.Errorf("Expected the highest score on the entry, got %+v, %v", logs, err)
+	}
+
+	// Reports can arrive before the entries are inserted.
+	if err := s.InsertOrUpdateReputation("198.51.100.7", &threatintel.Report{Provider: "abuseipdb", FetchedAt: ts}); err != nil {
+		t.Fatalf("Failed to insert reputation: %v", err)
+	}
+	if got, err := s.Reputation("198.51.100.7", ""); err != nil || len(got) != 1 || got[0].Categories == nil {
+		t.Errorf("Expected a clean report, got %+v, %v", got, err)
+	}
+	if got, err := s.Reputation("198.51.100.7", store.DefaultSensor); err != nil || len(got) != 0 {
+		t.Errorf("Expected no reports for an IP not in the sensor's logs, got %+v, %v", got, err)
+	}
+}
+
+func TestBlocklists(t *testing.T) {
+	s := openTestStore(t)
+
+	updated := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	drop := blocklist.List{
+		Name:      "spamhaus_drop",
+		Networks:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8::/32")},
+		UpdatedAt: updated,
+	}
+	et := blocklist.List{Name: "et_compromised", Networks: []netip.Prefix{netip.MustParsePrefix("198.51.100.7/32")}, UpdatedAt: updated}
+	for _, l := range []blocklist.List{drop, et} {
+		if err := s.ReplaceBlocklist(l); err != nil {
+			t.Fatalf("Failed to store blocklist: %v", err)
+		}
+	}
+
+	// Replacing a list drops the networks it no longer has.
+	drop.Networks, drop.UpdatedAt = drop.Networks[:1], updated.Add(time.Hour)
+	if err := s.ReplaceBlocklist(drop); err != nil {
+		t.Fatalf("Failed to store blocklist: %v", err)
+	}
+	lists, err := s.Blocklists()
+	if err != nil || len(lists) != 2 {
+		t.Fatalf("Expected two lists, got %+v, %v", lists, err)
+	}
+	if lists[1].Name != "spamhaus_drop" || fmt.Sprint(lists[1].Networks) != "[203.0.113.0/24]" || !lists[1].UpdatedAt.Equal(drop.UpdatedAt) {
+		t.Errorf("Expected the replaced list, got %+v", lists[1])
+	}
+	updatedAt, err := s.BlocklistsUpdatedAt()
+	if err != nil || len(updatedAt) != 2 || !updatedAt["et_compromised"].Equal(updated) {
+		t.Errorf("Expected when each list was fetched, got %v, %v", updatedAt, err)
+	}
+
+	if err := s.ReplaceBlocklist(blocklist.List{Name: "et_compromised"}); err != nil {
+		t.Fatalf("Failed to delete blocklist: %v", err)
+	}
+	if lists, err := s.Blocklists(); err != nil || len(lists) != 1 {
+		t.Errorf("Expected the empty list to be deleted, got %+v, %v", lists, err)
+	}
+
+	// Entries keep the lists their source address was on.
+	ev := testEvent(updated)
+	ev.Blocklists = []string{"firehol_level1", "spamhaus_drop"}
+	if _, err := s.InsertLogEntry(ev); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logs, err := s.Logs(store.LogQuery{Limit: 10})
+	if err != nil || len(logs) != 1 || fmt.Sprint(logs[0].Blocklists) != "[firehol_level1 spamhaus_drop]" {
+		t.Errorf("Expected the entry's blocklists, got %+v, %v", logs, err)
+	}
+}
+
+func TestIncidents(t *testing.T) {
+	s := openTestStore(t)
+
+	start := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	incidents := []scan.Incident{
+		{Sensor: store.DefaultSensor, SourceIP: netip.MustParseAddr("203.0.113.7"), Type: scan.Vertical,
+			Start: start, End: start.Add(30 * time.Second), Ports: []int{22, 80, 443}, Hosts: 1, Hits: 25},
+		{Sensor: store.DefaultSensor, SourceIP: netip.MustParseAddr("2001:db8::7"), Type: scan.Slow,
+			Start: start.Add(time.Hour), End: start.Add(7 * time.Hour), Ports: []int{3389}, Hosts: 40, Hits: 40},
+	}
+	for _, inc := range incidents {
+		if err := s.InsertIncident(inc); err != nil {
+			t.Fatalf("Failed to store incident: %v", err)
+		}
+	}
+	if err := s.InsertIncident(scan.Incident{Sensor: "unknown", SourceIP: netip.MustParseAddr("192.0.2.1"), Type: scan.Vertical}); err == nil {
+		t.Error("Expected an error for an unknown sensor")
+	}
+
+	// Reading the same logs again finds the same scan, which is merged.
+	again := incidents[0]
+	again.End, again.Ports, again.Hits = start.Add(40*time.Second), []int{22, 8080}, 30
+	if err := s.InsertIncident(again); err != nil {
+		t.Fatalf("Failed to store incident: %v", err)
+	}
+
+	got, err := s.Incidents(store.IncidentQuery{Limit: 10})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected two incidents, got %+v, %v", got, err)
+	}
+	slow := got[0]
+	if slow.ID == 0 || slow.Type != scan.Slow || slow.SourceIP != incidents[1].SourceIP || !slow.Start.Equal(incidents[1].Start) ||
+		!slow.End.Equal(incidents[1].End) || fmt.Sprint(slow.Ports) != "[3389]" || slow.Hosts != 40 || slow.Hits != 40 {
+		t.Errorf("Expected the slow scan first, got %+v", slow)
+	}
+	if vertical := got[1]; !vertical.End.Equal(again.End) || fmt.Sprint(vertical.Ports) != "[22 80 443 8080]" || vertical.Hits != 30 {
+		t.Errorf("Expected the vertical scan to be merged, got %+v", vertical)
+	}
+
+	tests := []struct {
+		name     string
+		query    store.IncidentQuery
+		expected int
+	}{
+		{"Type", store.IncidentQuery{Type: scan.Vertical}, 1},
+		{"Source", store.IncidentQuery{SourceCIDR: netip.MustParsePrefix("203.0.113.0/24")}, 1},
+		{"Since", store.IncidentQuery{Since: start.Add(time.Hour)}, 1},
+		{"Sensor", store.IncidentQuery{Sensor: "edge"}, 0},
+		{"Offset", store.IncidentQuery{Offset: 1}, 1},
+	}
+	for _, tc := range tests {
+		tc.query.Limit = 10
+		if got, err := s.Incidents(tc.query); err != nil || len(got) != tc.expected {
+			t.Errorf("%s: expected %d incidents, got %+v, %v", tc.name, tc.expected, got, err)
+		}
+	}
+}
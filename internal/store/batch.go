@@ -0,0 +1,116 @@
+package store
+
+import (
+	"minerva/internal/parser"
+	"time"
+)
+
+// BatchResult is the outcome of writing one batch of events.
+type BatchResult struct {
+	Events     []parser.LogEvent
+	Inserted   int64 // Events stored
+	Duplicates int64 // Events skipped because they were already stored
+	Failed     int64 // Events that could not be stored
+	Err        error // The last error, if any event failed
+}
+
+// BatchWriter collects events and inserts them with Store.InsertLogEntries once
+// size events are queued or window has passed since the first one, whichever
+// comes first.
+//
+// If a batch fails, for example because one event is invalid, its events are
+// retried one at a time so that a single bad event does not lose the others.
+type BatchWriter struct {
+	size    int
+	window  time.Duration
+	onWrite func(BatchResult)
+
+	// insertBatch and insertOne write to the store; tests replace them.
+	insertBatch func([]parser.LogEvent) (int64, error)
+	insertOne   func(parser.LogEvent) (int64, error)
+
+	events chan parser.LogEvent
+	done   chan struct{}
+}
+
+// NewBatchWriter starts a BatchWriter that writes to s. onWrite is called from
+// the writer's goroutine after every batch.
+func NewBatchWriter(s Store, size int, window time.Duration, onWrite func(BatchResult)) *BatchWriter {
+	w := &BatchWriter{
+		size:        size,
+		window:      window,
+		onWrite:     onWrite,
+		insertBatch: s.InsertLogEntries,
+		insertOne:   s.InsertLogEntry,
+		events:      make(chan parser.LogEvent, size),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues an event. It is safe for concurrent use but must not be called
+// after Close.
+func (w *BatchWriter) Add(ev parser.LogEvent) {
+	w.events <- ev
+}
+
+// Close writes the events still queued and waits for the last batch.
+func (w *BatchWriter) Close() {
+	close(w.events)
+	<-w.done
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+
+	timer := time.NewTimer(w.window)
+	timer.Stop()
+
+	var batch []parser.LogEvent
+	for {
+		select {
+		case ev, ok := <-w.events:
+			if !ok {
+				w.write(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(w.window)
+			}
+			batch = append(batch, ev)
+			if len(batch) < w.size {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+		w.write(batch)
+		batch = nil
+	}
+}
+
+// write stores a batch and reports the result.
+func (w *BatchWriter) write(batch []parser.LogEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	result := BatchResult{Events: batch}
+	inserted, err := w.insertBatch(batch)
+	if err == nil {
+		result.Inserted = inserted
+	} else {
+		for _, ev := range batch {
+			n, err := w.insertOne(ev)
+			if err != nil {
+				result.Failed++
+				result.Err = err
+				continue
+			}
+			result.Inserted += n
+		}
+	}
+	result.Duplicates = int64(len(batch)) - result.Inserted - result.Failed
+	w.onWrite(result)
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"errors"
+	"minerva/internal/parser"
+	"net/netip"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBatchWriter returns a BatchWriter whose inserts succeed without a database,
+// reporting every event as inserted, and the batches it has written so far.
+func fakeBatchWriter(size int, window time.Duration) (*BatchWriter, func() []BatchResult) {
+	var mu sync.Mutex
+	var results []BatchResult
+	w := &BatchWriter{
+		size:   size,
+		window: window,
+		onWrite: func(r BatchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, r)
+		},
+		insertBatch: func(events []parser.LogEvent) (int64, error) {
+			return int64(len(events)), nil
+		},
+		events: make(chan parser.LogEvent, size),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, func() []BatchResult {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]BatchResult(nil), results...)
+	}
+}
+
+func TestBatchWriter_Size(t *testing.T) {
+	w, results := fakeBatchWriter(2, time.Hour)
+	for i := 0; i < 5; i++ {
+		w.Add(parser.LogEvent{TTL: i})
+	}
+	w.Close()
+
+	var sizes []int
+	for _, r := range results() {
+		sizes = append(sizes, len(r.Events))
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 2, 1}) {
+		t.Errorf("Expected batches of [2 2 1], got %v", sizes)
+	}
+}
+
+func TestBatchWriter_Window(t *testing.T) {
+	w, results := fakeBatchWriter(100, 10*time.Millisecond)
+	defer w.Close()
+
+	w.Add(parser.LogEvent{})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(results()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the batch to be written once the window passed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if r := results()[0]; len(r.Events) != 1 || r.Inserted != 1 {
+		t.Errorf("Expected one inserted event, got %+v", r)
+	}
+}
+
+func TestBatchWriter_Fallback(t *testing.T) {
+	w, results := fakeBatchWriter(3, time.Hour)
+	w.insertBatch = func([]parser.LogEvent) (int64, error) {
+		return 0, errors.New("batch failed")
+	}
+	stored := map[netip.Addr]bool{}
+	w.insertOne = func(ev parser.LogEvent) (int64, error) {
+		if ev.Timestamp.IsZero() {
+			return 0, errors.New("invalid timestamp")
+		}
+		if stored[ev.DestinationIP] {
+			return 0, nil
+		}
+		stored[ev.DestinationIP] = true
+		return 1, nil
+	}
+
+	dst := netip.MustParseAddr("203.0.113.5")
+	w.Add(parser.LogEvent{Timestamp: time.Now(), DestinationIP: dst})
+	w.Add(parser.LogEvent{Timestamp: time.Now(), DestinationIP: dst})
+	w.Add(parser.LogEvent{DestinationIP: dst})
+	w.Close()
+
+	r := results()
+	if len(r) != 1 {
+		t.Fatalf("Expected one batch, got %d", len(r))
+	}
+	if r[0].Inserted != 1 || r[0].Duplicates != 1 || r[0].Failed != 1 || r[0].Err == nil {
+		t.Errorf("Expected 1 inserted, 1 duplicate and 1 failed, got %+v", r[0])
+	}
+}
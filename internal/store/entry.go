@@ -0,0 +1,206 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"minerva/internal/parser"
+)
+
+// LogEntryColumns are the log_data columns written for each event, in the order
+// of the values returned by LogEntryValues. Both SQL backends share them.
+var LogEntryColumns = []string{
+	"timestamp", "source_ip", "destination_ip", "protocol",
+	"source_port", "destination_port", "action", "reason",
+	"packet_length", "ttl", "rules", "severity", "header",
+	"hostname", "program", "pid", "facility", "syslog_severity", "sensor_id",
+	"source_class", "blocklists",
+}
+
+// LogEntryConflict lists the columns of the unique_log_entry constraint that
+// identifies duplicates.
+const LogEntryConflict = "(sensor_id, timestamp, source_ip, destination_ip, protocol, source_port, destination_port)"
+
+// LogEntryValues validates ev and returns the values of LogEntryColumns for
+// it. Optional header fields are encoded as JSON, and events without a sensor
+// are stored under DefaultSensor.
+func LogEntryValues(ev parser.LogEvent) ([]interface{}, error) {
+	// Basic validation to enforce mandatory fields.
+	if ev.Timestamp.IsZero() {
+		return nil, fmt.Errorf("invalid timestamp")
+	}
+	if !ev.SourceIP.IsValid() {
+		return nil, fmt.Errorf("invalid source IP")
+	}
+	if !ev.DestinationIP.IsValid() {
+		return nil, fmt.Errorf("invalid destination IP")
+	}
+
+	header, err := json.Marshal(ev.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode header fields: %w", err)
+	}
+
+	return []interface{}{
+		ev.Timestamp,
+		Inet(ev.SourceIP),
+		Inet(ev.DestinationIP),
+		ev.Protocol,
+		int(ev.SourcePort),
+		int(ev.DestinationPort),
+		ev.Action,
+		ev.Reason,
+		ev.PacketLength,
+		ev.TTL,
+		strings.Join(ev.Rules, ","),
+		ev.Severity,
+		string(header),
+		nullString(ev.Syslog.Hostname),
+		nullString(ev.Syslog.Program),
+		nullInt(ev.Syslog.PID),
+		nullUint8(ev.Syslog.Facility),
+		nullUint8(ev.Syslog.Severity),
+		SensorID(ev.Sensor),
+		nullString(ev.SourceClass),
+		nullString(strings.Join(ev.Blocklists, ",")),
+	}, nil
+}
+
+// Inet stores a netip.Addr in a PostgreSQL INET column, or as text where
+// there is no such type. The zero Addr is stored as NULL.
+type Inet netip.Addr
+
+// Value implements driver.Valuer.
+func (a Inet) Value() (driver.Value, error) {
+	addr := netip.Addr(a)
+	if !addr.IsValid() {
+		return nil, nil
+	}
+	return addr.String(), nil
+}
+
+// Scan implements sql.Scanner. PostgreSQL returns an INET value with a prefix
+// length unless it covers a single host, so only the address is kept. Text
+// that is not an address, such as "unknown" in rows stored before addresses
+// were validated, scans as the zero Addr.
+func (a *Inet) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*a = Inet{}
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an IP address", src)
+	}
+
+	if addr, err := netip.ParseAddr(text); err == nil {
+		*a = Inet(addr)
+	} else if prefix, err := netip.ParsePrefix(text); err == nil {
+		*a = Inet(prefix.Addr())
+	} else {
+		*a = Inet{}
+	}
+	return nil
+}
+
+// SensorID returns the sensor to store an event under.
+func SensorID(sensor string) string {
+	if sensor == "" {
+		return DefaultSensor
+	}
+	return sensor
+}
+
+// nullString maps an empty string to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt maps zero to SQL NULL.
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// nullUint8 maps a missing optional value to SQL NULL.
+func nullUint8(v *uint8) sql.NullInt16 {
+	if v == nil {
+		return sql.NullInt16{}
+	}
+	return sql.NullInt16{Int16: int16(*v), Valid: true}
+}
+
+// LogEventColumns are the log_data columns read by ScanLogEvent, in order,
+// followed by the highest threat intelligence score of the source address.
+const LogEventColumns = `sensor_id, timestamp, source_ip, destination_ip, source_port, destination_port,
+	protocol, action, reason, packet_length, ttl, rules, severity, header,
+	hostname, program, pid, facility, syslog_severity, source_class, blocklists,
+	(SELECT MAX(score) FROM ip_reputation r WHERE r.ip_address = log_data.source_ip)`
+
+// ScanLogEvent reads a row of LogEventColumns into a LogEvent. scan is the Scan
+// method of *sql.Rows or *sql.Row.
+func ScanLogEvent(scan func(dest ...interface{}) error) (parser.LogEvent, error) {
+	var (
+		ev               parser.LogEvent
+		srcPort, dstPort sql.NullInt64
+		action, reason   sql.NullString
+		length, ttl      sql.NullInt64
+		matched, sev     sql.NullString
+		header           []byte
+		host, program    sql.NullString
+		pid              sql.NullInt64
+		facility, sysSev sql.NullInt16
+		class, lists     sql.NullString
+		threatScore      sql.NullInt64
+	)
+	if err := scan(&ev.Sensor, &ev.Timestamp, (*Inet)(&ev.SourceIP), (*Inet)(&ev.DestinationIP), &srcPort, &dstPort,
+		&ev.Protocol, &action, &reason, &length, &ttl, &matched, &sev, &header,
+		&host, &program, &pid, &facility, &sysSev, &class, &lists, &threatScore); err != nil {
+		return parser.LogEvent{}, err
+	}
+	ev.SourcePort = uint16(srcPort.Int64)
+	ev.DestinationPort = uint16(dstPort.Int64)
+	ev.Action = action.String
+	ev.Reason = reason.String
+	ev.PacketLength = int(length.Int64)
+	ev.TTL = int(ttl.Int64)
+	if matched.String != "" {
+		ev.Rules = strings.Split(matched.String, ",")
+	}
+	ev.Severity = sev.String
+	ev.Syslog.Hostname = host.String
+	ev.Syslog.Program = program.String
+	ev.Syslog.PID = int(pid.Int64)
+	ev.Syslog.Facility = optionalUint8(facility)
+	ev.Syslog.Severity = optionalUint8(sysSev)
+	ev.SourceClass = class.String
+	if lists.String != "" {
+		ev.Blocklists = strings.Split(lists.String, ",")
+	}
+	if threatScore.Valid {
+		score := int(threatScore.Int64)
+		ev.ThreatScore = &score
+	}
+	if len(header) > 0 {
+		if err := json.Unmarshal(header, &ev.Header); err != nil {
+			return parser.LogEvent{}, err
+		}
+	}
+	return ev, nil
+}
+
+// optionalUint8 converts a nullable small integer column to an optional value.
+func optionalUint8(n sql.NullInt16) *uint8 {
+	if !n.Valid {
+		return nil
+	}
+	v := uint8(n.Int16)
+	return &v
+}
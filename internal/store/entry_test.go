@@ -0,0 +1,40 @@
+package store
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestInet(t *testing.T) {
+	tests := []struct {
+		src      interface{}
+		expected netip.Addr
+	}{
+		{"203.0.113.5", netip.MustParseAddr("203.0.113.5")},
+		{[]byte("2001:db8::1"), netip.MustParseAddr("2001:db8::1")},
+		{"203.0.113.5/24", netip.MustParseAddr("203.0.113.5")},
+		{"unknown", netip.Addr{}},
+		{nil, netip.Addr{}},
+	}
+
+	for _, tc := range tests {
+		var a Inet
+		if err := a.Scan(tc.src); err != nil {
+			t.Errorf("Scan(%v): unexpected error: %v", tc.src, err)
+		} else if netip.Addr(a) != tc.expected {
+			t.Errorf("Scan(%v): expected %v, got %v", tc.src, tc.expected, netip.Addr(a))
+		}
+	}
+
+	var a Inet
+	if err := a.Scan(42); err == nil {
+		t.Error("Expected an error scanning an integer")
+	}
+
+	if v, err := Inet(netip.MustParseAddr("203.0.113.5")).Value(); err != nil || v != "203.0.113.5" {
+		t.Errorf("Expected the address text, got %v, %v", v, err)
+	}
+	if v, err := (Inet{}).Value(); err != nil || v != nil {
+		t.Errorf("Expected NULL for the zero address, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"database/sql"
+
+	"minerva/internal/geo"
+)
+
+// GeoColumns are the ip_geo columns holding geo.Data, in the order of the
+// values returned by GeoValues, qualified with the table alias g.
+var GeoColumns = []string{
+	"g.country", "g.region", "g.city", "g.isp", "g.latitude", "g.longitude",
+	"g.asn", "g.as_org", "g.as_prefix", "g.hosting",
+}
+
+// GeoValues returns the values of GeoColumns for data. An unknown AS number or
+// prefix is stored as NULL.
+func GeoValues(data *geo.Data) []interface{} {
+	return []interface{}{
+		data.Country,
+		data.Region,
+		data.City,
+		data.ISP,
+		data.Latitude,
+		data.Longitude,
+		sql.NullInt64{Int64: int64(data.ASN), Valid: data.ASN != 0},
+		data.ASOrg,
+		nullString(data.ASPrefix),
+		data.Hosting,
+	}
+}
+
+// GeoRow scans GeoColumns, which are all NULL for an address without
+// geolocation data in a LEFT JOIN.
+type GeoRow struct {
+	country, region, city, isp, asOrg, asPrefix sql.NullString
+	latitude, longitude                         sql.NullFloat64
+	asn                                         sql.NullInt64
+	hosting                                     sql.NullBool
+}
+
+// Dest returns the scan destinations of GeoColumns.
+func (r *GeoRow) Dest() []interface{} {
+	return []interface{}{
+		&r.country, &r.region, &r.city, &r.isp, &r.latitude, &r.longitude,
+		&r.asn, &r.asOrg, &r.asPrefix, &r.hosting,
+	}
+}
+
+// Data returns the scanned geolocation data.
+func (r *GeoRow) Data() *geo.Data {
+	return &geo.Data{
+		Country:   r.country.String,
+		Region:    r.region.String,
+		City:      r.city.String,
+		ISP:       r.isp.String,
+		Latitude:  r.latitude.Float64,
+		Longitude: r.longitude.Float64,
+		ASN:       uint32(r.asn.Int64),
+		ASOrg:     r.asOrg.String,
+		ASPrefix:  r.asPrefix.String,
+		Hosting:   r.hosting.Bool,
+	}
+}
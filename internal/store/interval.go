@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PartitionInterval is the time range covered by each partition of log entries.
+type PartitionInterval string
+
+const (
+	Daily   PartitionInterval = "day"
+	Weekly  PartitionInterval = "week"
+	Monthly PartitionInterval = "month"
+)
+
+// ParsePartitionInterval parses a partition interval; "" means Monthly.
+func ParsePartitionInterval(s string) (PartitionInterval, error) {
+	switch i := PartitionInterval(strings.ToLower(s)); i {
+	case "":
+		return Monthly, nil
+	case Daily, Weekly, Monthly:
+		return i, nil
+	}
+	return "", fmt.Errorf("unknown partition interval %q (expected day, week or month)", s)
+}
+
+// Start returns the start of the interval containing t. Intervals are aligned
+// to UTC, and weeks start on Monday.
+func (i PartitionInterval) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch i {
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Weekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the interval after the one starting at start.
+func (i PartitionInterval) Next(start time.Time) time.Time {
+	switch i {
+	case Daily:
+		return start.AddDate(0, 0, 1)
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionInterval(t *testing.T) {
+	// A Wednesday evening in New York, which is already Thursday in UTC.
+	ts := time.Date(2025, 1, 15, 23, 30, 0, 0, time.FixedZone("", -5*60*60))
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		interval    PartitionInterval
+		start, next time.Time
+	}{
+		{Daily, day(2025, 1, 16), day(2025, 1, 17)},
+		{Weekly, day(2025, 1, 13), day(2025, 1, 20)},
+		{Monthly, day(2025, 1, 1), day(2025, 2, 1)},
+	}
+
+	for _, tc := range tests {
+		start := tc.interval.Start(ts)
+		if !start.Equal(tc.start) {
+			t.Errorf("%s: expected start %v, got %v", tc.interval, tc.start, start)
+		}
+		if next := tc.interval.Next(start); !next.Equal(tc.next) {
+			t.Errorf("%s: expected next %v, got %v", tc.interval, tc.next, next)
+		}
+	}
+
+	// Sunday belongs to the week that started on the Monday before.
+	if start := Weekly.Start(day(2025, 1, 19)); !start.Equal(day(2025, 1, 13)) {
+		t.Errorf("Expected the week of Sunday 2025-01-19 to start on 2025-01-13, got %v", start)
+	}
+}
+
+func TestParsePartitionInterval(t *testing.T) {
+	for input, expected := range map[string]PartitionInterval{"": Monthly, "day": Daily, "Week": Weekly, "month": Monthly} {
+		if i, err := ParsePartitionInterval(input); err != nil || i != expected {
+			t.Errorf("ParsePartitionInterval(%q): expected %s, got %s, %v", input, expected, i, err)
+		}
+	}
+	if _, err := ParsePartitionInterval("year"); err == nil {
+		t.Error("Expected an error for an unknown interval")
+	}
+}
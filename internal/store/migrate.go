@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied, and when.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the migrations in dir, named NNNN_description.up.sql
+// and NNNN_description.down.sql. It checks that versions run from 1 without
+// gaps and that each has both an up and a down script.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		versionText, description, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionText)
+		if !ok || !found || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		script, err := fs.ReadFile(fsys, dir+"/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: description}
+			byVersion[version] = m
+		} else if m.Name != description {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, description)
+		}
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) needs both an up and a down script", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// CheckVersion returns an error wrapping ErrSchemaOutdated unless version is
+// the last of migrations. A schema newer than this binary is also rejected,
+// since the binary may not know how to use it.
+func CheckVersion(version int, migrations []Migration) error {
+	if latest := len(migrations); version != latest {
+		return fmt.Errorf("%w: at version %d, this binary expects %d", ErrSchemaOutdated, version, latest)
+	}
+	return nil
+}
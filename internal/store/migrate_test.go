@@ -0,0 +1,31 @@
+package store
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Errors(t *testing.T) {
+	script := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"Bad file name", fstest.MapFS{"m/initial.up.sql": script}},
+		{"Bad direction", fstest.MapFS{"m/0001_initial.sideways.sql": script}},
+		{"Missing down", fstest.MapFS{"m/0001_initial.up.sql": script}},
+		{"Gap", fstest.MapFS{
+			"m/0001_initial.up.sql": script, "m/0001_initial.down.sql": script,
+			"m/0003_later.up.sql": script, "m/0003_later.down.sql": script,
+		}},
+		{"Two names", fstest.MapFS{"m/0001_initial.up.sql": script, "m/0001_other.down.sql": script}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tc.files, "m"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
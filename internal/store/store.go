@@ -0,0 +1,245 @@
+// Package store defines the storage backend used by minerva and minerva-api.
+//
+// Backends register themselves by driver name, like database/sql drivers, and
+// are selected with the driver key of the [storage] config section.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"minerva/internal/blocklist"
+	"minerva/internal/config"
+	"minerva/internal/geo"
+	"minerva/internal/parser"
+	"minerva/internal/scan"
+	"minerva/internal/threatintel"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store holds log entries, sensors, geolocation data, threat intelligence
+// reports, blocklists and scan incidents.
+type Store interface {
+	// IsIPInGeoTable, InsertOrUpdateGeoData and StaleGeoIPs store
+	// geolocation data and find what is due for a refresh.
+	geo.RefreshHandler
+	// ReputationFetchedAt and InsertOrUpdateReputation store threat
+	// intelligence reports.
+	threatintel.DataHandler
+	// BlocklistsUpdatedAt, Blocklists and ReplaceBlocklist store the
+	// networks of blocklist feeds.
+	blocklist.DataHandler
+	// InsertIncident stores detected scans.
+	scan.DataHandler
+
+	// InsertSensor registers a sensor if it is not known yet. Log entries
+	// reference their sensor, so it must exist before they are inserted.
+	InsertSensor(id string) error
+	// InsertLogEntry inserts one event. It returns 0 if the event was already
+	// stored.
+	InsertLogEntry(ev parser.LogEvent) (rowsInserted int64, err error)
+	// InsertLogEntries inserts a batch of events, skipping those already
+	// stored, and returns the number inserted. Any invalid event fails the
+	// whole batch.
+	InsertLogEntries(events []parser.LogEvent) (rowsInserted int64, err error)
+
+	// Logs returns stored events, newest first.
+	Logs(q LogQuery) ([]parser.LogEvent, error)
+	// Geo returns the geolocation data for ip. With a sensor, only addresses
+	// that appear in that sensor's logs are found. It returns ErrNotFound if
+	// there is none.
+	Geo(ip, sensor string) (*geo.Data, error)
+	// Reputation returns the threat intelligence reports on ip, ordered by
+	// provider. With a sensor, only addresses that appear in that sensor's
+	// logs are found. It returns an empty slice if there are none.
+	Reputation(ip, sensor string) ([]threatintel.Report, error)
+	// IPProfile returns the activity of the source address ip and its
+	// geolocation data. With a sensor, only addresses that appear in that
+	// sensor's logs are found. It returns ErrNotFound if there is none.
+	IPProfile(ip, sensor string) (*IPProfile, error)
+	// TopASNs returns the autonomous systems with the most flagged entries,
+	// most first. Addresses without a known AS are left out.
+	TopASNs(q ASNQuery) ([]ASNStats, error)
+	// Incidents returns detected scans, latest start first.
+	Incidents(q IncidentQuery) ([]scan.Incident, error)
+	// Sensors returns every sensor, or only the given one, with its log count.
+	Sensors(sensor string) ([]Sensor, error)
+	// Stats reports the size of the database and its tables.
+	Stats() (Stats, error)
+
+	// CheckSchema returns an error wrapping ErrSchemaOutdated unless every
+	// migration has been applied.
+	CheckSchema() error
+	MigrateUp() ([]Migration, error)
+	MigrateDown(steps int) ([]Migration, error)
+	MigrationStatuses() ([]MigrationStatus, error)
+
+	// Maintain prepares the store for entries arriving after now, for
+	// example by creating upcoming partitions.
+	Maintain(now time.Time) error
+	// Partitions returns the ranges of log entries that retention drops as a
+	// unit, in time order.
+	Partitions() ([]Partition, error)
+	// DropPartition deletes a partition and its entries.
+	DropPartition(p Partition) error
+
+	Close() error
+}
+
+// DefaultSensor is the sensor that log entries without one are stored under.
+const DefaultSensor = "default"
+
+var (
+	// ErrNotFound is returned when a requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrSchemaOutdated is returned by CheckSchema when migrations are pending.
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+)
+
+// LogQuery selects the events returned by Logs.
+type LogQuery struct {
+	Sensor      string       // Empty for all sensors
+	SourceCIDR  netip.Prefix // Source addresses to return; the zero Prefix for all
+	SourceClass string       // Class of the source addresses to return, such as "public"; empty for all
+	Limit       int
+	Offset      int
+}
+
+// ASNQuery selects the entries counted by TopASNs.
+type ASNQuery struct {
+	Sensor string    // Empty for all sensors
+	Since  time.Time // Earliest entry counted; the zero Time for all
+	Limit  int
+}
+
+// IncidentQuery selects the incidents returned by Incidents.
+type IncidentQuery struct {
+	Sensor     string       // Empty for all sensors
+	SourceCIDR netip.Prefix // Source addresses to return; the zero Prefix for all
+	Type       scan.Type    // Empty for all types
+	Since      time.Time    // Earliest end of the incidents returned; the zero Time for all
+	Limit      int
+	Offset     int
+}
+
+// ASNStats summarizes the flagged entries from the addresses of one autonomous
+// system.
+type ASNStats struct {
+	ASN            uint32 `json:"asn"`
+	Org            string `json:"org"`
+	Sources        int64  `json:"sources"`         // Distinct source addresses
+	HostingSources int64  `json:"hosting_sources"` // Sources flagged as hosting or data center
+	Hits           int64  `json:"hits"`
+}
+
+// Sensor is a site or device whose logs are stored, with a summary of them.
+type Sensor struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	LogCount  int64      `json:"log_count"`
+	FirstLog  *time.Time `json:"first_log,omitempty"`
+	LastLog   *time.Time `json:"last_log,omitempty"`
+}
+
+// MaxIntelPorts bounds the destination ports kept for an address, so that a
+// scan of every port does not grow its ip_intel record without bound. Once it
+// is reached, further ports are not recorded.
+const MaxIntelPorts = 1000
+
+// IPProfile summarizes the flagged entries from a source address across all
+// sensors. Addresses known only from a geolocation lookup have no hits, and
+// nil FirstSeen and LastSeen.
+type IPProfile struct {
+	IP               string
+	Class            string // See package netclass; empty if no entry has been stored yet
+	FirstSeen        *time.Time
+	LastSeen         *time.Time
+	TotalHits        int64
+	DestinationPorts []int     // Distinct, sorted, at most MaxIntelPorts
+	Reasons          []string  // Distinct, sorted
+	Geo              *geo.Data // Nil if not looked up yet
+}
+
+// Stats reports the size of the database, in bytes, and of each table.
+type Stats struct {
+	DatabaseSize int64
+	Tables       []TableStats
+	Partitions   []Partition
+}
+
+// TableStats is the size of one table. Size is -1 if the backend cannot
+// measure it.
+type TableStats struct {
+	Name string
+	Rows int64
+	Size int64
+}
+
+// Partition is a range of log entries, From <= timestamp < To, that retention
+// drops as a unit.
+type Partition struct {
+	Name string
+	From time.Time
+	To   time.Time
+	Rows int64 // May be an estimate; -1 if unknown
+	Size int64 // Bytes, including indexes; -1 if unknown
+}
+
+// ExpiredPartitions returns the partitions of s holding only entries older
+// than cutoff.
+func ExpiredPartitions(s Store, cutoff time.Time) ([]Partition, error) {
+	partitions, err := s.Partitions()
+	if err != nil {
+		return nil, err
+	}
+	var expired []Partition
+	for _, p := range partitions {
+		if !p.To.After(cutoff) {
+			expired = append(expired, p)
+		}
+	}
+	return expired, nil
+}
+
+// Opener opens a store for a driver from the application configuration.
+type Opener func(conf *config.Config) (Store, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Opener{}
+)
+
+// Register makes a storage driver available by name.
+func Register(name string, open Opener) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := drivers[name]; dup {
+		panic(fmt.Sprintf("store: Register called twice for driver %q", name))
+	}
+	drivers[name] = open
+}
+
+// Drivers returns the names of the registered drivers, sorted.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the store selected by conf.Storage.Driver.
+func Open(conf *config.Config) (Store, error) {
+	driversMu.RLock()
+	open, ok := drivers[conf.Storage.Driver]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver %q (available: %v)", conf.Storage.Driver, Drivers())
+	}
+	return open(conf)
+}
@@ -0,0 +1,258 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lineTimeFormat matches the high-precision timestamps rsyslog writes to /var/log/syslog.
+const lineTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// isoNoZoneFormat matches ISO 8601 timestamps that carry no UTC offset.
+const isoNoZoneFormat = "2006-01-02T15:04:05.999999999"
+
+// rfc3164TimeFormat is the BSD-style timestamp of RFC 3164 headers, once the
+// space padding of the day has been collapsed.
+const rfc3164TimeFormat = "Jan 2 15:04:05"
+
+// Message is a syslog message received from the network.
+type Message struct {
+	Priority  int // Raw PRI value, or -1 if the message had none
+	Facility  int // Priority / 8
+	Severity  int // Priority % 8
+	Timestamp time.Time
+	Hostname  string
+	AppName   string // Program or tag that produced the message
+	ProcID    string // Process ID, if reported
+	MsgID     string // RFC 5424 message type, if reported
+	Content   string // Free-form message text
+}
+
+// Parse decodes an RFC 5424 or RFC 3164 syslog message.
+//
+// received is the time the message arrived. It is used when the header carries no
+// usable timestamp, and its location and year complete RFC 3164 timestamps, which
+// have neither. Messages whose header cannot be parsed are kept whole as Content.
+func Parse(data []byte, received time.Time) (Message, error) {
+	return ParseLine(string(data), received)
+}
+
+// ParseLine is like Parse for a line of text, such as one read from a log file.
+func ParseLine(line string, received time.Time) (Message, error) {
+	text := strings.TrimRight(line, "\r\n\x00")
+	if strings.TrimSpace(text) == "" {
+		return Message{}, errors.New("empty syslog message")
+	}
+
+	msg := Message{Priority: -1, Timestamp: received}
+	rest := text
+	if pri, after, ok := parsePriority(text); ok {
+		msg.Priority = pri
+		msg.Facility = pri / 8
+		msg.Severity = pri % 8
+		rest = after
+	}
+
+	if after, ok := strings.CutPrefix(rest, "1 "); ok && msg.Priority >= 0 {
+		if parseRFC5424(&msg, after) {
+			return msg, nil
+		}
+	}
+	if !parseRFC3164(&msg, rest, received) {
+		msg.Content = rest
+	}
+	return msg, nil
+}
+
+// Tag renders the program and process ID the way they appear in syslog files.
+func (m Message) Tag() string {
+	if m.ProcID != "" {
+		return fmt.Sprintf("%s[%s]", m.AppName, m.ProcID)
+	}
+	return m.AppName
+}
+
+// Line renders the message the way rsyslog writes it to /var/log/syslog,
+// so received messages can be handled exactly like lines read from a file.
+func (m Message) Line() string {
+	var b strings.Builder
+	b.WriteString(m.Timestamp.Format(lineTimeFormat))
+	if m.Hostname != "" {
+		b.WriteByte(' ')
+		b.WriteString(m.Hostname)
+	}
+	if m.AppName != "" {
+		b.WriteByte(' ')
+		b.WriteString(m.Tag())
+		b.WriteByte(':')
+	}
+	b.WriteByte(' ')
+	b.WriteString(m.Content)
+	return b.String()
+}
+
+// LineWithPriority renders the message like Line, prefixed with "<PRI>" when it
+// had one, so its facility and severity survive being parsed again with ParseLine.
+func (m Message) LineWithPriority() string {
+	if m.Priority < 0 {
+		return m.Line()
+	}
+	return "<" + strconv.Itoa(m.Priority) + ">" + m.Line()
+}
+
+// parsePriority reads a leading "<PRI>" and returns the remainder of the message.
+func parsePriority(s string) (int, string, bool) {
+	if !strings.HasPrefix(s, "<") {
+		return 0, s, false
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, s, false
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, s, false
+	}
+	return pri, s[end+1:], true
+}
+
+// parseRFC5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parseRFC5424(msg *Message, s string) bool {
+	fields := make([]string, 0, 5)
+	for len(fields) < 5 {
+		field, rest, ok := strings.Cut(s, " ")
+		if !ok {
+			return false
+		}
+		fields = append(fields, field)
+		s = rest
+	}
+
+	if fields[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return false
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	msg.ProcID = nilValue(fields[3])
+	msg.MsgID = nilValue(fields[4])
+
+	rest, ok := skipStructuredData(s)
+	if !ok {
+		return false
+	}
+	msg.Content = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+	return true
+}
+
+// skipStructuredData consumes the STRUCTURED-DATA field and returns what follows it.
+func skipStructuredData(s string) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	for strings.HasPrefix(s, "[") {
+		inQuotes := false
+		end := -1
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && inQuotes:
+				i++
+			case s[i] == '"':
+				inQuotes = !inQuotes
+			case s[i] == ']' && !inQuotes:
+				end = i
+			}
+			if end >= 0 {
+				break
+			}
+		}
+		if end < 0 {
+			return "", false
+		}
+		s = s[end+1:]
+	}
+	return s, true
+}
+
+// parseRFC3164 parses "TIMESTAMP HOSTNAME TAG: MSG". Timestamps may be BSD-style
+// or ISO 8601, as written by rsyslog's high-precision file format.
+func parseRFC3164(msg *Message, s string, received time.Time) bool {
+	ts, rest, ok := parseHeaderTime(s, received)
+	if !ok {
+		return false
+	}
+	msg.Timestamp = ts
+
+	// The hostname is optional; a first word that ends in ':' or carries a
+	// "[pid]" is the tag of a message sent without one.
+	if word, after, found := strings.Cut(rest, " "); found && !strings.HasSuffix(word, ":") && !strings.Contains(word, "[") {
+		msg.Hostname = word
+		rest = after
+	}
+
+	// The tag runs up to the first ": ". Some devices put spaces in it
+	// (for example "L4 FIREWALL[7567]:"), so only key=value text ends the search.
+	if idx := strings.Index(rest, ": "); idx > 0 && !strings.ContainsAny(rest[:idx], "=") {
+		tag := rest[:idx]
+		msg.Content = rest[idx+2:]
+		if open := strings.LastIndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			msg.AppName = tag[:open]
+			msg.ProcID = tag[open+1 : len(tag)-1]
+		} else {
+			msg.AppName = tag
+		}
+		return true
+	}
+
+	msg.Content = rest
+	return true
+}
+
+// parseHeaderTime reads the leading timestamp of an RFC 3164 header.
+func parseHeaderTime(s string, received time.Time) (time.Time, string, bool) {
+	if word, rest, ok := strings.Cut(s, " "); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, word); err == nil {
+			return ts, rest, true
+		}
+		// ISO timestamps without an offset are in the receiver's location.
+		if ts, err := time.ParseInLocation(isoNoZoneFormat, word, received.Location()); err == nil {
+			return ts, rest, true
+		}
+	}
+
+	// BSD timestamps are "Mmm dd hh:mm:ss", with the day padded by a space.
+	month, rest, _ := strings.Cut(s, " ")
+	day, rest, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	clock, rest, _ := strings.Cut(rest, " ")
+	ts, err := time.ParseInLocation(rfc3164TimeFormat, month+" "+day+" "+clock, received.Location())
+	if err != nil {
+		return time.Time{}, s, false
+	}
+	return InferYear(ts, received), rest, true
+}
+
+// InferYear completes a timestamp that was parsed without a year.
+// It assumes the message is recent: dates more than a month ahead of now are
+// taken to be from the previous year, which handles logs spanning New Year.
+func InferYear(ts, now time.Time) time.Time {
+	year := now.Year()
+	t := time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), ts.Location())
+	if t.After(now.AddDate(0, 1, 0)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// nilValue maps the RFC 5424 NILVALUE "-" to an empty string.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
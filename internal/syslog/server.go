@@ -0,0 +1,235 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMessageSize bounds a single received message, for both UDP and TCP.
+const maxMessageSize = 64 * 1024
+
+// Read and accept errors, such as running out of file descriptors, are
+// retried after a delay that doubles from minRetryDelay up to maxRetryDelay.
+const (
+	minRetryDelay = 5 * time.Millisecond
+	maxRetryDelay = time.Second
+)
+
+// Handler is called for every message the server receives.
+type Handler func(Message)
+
+// Server receives syslog messages over UDP and TCP.
+type Server struct {
+	handler  Handler
+	location *time.Location
+
+	mu        sync.Mutex
+	closed    bool
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a Server that passes every message to handler.
+// RFC 3164 timestamps, which carry no zone, are interpreted in loc.
+func NewServer(handler Handler, loc *time.Location) *Server {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Server{
+		handler:  handler,
+		location: loc,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenUDP starts receiving datagrams on addr and returns the bound address.
+func (s *Server) ListenUDP(addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on udp %s: %w", addr, err)
+	}
+	if err := s.track(conn); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, maxMessageSize)
+		var delay time.Duration
+		for {
+			n, remote, err := conn.ReadFrom(buf)
+			if err != nil {
+				if s.isClosed() || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				delay = retryDelay(delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			s.handle(buf[:n], remote)
+		}
+	}()
+	return conn.LocalAddr(), nil
+}
+
+// ListenTCP starts accepting connections on addr and returns the bound address.
+// Both octet-counted and newline-delimited framing (RFC 6587) are accepted.
+func (s *Server) ListenTCP(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on tcp %s: %w", addr, err)
+	}
+	if err := s.track(listener); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		var delay time.Duration
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if s.isClosed() || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				delay = retryDelay(delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			s.serveConn(conn)
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// retryDelay returns the delay before retrying after an error, given the delay
+// before the previous retry, or zero if the last attempt succeeded.
+func retryDelay(prev time.Duration) time.Duration {
+	return min(max(2*prev, minRetryDelay), maxRetryDelay)
+}
+
+// Shutdown stops all listeners and connections and waits until every message
+// already read has been passed to the handler.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// track registers a listener so Shutdown can close it.
+func (s *Server) track(l io.Closer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		l.Close()
+		return errors.New("server is shut down")
+	}
+	s.listeners = append(s.listeners, l)
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// serveConn reads framed messages from a TCP connection until it is closed.
+func (s *Server) serveConn(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+
+		reader := bufio.NewReaderSize(conn, maxMessageSize)
+		for {
+			frame, err := readFrame(reader)
+			if len(frame) > 0 {
+				s.handle(frame, conn.RemoteAddr())
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// readFrame reads one message using octet counting ("LEN MSG") when the frame
+// starts with a digit, and non-transparent framing (one message per line) otherwise.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil || length > maxMessageSize {
+			return nil, fmt.Errorf("invalid octet count %q", prefix)
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+	}
+	frame := append([]byte(nil), line...)
+	return frame, err
+}
+
+// handle parses a received message and passes it to the handler.
+func (s *Server) handle(data []byte, remote net.Addr) {
+	msg, err := Parse(data, time.Now().In(s.location))
+	if err != nil {
+		return
+	}
+	if msg.Hostname == "" && remote != nil {
+		if host, _, err := net.SplitHostPort(remote.String()); err == nil {
+			msg.Hostname = host
+		}
+	}
+	s.handler(msg)
+}
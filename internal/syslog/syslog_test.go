@@ -0,0 +1,266 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse_RFC3164(t *testing.T) {
+	received := time.Date(2025, time.January, 9, 12, 0, 0, 0, time.UTC)
+
+	msg, err := Parse([]byte("<134>Jan  8 00:01:08 dsldevice.attlocal.net L4 FIREWALL[7567]: action=DROP reason=PORTSCAN\n"), received)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedTime := time.Date(2025, time.January, 8, 0, 1, 8, 0, time.UTC)
+	if !msg.Timestamp.Equal(expectedTime) {
+		t.Errorf("Expected timestamp %v, got %v", expectedTime, msg.Timestamp)
+	}
+	if msg.Facility != 16 || msg.Severity != 6 {
+		t.Errorf("Expected facility 16 and severity 6, got %d and %d", msg.Facility, msg.Severity)
+	}
+	if msg.Hostname != "dsldevice.attlocal.net" {
+		t.Errorf("Expected hostname 'dsldevice.attlocal.net', got %q", msg.Hostname)
+	}
+	if msg.AppName != "L4 FIREWALL" || msg.ProcID != "7567" {
+		t.Errorf("Expected tag 'L4 FIREWALL[7567]', got %q", msg.Tag())
+	}
+	if msg.Content != "action=DROP reason=PORTSCAN" {
+		t.Errorf("Expected content 'action=DROP reason=PORTSCAN', got %q", msg.Content)
+	}
+}
+
+func TestParse_RFC3164_YearRollover(t *testing.T) {
+	// A December message received in early January belongs to the previous year.
+	received := time.Date(2025, time.January, 1, 0, 0, 30, 0, time.UTC)
+
+	msg, err := Parse([]byte("<134>Dec 31 23:59:59 router kernel: test"), received)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.Timestamp.Year() != 2024 {
+		t.Errorf("Expected year 2024, got %d", msg.Timestamp.Year())
+	}
+}
+
+func TestParse_RFC5424(t *testing.T) {
+	received := time.Now()
+	data := `<165>1 2025-01-05T00:01:08.143626-05:00 router.example.net firewall 42 ID47 [exampleSDID@32473 iut="3" eventSource="App\]"] action=DROP SRC=192.0.2.1`
+
+	msg, err := Parse([]byte(data), received)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedTime, _ := time.Parse(time.RFC3339Nano, "2025-01-05T00:01:08.143626-05:00")
+	if !msg.Timestamp.Equal(expectedTime) {
+		t.Errorf("Expected timestamp %v, got %v", expectedTime, msg.Timestamp)
+	}
+	if msg.Hostname != "router.example.net" {
+		t.Errorf("Expected hostname 'router.example.net', got %q", msg.Hostname)
+	}
+	if msg.AppName != "firewall" || msg.ProcID != "42" || msg.MsgID != "ID47" {
+		t.Errorf("Unexpected app/proc/msg IDs: %q %q %q", msg.AppName, msg.ProcID, msg.MsgID)
+	}
+	if msg.Content != "action=DROP SRC=192.0.2.1" {
+		t.Errorf("Expected content 'action=DROP SRC=192.0.2.1', got %q", msg.Content)
+	}
+}
+
+func TestParse_EdgeCases(t *testing.T) {
+	received := time.Date(2025, time.January, 9, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		data            string
+		expectErr       bool
+		expectedContent string
+	}{
+		{"Empty", "", true, ""},
+		{"Whitespace", " \r\n", true, ""},
+		{"NoHeader", "action=DROP SRC=192.0.2.1", false, "action=DROP SRC=192.0.2.1"},
+		{"PriorityOnly", "<13>action=DROP", false, "action=DROP"},
+		{"NilStructuredData", "<13>1 - - - - - - hello", false, "hello"},
+		{"ISOTimestamp", "2025-01-05T00:01:08.143626-05:00 host prog: hello", false, "hello"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := Parse([]byte(tc.data), received)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Parse(%q) error = %v, expectErr = %v", tc.data, err, tc.expectErr)
+			}
+			if msg.Content != tc.expectedContent {
+				t.Errorf("Expected content %q, got %q", tc.expectedContent, msg.Content)
+			}
+		})
+	}
+}
+
+func TestMessageLine(t *testing.T) {
+	loc := time.FixedZone("EST", -5*3600)
+	msg := Message{
+		Timestamp: time.Date(2025, time.January, 5, 0, 1, 8, 143626000, loc),
+		Hostname:  "router",
+		AppName:   "kernel",
+		ProcID:    "7",
+		Content:   "action=DROP",
+	}
+
+	expected := "2025-01-05T00:01:08.143626-05:00 router kernel[7]: action=DROP"
+	if line := msg.Line(); line != expected {
+		t.Errorf("Expected line %q, got %q", expected, line)
+	}
+
+	msg.Priority = -1
+	if line := msg.LineWithPriority(); line != expected {
+		t.Errorf("Expected line without priority %q, got %q", expected, line)
+	}
+
+	msg.Priority, msg.Facility, msg.Severity = 134, 16, 6
+	line := msg.LineWithPriority()
+	if line != "<134>"+expected {
+		t.Errorf("Expected line with priority, got %q", line)
+	}
+	parsed, err := ParseLine(line, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.Facility != 16 || parsed.Severity != 6 || parsed.Hostname != "router" || parsed.ProcID != "7" {
+		t.Errorf("Expected the line to parse back to the message, got %+v", parsed)
+	}
+}
+
+func TestParseLine_ZonelessTimestamp(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	received := time.Date(2025, time.January, 5, 12, 0, 0, 0, loc)
+
+	msg, err := ParseLine("2025-01-05T00:01:08.5 gw kernel: DROP-IN: IN=eth0", received)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := time.Date(2025, time.January, 5, 0, 1, 8, 500000000, loc)
+	if !msg.Timestamp.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, msg.Timestamp)
+	}
+	if msg.Hostname != "gw" || msg.AppName != "kernel" {
+		t.Errorf("Unexpected header %+v", msg)
+	}
+}
+
+// collector gathers handled messages for server tests.
+type collector struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (c *collector) handle(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+}
+
+func (c *collector) waitFor(t *testing.T, n int) []Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.messages) >= n {
+			msgs := append([]Message(nil), c.messages...)
+			c.mu.Unlock()
+			return msgs
+		}
+		c.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d messages", n)
+	return nil
+}
+
+func TestServer_UDP(t *testing.T) {
+	c := &collector{}
+	server := NewServer(c.handle, time.UTC)
+	defer server.Shutdown()
+
+	addr, err := server.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("<134>Jan  8 00:01:08 router kernel: action=DROP")); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	msgs := c.waitFor(t, 1)
+	if msgs[0].Content != "action=DROP" {
+		t.Errorf("Expected content 'action=DROP', got %q", msgs[0].Content)
+	}
+}
+
+func TestServer_TCPFraming(t *testing.T) {
+	c := &collector{}
+	server := NewServer(c.handle, time.UTC)
+
+	addr, err := server.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	counted := "<134>Jan  8 00:01:08 router kernel: first\nwith newline"
+	fmt.Fprintf(conn, "%d %s", len(counted), counted)
+	fmt.Fprint(conn, "<134>Jan  8 00:01:09 router kernel: second\n")
+	fmt.Fprint(conn, "<134>Jan  8 00:01:10 router kernel: third\r\n")
+
+	msgs := c.waitFor(t, 3)
+	conn.Close()
+	server.Shutdown()
+
+	expected := []string{"first\nwith newline", "second", "third"}
+	for i, msg := range msgs {
+		if msg.Content != expected[i] {
+			t.Errorf("Expected message %d content %q, got %q", i, expected[i], msg.Content)
+		}
+	}
+}
+
+func TestServer_HostnameFromRemote(t *testing.T) {
+	c := &collector{}
+	server := NewServer(c.handle, time.UTC)
+	defer server.Shutdown()
+
+	addr, err := server.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Without a parseable header the sender's address stands in for the hostname.
+	if _, err := conn.Write([]byte("action=DROP")); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	msgs := c.waitFor(t, 1)
+	if msgs[0].Hostname != "127.0.0.1" {
+		t.Errorf("Expected hostname '127.0.0.1', got %q", msgs[0].Hostname)
+	}
+}
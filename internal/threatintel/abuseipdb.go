@@ -0,0 +1,90 @@
+package threatintel
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// abuseIPDBCategories names AbuseIPDB's report categories by ID; see
+// https://www.abuseipdb.com/categories.
+var abuseIPDBCategories = map[int]string{
+	1: "dns-compromise", 2: "dns-poisoning", 3: "fraud-orders", 4: "ddos-attack",
+	5: "ftp-brute-force", 6: "ping-of-death", 7: "phishing", 8: "fraud-voip",
+	9: "open-proxy", 10: "web-spam", 11: "email-spam", 12: "blog-spam",
+	13: "vpn-ip", 14: "port-scan", 15: "hacking", 16: "sql-injection",
+	17: "spoofing", 18: "brute-force", 19: "bad-web-bot", 20: "exploited-host",
+	21: "web-app-attack", 22: "ssh", 23: "iot-targeted",
+}
+
+// abuseIPDBResponse is the JSON returned by AbuseIPDB's check endpoint. With
+// the verbose flag it includes the reports, whose categories are collected.
+type abuseIPDBResponse struct {
+	Data struct {
+		AbuseConfidenceScore int        `json:"abuseConfidenceScore"`
+		LastReportedAt       *time.Time `json:"lastReportedAt"`
+		Reports              []struct {
+			Categories []int `json:"categories"`
+		} `json:"reports"`
+	} `json:"data"`
+}
+
+// AbuseIPDB is the Provider for AbuseIPDB, whose score is its abuse
+// confidence score.
+type AbuseIPDB struct {
+	*apiClient
+	url        string
+	apiKey     string
+	maxAgeDays int
+}
+
+// NewAbuseIPDB returns a Provider that queries the check endpoint at url, by
+// default "https://api.abuseipdb.com/api/v2/check", with apiKey, at most
+// perDay times a day, for reports from the last maxAgeDays days.
+func NewAbuseIPDB(url, apiKey string, perDay, maxAgeDays int) *AbuseIPDB {
+	if url == "" {
+		url = "https://api.abuseipdb.com/api/v2/check"
+	}
+	return &AbuseIPDB{apiClient: newAPIClient(perDay), url: url, apiKey: apiKey, maxAgeDays: maxAgeDays}
+}
+
+// Name returns "abuseipdb".
+func (p *AbuseIPDB) Name() string {
+	return "abuseipdb"
+}
+
+// Lookup checks ip with AbuseIPDB.
+func (p *AbuseIPDB) Lookup(ip string) (*Report, error) {
+	query := url.Values{"ipAddress": {ip}, "verbose": {""}}
+	if p.maxAgeDays > 0 {
+		query.Set("maxAgeInDays", strconv.Itoa(p.maxAgeDays))
+	}
+	var resp abuseIPDBResponse
+	if err := p.getJSON(p.url+"?"+query.Encode(), map[string]string{"Key": p.apiKey}, &resp); err != nil {
+		return nil, fmt.Errorf("abuseipdb: %w", err)
+	}
+
+	seen := map[string]bool{}
+	categories := []string{}
+	for _, report := range resp.Data.Reports {
+		for _, id := range report.Categories {
+			name, ok := abuseIPDBCategories[id]
+			if !ok {
+				name = "category-" + strconv.Itoa(id)
+			}
+			if !seen[name] {
+				seen[name] = true
+				categories = append(categories, name)
+			}
+		}
+	}
+	sort.Strings(categories)
+
+	return &Report{
+		Score:        resp.Data.AbuseConfidenceScore,
+		Categories:   categories,
+		LastReported: resp.Data.LastReportedAt,
+	}, nil
+}
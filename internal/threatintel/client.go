@@ -0,0 +1,126 @@
+package threatintel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"minerva/internal/ratelimit"
+)
+
+// maxAttempts is how many times a request is sent before giving up.
+const maxAttempts = 3
+
+// statusError is an unexpected HTTP status from an API.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned status code %d", e.code)
+}
+
+// retryable reports whether a request that failed with err may succeed if sent
+// again: it was rate limited, failed on the server, or never got there.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	var urlErr *neturl.Error
+	return errors.As(err, &urlErr)
+}
+
+// apiClient sends the requests of one provider. It keeps to the provider's
+// daily quota, and retries rate-limited and failed requests with exponential
+// backoff, waiting at least as long as a Retry-After header asks.
+type apiClient struct {
+	limiter *ratelimit.Limiter
+	backoff time.Duration // Wait before the first retry, doubled for each one after it
+
+	mu     sync.Mutex
+	resume time.Time // No requests are sent before then
+}
+
+func newAPIClient(perDay int) *apiClient {
+	return &apiClient{limiter: ratelimit.NewPerDay(perDay), backoff: time.Second}
+}
+
+// getJSON sends a GET request for url with the given headers and decodes the
+// JSON response into dst. A 404 response is ErrNoData.
+func (c *apiClient) getJSON(url string, headers map[string]string, dst interface{}) error {
+	for attempt := 1; ; attempt++ {
+		c.limiter.Wait()
+		c.wait()
+
+		retryAfter, err := c.get(url, headers, dst)
+		if err == nil || !retryable(err) || attempt == maxAttempts {
+			return err
+		}
+		wait := c.backoff << (attempt - 1)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		c.pause(wait)
+	}
+}
+
+// get sends one request. It returns the wait asked for by a Retry-After header
+// along with any error.
+func (c *apiClient) get(url string, headers map[string]string, dst interface{}) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch reputation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, ErrNoData
+	case resp.StatusCode != http.StatusOK:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, &statusError{code: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return 0, fmt.Errorf("failed to decode reputation: %w", err)
+	}
+	return 0, nil
+}
+
+// wait blocks until requests may be sent again.
+func (c *apiClient) wait() {
+	c.mu.Lock()
+	d := time.Until(c.resume)
+	c.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// pause holds back requests for d, unless they are already held back longer.
+func (c *apiClient) pause(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resume := time.Now().Add(d); resume.After(c.resume) {
+		c.resume = resume
+	}
+}
+
+// Close stops the rate limiter.
+func (c *apiClient) Close() error {
+	c.limiter.Stop()
+	return nil
+}
@@ -0,0 +1,40 @@
+package threatintel
+
+import (
+	"fmt"
+
+	"minerva/internal/config"
+)
+
+// NewProviders returns the providers listed in conf, which may be none. Close
+// them when done with them.
+func NewProviders(conf config.ThreatIntelConfig) (Providers, error) {
+	var providers Providers
+	for _, name := range conf.Providers {
+		var p Provider
+		switch name {
+		case "abuseipdb":
+			if conf.AbuseIPDB.APIKey == "" {
+				providers.Close()
+				return nil, fmt.Errorf("the abuseipdb provider needs api_key")
+			}
+			p = NewAbuseIPDB(conf.AbuseIPDB.URL, conf.AbuseIPDB.APIKey, conf.AbuseIPDB.PerDay, conf.AbuseIPDB.MaxAgeDays)
+		case "virustotal":
+			if conf.VirusTotal.APIKey == "" {
+				providers.Close()
+				return nil, fmt.Errorf("the virustotal provider needs api_key")
+			}
+			v, err := NewVirusTotal(conf.VirusTotal.URL, conf.VirusTotal.APIKey, conf.VirusTotal.PerDay)
+			if err != nil {
+				providers.Close()
+				return nil, fmt.Errorf("invalid virustotal provider: %w", err)
+			}
+			p = v
+		default:
+			providers.Close()
+			return nil, fmt.Errorf("unknown threat intelligence provider %q", name)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
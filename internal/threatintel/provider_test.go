@@ -0,0 +1,185 @@
+package threatintel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"minerva/internal/config"
+)
+
+func TestAbuseIPDB(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != "secret" {
+			t.Errorf("Expected the API key header, got %q", r.Header.Get("Key"))
+		}
+		query := r.URL.Query()
+		if query.Get("maxAgeInDays") != "30" || !query.Has("verbose") {
+			t.Errorf("Unexpected query %q", r.URL.RawQuery)
+		}
+		switch query.Get("ipAddress") {
+		case "192.0.2.1":
+			w.Write([]byte(`{"data":{"ipAddress":"192.0.2.1","abuseConfidenceScore":87,
+				"lastReportedAt":"2024-03-01T12:00:00+00:00",
+				"reports":[{"categories":[18,22]},{"categories":[14,22,99]}]}}`))
+		case "2001:db8::1":
+			w.Write([]byte(`{"data":{"ipAddress":"2001:db8::1","abuseConfidenceScore":0,"lastReportedAt":null,"reports":[]}}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer mockServer.Close()
+
+	p := NewAbuseIPDB(mockServer.URL, "secret", 0, 30)
+	defer p.Close()
+
+	report, err := p.Lookup("192.0.2.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"brute-force", "category-99", "port-scan", "ssh"}
+	if report.Score != 87 || !reflect.DeepEqual(report.Categories, expected) {
+		t.Errorf("Expected score 87 and %v, got %d and %v", expected, report.Score, report.Categories)
+	}
+	if report.LastReported == nil || !report.LastReported.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected last report %v", report.LastReported)
+	}
+
+	report, err = p.Lookup("2001:db8::1")
+	if err != nil || report.Score != 0 || len(report.Categories) != 0 || report.LastReported != nil {
+		t.Errorf("Expected a clean report, got %+v, %v", report, err)
+	}
+
+	if _, err := p.Lookup("not-an-ip"); err == nil {
+		t.Error("Expected an error for a rejected request")
+	}
+}
+
+func TestVirusTotal(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-apikey") != "secret" {
+			t.Errorf("Expected the API key header, got %q", r.Header.Get("x-apikey"))
+		}
+		switch r.URL.Path {
+		case "/ip_addresses/192.0.2.1":
+			w.Write([]byte(`{"data":{"attributes":{"last_analysis_date":1709294400,
+				"last_analysis_stats":{"malicious":2,"suspicious":1,"harmless":5,"undetected":2,"timeout":3},
+				"last_analysis_results":{
+					"A":{"category":"malicious","result":"Malware"},
+					"B":{"category":"malicious","result":"phishing"},
+					"C":{"category":"suspicious","result":"malware"},
+					"D":{"category":"harmless","result":"clean"}}}}}`))
+		case "/ip_addresses/192.0.2.2":
+			w.Write([]byte(`{"data":{"attributes":{"last_analysis_stats":{}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	p, err := NewVirusTotal(mockServer.URL+"/ip_addresses/{ip}", "secret", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	report, err := p.Lookup("192.0.2.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"malware", "phishing"}
+	if report.Score != 30 || !reflect.DeepEqual(report.Categories, expected) {
+		t.Errorf("Expected score 30 and %v, got %d and %v", expected, report.Score, report.Categories)
+	}
+	if report.LastReported == nil || report.LastReported.Unix() != 1709294400 {
+		t.Errorf("Unexpected last report %v", report.LastReported)
+	}
+
+	for _, ip := range []string{"192.0.2.2", "192.0.2.3"} {
+		if _, err := p.Lookup(ip); !errors.Is(err, ErrNoData) {
+			t.Errorf("Lookup(%s): expected ErrNoData, got %v", ip, err)
+		}
+	}
+
+	if _, err := NewVirusTotal("https://example.com/lookup", "secret", 0); err == nil {
+		t.Error("Expected an error for a URL without {ip}")
+	}
+}
+
+func TestAPIClient_Retry(t *testing.T) {
+	var requests int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Query().Get("ipAddress") {
+		case "192.0.2.1":
+			if requests < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`{"data":{"abuseConfidenceScore":12}}`))
+		case "192.0.2.2":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer mockServer.Close()
+
+	p := NewAbuseIPDB(mockServer.URL, "secret", 0, 0)
+	defer p.Close()
+	p.backoff = time.Millisecond
+
+	report, err := p.Lookup("192.0.2.1")
+	if err != nil || report.Score != 12 || requests != 3 {
+		t.Errorf("Expected success on the third request, got %+v, %v after %d", report, err, requests)
+	}
+
+	requests = 0
+	if _, err := p.Lookup("192.0.2.2"); err == nil || requests != 1 {
+		t.Errorf("Expected a client error without retries, got %v after %d requests", err, requests)
+	}
+
+	requests = 0
+	if _, err := p.Lookup("192.0.2.3"); err == nil || requests != maxAttempts {
+		t.Errorf("Expected an error after %d requests, got %v after %d", maxAttempts, err, requests)
+	}
+}
+
+func TestNewProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      config.ThreatIntelConfig
+		expected  string
+		expectErr bool
+	}{
+		{"None", config.ThreatIntelConfig{}, "", false},
+		{"Both", config.ThreatIntelConfig{
+			Providers:  []string{"virustotal", "abuseipdb"},
+			AbuseIPDB:  config.AbuseIPDBConfig{APIKey: "a"},
+			VirusTotal: config.VirusTotalConfig{APIKey: "v"},
+		}, "virustotal,abuseipdb", false},
+		{"Unknown", config.ThreatIntelConfig{Providers: []string{"crystal-ball"}}, "", true},
+		{"Missing key", config.ThreatIntelConfig{Providers: []string{"abuseipdb"}}, "", true},
+		{"VirusTotal without placeholder", config.ThreatIntelConfig{
+			Providers:  []string{"virustotal"},
+			VirusTotal: config.VirusTotalConfig{APIKey: "v", URL: "https://example.com"},
+		}, "", true},
+	}
+	for _, tc := range tests {
+		providers, err := NewProviders(tc.conf)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expectErr, err)
+			continue
+		}
+		if err == nil {
+			if providers.Name() != tc.expected {
+				t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, providers.Name())
+			}
+			providers.Close()
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package threatintel checks source addresses against threat intelligence
+// services, such as AbuseIPDB and VirusTotal, and stores what they report.
+//
+// Unlike geolocation providers, which are tried in turn until one has data,
+// every configured provider is asked about each address, and its report is
+// stored separately, so that one service's verdict can be weighed against
+// another's.
+package threatintel
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client is a reusable HTTP client with a timeout, shared by the providers.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// SetHTTPClient allows overriding the default HTTP client.
+func SetHTTPClient(c *http.Client) {
+	client = c
+}
+
+// ErrNoData is returned by a Provider that knows nothing about an address.
+var ErrNoData = errors.New("no threat intelligence data")
+
+// Report is what a provider knows about an address.
+type Report struct {
+	Provider     string     `json:"provider"`
+	Score        int        `json:"score"`                   // 0 (no sign of abuse) to 100 (certainly malicious)
+	Categories   []string   `json:"categories"`              // Kinds of abuse reported, such as "port-scan", sorted
+	LastReported *time.Time `json:"last_reported,omitempty"` // Latest report of abuse; nil if there is none
+	FetchedAt    time.Time  `json:"fetched_at"`
+}
+
+// Provider looks up the reputation of addresses with a threat intelligence
+// service.
+type Provider interface {
+	// Name identifies the provider in configuration, logs and stored reports.
+	Name() string
+	// Lookup returns the provider's report on ip, or an error wrapping
+	// ErrNoData if it has none. Providers wait for their rate limit.
+	Lookup(ip string) (*Report, error)
+}
+
+// Providers are the configured providers, each asked about every address.
+type Providers []Provider
+
+// Name returns the names of the providers, in order.
+func (ps Providers) Name() string {
+	names := make([]string, len(ps))
+	for i, p := range ps {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Close closes the providers that hold resources, such as rate limiters.
+func (ps Providers) Close() error {
+	var errs []error
+	for _, p := range ps {
+		if closer, ok := p.(interface{ Close() error }); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DataHandler defines methods for storing threat intelligence reports.
+type DataHandler interface {
+	// ReputationFetchedAt returns when provider's report on ip was fetched,
+	// or the zero Time if there is none.
+	ReputationFetchedAt(ip, provider string) (time.Time, error)
+	// InsertOrUpdateReputation stores a report on ip, replacing the
+	// previous report of the same provider.
+	InsertOrUpdateReputation(ip string, r *Report) error
+}
+
+// ProcessIP checks ip with provider and stores the report, unless the stored
+// report is younger than ttl, or exists at all when ttl is 0. An address the
+// provider knows nothing about is stored with a clean report, so that it is
+// not checked again before ttl either. A failed lookup is logged and returned,
+// to be retried the next time the address is processed.
+func ProcessIP(provider Provider, handler DataHandler, ip string, ttl time.Duration) error {
+	fetched, err := handler.ReputationFetchedAt(ip, provider.Name())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !fetched.IsZero() && (ttl <= 0 || now.Sub(fetched) < ttl) {
+		return nil
+	}
+
+	report, err := provider.Lookup(ip)
+	if errors.Is(err, ErrNoData) {
+		report, err = &Report{}, nil
+	}
+	if err != nil {
+		log.Printf("Error fetching %s reputation for IP %s: %v", provider.Name(), ip, err)
+		return err
+	}
+	report.Provider = provider.Name()
+	report.FetchedAt = now
+	if report.Categories == nil {
+		report.Categories = []string{}
+	}
+
+	if err := handler.InsertOrUpdateReputation(ip, report); err != nil {
+		log.Printf("Error storing %s reputation for IP %s: %v", provider.Name(), ip, err)
+		return err
+	}
+	return nil
+}
+
+// MaxScore returns the highest score among reports, or nil if there are none.
+func MaxScore(reports []Report) *int {
+	if len(reports) == 0 {
+		return nil
+	}
+	max := reports[0].Score
+	for _, r := range reports[1:] {
+		if r.Score > max {
+			max = r.Score
+		}
+	}
+	return &max
+}
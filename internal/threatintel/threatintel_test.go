@@ -0,0 +1,96 @@
+package threatintel
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// staticProvider returns the same report for every address, or err, or
+// ErrNoData if it has neither.
+type staticProvider struct {
+	report *Report
+	err    error
+	calls  int
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) Lookup(ip string) (*Report, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.report == nil {
+		return nil, ErrNoData
+	}
+	r := *p.report
+	return &r, nil
+}
+
+type mockHandler struct {
+	reports map[string]*Report
+}
+
+func (h *mockHandler) ReputationFetchedAt(ip, provider string) (time.Time, error) {
+	if r, ok := h.reports[ip+"/"+provider]; ok {
+		return r.FetchedAt, nil
+	}
+	return time.Time{}, nil
+}
+
+func (h *mockHandler) InsertOrUpdateReputation(ip string, r *Report) error {
+	h.reports[ip+"/"+r.Provider] = r
+	return nil
+}
+
+func TestProcessIP(t *testing.T) {
+	handler := &mockHandler{reports: map[string]*Report{}}
+	provider := &staticProvider{}
+
+	// An unknown address is stored with a clean report and not looked up
+	// again within the TTL.
+	for i := 0; i < 2; i++ {
+		if err := ProcessIP(provider, handler, "192.0.2.1", 24*time.Hour); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	r := handler.reports["192.0.2.1/static"]
+	if provider.calls != 1 || r == nil || r.Score != 0 || r.Categories == nil || r.FetchedAt.IsZero() {
+		t.Fatalf("Expected one lookup and a clean report, got %d lookups and %+v", provider.calls, r)
+	}
+
+	// Once stale, the address is looked up again.
+	r.FetchedAt = time.Now().Add(-25 * time.Hour)
+	provider.report = &Report{Score: 90, Categories: []string{"port-scan"}}
+	if err := ProcessIP(provider, handler, "192.0.2.1", 24*time.Hour); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r := handler.reports["192.0.2.1/static"]; provider.calls != 2 || r.Score != 90 || r.Provider != "static" {
+		t.Errorf("Expected the report to be replaced, got %d lookups and %+v", provider.calls, r)
+	}
+
+	// With no TTL, stored reports are never refreshed.
+	handler.reports["192.0.2.1/static"].FetchedAt = time.Now().AddDate(-1, 0, 0)
+	if err := ProcessIP(provider, handler, "192.0.2.1", 0); err != nil || provider.calls != 2 {
+		t.Errorf("Expected no lookup without a TTL, got %d lookups, %v", provider.calls, err)
+	}
+
+	// A failed lookup stores nothing.
+	provider.err = errors.New("API returned status code 503")
+	if err := ProcessIP(provider, handler, "192.0.2.2", 24*time.Hour); err == nil {
+		t.Error("Expected the failed lookup to be reported")
+	}
+	if _, ok := handler.reports["192.0.2.2/static"]; ok {
+		t.Error("Expected no report after a failed lookup")
+	}
+}
+
+func TestMaxScore(t *testing.T) {
+	if MaxScore(nil) != nil {
+		t.Error("Expected no score without reports")
+	}
+	if s := MaxScore([]Report{{Score: 20}, {Score: 75}, {Score: 5}}); s == nil || *s != 75 {
+		t.Errorf("Expected 75, got %v", s)
+	}
+}
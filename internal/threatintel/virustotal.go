@@ -0,0 +1,102 @@
+package threatintel
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// virusTotalResponse is the JSON returned by VirusTotal's IP address endpoint:
+// the verdict of each engine in its last analysis, and how many gave each.
+type virusTotalResponse struct {
+	Data struct {
+		Attributes struct {
+			LastAnalysisDate    int64          `json:"last_analysis_date"`
+			LastAnalysisStats   map[string]int `json:"last_analysis_stats"`
+			LastAnalysisResults map[string]struct {
+				Category string `json:"category"`
+				Result   string `json:"result"`
+			} `json:"last_analysis_results"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// VirusTotal is the Provider for VirusTotal. Its score is the share of the
+// engines with a verdict that found the address malicious or suspicious, and
+// its categories are their verdicts, such as "malware" or "phishing".
+type VirusTotal struct {
+	*apiClient
+	url    string
+	apiKey string
+}
+
+// NewVirusTotal returns a Provider that queries url, in which "{ip}" is
+// replaced by the address, by default
+// "https://www.virustotal.com/api/v3/ip_addresses/{ip}", with apiKey, at most
+// perDay times a day.
+func NewVirusTotal(urlTemplate, apiKey string, perDay int) (*VirusTotal, error) {
+	if urlTemplate == "" {
+		urlTemplate = "https://www.virustotal.com/api/v3/ip_addresses/{ip}"
+	}
+	if !strings.Contains(urlTemplate, "{ip}") {
+		return nil, fmt.Errorf("URL %q has no {ip} placeholder", urlTemplate)
+	}
+	return &VirusTotal{apiClient: newAPIClient(perDay), url: urlTemplate, apiKey: apiKey}, nil
+}
+
+// Name returns "virustotal".
+func (p *VirusTotal) Name() string {
+	return "virustotal"
+}
+
+// Lookup checks ip with VirusTotal.
+func (p *VirusTotal) Lookup(ip string) (*Report, error) {
+	var resp virusTotalResponse
+	u := strings.ReplaceAll(p.url, "{ip}", url.PathEscape(ip))
+	if err := p.getJSON(u, map[string]string{"x-apikey": p.apiKey}, &resp); err != nil {
+		return nil, fmt.Errorf("virustotal: %w", err)
+	}
+	attrs := resp.Data.Attributes
+
+	stats := attrs.LastAnalysisStats
+	flagged := stats["malicious"] + stats["suspicious"]
+	total := flagged + stats["harmless"] + stats["undetected"]
+	if total == 0 {
+		return nil, fmt.Errorf("virustotal: %w", ErrNoData)
+	}
+
+	seen := map[string]bool{}
+	categories := []string{}
+	for _, r := range attrs.LastAnalysisResults {
+		if r.Category != "malicious" && r.Category != "suspicious" {
+			continue
+		}
+		name := strings.ToLower(nonEmpty(r.Result, r.Category))
+		if !seen[name] {
+			seen[name] = true
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+
+	report := &Report{
+		Score:      int(math.Round(100 * float64(flagged) / float64(total))),
+		Categories: categories,
+	}
+	if flagged > 0 && attrs.LastAnalysisDate > 0 {
+		t := time.Unix(attrs.LastAnalysisDate, 0).UTC()
+		report.LastReported = &t
+	}
+	return report, nil
+}
+
+// nonEmpty returns the default value if the input is an empty string.
+func nonEmpty(input, defaultValue string) string {
+	if input == "" {
+		return defaultValue
+	}
+	return input
+}